@@ -696,6 +696,26 @@ func (ctrl InventoryController) AccessList(c *gin.Context) {
 					}
 					allaccess[v.GranteeID].DirectAccess = append(allaccess[v.GranteeID].DirectAccess, access)
 				}
+			// if an inventory ad hoc
+			case rbac.InventoryAdHoc:
+				{
+					access := gin.H{
+						"descendant_roles": []string{
+							"adhoc",
+							"read",
+						},
+						"role": gin.H{
+							"resource_name": inventory.Name,
+							"description":   "Can run ad hoc commands against the inventory",
+							"related": gin.H{
+								"inventory": "/v1/inventories/" + inventory.ID.Hex() + "/",
+							},
+							"resource_type": "inventory",
+							"name":          rbac.InventoryAdHoc,
+						},
+					}
+					allaccess[v.GranteeID].DirectAccess = append(allaccess[v.GranteeID].DirectAccess, access)
+				}
 			}
 		}
 
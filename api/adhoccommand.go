@@ -0,0 +1,473 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/api/metadata"
+	"github.com/pearsonappeng/tensor/db"
+	execansible "github.com/pearsonappeng/tensor/exec/ansible"
+	"github.com/pearsonappeng/tensor/log/activity"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/rbac"
+	"github.com/pearsonappeng/tensor/util"
+	"github.com/pearsonappeng/tensor/validate"
+	"gopkg.in/gin-gonic/gin.v1/binding"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Keys for ad hoc command related items stored in the Gin Context
+const (
+	cAdHocCommand   = "ad_hoc_command"
+	cAdHocCommandID = "ad_hoc_command_id"
+)
+
+type AdHocCommandController struct{}
+
+// Middleware generates a middleware handler function that works inside of a Gin request.
+// This function takes ad_hoc_command_id parameter from Gin Context and fetches the ad hoc command
+// from the database, this set the ad hoc command under key ad_hoc_command in Gin Context.
+// Reading an ad hoc command requires read permissions on its inventory,
+// canceling, relaunching and deleting requires the adhoc role.
+func (ctrl AdHocCommandController) Middleware(c *gin.Context) {
+	objectID := c.Params.ByName(cAdHocCommandID)
+	user := c.MustGet(cUser).(common.User)
+
+	if !bson.IsObjectIdHex(objectID) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound, Message: "Ad hoc command does not exist"})
+		return
+	}
+
+	var command ansible.AdHocCommand
+	if err := db.AdHocCommands().FindId(bson.ObjectIdHex(objectID)).One(&command); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound, Message: "Ad hoc command does not exist",
+			Log: logrus.Fields{
+				"Ad Hoc Command ID": objectID,
+				"Error":             err.Error(),
+			},
+		})
+		return
+	}
+
+	roles := new(rbac.Inventory)
+	switch c.Request.Method {
+	case "GET":
+		{
+			if !roles.ReadByID(user, command.InventoryID) {
+				AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+					Message: "You don't have sufficient permissions to perform this action.",
+				})
+				return
+			}
+		}
+	case "POST", "DELETE":
+		{
+			if !roles.AdHocByID(user, command.InventoryID) {
+				AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+					Message: "You don't have sufficient permissions to perform this action.",
+				})
+				return
+			}
+		}
+	}
+
+	c.Set(cAdHocCommand, command)
+	c.Next()
+}
+
+// One is a Gin handler function which returns the ad hoc command as a JSON object
+func (ctrl AdHocCommandController) One(c *gin.Context) {
+	command := c.MustGet(cAdHocCommand).(ansible.AdHocCommand)
+	metadata.AdHocCommandMetadata(&command)
+	c.JSON(http.StatusOK, command)
+}
+
+// All is a Gin handler function which returns list of ad hoc commands
+// This takes lookup parameters and order parameters to filter and sort output data
+func (ctrl AdHocCommandController) All(c *gin.Context) {
+	listAdHocCommands(c, bson.M{})
+}
+
+// Create is a Gin handler function which runs a new ad hoc command using request payload.
+// fields to run an ad hoc command:
+// module_name: Name of the ansible module. string, required
+// module_args: Arguments of the module. string, default=""
+// inventory: bson.ObjectId, required
+// credential: Machine credential. bson.ObjectId, required
+// limit: Host pattern. string, default="all"
+// job_type: choice
+//   - `run: Run default
+//   - `check: Check
+//
+// forks: integer, default=0
+// verbosity: integer between 0 and 5, default=0
+// become_enabled: boolean, default=False
+// extra_vars: object, default={}
+// secret_vars: names of extra_vars whose values are redacted from the output. list, default=[]
+// priority: integer between 0 and 10, default=0
+func (ctrl AdHocCommandController) Create(c *gin.Context) {
+	var req ansible.AdHocCommand
+	if err := binding.JSON.Bind(c.Request, &req); err != nil {
+		AbortWithErrors(c, http.StatusBadRequest,
+			"Invalid JSON body",
+			validate.GetValidationErrors(err)...)
+		return
+	}
+
+	createAdHocCommand(c, req)
+}
+
+// Delete is a Gin handler function which removes an ad hoc command that is not running
+func (ctrl AdHocCommandController) Delete(c *gin.Context) {
+	command := c.MustGet(cAdHocCommand).(ansible.AdHocCommand)
+	user := c.MustGet(cUser).(common.User)
+
+	if isActive(command.Status) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusMethodNotAllowed,
+			Message: "Ad hoc command is running, cancel it before deleting.",
+		})
+		return
+	}
+
+	if err := db.AdHocCommands().RemoveId(command.ID); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while deleting ad hoc command",
+			Log:     logrus.Fields{"Ad Hoc Command ID": command.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	if _, err := db.JobStdout().RemoveAll(bson.M{"job_id": command.ID}); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Ad Hoc Command ID": command.ID.Hex(),
+			"Error":             err.Error(),
+		}).Errorln("Error while deleting ad hoc command stdout")
+	}
+
+	activity.AddActivity(activity.Delete, user.ID, command, nil)
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// CancelInfo to determine if the ad hoc command can be cancelled.
+// The response will include the following field:
+// can_cancel: [boolean] Indicates whether this ad hoc command can be canceled
+func (ctrl AdHocCommandController) CancelInfo(c *gin.Context) {
+	command := c.MustGet(cAdHocCommand).(ansible.AdHocCommand)
+	c.JSON(http.StatusOK, gin.H{"can_cancel": isCancelable(command.Status, command.CancelFlag)})
+}
+
+// Cancel cancels the pending ad hoc command.
+// The response status code will be 202 if successful, or 405 if the
+// ad hoc command cannot be canceled.
+func (ctrl AdHocCommandController) Cancel(c *gin.Context) {
+	command := c.MustGet(cAdHocCommand).(ansible.AdHocCommand)
+
+	if !isCancelable(command.Status, command.CancelFlag) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusMethodNotAllowed,
+			Message: "Ad hoc command cannot be canceled.",
+		})
+		return
+	}
+
+	// only flag commands that are still in a cancelable state, the runner
+	// might have finished the command in the meantime
+	query := bson.M{
+		"_id":    command.ID,
+		"status": bson.M{"$in": common.ActiveJobStatus},
+	}
+	if err := db.AdHocCommands().Update(query, bson.M{"$set": bson.M{"cancel_flag": true}}); err != nil {
+		if err == mgo.ErrNotFound {
+			AbortWithError(LogFields{Context: c, Status: http.StatusMethodNotAllowed,
+				Message: "Ad hoc command cannot be canceled.",
+			})
+			return
+		}
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while canceling ad hoc command",
+			Log:     logrus.Fields{"Ad Hoc Command ID": command.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	c.AbortWithStatus(http.StatusAccepted)
+}
+
+// StdOut returns ANSI standard output of an ad hoc command
+// while the job is running the output persisted so far is returned.
+// The `start_line` and `end_line` query parameters select a range of lines and
+// the `format` query parameter selects json (default), txt, ansi or html output.
+func (ctrl AdHocCommandController) StdOut(c *gin.Context) {
+	command := c.MustGet(cAdHocCommand).(ansible.AdHocCommand)
+
+	start, end, ok := stdoutLines(c)
+	if !ok {
+		return
+	}
+
+	stdout, err := jobStdout(command.ID, command.ResultStdout, start, end)
+	if err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting ad hoc command stdout",
+			Log:     logrus.Fields{"Ad Hoc Command ID": command.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	writeStdout(c, "ad_hoc_command_"+command.ID.Hex(), stdout)
+}
+
+// StdOutStream streams the standard output of an ad hoc command as
+// Server-Sent Events and follows the output until the command is finished
+func (ctrl AdHocCommandController) StdOutStream(c *gin.Context) {
+	command := c.MustGet(cAdHocCommand).(ansible.AdHocCommand)
+	streamStdout(c, command.ID, db.AdHocCommands())
+}
+
+// RelaunchInfo determines if the ad hoc command can be relaunched.
+// The response will include the following fields:
+// can_relaunch: [boolean] Indicates whether this ad hoc command can be relaunched
+// inventory_needed_to_start: [boolean] The inventory no longer exists
+// credential_needed_to_start: [boolean] The credential no longer exists
+func (ctrl AdHocCommandController) RelaunchInfo(c *gin.Context) {
+	command := c.MustGet(cAdHocCommand).(ansible.AdHocCommand)
+
+	inventoryNeeded, credentialNeeded := adHocRelaunchCheck(command)
+	c.JSON(http.StatusOK, gin.H{
+		"can_relaunch":               !inventoryNeeded && !credentialNeeded,
+		"passwords_needed_to_start":  []gin.H{},
+		"inventory_needed_to_start":  inventoryNeeded,
+		"credential_needed_to_start": credentialNeeded,
+	})
+}
+
+// Relaunch runs a new ad hoc command with the module, arguments,
+// inventory and credential of the ad hoc command
+func (ctrl AdHocCommandController) Relaunch(c *gin.Context) {
+	command := c.MustGet(cAdHocCommand).(ansible.AdHocCommand)
+	user := c.MustGet(cUser).(common.User)
+
+	inventoryNeeded, credentialNeeded := adHocRelaunchCheck(command)
+	if inventoryNeeded {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Inventory does not exists.",
+		})
+		return
+	}
+	if credentialNeeded {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Machine Credential does not exists.",
+		})
+		return
+	}
+
+	if !new(rbac.Credential).ReadByID(user, command.CredentialID) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+			Message: "You don't have sufficient permissions to perform this action.",
+		})
+		return
+	}
+
+	relaunch := newAdHocCommand(command, user)
+	relaunch.LaunchType = ansible.JOB_LAUNCH_TYPE_RELAUNCH
+	if err := execansible.LaunchAdHocCommand(relaunch, user); err != nil {
+		AbortWithError(LogFields{Context: c, Status: launchStatus(err),
+			Message: err.Error(),
+		})
+		return
+	}
+
+	activity.AddActivity(activity.Create, user.ID, relaunch, nil)
+	metadata.AdHocCommandMetadata(&relaunch)
+	c.JSON(http.StatusCreated, relaunch)
+}
+
+// AdHocCommands is a Gin handler function which returns the ad hoc commands of an inventory
+func (ctrl InventoryController) AdHocCommands(c *gin.Context) {
+	inventory := c.MustGet(cInventory).(ansible.Inventory)
+	listAdHocCommands(c, bson.M{"inventory_id": inventory.ID})
+}
+
+// CreateAdHocCommand is a Gin handler function which runs a new ad hoc command
+// against the inventory, it accepts the same fields as AdHocCommandController.Create
+func (ctrl InventoryController) CreateAdHocCommand(c *gin.Context) {
+	inventory := c.MustGet(cInventory).(ansible.Inventory)
+
+	var req ansible.AdHocCommand
+	if err := binding.JSON.Bind(c.Request, &req); err != nil {
+		AbortWithErrors(c, http.StatusBadRequest,
+			"Invalid JSON body",
+			validate.GetValidationErrors(err)...)
+		return
+	}
+
+	req.InventoryID = inventory.ID
+	createAdHocCommand(c, req)
+}
+
+// createAdHocCommand checks the inventory and the credential of the
+// ad hoc command and publishes it to the ansible queue
+func createAdHocCommand(c *gin.Context, req ansible.AdHocCommand) {
+	user := c.MustGet(cUser).(common.User)
+
+	if len(req.InventoryID) != 12 {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Inventory required.",
+		})
+		return
+	}
+
+	var inventory ansible.Inventory
+	if err := db.Inventories().FindId(req.InventoryID).One(&inventory); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Inventory does not exists.",
+		})
+		return
+	}
+
+	// running ad hoc commands is granted separately from job templates
+	if !new(rbac.Inventory).AdHoc(user, inventory) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+			Message: "You don't have sufficient permissions to perform this action.",
+		})
+		return
+	}
+
+	if n, err := db.Credentials().FindId(req.CredentialID).Count(); err != nil || n == 0 {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Machine Credential does not exists.",
+		})
+		return
+	}
+
+	if !new(rbac.Credential).ReadByID(user, req.CredentialID) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+			Message: "You don't have sufficient permissions to perform this action.",
+		})
+		return
+	}
+
+	command := newAdHocCommand(req, user)
+	if err := execansible.LaunchAdHocCommand(command, user); err != nil {
+		AbortWithError(LogFields{Context: c, Status: launchStatus(err),
+			Message: err.Error(),
+		})
+		return
+	}
+
+	activity.AddActivity(activity.Create, user.ID, command, nil)
+	metadata.AdHocCommandMetadata(&command)
+	c.JSON(http.StatusCreated, command)
+}
+
+// newAdHocCommand returns a new ad hoc command with the parameters of
+// the given command and a fresh execution state
+func newAdHocCommand(req ansible.AdHocCommand, user common.User) ansible.AdHocCommand {
+	name := req.ModuleName
+	if len(req.ModuleArgs) > 0 {
+		name += " " + req.ModuleArgs
+	}
+
+	jobType := req.JobType
+	if len(jobType) == 0 {
+		jobType = "run"
+	}
+
+	return ansible.AdHocCommand{
+		ID:            bson.NewObjectId(),
+		Name:          name,
+		ModuleName:    req.ModuleName,
+		ModuleArgs:    req.ModuleArgs,
+		Limit:         req.Limit,
+		JobType:       jobType,
+		Forks:         req.Forks,
+		Verbosity:     req.Verbosity,
+		BecomeEnabled: req.BecomeEnabled,
+		CredentialID:  req.CredentialID,
+		InventoryID:   req.InventoryID,
+		ExtraVars:     req.ExtraVars,
+		Priority:      req.Priority,
+		SecretVars:    req.SecretVars,
+		LaunchType:    ansible.JOB_LAUNCH_TYPE_MANUAL,
+		Status:        "new",
+		CreatedByID:   user.ID,
+		ModifiedByID:  user.ID,
+		Created:       time.Now(),
+		Modified:      time.Now(),
+	}
+}
+
+// adHocRelaunchCheck reports whether the inventory and the machine
+// credential of an ad hoc command no longer exist
+func adHocRelaunchCheck(command ansible.AdHocCommand) (inventoryNeeded bool, credentialNeeded bool) {
+	if n, err := db.Inventories().FindId(command.InventoryID).Count(); err != nil || n == 0 {
+		inventoryNeeded = true
+	}
+
+	if n, err := db.Credentials().FindId(command.CredentialID).Count(); err != nil || n == 0 {
+		credentialNeeded = true
+	}
+
+	return
+}
+
+// listAdHocCommands writes the ad hoc commands matching the filter that
+// the user can read, the query parameters are applied to the filter
+func listAdHocCommands(c *gin.Context, filter bson.M) {
+	user := c.MustGet(cUser).(common.User)
+
+	parser := util.NewQueryParser(c)
+	match := parser.Match([]string{"status", "failed", "module_name", "launch_type"}, filter)
+	match = parser.Lookups([]string{"name", "limit"}, match)
+	query := db.AdHocCommands().Find(match).Select(bson.M{"result_stdout": 0})
+	if order := parser.OrderBy(); order != "" {
+		query.Sort(order)
+	}
+
+	roles := new(rbac.Inventory)
+	// permissions are checked once per inventory
+	readable := map[bson.ObjectId]bool{}
+
+	var commands []ansible.AdHocCommand
+	iter := query.Iter()
+	var tmpCommand ansible.AdHocCommand
+	for iter.Next(&tmpCommand) {
+		read, ok := readable[tmpCommand.InventoryID]
+		if !ok {
+			read = roles.ReadByID(user, tmpCommand.InventoryID)
+			readable[tmpCommand.InventoryID] = read
+		}
+		if !read {
+			continue
+		}
+		metadata.AdHocCommandMetadata(&tmpCommand)
+		commands = append(commands, tmpCommand)
+	}
+	if err := iter.Close(); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting ad hoc commands",
+			Log:     logrus.Fields{"Error": err.Error()},
+		})
+		return
+	}
+
+	count := len(commands)
+	pgi := util.NewPagination(c, count)
+	if pgi.HasPage() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound,
+			Message: "#" + strconv.Itoa(pgi.Page()) + " page contains no results.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		Count:    count,
+		Next:     pgi.NextPage(),
+		Previous: pgi.PreviousPage(),
+		Data:     commands[pgi.Skip():pgi.End()],
+	})
+}
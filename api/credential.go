@@ -185,6 +185,7 @@ func (ctrl CredentialController) Create(c *gin.Context) {
 	req.VaultPassword = util.Cipher(req.VaultPassword)
 	req.AuthorizePassword = util.Cipher(req.AuthorizePassword)
 	req.Secret = util.Cipher(req.Secret)
+	req.SecurityToken = util.Cipher(req.SecurityToken)
 	req.CreatedByID = user.ID
 	req.ModifiedByID = user.ID
 	req.Created = time.Now()
@@ -259,15 +260,17 @@ func (ctrl CredentialController) Update(c *gin.Context) {
 	credential.Cloud = req.Cloud
 	credential.Host = req.Host
 	credential.Username = req.Username
-	credential.SecurityToken = req.SecurityToken
 	credential.Project = req.Project
 	credential.Domain = req.Domain
+	credential.WinRMTransport = req.WinRMTransport
 	credential.BecomeMethod = req.BecomeMethod
 	credential.BecomeUsername = req.BecomeUsername
 	credential.Subscription = req.Subscription
 	credential.Tenant = req.Tenant
 	credential.Client = req.Client
 	credential.Authorize = req.Authorize
+	credential.SSLVerifyDisabled = req.SSLVerifyDisabled
+	credential.VaultID = req.VaultID
 	credential.OrganizationID = req.OrganizationID
 	credential.ModifiedByID = user.ID
 	credential.Modified = time.Now()
@@ -293,6 +296,9 @@ func (ctrl CredentialController) Update(c *gin.Context) {
 	if req.Secret != "$encrypted$" {
 		credential.Secret = util.Cipher(req.Secret)
 	}
+	if req.SecurityToken != "$encrypted$" {
+		credential.SecurityToken = util.Cipher(req.SecurityToken)
+	}
 
 	if err := db.Credentials().UpdateId(credential.ID, credential); err != nil {
 		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
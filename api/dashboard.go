@@ -1,38 +1,56 @@
 package api
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// inventorySourceLabels are the dashboard labels of the inventory sources
+var inventorySourceLabels = []struct {
+	Source string
+	Label  string
+}{
+	{ansible.INVENTORY_SOURCE_EC2, "Amazon EC2"},
+	{ansible.INVENTORY_SOURCE_GCE, "Google Compute Engine"},
+	{ansible.INVENTORY_SOURCE_AZURE_RM, "Microsoft Azure Resource Manager"},
+	{ansible.INVENTORY_SOURCE_OPENSTACK, "OpenStack"},
+	{ansible.INVENTORY_SOURCE_VMWARE, "VMware vCenter"},
+	{ansible.INVENTORY_SOURCE_RAX, "Rackspace"},
+	{ansible.INVENTORY_SOURCE_SATELLITE6, "Red Hat Satellite 6"},
+	{ansible.INVENTORY_SOURCE_CLOUDFORMS, "Red Hat CloudForms"},
+}
 
 type DashBoardController struct{}
 
 // GetInfo is a Gin handler function which returns summary data for UI dashboard
 func (ctrl DashBoardController) GetInfo(c *gin.Context) {
+	sources := gin.H{}
+	for _, v := range inventorySourceLabels {
+		sources[v.Source] = gin.H{
+			"url":          "/v1/inventory_sources/?source=" + v.Source,
+			"total":        countDocuments(db.InventorySources(), bson.M{"source": v.Source}),
+			"failures_url": "/v1/inventory_sources/?source=" + v.Source + "&status=failed",
+			"failed":       countDocuments(db.InventorySources(), bson.M{"source": v.Source, "status": "failed"}),
+			"label":        v.Label,
+		}
+	}
+
 	info := gin.H{
 		"related": gin.H{
 			"jobs_graph": "/v1/dashboard/graphs/jobs/",
 		},
 		"inventories": gin.H{
 			"url":                         "/v1/inventories/",
-			"job_failed":                  0,
-			"total":                       1,
-			"inventory_failed":            0,
-			"total_with_inventory_source": 0,
-		},
-		"inventory_sources": gin.H{
-			"ec2": gin.H{
-				"url":          "/v1/inventory_sources/?source=ec2",
-				"total":        0,
-				"failures_url": "/v1/inventory_sources/?source=ec2&status=failed",
-				"failed":       0,
-				"label":        "Amazon EC2",
-			},
-			"rax": gin.H{
-				"url":          "/v1/inventory_sources/?source=rax",
-				"total":        0,
-				"failures_url": "/v1/inventory_sources/?source=rax&status=failed",
-				"failed":       0,
-				"label":        "Rackspace",
-			},
+			"job_failed":                  countDocuments(db.Inventories(), bson.M{"has_active_failures": true}),
+			"total":                       countDocuments(db.Inventories(), nil),
+			"inventory_failed":            countDocuments(db.Inventories(), bson.M{"inventory_sources_with_failures": bson.M{"$gt": 0}}),
+			"total_with_inventory_source": countDocuments(db.Inventories(), bson.M{"has_inventory_sources": true}),
 		},
+		"inventory_sources": sources,
 		"groups": gin.H{
 			"url":              "/v1/groups/",
 			"total":            1,
@@ -105,3 +123,17 @@ func (ctrl DashBoardController) GetInfo(c *gin.Context) {
 
 	c.JSON(201, info)
 }
+
+// countDocuments returns the number of documents in the collection that match the query,
+// errors are logged and counted as zero
+func countDocuments(c *mgo.Collection, query interface{}) int {
+	n, err := c.Find(query).Count()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Collection": c.Name,
+			"Error":      err.Error(),
+		}).Errorln("Error while counting documents")
+		return 0
+	}
+	return n
+}
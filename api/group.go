@@ -309,6 +309,16 @@ func (ctrl GroupController) Delete(c *gin.Context) {
 		return
 	}
 
+	// hosts imported by an inventory source can be members of the removed groups
+	if _, err := db.Hosts().UpdateAll(bson.M{"group_ids": bson.M{"$in": groupIDs}},
+		bson.M{"$pull": bson.M{"group_ids": bson.M{"$in": groupIDs}}}); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while removing groups",
+			Log:     logrus.Fields{"Group ID": group.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
 	if _, err := db.Groups().RemoveAll(bson.M{"_id": bson.M{"$in": groupIDs}}); err != nil {
 		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
 			Message: "Error while removing groups",
@@ -19,6 +19,7 @@ import (
 	"github.com/pearsonappeng/tensor/util"
 	"github.com/pearsonappeng/tensor/validate"
 	"gopkg.in/gin-gonic/gin.v1/binding"
+	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -183,6 +184,19 @@ func (ctrl HostController) Create(c *gin.Context) {
 		return
 	}
 
+	var inventory ansible.Inventory
+	if err := db.Inventories().FindId(req.InventoryID).One(&inventory); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting inventory",
+			Log:     logrus.Fields{"Inventory ID": req.InventoryID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	if !checkHostQuota(c, inventory.OrganizationID, 1) {
+		return
+	}
+
 	req.ID = bson.NewObjectId()
 	req.Created = time.Now()
 	req.Modified = time.Now()
@@ -239,6 +253,28 @@ func (ctrl HostController) Update(c *gin.Context) {
 		return
 	}
 
+	// a host moved to an inventory of another organization counts against its quota
+	if req.InventoryID != host.InventoryID {
+		var current, inventory ansible.Inventory
+		if err := db.Inventories().FindId(host.InventoryID).One(&current); err != nil && err != mgo.ErrNotFound {
+			AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+				Message: "Error while getting inventory",
+				Log:     logrus.Fields{"Inventory ID": host.InventoryID.Hex(), "Error": err.Error()},
+			})
+			return
+		}
+		if err := db.Inventories().FindId(req.InventoryID).One(&inventory); err != nil {
+			AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+				Message: "Error while getting inventory",
+				Log:     logrus.Fields{"Inventory ID": req.InventoryID.Hex(), "Error": err.Error()},
+			})
+			return
+		}
+		if inventory.OrganizationID != current.OrganizationID && !checkHostQuota(c, inventory.OrganizationID, 1) {
+			return
+		}
+	}
+
 	host.Name = strings.Trim(req.Name, " ")
 	host.InventoryID = req.InventoryID
 	host.Description = strings.Trim(req.Description, " ")
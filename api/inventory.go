@@ -235,6 +235,21 @@ func (ctrl InventoryController) Update(c *gin.Context) {
 		}
 	}
 
+	// the hosts of an inventory moved to another organization count against its quota
+	if req.OrganizationID != inventory.OrganizationID {
+		count, err := db.Hosts().Find(bson.M{"inventory_id": inventory.ID}).Count()
+		if err != nil {
+			AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+				Message: "Error while getting hosts",
+				Log:     logrus.Fields{"Inventory ID": inventory.ID.Hex(), "Error": err.Error()},
+			})
+			return
+		}
+		if !checkHostQuota(c, req.OrganizationID, count) {
+			return
+		}
+	}
+
 	inventory.Name = strings.Trim(req.Name, " ")
 	inventory.Description = strings.Trim(req.Description, " ")
 	inventory.OrganizationID = req.OrganizationID
@@ -282,6 +297,14 @@ func (ctrl InventoryController) Delete(c *gin.Context) {
 		return
 	}
 
+	if _, err := db.InventorySources().RemoveAll(bson.M{"inventory_id": inventory.ID}); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while removing inventory sources",
+			Log:     logrus.Fields{"Inventory ID": inventory.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
 	if err := db.Inventories().RemoveId(inventory.ID); err != nil {
 		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
 			Message: "Error while removing inventory",
@@ -339,7 +362,8 @@ func (ctrl InventoryController) Script(c *gin.Context) {
 	allhosts := []ansible.Host{}
 	for _, v := range parents {
 		var hosts []ansible.Host
-		q := bson.M{"inventory_id": inv.ID, "group_id": v.ID}
+		// hosts imported by an inventory source can be a member of more groups
+		q := bson.M{"inventory_id": inv.ID, "$or": []bson.M{{"group_id": v.ID}, {"group_ids": v.ID}}}
 		if err := db.Hosts().Find(q).All(&hosts); err != nil {
 			AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
 				Message: "Error while getting hosts",
@@ -0,0 +1,305 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/api/metadata"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/log/activity"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/rbac"
+	"github.com/pearsonappeng/tensor/util"
+	"github.com/pearsonappeng/tensor/validate"
+	"gopkg.in/gin-gonic/gin.v1/binding"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Keys for inventory script related items stored in the Gin Context
+const (
+	cInventoryScript   = "inventory_script"
+	cInventoryScriptID = "inventory_script_id"
+)
+
+type InventoryScriptController struct{}
+
+// Middleware generates a middleware handler function that works inside of a Gin request.
+// This function takes cInventoryScriptID from Gin Context and retrieves inventory script data
+// from the collection and store inventory script data under key cInventoryScript in Gin Context
+func (ctrl InventoryScriptController) Middleware(c *gin.Context) {
+	objectID := c.Params.ByName(cInventoryScriptID)
+	user := c.MustGet(cUser).(common.User)
+
+	if !bson.IsObjectIdHex(objectID) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound, Message: "Inventory Script does not exist"})
+		return
+	}
+
+	var script ansible.InventoryScript
+	if err := db.InventoryScripts().FindId(bson.ObjectIdHex(objectID)).One(&script); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound, Message: "Inventory Script does not exist",
+			Log: logrus.Fields{
+				"Inventory Script ID": objectID,
+				"Error":               err.Error(),
+			},
+		})
+		return
+	}
+
+	roles := new(rbac.InventoryScript)
+	switch c.Request.Method {
+	case "GET":
+		{
+			if !roles.Read(user, script) {
+				AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+					Message: "You don't have sufficient permissions to perform this action.",
+				})
+				return
+			}
+		}
+	case "POST", "PUT", "DELETE":
+		{
+			// Reject the request if the user doesn't have write permissions
+			if !roles.Write(user, script) {
+				AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+					Message: "You don't have sufficient permissions to perform this action.",
+				})
+				return
+			}
+		}
+	}
+
+	c.Set(cInventoryScript, script)
+	c.Next()
+}
+
+// One returns the inventory script as a JSON object
+func (ctrl InventoryScriptController) One(c *gin.Context) {
+	script := c.MustGet(cInventoryScript).(ansible.InventoryScript)
+	metadata.InventoryScriptMetadata(&script)
+	c.JSON(http.StatusOK, script)
+}
+
+// All returns a JSON array of the inventory scripts the user can read
+func (ctrl InventoryScriptController) All(c *gin.Context) {
+	user := c.MustGet(cUser).(common.User)
+	roles := new(rbac.InventoryScript)
+	listInventoryScripts(c, bson.M{}, func(script ansible.InventoryScript) bool {
+		return roles.Read(user, script)
+	})
+}
+
+// Create is a Gin handler function which creates a new inventory script using request payload.
+func (ctrl InventoryScriptController) Create(c *gin.Context) {
+	user := c.MustGet(cUser).(common.User)
+
+	var req ansible.InventoryScript
+	if err := binding.JSON.Bind(c.Request, &req); err != nil {
+		AbortWithErrors(c, http.StatusBadRequest,
+			"Invalid JSON body",
+			validate.GetValidationErrors(err)...)
+		return
+	}
+
+	if !req.OrganizationExist() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Organization does not exists.",
+		})
+		return
+	}
+
+	if !new(rbac.InventoryScript).Write(user, req) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+			Message: "You don't have sufficient permissions to perform this action.",
+		})
+		return
+	}
+
+	req.Name = strings.Trim(req.Name, " ")
+	if !req.IsUnique() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Inventory Script with this name and organization already exists.",
+		})
+		return
+	}
+
+	if !strings.HasPrefix(req.Script, "#!") {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Script must begin with a hashbang sequence: i.e.... #!/usr/bin/env python",
+		})
+		return
+	}
+
+	req.ID = bson.NewObjectId()
+	req.Description = strings.Trim(req.Description, " ")
+	req.CreatedByID = user.ID
+	req.ModifiedByID = user.ID
+	req.Created = time.Now()
+	req.Modified = time.Now()
+
+	if err := db.InventoryScripts().Insert(req); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while creating inventory script",
+			Log:     logrus.Fields{"Inventory Script ID": req.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	activity.AddActivity(activity.Create, user.ID, req, nil)
+	metadata.InventoryScriptMetadata(&req)
+	c.JSON(http.StatusCreated, req)
+}
+
+// Update is a Gin handler function which updates an inventory script using request payload.
+func (ctrl InventoryScriptController) Update(c *gin.Context) {
+	script := c.MustGet(cInventoryScript).(ansible.InventoryScript)
+	tmpScript := script
+	user := c.MustGet(cUser).(common.User)
+
+	var req ansible.InventoryScript
+	if err := binding.JSON.Bind(c.Request, &req); err != nil {
+		AbortWithErrors(c, http.StatusBadRequest,
+			"Invalid JSON body",
+			validate.GetValidationErrors(err)...)
+		return
+	}
+
+	// inventory sources of other organizations must not use the script
+	if req.OrganizationID != script.OrganizationID {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Organization of an Inventory Script cannot be changed.",
+		})
+		return
+	}
+
+	req.Name = strings.Trim(req.Name, " ")
+	if req.Name != script.Name && !req.IsUnique() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Inventory Script with this name and organization already exists.",
+		})
+		return
+	}
+
+	if !strings.HasPrefix(req.Script, "#!") {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Script must begin with a hashbang sequence: i.e.... #!/usr/bin/env python",
+		})
+		return
+	}
+
+	script.Name = req.Name
+	script.Description = strings.Trim(req.Description, " ")
+	script.Script = req.Script
+	script.ModifiedByID = user.ID
+	script.Modified = time.Now()
+
+	if err := db.InventoryScripts().UpdateId(script.ID, script); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while updating inventory script",
+			Log:     logrus.Fields{"Inventory Script ID": script.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	activity.AddActivity(activity.Update, user.ID, tmpScript, script)
+	metadata.InventoryScriptMetadata(&script)
+	c.JSON(http.StatusOK, script)
+}
+
+// Delete is a Gin handler function which removes an inventory script,
+// scripts used by inventory sources cannot be removed
+func (ctrl InventoryScriptController) Delete(c *gin.Context) {
+	script := c.MustGet(cInventoryScript).(ansible.InventoryScript)
+	user := c.MustGet(cUser).(common.User)
+
+	count, err := db.InventorySources().Find(bson.M{"source_script_id": script.ID}).Count()
+	if err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while removing inventory script",
+			Log:     logrus.Fields{"Inventory Script ID": script.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+	if count > 0 {
+		AbortWithError(LogFields{Context: c, Status: http.StatusConflict,
+			Message: "Inventory Script is used by " + strconv.Itoa(count) + " inventory sources",
+		})
+		return
+	}
+
+	if err := db.InventoryScripts().RemoveId(script.ID); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while removing inventory script",
+			Log:     logrus.Fields{"Inventory Script ID": script.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	activity.AddActivity(activity.Delete, user.ID, script, nil)
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// InventorySources is a Gin handler function which returns the
+// inventory sources that use the inventory script
+func (ctrl InventoryScriptController) InventorySources(c *gin.Context) {
+	script := c.MustGet(cInventoryScript).(ansible.InventoryScript)
+	listInventorySources(c, bson.M{"source_script_id": script.ID})
+}
+
+// InventoryScripts is a Gin handler function which returns the
+// inventory scripts that belong to the organization
+func (ctrl OrganizationController) InventoryScripts(c *gin.Context) {
+	organization := c.MustGet(cOrganization).(common.Organization)
+	listInventoryScripts(c, bson.M{"organization_id": organization.ID}, nil)
+}
+
+// listInventoryScripts writes the inventory scripts that match the query and
+// pass the filter as a paginated response, a nil filter passes all scripts
+func listInventoryScripts(c *gin.Context, query bson.M, filter func(ansible.InventoryScript) bool) {
+	parser := util.NewQueryParser(c)
+	query = parser.Lookups([]string{"name", "description"}, query)
+
+	dbq := db.InventoryScripts().Find(query)
+	if order := parser.OrderBy(); order != "" {
+		dbq.Sort(order)
+	}
+
+	var scripts []ansible.InventoryScript
+	iter := dbq.Iter()
+	var tmpScript ansible.InventoryScript
+	for iter.Next(&tmpScript) {
+		if filter != nil && !filter(tmpScript) {
+			continue
+		}
+		metadata.InventoryScriptMetadata(&tmpScript)
+		scripts = append(scripts, tmpScript)
+	}
+	if err := iter.Close(); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting inventory scripts",
+			Log:     logrus.Fields{"Error": err.Error()},
+		})
+		return
+	}
+
+	count := len(scripts)
+	pgi := util.NewPagination(c, count)
+	if pgi.HasPage() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound,
+			Message: "#" + strconv.Itoa(pgi.Page()) + " page contains no results.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		Count:    count,
+		Next:     pgi.NextPage(),
+		Previous: pgi.PreviousPage(),
+		Data:     scripts[pgi.Skip():pgi.End()],
+	})
+}
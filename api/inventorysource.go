@@ -0,0 +1,603 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/api/metadata"
+	"github.com/pearsonappeng/tensor/db"
+	execinventory "github.com/pearsonappeng/tensor/exec/inventory"
+	"github.com/pearsonappeng/tensor/log/activity"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/rbac"
+	"github.com/pearsonappeng/tensor/util"
+	"github.com/pearsonappeng/tensor/validate"
+	"gopkg.in/gin-gonic/gin.v1/binding"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Keys for inventory source related items stored in the Gin Context
+const (
+	cInventorySource   = "inventory_source"
+	cInventorySourceID = "inventory_source_id"
+)
+
+type InventorySourceController struct{}
+
+// Middleware generates a middleware handler function that works inside of a Gin request.
+// Middleware takes cInventorySourceID parameter from the Gin Context and fetches inventory source data from the database
+// it set inventory source data under key cInventorySource in the Gin Context
+func (ctrl InventorySourceController) Middleware(c *gin.Context) {
+	objectID := c.Params.ByName(cInventorySourceID)
+	user := c.MustGet(cUser).(common.User)
+
+	if !bson.IsObjectIdHex(objectID) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound, Message: "Inventory Source does not exist"})
+		return
+	}
+
+	var source ansible.InventorySource
+	if err := db.InventorySources().FindId(bson.ObjectIdHex(objectID)).One(&source); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound, Message: "Inventory Source does not exist",
+			Log: logrus.Fields{
+				"Inventory Source ID": objectID,
+				"Error":               err.Error(),
+			},
+		})
+		return
+	}
+
+	roles := new(rbac.Inventory)
+	switch c.Request.Method {
+	case "GET":
+		{
+			if !roles.ReadByID(user, source.InventoryID) {
+				AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+					Message: "You don't have sufficient permissions to perform this action.",
+				})
+				return
+			}
+		}
+	case "PUT", "POST", "DELETE":
+		{
+			// Reject the request if the user doesn't have inventory write permissions
+			if !roles.WriteByID(user, source.InventoryID) {
+				AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+					Message: "You don't have sufficient permissions to perform this action.",
+				})
+				return
+			}
+		}
+	}
+
+	c.Set(cInventorySource, source)
+	c.Next()
+}
+
+// One is a Gin Handler function, returns the inventory source as a JSON object
+func (ctrl InventorySourceController) One(c *gin.Context) {
+	source := c.MustGet(cInventorySource).(ansible.InventorySource)
+	metadata.InventorySourceMetadata(&source)
+	c.JSON(http.StatusOK, source)
+}
+
+// All is Gin handler function which returns list of inventory sources
+// This takes lookup parameters and order parameters to filter and sort output data
+func (ctrl InventorySourceController) All(c *gin.Context) {
+	listInventorySources(c, bson.M{})
+}
+
+// Create is a Gin handler function which creates a new inventory source using request payload
+// This accepts InventorySource model.
+func (ctrl InventorySourceController) Create(c *gin.Context) {
+	var req ansible.InventorySource
+	user := c.MustGet(cUser).(common.User)
+
+	if err := binding.JSON.Bind(c.Request, &req); err != nil {
+		AbortWithErrors(c, http.StatusBadRequest,
+			"Invalid JSON body",
+			validate.GetValidationErrors(err)...)
+		return
+	}
+
+	// check whether the inventory exist or not
+	if !req.InventoryExist() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Inventory does not exists.",
+		})
+		return
+	}
+
+	// Reject the request if the user doesn't have inventory write permissions
+	if !new(rbac.Inventory).WriteByID(user, req.InventoryID) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+			Message: "You don't have sufficient permissions to perform this action.",
+		})
+		return
+	}
+
+	if !req.IsUnique() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Inventory Source with this name and inventory already exists.",
+		})
+		return
+	}
+
+	if !validateInventorySource(c, user, req) {
+		return
+	}
+
+	req.ID = bson.NewObjectId()
+	req.Name = strings.Trim(req.Name, " ")
+	req.Status = "never updated"
+	req.Created = time.Now()
+	req.Modified = time.Now()
+	req.CreatedByID = user.ID
+	req.ModifiedByID = user.ID
+	if err := db.InventorySources().Insert(req); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while creating inventory source",
+			Log:     logrus.Fields{"Error": err.Error()},
+		})
+		return
+	}
+
+	execinventory.UpdateCounters(req.InventoryID)
+	activity.AddActivity(activity.Create, user.ID, req, nil)
+	metadata.InventorySourceMetadata(&req)
+	c.JSON(http.StatusCreated, req)
+}
+
+// Update is a handler function which updates an inventory source using request payload.
+// The inventory of a source cannot be changed, the status of the last update is kept.
+func (ctrl InventorySourceController) Update(c *gin.Context) {
+	source := c.MustGet(cInventorySource).(ansible.InventorySource)
+	tmpSource := source
+	user := c.MustGet(cUser).(common.User)
+
+	var req ansible.InventorySource
+	if err := binding.JSON.Bind(c.Request, &req); err != nil {
+		AbortWithErrors(c, http.StatusBadRequest,
+			"Invalid JSON body",
+			validate.GetValidationErrors(err)...)
+		return
+	}
+
+	if req.InventoryID != source.InventoryID {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Inventory of an Inventory Source cannot be changed.",
+		})
+		return
+	}
+
+	if req.Name != source.Name && !req.IsUnique() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Inventory Source with this name and inventory already exists.",
+		})
+		return
+	}
+
+	if !validateInventorySource(c, user, req) {
+		return
+	}
+
+	source.Name = strings.Trim(req.Name, " ")
+	source.Description = strings.Trim(req.Description, " ")
+	source.Source = req.Source
+	source.SourcePath = req.SourcePath
+	source.SourceVars = req.SourceVars
+	source.SourceRegions = req.SourceRegions
+	source.InstanceFilters = req.InstanceFilters
+	source.GroupBy = req.GroupBy
+	source.Overwrite = req.Overwrite
+	source.OverwriteVars = req.OverwriteVars
+	source.UpdateOnLaunch = req.UpdateOnLaunch
+	source.UpdateCacheTimeout = req.UpdateCacheTimeout
+	source.CredentialID = req.CredentialID
+	source.GroupID = req.GroupID
+	source.SourceScriptID = req.SourceScriptID
+	source.Modified = time.Now()
+	source.ModifiedByID = user.ID
+
+	if err := db.InventorySources().UpdateId(source.ID, source); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while updating inventory source",
+			Log:     logrus.Fields{"Inventory Source ID": source.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	activity.AddActivity(activity.Update, user.ID, tmpSource, source)
+	metadata.InventorySourceMetadata(&source)
+	c.JSON(http.StatusOK, source)
+}
+
+// Delete is a Gin handler function which removes an inventory source and
+// the hosts and groups imported by it
+func (ctrl InventorySourceController) Delete(c *gin.Context) {
+	source := c.MustGet(cInventorySource).(ansible.InventorySource)
+	user := c.MustGet(cUser).(common.User)
+
+	if source.Status == "pending" || source.Status == "running" {
+		AbortWithError(LogFields{Context: c, Status: http.StatusConflict,
+			Message: "Inventory Source is being updated",
+		})
+		return
+	}
+
+	if _, err := db.Hosts().RemoveAll(bson.M{"inventory_source_id": source.ID}); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while removing inventory source hosts",
+			Log:     logrus.Fields{"Inventory Source ID": source.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	if _, err := db.Groups().RemoveAll(bson.M{"inventory_source_id": source.ID}); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while removing inventory source groups",
+			Log:     logrus.Fields{"Inventory Source ID": source.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	if err := db.InventorySources().RemoveId(source.ID); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while removing inventory source",
+			Log:     logrus.Fields{"Inventory Source ID": source.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	execinventory.UpdateCounters(source.InventoryID)
+	activity.AddActivity(activity.Delete, user.ID, source, nil)
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// UpdateInfo returns whether the inventory source can be updated or not
+func (ctrl InventorySourceController) UpdateInfo(c *gin.Context) {
+	source := c.MustGet(cInventorySource).(ansible.InventorySource)
+	user := c.MustGet(cUser).(common.User)
+
+	canUpdate := new(rbac.Inventory).WriteByID(user, source.InventoryID) &&
+		source.Status != "pending" && source.Status != "running"
+
+	c.JSON(http.StatusOK, gin.H{"can_update": canUpdate})
+}
+
+// LaunchUpdate creates a new inventory update job for the inventory source
+func (ctrl InventorySourceController) LaunchUpdate(c *gin.Context) {
+	source := c.MustGet(cInventorySource).(ansible.InventorySource)
+	user := c.MustGet(cUser).(common.User)
+
+	if source.Status == "pending" || source.Status == "running" {
+		AbortWithError(LogFields{Context: c, Status: http.StatusConflict,
+			Message: "Inventory Source is already being updated",
+		})
+		return
+	}
+
+	update, err := execinventory.UpdateSource(source, user, ansible.JOB_LAUNCH_TYPE_MANUAL)
+	if err != nil {
+		if isQueueError(err) {
+			AbortWithError(LogFields{Context: c, Status: launchStatus(err), Message: err.Error()})
+			return
+		}
+		AbortWithError(LogFields{Context: c, Status: http.StatusMethodNotAllowed,
+			Message: "Inventory Update failed",
+			Log:     logrus.Fields{"Inventory Source ID": source.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"inventory_update": update.Job.ID.Hex()})
+}
+
+// InventoryUpdates is a Gin handler function which returns the update jobs of the inventory source
+func (ctrl InventorySourceController) InventoryUpdates(c *gin.Context) {
+	source := c.MustGet(cInventorySource).(ansible.InventorySource)
+
+	parser := util.NewQueryParser(c)
+	match := bson.M{}
+	match = parser.Match([]string{"status", "failed", "launch_type"}, match)
+	match = parser.Lookups([]string{"id", "name"}, match)
+	match["job_type"] = ansible.JOBTYPE_INVENTORY_UPDATE
+	match["inventory_source_id"] = source.ID
+	query := db.Jobs().Find(match).Select(bson.M{"result_stdout": 0})
+	if order := parser.OrderBy(); order != "" {
+		query.Sort(order)
+	}
+
+	var jobs []ansible.Job
+	if err := query.All(&jobs); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting inventory updates",
+			Log:     logrus.Fields{"Inventory Source ID": source.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	for i := range jobs {
+		metadata.JobMetadata(&jobs[i])
+	}
+
+	count := len(jobs)
+	pgi := util.NewPagination(c, count)
+	if pgi.HasPage() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound,
+			Message: "#" + strconv.Itoa(pgi.Page()) + " page contains no results.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		Count:    count,
+		Next:     pgi.NextPage(),
+		Previous: pgi.PreviousPage(),
+		Data:     jobs[pgi.Skip():pgi.End()],
+	})
+}
+
+// Hosts is a Gin handler function which returns the hosts imported by the inventory source
+func (ctrl InventorySourceController) Hosts(c *gin.Context) {
+	source := c.MustGet(cInventorySource).(ansible.InventorySource)
+
+	parser := util.NewQueryParser(c)
+	match := bson.M{}
+	match = parser.Match([]string{"enabled", "has_active_failures"}, match)
+	match = parser.Lookups([]string{"name", "description"}, match)
+	match["inventory_source_id"] = source.ID
+	query := db.Hosts().Find(match)
+	if order := parser.OrderBy(); order != "" {
+		query.Sort(order)
+	}
+
+	var hosts []ansible.Host
+	if err := query.All(&hosts); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting hosts",
+			Log:     logrus.Fields{"Inventory Source ID": source.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	for i := range hosts {
+		metadata.HostMetadata(&hosts[i])
+	}
+
+	count := len(hosts)
+	pgi := util.NewPagination(c, count)
+	if pgi.HasPage() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound,
+			Message: "#" + strconv.Itoa(pgi.Page()) + " page contains no results.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		Count:    count,
+		Next:     pgi.NextPage(),
+		Previous: pgi.PreviousPage(),
+		Data:     hosts[pgi.Skip():pgi.End()],
+	})
+}
+
+// Groups is a Gin handler function which returns the groups imported by the inventory source
+func (ctrl InventorySourceController) Groups(c *gin.Context) {
+	source := c.MustGet(cInventorySource).(ansible.InventorySource)
+
+	parser := util.NewQueryParser(c)
+	match := bson.M{}
+	match = parser.Match([]string{"has_active_failures"}, match)
+	match = parser.Lookups([]string{"name", "description"}, match)
+	match["inventory_source_id"] = source.ID
+	query := db.Groups().Find(match)
+	if order := parser.OrderBy(); order != "" {
+		query.Sort(order)
+	}
+
+	var groups []ansible.Group
+	if err := query.All(&groups); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting groups",
+			Log:     logrus.Fields{"Inventory Source ID": source.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	for i := range groups {
+		metadata.GroupMetadata(&groups[i])
+	}
+
+	count := len(groups)
+	pgi := util.NewPagination(c, count)
+	if pgi.HasPage() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound,
+			Message: "#" + strconv.Itoa(pgi.Page()) + " page contains no results.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		Count:    count,
+		Next:     pgi.NextPage(),
+		Previous: pgi.PreviousPage(),
+		Data:     groups[pgi.Skip():pgi.End()],
+	})
+}
+
+// InventorySources is a Gin handler function which returns the inventory sources of the inventory
+func (ctrl InventoryController) InventorySources(c *gin.Context) {
+	inventory := c.MustGet(cInventory).(ansible.Inventory)
+	listInventorySources(c, bson.M{"inventory_id": inventory.ID})
+}
+
+// InventorySources is a Gin handler function which returns the inventory source
+// that imported the host
+func (ctrl HostController) InventorySources(c *gin.Context) {
+	host := c.MustGet(cHost).(ansible.Host)
+	if host.InventorySourceID == nil {
+		listInventorySources(c, bson.M{"_id": nil})
+		return
+	}
+	listInventorySources(c, bson.M{"_id": *host.InventorySourceID})
+}
+
+// listInventorySources writes the inventory sources that match the query
+// and the user can read as a paginated response
+func listInventorySources(c *gin.Context, match bson.M) {
+	user := c.MustGet(cUser).(common.User)
+
+	parser := util.NewQueryParser(c)
+	match = parser.Match([]string{"source", "status", "last_update_failed", "update_on_launch"}, match)
+	match = parser.Lookups([]string{"name", "description"}, match)
+	query := db.InventorySources().Find(match)
+	if order := parser.OrderBy(); order != "" {
+		query.Sort(order)
+	}
+
+	roles := new(rbac.Inventory)
+	readable := map[bson.ObjectId]bool{}
+	var sources []ansible.InventorySource
+	iter := query.Iter()
+	var tmpSource ansible.InventorySource
+	for iter.Next(&tmpSource) {
+		canRead, ok := readable[tmpSource.InventoryID]
+		if !ok {
+			canRead = roles.ReadByID(user, tmpSource.InventoryID)
+			readable[tmpSource.InventoryID] = canRead
+		}
+		if !canRead {
+			continue
+		}
+
+		metadata.InventorySourceMetadata(&tmpSource)
+		sources = append(sources, tmpSource)
+	}
+	if err := iter.Close(); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting inventory sources",
+			Log:     logrus.Fields{"Error": err.Error()},
+		})
+		return
+	}
+
+	count := len(sources)
+	pgi := util.NewPagination(c, count)
+	if pgi.HasPage() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound,
+			Message: "#" + strconv.Itoa(pgi.Page()) + " page contains no results.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		Count:    count,
+		Next:     pgi.NextPage(),
+		Previous: pgi.PreviousPage(),
+		Data:     sources[pgi.Skip():pgi.End()],
+	})
+}
+
+// validateInventorySource checks the group, the cloud credential and the source
+// variables of an inventory source. It aborts the request and returns false if the
+// source is not valid.
+func validateInventorySource(c *gin.Context, user common.User, req ansible.InventorySource) bool {
+	if req.GroupID != nil && !req.GroupExist() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Group does not exist.",
+		})
+		return false
+	}
+
+	if req.CredentialID != nil {
+		var credential common.Credential
+		if err := db.Credentials().FindId(*req.CredentialID).One(&credential); err != nil {
+			AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+				Message: "Credential does not exists.",
+			})
+			return false
+		}
+
+		if kind := req.CredentialKind(); kind == "" && !credential.Cloud {
+			AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+				Message: "Credential must be a cloud credential for " + req.Source + " inventory sources.",
+			})
+			return false
+		} else if kind != "" && credential.Kind != kind {
+			AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+				Message: "Credential kind must be " + kind + " for " + req.Source + " inventory sources.",
+			})
+			return false
+		}
+
+		if !new(rbac.Credential).Read(user, credential) {
+			AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+				Message: "You don't have sufficient permissions to perform this action.",
+			})
+			return false
+		}
+	}
+
+	if req.Source == ansible.INVENTORY_SOURCE_CUSTOM {
+		if req.SourceScriptID == nil {
+			AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+				Message: "Source script is required for custom inventory sources.",
+			})
+			return false
+		}
+
+		var inventory ansible.Inventory
+		if err := db.Inventories().FindId(req.InventoryID).One(&inventory); err != nil {
+			AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+				Message: "Inventory does not exists.",
+			})
+			return false
+		}
+
+		var script ansible.InventoryScript
+		if err := db.InventoryScripts().FindId(*req.SourceScriptID).One(&script); err != nil {
+			AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+				Message: "Inventory Script does not exists.",
+			})
+			return false
+		}
+
+		if script.OrganizationID != inventory.OrganizationID {
+			AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+				Message: "Inventory Script must belong to the organization of the inventory.",
+			})
+			return false
+		}
+
+		if !new(rbac.InventoryScript).Read(user, script) {
+			AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+				Message: "You don't have sufficient permissions to perform this action.",
+			})
+			return false
+		}
+	} else if req.SourceScriptID != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Source script can only be used with custom inventory sources.",
+		})
+		return false
+	}
+
+	if len(req.SourceVars) > 0 {
+		vars := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(req.SourceVars), &vars); err != nil {
+			AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+				Message: "Source variables must be a JSON object.",
+			})
+			return false
+		}
+	}
+
+	return true
+}
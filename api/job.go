@@ -1,11 +1,15 @@
 package api
 
 import (
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pearsonappeng/tensor/api/metadata"
 	"github.com/pearsonappeng/tensor/db"
+	execansible "github.com/pearsonappeng/tensor/exec/ansible"
 	"github.com/pearsonappeng/tensor/models/ansible"
 	"github.com/pearsonappeng/tensor/models/common"
 
@@ -13,6 +17,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pearsonappeng/tensor/rbac"
 	"github.com/pearsonappeng/tensor/util"
+	"github.com/pearsonappeng/tensor/validate"
+	"gopkg.in/gin-gonic/gin.v1/binding"
+	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -58,7 +65,7 @@ func (ctrl JobController) Middleware(c *gin.Context) {
 				return
 			}
 		}
-	case "PUT", "DELETE":
+	case "PUT", "POST", "DELETE":
 		{
 			// Reject the request if the user doesn't have write permissions
 			if !roles.WriteByID(user, job.JobTemplateID) {
@@ -90,7 +97,7 @@ func (ctrl JobController) All(c *gin.Context) {
 	match := bson.M{}
 	match = parser.Match([]string{"status", "type", "failed"}, match)
 	match = parser.Lookups([]string{"id", "name", "labels"}, match)
-	query := db.Jobs().Find(match)
+	query := db.Jobs().Find(match).Select(bson.M{"result_stdout": 0})
 	if order := parser.OrderBy(); order != "" {
 		query.Sort(order)
 	}
@@ -138,19 +145,260 @@ func (ctrl JobController) All(c *gin.Context) {
 // The response will include the following field:
 // can_cancel: [boolean] Indicates whether this job can be canceled
 func (ctrl JobController) CancelInfo(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"can_cancel": false})
+	job := c.MustGet(cJob).(ansible.Job)
+	c.JSON(http.StatusOK, gin.H{"can_cancel": isCancelable(job.Status, job.CancelFlag)})
 }
 
 // Cancel cancels the pending job.
 // The response status code will be 202 if successful, or 405 if the job cannot be
 // canceled.
+// A job that is still queued will not be started by the consumer, a running job
+// is killed by the runner and its partial output is saved.
 func (ctrl JobController) Cancel(c *gin.Context) {
-	c.AbortWithStatus(http.StatusMethodNotAllowed)
+	job := c.MustGet(cJob).(ansible.Job)
+
+	if !isCancelable(job.Status, job.CancelFlag) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusMethodNotAllowed,
+			Message: "Job cannot be canceled.",
+		})
+		return
+	}
+
+	// only flag jobs that are still in a cancelable state, the runner might
+	// have finished the job in the meantime
+	query := bson.M{
+		"_id":    job.ID,
+		"status": bson.M{"$in": common.ActiveJobStatus},
+	}
+	if err := db.Jobs().Update(query, bson.M{"$set": bson.M{"cancel_flag": true}}); err != nil {
+		if err == mgo.ErrNotFound {
+			AbortWithError(LogFields{Context: c, Status: http.StatusMethodNotAllowed,
+				Message: "Job cannot be canceled.",
+			})
+			return
+		}
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while canceling job",
+			Log:     logrus.Fields{"Job ID": job.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	c.AbortWithStatus(http.StatusAccepted)
 }
 
 // StdOut returns ANSI standard output of a Job
+// while the job is running the output persisted so far is returned.
+// The `start_line` and `end_line` query parameters select a range of lines and
+// the `format` query parameter selects json (default), txt, ansi or html output.
 func (ctrl JobController) StdOut(c *gin.Context) {
 	job := c.MustGet(cJob).(ansible.Job)
 
-	c.JSON(http.StatusOK, job.ResultStdout)
+	start, end, ok := stdoutLines(c)
+	if !ok {
+		return
+	}
+
+	stdout, err := jobStdout(job.ID, job.ResultStdout, start, end)
+	if err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting job stdout",
+			Log:     logrus.Fields{"Job ID": job.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	writeStdout(c, "job_"+job.ID.Hex(), stdout)
+}
+
+// StdOutStream streams the standard output of a Job as Server-Sent Events
+// and follows the output until the job is finished
+func (ctrl JobController) StdOutStream(c *gin.Context) {
+	job := c.MustGet(cJob).(ansible.Job)
+	streamStdout(c, job.ID, db.Jobs())
+}
+
+// relaunchCheck reports whether the inventory and the machine credential of
+// a job still exist. A missing one must be provided on relaunch, which is
+// only possible when the job template prompts for it
+func relaunchCheck(job ansible.Job) (inventoryNeeded bool, credentialNeeded bool) {
+	if n, err := db.Inventories().FindId(job.InventoryID).Count(); err != nil || n == 0 {
+		inventoryNeeded = true
+	}
+
+	if job.MachineCredentialID != nil {
+		if n, err := db.Credentials().FindId(*job.MachineCredentialID).Count(); err != nil || n == 0 {
+			credentialNeeded = true
+		}
+	}
+
+	return
+}
+
+// failedHosts returns the names of the hosts that failed or were
+// unreachable in the given job
+func failedHosts(jobID bson.ObjectId) ([]string, error) {
+	var summaries []ansible.JobHostSummary
+	err := db.JobHostSummaries().Find(bson.M{"job_id": jobID, "failed": true}).
+		Select(bson.M{"host_name": 1}).Sort("host_name").All(&summaries)
+
+	hosts := make([]string, len(summaries))
+	for i, summary := range summaries {
+		hosts[i] = summary.HostName
+	}
+	return hosts, err
+}
+
+// RelaunchInfo determines if the job can be relaunched.
+// The response will include the following fields:
+// can_relaunch: [boolean] Indicates whether this job can be relaunched
+// inventory_needed_to_start: [boolean] The inventory of the job no longer exists and must be provided
+// credential_needed_to_start: [boolean] The credential of the job no longer exists and must be provided
+// retry_counts: number of hosts for each value of the hosts parameter (all, failed)
+func (ctrl JobController) RelaunchInfo(c *gin.Context) {
+	job := c.MustGet(cJob).(ansible.Job)
+
+	hosts, err := failedHosts(job.ID)
+	if err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting job host summaries",
+			Log:     logrus.Fields{"Job ID": job.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	all, err := db.JobHostSummaries().Find(bson.M{"job_id": job.ID}).Count()
+	if err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting job host summaries",
+			Log:     logrus.Fields{"Job ID": job.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	canRelaunch := true
+	inventoryNeeded, credentialNeeded := relaunchCheck(job)
+	template, err := job.GetJobTemplate()
+	if err != nil {
+		canRelaunch = false
+	} else {
+		canRelaunch = (!inventoryNeeded || template.PromptInventory) &&
+			(!credentialNeeded || template.PromptCredential)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"can_relaunch":               canRelaunch,
+		"passwords_needed_to_start":  []gin.H{},
+		"inventory_needed_to_start":  inventoryNeeded,
+		"credential_needed_to_start": credentialNeeded,
+		"retry_counts": gin.H{
+			ansible.RELAUNCH_HOSTS_ALL:    all,
+			ansible.RELAUNCH_HOSTS_FAILED: len(hosts),
+		},
+	})
+}
+
+// Relaunch creates a new job from the stored parameters of the job and
+// publishes it to the ansible queue. The job template must still exist,
+// its current permissions and settings are applied to the new job.
+// Passing `"hosts": "failed"` limits the new job to the hosts that failed
+// or were unreachable in the original run.
+func (ctrl JobController) Relaunch(c *gin.Context) {
+	job := c.MustGet(cJob).(ansible.Job)
+	user := c.MustGet(cUser).(common.User)
+
+	var req ansible.Relaunch
+	if err := binding.JSON.Bind(c.Request, &req); err != nil && err != io.EOF {
+		AbortWithErrors(c, http.StatusBadRequest,
+			"Invalid JSON body",
+			validate.GetValidationErrors(err)...)
+		return
+	}
+
+	template, err := job.GetJobTemplate()
+	if err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Job template does not exist.",
+		})
+		return
+	}
+
+	// reset the execution state, the stored launch parameters are kept
+	relaunch := job
+	relaunch.ID = bson.NewObjectId()
+	relaunch.LaunchType = ansible.JOB_LAUNCH_TYPE_RELAUNCH
+	relaunch.CancelFlag = false
+	relaunch.Status = "new"
+	relaunch.Failed = false
+	relaunch.Started = time.Time{}
+	relaunch.Finished = time.Time{}
+	relaunch.Elapsed = 0
+	relaunch.ResultStdout = ""
+	relaunch.ResultTraceback = ""
+	relaunch.JobExplanation = ""
+	relaunch.JobCWD = ""
+	relaunch.JobARGS = nil
+	relaunch.JobENV = nil
+	relaunch.WorkerID = nil
+	relaunch.AllowSimultaneous = template.AllowSimultaneous
+	relaunch.CreatedByID = user.ID
+	relaunch.ModifiedByID = user.ID
+	relaunch.Created = time.Now()
+	relaunch.Modified = time.Now()
+
+	switch req.Hosts {
+	case "", ansible.RELAUNCH_HOSTS_ALL:
+	case ansible.RELAUNCH_HOSTS_FAILED:
+		hosts, err := failedHosts(job.ID)
+		if err != nil {
+			AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+				Message: "Error while getting job host summaries",
+				Log:     logrus.Fields{"Job ID": job.ID.Hex(), "Error": err.Error()},
+			})
+			return
+		}
+		if len(hosts) == 0 {
+			AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+				Message: "No failed hosts to relaunch the job on.",
+			})
+			return
+		}
+		relaunch.Limit = strings.Join(hosts, ",")
+	default:
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Hosts must be one of all, failed.",
+		})
+		return
+	}
+
+	inventoryNeeded, credentialNeeded := relaunchCheck(job)
+	if inventoryNeeded {
+		if !template.PromptInventory || len(req.InventoryID) != 12 {
+			AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+				Message: "Inventory required.",
+			})
+			return
+		}
+		relaunch.InventoryID = req.InventoryID
+	}
+
+	if credentialNeeded {
+		if !template.PromptCredential || len(req.MachineCredentialID) != 12 {
+			AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+				Message: "Credential required.",
+			})
+			return
+		}
+		relaunch.MachineCredentialID = &req.MachineCredentialID
+	}
+
+	if err := execansible.Launch(relaunch, template, user); err != nil {
+		AbortWithError(LogFields{Context: c, Status: launchStatus(err),
+			Message: err.Error(),
+		})
+		return
+	}
+
+	metadata.JobMetadata(&relaunch)
+	c.JSON(http.StatusCreated, relaunch)
 }
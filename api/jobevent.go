@@ -0,0 +1,374 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pearsonappeng/tensor/api/metadata"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/exec/misc"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"github.com/pearsonappeng/tensor/models/common"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/rbac"
+	"github.com/pearsonappeng/tensor/util"
+	"github.com/pearsonappeng/tensor/validate"
+	"gopkg.in/gin-gonic/gin.v1/binding"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// redactorTTL is how long the Redactor of a job is reused for its events,
+// jobs post events in bursts and the cache is kept small this way
+const redactorTTL = time.Minute
+
+// cachedRedactor is the Redactor of a job and the time it expires
+type cachedRedactor struct {
+	redactor *misc.Redactor
+	expires  time.Time
+}
+
+var (
+	redactorsMu sync.Mutex
+	redactors   = map[bson.ObjectId]cachedRedactor{}
+)
+
+// jobRedactor returns the Redactor of the secrets injected into the job,
+// it is loaded once and shared by the events the job posts within redactorTTL
+func jobRedactor(job ansible.Job) (*misc.Redactor, error) {
+	now := time.Now()
+
+	redactorsMu.Lock()
+	cached, ok := redactors[job.ID]
+	redactorsMu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.redactor, nil
+	}
+
+	credentialIDs := []*bson.ObjectId{job.MachineCredentialID, job.NetworkCredentialID, job.CloudCredentialID}
+	for i := range job.VaultCredentialIDs {
+		credentialIDs = append(credentialIDs, &job.VaultCredentialIDs[i])
+	}
+	redactor, err := misc.LoadRedactor(credentialIDs, job.ExtraVars, job.SecretVars)
+	if err != nil {
+		return nil, err
+	}
+
+	redactorsMu.Lock()
+	for id, c := range redactors {
+		if !now.Before(c.expires) {
+			delete(redactors, id)
+		}
+	}
+	redactors[job.ID] = cachedRedactor{redactor: redactor, expires: now.Add(redactorTTL)}
+	redactorsMu.Unlock()
+
+	return redactor, nil
+}
+
+// eventFilters returns a job event query built from the request parameters,
+// the given query is used as the base
+func eventFilters(c *gin.Context, query bson.M) bson.M {
+	parser := util.NewQueryParser(c)
+	query = parser.Match([]string{"event", "host_name", "uuid", "parent_uuid",
+		"play_uuid", "task_uuid"}, query)
+	query = parser.Lookups([]string{"event", "host_name", "play", "task", "role"}, query)
+	for _, field := range []string{"failed", "changed"} {
+		if v, err := strconv.ParseBool(parser.RawQuery(field)); err == nil {
+			query[field] = v
+		}
+	}
+	return query
+}
+
+// eventOrder returns the requested sort order of job events,
+// events are ordered by counter by default
+func eventOrder(c *gin.Context) string {
+	parser := util.NewQueryParser(c)
+	if order := parser.OrderBy(); order != "" {
+		return order
+	}
+	return "counter"
+}
+
+// findEvents queries the job_events collection and writes a paginated
+// response with the matching events
+func findEvents(c *gin.Context, query bson.M) {
+	var events []ansible.JobEvent
+	if err := db.JobEvents().Find(query).Sort(eventOrder(c)).All(&events); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting job events",
+			Log:     logrus.Fields{"Error": err.Error()},
+		})
+		return
+	}
+
+	for i := range events {
+		metadata.JobEventMetadata(&events[i])
+	}
+
+	count := len(events)
+	pgi := util.NewPagination(c, count)
+	if pgi.HasPage() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound,
+			Message: "#" + strconv.Itoa(pgi.Page()) + " page contains no results.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		Count:    count,
+		Next:     pgi.NextPage(),
+		Previous: pgi.PreviousPage(),
+		Data:     events[pgi.Skip():pgi.End()],
+	})
+}
+
+// CreateEvent is a Gin handler function which stores an event posted by the
+// tensor callback plugin of a running job.
+// Only system tokens are allowed to post events.
+func (ctrl JobController) CreateEvent(c *gin.Context) {
+	job := c.MustGet(cJob).(ansible.Job)
+	user := c.MustGet(cUser).(common.User)
+
+	if !rbac.HasGlobalWrite(user) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+			Message: "You don't have sufficient permissions to perform this action.",
+		})
+		return
+	}
+
+	var req ansible.JobEvent
+	if err := binding.JSON.Bind(c.Request, &req); err != nil {
+		AbortWithErrors(c, http.StatusBadRequest,
+			"Invalid JSON body",
+			validate.GetValidationErrors(err)...)
+		return
+	}
+
+	req.ID = bson.NewObjectId()
+	req.JobID = job.ID
+	req.HostID = nil
+	req.Created = time.Now()
+
+	// secrets injected into the job are scrubbed before the event is stored
+	redactor, err := jobRedactor(job)
+	if err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while creating job event",
+			Log:     logrus.Fields{"Job ID": job.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+	if data, ok := redactor.Value(req.EventData).(gin.H); ok {
+		req.EventData = data
+	}
+	req.Play = redactor.String(req.Play)
+	req.Task = redactor.String(req.Task)
+	req.Role = redactor.String(req.Role)
+
+	switch req.Event {
+	case ansible.EVENT_RUNNER_ON_FAILED:
+		// failures of tasks with ignore_errors do not fail the host
+		ignore, _ := req.EventData["ignore_errors"].(bool)
+		req.Failed = !ignore
+	case ansible.EVENT_RUNNER_ON_UNREACHABLE:
+		req.Failed = true
+	}
+	if changed, ok := req.EventData["changed"].(bool); ok {
+		req.Changed = changed
+	}
+
+	// resolve the host from the job inventory, hosts added
+	// at runtime are not part of the inventory
+	if len(req.HostName) > 0 {
+		var host ansible.Host
+		err := db.Hosts().Find(bson.M{
+			"name":         req.HostName,
+			"inventory_id": job.InventoryID,
+		}).Select(bson.M{"_id": 1}).One(&host)
+		if err == nil {
+			req.HostID = &host.ID
+		}
+	}
+
+	if err := db.JobEvents().Insert(req); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while creating job event",
+			Log:     logrus.Fields{"Job ID": job.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	metadata.JobEventMetadata(&req)
+	c.JSON(http.StatusCreated, req)
+}
+
+// Events is a Gin handler function which returns the events of a job
+// This takes lookup parameters and order parameters to filter and sort output data
+func (ctrl JobController) Events(c *gin.Context) {
+	job := c.MustGet(cJob).(ansible.Job)
+	findEvents(c, eventFilters(c, bson.M{"job_id": job.ID}))
+}
+
+// Plays is a Gin handler function which returns the plays of a job
+// with their failed and changed state
+func (ctrl JobController) Plays(c *gin.Context) {
+	job := c.MustGet(cJob).(ansible.Job)
+
+	var events []ansible.JobEvent
+	query := bson.M{"job_id": job.ID, "play_uuid": bson.M{"$ne": ""}}
+	if err := db.JobEvents().Find(query).Sort("counter").All(&events); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting job plays",
+			Log:     logrus.Fields{"Job ID": job.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	var plays []ansible.JobPlay
+	index := map[string]int{}
+	for _, event := range events {
+		i, ok := index[event.PlayUUID]
+		if !ok {
+			if event.Event != ansible.EVENT_PLAYBOOK_ON_PLAY_START {
+				continue
+			}
+			index[event.PlayUUID] = len(plays)
+			plays = append(plays, ansible.JobPlay{
+				ID:       event.PlayUUID,
+				Play:     event.Play,
+				Counter:  event.Counter,
+				Started:  event.Created,
+				Finished: event.Created,
+			})
+			continue
+		}
+		plays[i].Failed = plays[i].Failed || event.Failed
+		plays[i].Changed = plays[i].Changed || event.Changed
+		plays[i].Finished = event.Created
+	}
+
+	count := len(plays)
+	pgi := util.NewPagination(c, count)
+	if pgi.HasPage() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound,
+			Message: "#" + strconv.Itoa(pgi.Page()) + " page contains no results.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		Count:    count,
+		Next:     pgi.NextPage(),
+		Previous: pgi.PreviousPage(),
+		Data:     plays[pgi.Skip():pgi.End()],
+	})
+}
+
+// Tasks is a Gin handler function which returns the tasks of a job
+// with the number of hosts per result, tasks can be filtered by play
+// using the play_uuid parameter
+func (ctrl JobController) Tasks(c *gin.Context) {
+	job := c.MustGet(cJob).(ansible.Job)
+
+	parser := util.NewQueryParser(c)
+	query := parser.Match([]string{"play_uuid"}, bson.M{
+		"job_id":    job.ID,
+		"task_uuid": bson.M{"$ne": ""},
+	})
+
+	var events []ansible.JobEvent
+	if err := db.JobEvents().Find(query).Sort("counter").All(&events); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting job tasks",
+			Log:     logrus.Fields{"Job ID": job.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	var tasks []ansible.JobTask
+	index := map[string]int{}
+	hosts := map[string]map[string]bool{}
+	for _, event := range events {
+		i, ok := index[event.TaskUUID]
+		if !ok {
+			if event.Event != ansible.EVENT_PLAYBOOK_ON_TASK_START &&
+				event.Event != ansible.EVENT_PLAYBOOK_ON_HANDLER_START {
+				continue
+			}
+			index[event.TaskUUID] = len(tasks)
+			hosts[event.TaskUUID] = map[string]bool{}
+			tasks = append(tasks, ansible.JobTask{
+				ID:       event.TaskUUID,
+				Task:     event.Task,
+				Play:     event.Play,
+				PlayUUID: event.PlayUUID,
+				Role:     event.Role,
+				Counter:  event.Counter,
+				Started:  event.Created,
+				Finished: event.Created,
+			})
+			continue
+		}
+
+		t := &tasks[i]
+		t.Finished = event.Created
+		t.Failed = t.Failed || event.Failed
+		t.Changed = t.Changed || event.Changed
+		if len(event.HostName) == 0 {
+			continue
+		}
+
+		switch event.Event {
+		case ansible.EVENT_RUNNER_ON_OK:
+			t.SuccessfulCount++
+			if event.Changed {
+				t.ChangedCount++
+			}
+		case ansible.EVENT_RUNNER_ON_FAILED:
+			if event.Failed {
+				t.FailedCount++
+			} else {
+				t.SuccessfulCount++
+			}
+		case ansible.EVENT_RUNNER_ON_UNREACHABLE:
+			t.UnreachableCount++
+		case ansible.EVENT_RUNNER_ON_SKIPPED:
+			t.SkippedCount++
+		default:
+			continue
+		}
+
+		if !hosts[event.TaskUUID][event.HostName] {
+			hosts[event.TaskUUID][event.HostName] = true
+			t.HostCount++
+		}
+	}
+
+	count := len(tasks)
+	pgi := util.NewPagination(c, count)
+	if pgi.HasPage() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound,
+			Message: "#" + strconv.Itoa(pgi.Page()) + " page contains no results.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		Count:    count,
+		Next:     pgi.NextPage(),
+		Previous: pgi.PreviousPage(),
+		Data:     tasks[pgi.Skip():pgi.End()],
+	})
+}
+
+// JobEvents is a Gin handler function which returns the job events of a host
+// This takes lookup parameters and order parameters to filter and sort output data
+func (ctrl HostController) JobEvents(c *gin.Context) {
+	host := c.MustGet(cHost).(ansible.Host)
+	findEvents(c, eventFilters(c, bson.M{"host_id": host.ID}))
+}
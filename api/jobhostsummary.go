@@ -0,0 +1,129 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/pearsonappeng/tensor/api/metadata"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"github.com/pearsonappeng/tensor/models/common"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/util"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// findHostSummaries queries the job_host_summaries collection and writes
+// a paginated response with the matching summaries.
+// Summaries can be filtered by host_name and failed, the most recent
+// summaries are returned first unless order_by is given
+func findHostSummaries(c *gin.Context, query bson.M) {
+	parser := util.NewQueryParser(c)
+	query = parser.Match([]string{"host_name"}, query)
+	query = parser.Lookups([]string{"host_name"}, query)
+	if v, err := strconv.ParseBool(parser.RawQuery("failed")); err == nil {
+		query["failed"] = v
+	}
+
+	order := parser.OrderBy()
+	if order == "" {
+		order = "-created"
+	}
+
+	var summaries []ansible.JobHostSummary
+	if err := db.JobHostSummaries().Find(query).Sort(order).All(&summaries); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting job host summaries",
+			Log:     logrus.Fields{"Error": err.Error()},
+		})
+		return
+	}
+
+	for i := range summaries {
+		metadata.JobHostSummaryMetadata(&summaries[i])
+	}
+
+	count := len(summaries)
+	pgi := util.NewPagination(c, count)
+	if pgi.HasPage() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound,
+			Message: "#" + strconv.Itoa(pgi.Page()) + " page contains no results.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		Count:    count,
+		Next:     pgi.NextPage(),
+		Previous: pgi.PreviousPage(),
+		Data:     summaries[pgi.Skip():pgi.End()],
+	})
+}
+
+// HostSummaries is a Gin handler function which returns the per host recap of a job
+func (ctrl JobController) HostSummaries(c *gin.Context) {
+	job := c.MustGet(cJob).(ansible.Job)
+	findHostSummaries(c, bson.M{"job_id": job.ID})
+}
+
+// JobHostSummaries is a Gin handler function which returns the job recaps of a host
+func (ctrl HostController) JobHostSummaries(c *gin.Context) {
+	host := c.MustGet(cHost).(ansible.Host)
+	findHostSummaries(c, bson.M{"host_id": host.ID})
+}
+
+// JobHostSummaries is a Gin handler function which returns the job recaps
+// of the hosts in a group and its child groups
+func (ctrl GroupController) JobHostSummaries(c *gin.Context) {
+	group := c.MustGet(cGroup).(ansible.Group)
+
+	// collect the group and all of its descendants, groups that were
+	// seen before are skipped in case the parents form a cycle
+	groupIDs := []bson.ObjectId{group.ID}
+	parents := []bson.ObjectId{group.ID}
+	seen := map[bson.ObjectId]bool{group.ID: true}
+	for len(parents) > 0 {
+		var children []ansible.Group
+		if err := db.Groups().Find(bson.M{"parent_group_id": bson.M{"$in": parents}}).
+			Select(bson.M{"_id": 1}).All(&children); err != nil {
+			AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+				Message: "Error while getting child groups",
+				Log:     logrus.Fields{"Group ID": group.ID.Hex(), "Error": err.Error()},
+			})
+			return
+		}
+
+		parents = nil
+		for _, child := range children {
+			if seen[child.ID] {
+				continue
+			}
+			seen[child.ID] = true
+			groupIDs = append(groupIDs, child.ID)
+			parents = append(parents, child.ID)
+		}
+	}
+
+	var hosts []ansible.Host
+	// hosts imported by an inventory source can be a member of more groups
+	q := bson.M{"$or": []bson.M{
+		{"group_id": bson.M{"$in": groupIDs}},
+		{"group_ids": bson.M{"$in": groupIDs}},
+	}}
+	if err := db.Hosts().Find(q).Select(bson.M{"_id": 1}).All(&hosts); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting group hosts",
+			Log:     logrus.Fields{"Group ID": group.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	hostIDs := make([]bson.ObjectId, len(hosts))
+	for i, host := range hosts {
+		hostIDs[i] = host.ID
+	}
+
+	findHostSummaries(c, bson.M{"host_id": bson.M{"$in": hostIDs}})
+}
@@ -0,0 +1,99 @@
+package metadata
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"github.com/pearsonappeng/tensor/models/common"
+)
+
+func AdHocCommandMetadata(command *ansible.AdHocCommand) {
+	ID := command.ID.Hex()
+	command.Type = command.GetType()
+	command.Links = gin.H{
+		"self":          "/v1/ad_hoc_commands/" + ID,
+		"stdout":        "/v1/ad_hoc_commands/" + ID + "/stdout",
+		"stdout_stream": "/v1/ad_hoc_commands/" + ID + "/stdout/stream",
+		"cancel":        "/v1/ad_hoc_commands/" + ID + "/cancel",
+		"relaunch":      "/v1/ad_hoc_commands/" + ID + "/relaunch",
+		"inventory":     "/v1/inventories/" + command.InventoryID.Hex(),
+		"credential":    "/v1/credentials/" + command.CredentialID.Hex(),
+		"created_by":    "/v1/users/" + command.CreatedByID.Hex(),
+		"modified_by":   "/v1/users/" + command.ModifiedByID.Hex(),
+	}
+	adHocCommandSummary(command)
+}
+
+func adHocCommandSummary(command *ansible.AdHocCommand) {
+	summary := gin.H{
+		"inventory":   nil,
+		"credential":  nil,
+		"created_by":  nil,
+		"modified_by": nil,
+	}
+
+	var inv ansible.Inventory
+	if err := db.Inventories().FindId(command.InventoryID).One(&inv); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Inventory ID":      command.InventoryID.Hex(),
+			"Ad Hoc Command ID": command.ID.Hex(),
+		}).Warnln("Error while getting Inventory")
+	} else {
+		summary["inventory"] = gin.H{
+			"id":                  inv.ID.Hex(),
+			"name":                inv.Name,
+			"description":         inv.Description,
+			"has_active_failures": inv.HasActiveFailures,
+			"total_hosts":         inv.TotalHosts,
+		}
+	}
+
+	var cred common.Credential
+	if err := db.Credentials().FindId(command.CredentialID).One(&cred); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Credential ID":     command.CredentialID.Hex(),
+			"Ad Hoc Command ID": command.ID.Hex(),
+		}).Warnln("Error while getting Machine Credential")
+	} else {
+		summary["credential"] = gin.H{
+			"id":          cred.ID,
+			"name":        cred.Name,
+			"description": cred.Description,
+			"kind":        cred.Kind,
+			"cloud":       cred.Cloud,
+		}
+	}
+
+	var created common.User
+	if err := db.Users().FindId(command.CreatedByID).One(&created); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"User ID":           command.CreatedByID.Hex(),
+			"Ad Hoc Command ID": command.ID.Hex(),
+		}).Errorln("Error while getting created by User")
+	} else {
+		summary["created_by"] = gin.H{
+			"id":         created.ID,
+			"username":   created.Username,
+			"first_name": created.FirstName,
+			"last_name":  created.LastName,
+		}
+	}
+
+	var modified common.User
+	if err := db.Users().FindId(command.ModifiedByID).One(&modified); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"User ID":           command.ModifiedByID.Hex(),
+			"Ad Hoc Command ID": command.ID.Hex(),
+		}).Errorln("Error while getting modified by User")
+	} else {
+		summary["modified_by"] = gin.H{
+			"id":         modified.ID,
+			"username":   modified.Username,
+			"first_name": modified.FirstName,
+			"last_name":  modified.LastName,
+		}
+	}
+
+	command.Meta = summary
+}
@@ -16,7 +16,7 @@ func GroupMetadata(grp *ansible.Group) {
 	grp.Links = gin.H{
 		"self":               "/v1/groups/" + ID,
 		"created_by":         "/v1/users/" + grp.CreatedByID.Hex(),
-		"job_host_summaries": "/v1/groups/" + ID + "job_host_summaries",
+		"job_host_summaries": "/v1/groups/" + ID + "/job_host_summaries",
 		"variable_data":      "/v1/groups/" + ID + "/variable_data",
 		"job_events":         "/v1/groups/" + ID + "/job_events",
 		"potential_children": "/v1/groups/" + ID + "/potential_children",
@@ -27,7 +27,10 @@ func GroupMetadata(grp *ansible.Group) {
 		"children":           "/v1/groups/" + ID + "/children",
 		"inventory_sources":  "/v1/groups/" + ID + "/inventory_sources",
 		"inventory":          "/v1/inventories/" + grp.InventoryID.Hex(),
-		"inventory_source":   "/v1/inventory_sources/emptyid",
+	}
+
+	if grp.InventorySourceID != nil {
+		grp.Links["inventory_source"] = "/v1/inventory_sources/" + grp.InventorySourceID.Hex()
 	}
 
 	groupSummary(grp)
@@ -101,5 +104,21 @@ func groupSummary(grp *ansible.Group) {
 		}
 	}
 
+	if grp.InventorySourceID != nil {
+		var source ansible.InventorySource
+		if err := db.InventorySources().FindId(*grp.InventorySourceID).One(&source); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Inventory Source ID": grp.InventorySourceID.Hex(),
+				"Group":               grp.Name,
+				"Group ID":            grp.ID.Hex(),
+			}).Errorln("Error while getting Inventory Source")
+		} else {
+			summary["inventory_source"] = gin.H{
+				"source": source.Source,
+				"status": source.Status,
+			}
+		}
+	}
+
 	grp.Meta = summary
 }
@@ -30,6 +30,10 @@ func HostMetadata(host *ansible.Host) {
 		"inventory":             "/v1/inventories/" + host.InventoryID.Hex(),
 	}
 
+	if host.LastJobID != nil {
+		host.Links["last_job"] = "/v1/jobs/" + (*host.LastJobID).Hex()
+	}
+
 	hostSummary(host)
 }
 
@@ -0,0 +1,73 @@
+package metadata
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"github.com/pearsonappeng/tensor/models/common"
+)
+
+func InventoryScriptMetadata(script *ansible.InventoryScript) {
+	ID := script.ID.Hex()
+	script.Type = script.GetType()
+	script.Links = gin.H{
+		"self":              "/v1/inventory_scripts/" + ID,
+		"inventory_sources": "/v1/inventory_scripts/" + ID + "/inventory_sources",
+		"organization":      "/v1/organizations/" + script.OrganizationID.Hex(),
+		"created_by":        "/v1/users/" + script.CreatedByID.Hex(),
+		"modified_by":       "/v1/users/" + script.ModifiedByID.Hex(),
+	}
+
+	summary := gin.H{
+		"organization": nil,
+		"created_by":   nil,
+		"modified_by":  nil,
+	}
+
+	var org common.Organization
+	if err := db.Organizations().FindId(script.OrganizationID).One(&org); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Organization ID":     script.OrganizationID.Hex(),
+			"Inventory Script ID": ID,
+		}).Errorln("Error while getting Organization")
+	} else {
+		summary["organization"] = gin.H{
+			"id":          org.ID,
+			"name":        org.Name,
+			"description": org.Description,
+		}
+	}
+
+	var created common.User
+	if err := db.Users().FindId(script.CreatedByID).One(&created); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"User ID":             script.CreatedByID.Hex(),
+			"Inventory Script ID": ID,
+		}).Errorln("Error while getting created by User")
+	} else {
+		summary["created_by"] = gin.H{
+			"id":         created.ID,
+			"username":   created.Username,
+			"first_name": created.FirstName,
+			"last_name":  created.LastName,
+		}
+	}
+
+	var modified common.User
+	if err := db.Users().FindId(script.ModifiedByID).One(&modified); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"User ID":             script.ModifiedByID.Hex(),
+			"Inventory Script ID": ID,
+		}).Errorln("Error while getting modified by User")
+	} else {
+		summary["modified_by"] = gin.H{
+			"id":         modified.ID,
+			"username":   modified.Username,
+			"first_name": modified.FirstName,
+			"last_name":  modified.LastName,
+		}
+	}
+
+	script.Meta = summary
+}
@@ -0,0 +1,157 @@
+package metadata
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"github.com/pearsonappeng/tensor/models/common"
+)
+
+func InventorySourceMetadata(source *ansible.InventorySource) {
+	ID := source.ID.Hex()
+	source.Type = source.GetType()
+	source.Links = gin.H{
+		"self":              "/v1/inventory_sources/" + ID,
+		"update":            "/v1/inventory_sources/" + ID + "/update",
+		"inventory_updates": "/v1/inventory_sources/" + ID + "/inventory_updates",
+		"hosts":             "/v1/inventory_sources/" + ID + "/hosts",
+		"groups":            "/v1/inventory_sources/" + ID + "/groups",
+		"inventory":         "/v1/inventories/" + source.InventoryID.Hex(),
+		"created_by":        "/v1/users/" + source.CreatedByID.Hex(),
+		"modified_by":       "/v1/users/" + source.ModifiedByID.Hex(),
+	}
+
+	if source.CredentialID != nil {
+		source.Links["credential"] = "/v1/credentials/" + source.CredentialID.Hex()
+	}
+
+	if source.SourceScriptID != nil {
+		source.Links["source_script"] = "/v1/inventory_scripts/" + source.SourceScriptID.Hex()
+	}
+
+	if source.GroupID != nil {
+		source.Links["group"] = "/v1/groups/" + source.GroupID.Hex()
+	}
+
+	if source.LastJobID != nil {
+		source.Links["last_job"] = "/v1/jobs/" + source.LastJobID.Hex()
+	}
+
+	inventorySourceSummary(source)
+}
+
+func inventorySourceSummary(source *ansible.InventorySource) {
+	summary := gin.H{
+		"inventory":   nil,
+		"credential":  nil,
+		"group":       nil,
+		"last_job":    nil,
+		"created_by":  nil,
+		"modified_by": nil,
+	}
+
+	var inv ansible.Inventory
+	if err := db.Inventories().FindId(source.InventoryID).One(&inv); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Inventory ID":        source.InventoryID.Hex(),
+			"Inventory Source ID": source.ID.Hex(),
+		}).Warnln("Error while getting Inventory")
+	} else {
+		summary["inventory"] = gin.H{
+			"id":                              inv.ID.Hex(),
+			"name":                            inv.Name,
+			"description":                     inv.Description,
+			"has_active_failures":             inv.HasActiveFailures,
+			"total_hosts":                     inv.TotalHosts,
+			"total_groups":                    inv.TotalGroups,
+			"has_inventory_sources":           inv.HasInventorySources,
+			"total_inventory_sources":         inv.TotalInventorySources,
+			"inventory_sources_with_failures": inv.InventorySourcesWithFailures,
+		}
+	}
+
+	if source.CredentialID != nil {
+		var cred common.Credential
+		if err := db.Credentials().FindId(*source.CredentialID).One(&cred); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Credential ID":       source.CredentialID.Hex(),
+				"Inventory Source ID": source.ID.Hex(),
+			}).Warnln("Error while getting Cloud Credential")
+		} else {
+			summary["credential"] = gin.H{
+				"id":          cred.ID,
+				"name":        cred.Name,
+				"description": cred.Description,
+				"kind":        cred.Kind,
+				"cloud":       cred.Cloud,
+			}
+		}
+	}
+
+	if source.GroupID != nil {
+		var grp ansible.Group
+		if err := db.Groups().FindId(*source.GroupID).One(&grp); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Group ID":            source.GroupID.Hex(),
+				"Inventory Source ID": source.ID.Hex(),
+			}).Warnln("Error while getting Group")
+		} else {
+			summary["group"] = gin.H{
+				"id":          grp.ID,
+				"name":        grp.Name,
+				"description": grp.Description,
+			}
+		}
+	}
+
+	if source.LastJobID != nil {
+		var job ansible.Job
+		if err := db.Jobs().FindId(*source.LastJobID).One(&job); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Job ID":              source.LastJobID.Hex(),
+				"Inventory Source ID": source.ID.Hex(),
+			}).Warnln("Error while getting last inventory update")
+		} else {
+			summary["last_job"] = gin.H{
+				"id":       job.ID,
+				"name":     job.Name,
+				"status":   job.Status,
+				"failed":   job.Failed,
+				"finished": job.Finished,
+			}
+		}
+	}
+
+	var created common.User
+	if err := db.Users().FindId(source.CreatedByID).One(&created); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"User ID":             source.CreatedByID.Hex(),
+			"Inventory Source ID": source.ID.Hex(),
+		}).Errorln("Error while getting created by User")
+	} else {
+		summary["created_by"] = gin.H{
+			"id":         created.ID,
+			"username":   created.Username,
+			"first_name": created.FirstName,
+			"last_name":  created.LastName,
+		}
+	}
+
+	var modified common.User
+	if err := db.Users().FindId(source.ModifiedByID).One(&modified); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"User ID":             source.ModifiedByID.Hex(),
+			"Inventory Source ID": source.ID.Hex(),
+		}).Errorln("Error while getting modified by User")
+	} else {
+		summary["modified_by"] = gin.H{
+			"id":         modified.ID,
+			"username":   modified.Username,
+			"first_name": modified.FirstName,
+			"last_name":  modified.LastName,
+		}
+	}
+
+	source.Meta = summary
+}
@@ -16,6 +16,7 @@ func JobMetadata(job *ansible.Job) {
 		"labels":             "/v1/jobs/" + ID + "/labels",
 		"project":            "/v1/projects/" + job.ProjectID.Hex(),
 		"stdout":             "/v1/jobs/" + ID + "/stdout",
+		"stdout_stream":      "/v1/jobs/" + ID + "/stdout/stream",
 		"job_host_summaries": "/v1/jobs/" + ID + "/job_host_summaries",
 		"job_tasks":          "/v1/jobs/" + ID + "/job_tasks",
 		"job_plays":          "/v1/jobs/" + ID + "/job_plays",
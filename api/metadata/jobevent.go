@@ -0,0 +1,28 @@
+package metadata
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/models/ansible"
+)
+
+func JobEventMetadata(event *ansible.JobEvent) {
+	event.Type = event.GetType()
+	related := gin.H{
+		"job": "/v1/jobs/" + event.JobID.Hex(),
+	}
+
+	summary := gin.H{
+		"host": nil,
+	}
+
+	if event.HostID != nil {
+		related["host"] = "/v1/hosts/" + (*event.HostID).Hex()
+		summary["host"] = gin.H{
+			"id":   (*event.HostID).Hex(),
+			"name": event.HostName,
+		}
+	}
+
+	event.Links = related
+	event.Meta = summary
+}
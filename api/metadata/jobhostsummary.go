@@ -0,0 +1,47 @@
+package metadata
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/ansible"
+)
+
+func JobHostSummaryMetadata(summary *ansible.JobHostSummary) {
+	summary.Type = summary.GetType()
+	related := gin.H{
+		"job": "/v1/jobs/" + summary.JobID.Hex(),
+	}
+
+	meta := gin.H{
+		"host": nil,
+		"job":  nil,
+	}
+
+	if summary.HostID != nil {
+		related["host"] = "/v1/hosts/" + (*summary.HostID).Hex()
+		meta["host"] = gin.H{
+			"id":   (*summary.HostID).Hex(),
+			"name": summary.HostName,
+		}
+	}
+
+	var job ansible.Job
+	if err := db.Jobs().FindId(summary.JobID).One(&job); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": summary.JobID.Hex(),
+			"Error":  err.Error(),
+		}).Warnln("Error while getting Job")
+	} else {
+		meta["job"] = gin.H{
+			"id":       job.ID.Hex(),
+			"name":     job.Name,
+			"status":   job.Status,
+			"failed":   job.Failed,
+			"finished": job.Finished,
+		}
+	}
+
+	summary.Links = related
+	summary.Meta = meta
+}
@@ -0,0 +1,123 @@
+package metadata
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/common"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func NotificationTemplateMetadata(t *common.NotificationTemplate) {
+	ID := t.ID.Hex()
+	t.Type = t.GetType()
+	t.Links = gin.H{
+		"self":          "/v1/notification_templates/" + ID,
+		"test":          "/v1/notification_templates/" + ID + "/test",
+		"notifications": "/v1/notification_templates/" + ID + "/notifications",
+		"organization":  "/v1/organizations/" + t.OrganizationID.Hex(),
+		"created_by":    "/v1/users/" + t.CreatedByID.Hex(),
+		"modified_by":   "/v1/users/" + t.ModifiedByID.Hex(),
+	}
+
+	var modified common.User
+	var created common.User
+	var org common.Organization
+
+	if err := db.Users().FindId(t.CreatedByID).One(&created); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"User ID":                  t.CreatedByID.Hex(),
+			"Notification Template ID": ID,
+		}).Errorln("Error while getting created by User")
+	}
+
+	if err := db.Users().FindId(t.ModifiedByID).One(&modified); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"User ID":                  t.ModifiedByID.Hex(),
+			"Notification Template ID": ID,
+		}).Errorln("Error while getting modified by User")
+	}
+
+	if err := db.Organizations().FindId(t.OrganizationID).One(&org); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Organization ID":          t.OrganizationID.Hex(),
+			"Notification Template ID": ID,
+		}).Errorln("Error while getting Organization")
+	}
+
+	meta := gin.H{
+		"organization": gin.H{
+			"id":          org.ID,
+			"name":        org.Name,
+			"description": org.Description,
+		},
+		"created_by": gin.H{
+			"id":         created.ID,
+			"username":   created.Username,
+			"first_name": created.FirstName,
+			"last_name":  created.LastName,
+		},
+		"modified_by": gin.H{
+			"id":         modified.ID,
+			"username":   modified.Username,
+			"first_name": modified.FirstName,
+			"last_name":  modified.LastName,
+		},
+	}
+
+	var recent []common.Notification
+	if err := db.Notifications().Find(bson.M{"notification_template_id": t.ID}).
+		Sort("-created").Limit(5).All(&recent); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Notification Template ID": ID,
+			"Error":                    err.Error(),
+		}).Warnln("Error while getting recent notifications")
+	}
+
+	notifications := []gin.H{}
+	for _, n := range recent {
+		notifications = append(notifications, gin.H{
+			"id":      n.ID,
+			"status":  n.Status,
+			"created": n.Created,
+		})
+	}
+	meta["recent_notifications"] = notifications
+
+	t.Meta = meta
+}
+
+func NotificationMetadata(n *common.Notification) {
+	n.Type = n.GetType()
+	related := gin.H{
+		"self":                  "/v1/notifications/" + n.ID.Hex(),
+		"notification_template": "/v1/notification_templates/" + n.NotificationTemplateID.Hex(),
+	}
+
+	if n.JobID != nil {
+		if n.JobType == "terraform_job" {
+			related["job"] = "/v1/terraform_jobs/" + (*n.JobID).Hex()
+		} else {
+			related["job"] = "/v1/jobs/" + (*n.JobID).Hex()
+		}
+	}
+
+	meta := gin.H{}
+	var template common.NotificationTemplate
+	if err := db.NotificationTemplates().FindId(n.NotificationTemplateID).One(&template); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Notification Template ID": n.NotificationTemplateID.Hex(),
+			"Notification ID":          n.ID.Hex(),
+		}).Warnln("Error while getting Notification Template")
+		meta["notification_template"] = nil
+	} else {
+		meta["notification_template"] = gin.H{
+			"id":          template.ID,
+			"name":        template.Name,
+			"description": template.Description,
+		}
+	}
+
+	n.Links = related
+	n.Meta = meta
+}
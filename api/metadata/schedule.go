@@ -0,0 +1,80 @@
+package metadata
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/common"
+)
+
+func ScheduleMetadata(s *common.Schedule) {
+	ID := s.ID.Hex()
+	s.Type = s.GetType()
+
+	var resource string
+	switch s.ResourceType {
+	case common.ScheduleJobTemplate:
+		resource = "/v1/job_templates/" + s.ResourceID.Hex()
+	case common.ScheduleTerraformJobTemplate:
+		resource = "/v1/terraform_job_templates/" + s.ResourceID.Hex()
+	case common.ScheduleProject:
+		resource = "/v1/projects/" + s.ResourceID.Hex()
+	}
+
+	related := gin.H{
+		"self":         "/v1/schedules/" + ID,
+		"created_by":   "/v1/users/" + s.CreatedByID.Hex(),
+		"modified_by":  "/v1/users/" + s.ModifiedByID.Hex(),
+		s.ResourceType: resource,
+	}
+
+	if s.LastJobID != nil {
+		switch s.ResourceType {
+		case common.ScheduleJobTemplate:
+			related["last_job"] = "/v1/jobs/" + (*s.LastJobID).Hex()
+		case common.ScheduleTerraformJobTemplate:
+			related["last_job"] = "/v1/terraform_jobs/" + (*s.LastJobID).Hex()
+		case common.ScheduleProject:
+			related["last_job"] = "/v1/project_updates/" + (*s.LastJobID).Hex()
+		}
+	}
+
+	s.Links = related
+	scheduleSummary(s)
+}
+
+func scheduleSummary(s *common.Schedule) {
+	var modified common.User
+	var created common.User
+
+	if err := db.Users().FindId(s.CreatedByID).One(&created); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"User ID":     s.CreatedByID.Hex(),
+			"Schedule":    s.Name,
+			"Schedule ID": s.ID.Hex(),
+		}).Errorln("Error while getting created by User")
+	}
+
+	if err := db.Users().FindId(s.ModifiedByID).One(&modified); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"User ID":     s.ModifiedByID.Hex(),
+			"Schedule":    s.Name,
+			"Schedule ID": s.ID.Hex(),
+		}).Errorln("Error while getting modified by User")
+	}
+
+	s.Meta = gin.H{
+		"created_by": gin.H{
+			"id":         created.ID,
+			"username":   created.Username,
+			"first_name": created.FirstName,
+			"last_name":  created.LastName,
+		},
+		"modified_by": gin.H{
+			"id":         modified.ID,
+			"username":   modified.Username,
+			"first_name": modified.FirstName,
+			"last_name":  modified.LastName,
+		},
+	}
+}
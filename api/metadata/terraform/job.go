@@ -17,6 +17,7 @@ func JobMetadata(job *terraform.Job) {
 		"labels":          "/v1/terraform_jobs/" + ID + "/labels",
 		"project":         "/v1/projects/" + job.ProjectID.Hex(),
 		"stdout":          "/v1/terraform_jobs/" + ID + "/stdout",
+		"stdout_stream":   "/v1/terraform_jobs/" + ID + "/stdout/stream",
 		"notifications":   "/v1/terraform_jobs/" + ID + "/notifications",
 		"activity_stream": "/v1/terraform_jobs/" + ID + "/activity_stream",
 		"start":           "/v1/terraform_jobs/" + ID + "/start",
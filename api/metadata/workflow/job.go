@@ -0,0 +1,74 @@
+package workflow
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/models/workflow"
+)
+
+func JobMetadata(job *workflow.Job) {
+	ID := job.ID.Hex()
+	job.Type = job.GetType()
+	related := gin.H{
+		"self":                  "/v1/workflow_jobs/" + ID,
+		"cancel":                "/v1/workflow_jobs/" + ID + "/cancel",
+		"workflow_job_template": "/v1/workflow_job_templates/" + job.WorkflowJobTemplateID.Hex(),
+		"organization":          "/v1/organizations/" + job.OrganizationID.Hex(),
+		"created_by":            "/v1/users/" + job.CreatedByID.Hex(),
+	}
+
+	// links of the jobs launched for the nodes
+	nodes := gin.H{}
+	for _, n := range job.Nodes {
+		if n.JobID == nil {
+			continue
+		}
+		switch n.ResourceType {
+		case workflow.NodeTerraformJobTemplate:
+			nodes[n.ID] = "/v1/terraform_jobs/" + n.JobID.Hex()
+		default:
+			nodes[n.ID] = "/v1/jobs/" + n.JobID.Hex()
+		}
+	}
+	related["nodes"] = nodes
+
+	job.Links = related
+
+	summary := gin.H{
+		"workflow_job_template": nil,
+		"created_by":            nil,
+	}
+
+	var template workflow.JobTemplate
+	if err := db.WorkflowJobTemplates().FindId(job.WorkflowJobTemplateID).One(&template); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Workflow Job Template ID": job.WorkflowJobTemplateID.Hex(),
+			"Workflow Job ID":          ID,
+		}).Errorln("Error while getting Workflow Job Template")
+	} else {
+		summary["workflow_job_template"] = gin.H{
+			"id":          template.ID,
+			"name":        template.Name,
+			"description": template.Description,
+		}
+	}
+
+	var created common.User
+	if err := db.Users().FindId(job.CreatedByID).One(&created); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"User ID":         job.CreatedByID.Hex(),
+			"Workflow Job ID": ID,
+		}).Errorln("Error while getting created by User")
+	} else {
+		summary["created_by"] = gin.H{
+			"id":         created.ID,
+			"username":   created.Username,
+			"first_name": created.FirstName,
+			"last_name":  created.LastName,
+		}
+	}
+
+	job.Meta = summary
+}
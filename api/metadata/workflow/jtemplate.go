@@ -0,0 +1,99 @@
+package workflow
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/models/workflow"
+)
+
+func JTemplateMetadata(jt *workflow.JobTemplate) {
+	ID := jt.ID.Hex()
+	jt.Type = jt.GetType()
+	related := gin.H{
+		"self":          "/v1/workflow_job_templates/" + ID,
+		"launch":        "/v1/workflow_job_templates/" + ID + "/launch",
+		"workflow_jobs": "/v1/workflow_job_templates/" + ID + "/workflow_jobs",
+		"organization":  "/v1/organizations/" + jt.OrganizationID.Hex(),
+		"created_by":    "/v1/users/" + jt.CreatedByID.Hex(),
+		"modified_by":   "/v1/users/" + jt.ModifiedByID.Hex(),
+	}
+
+	if jt.LastJobID != nil {
+		related["last_job"] = "/v1/workflow_jobs/" + jt.LastJobID.Hex()
+	}
+
+	jt.Links = related
+
+	summary := gin.H{
+		"organization": nil,
+		"last_job":     nil,
+		"created_by":   nil,
+		"modified_by":  nil,
+	}
+
+	var org common.Organization
+	if err := db.Organizations().FindId(jt.OrganizationID).One(&org); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Organization ID":          jt.OrganizationID.Hex(),
+			"Workflow Job Template ID": ID,
+		}).Errorln("Error while getting Organization")
+	} else {
+		summary["organization"] = gin.H{
+			"id":          org.ID,
+			"name":        org.Name,
+			"description": org.Description,
+		}
+	}
+
+	if jt.LastJobID != nil {
+		var job workflow.Job
+		if err := db.WorkflowJobs().FindId(*jt.LastJobID).One(&job); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Workflow Job ID":          jt.LastJobID.Hex(),
+				"Workflow Job Template ID": ID,
+			}).Errorln("Error while getting last Workflow Job")
+		} else {
+			summary["last_job"] = gin.H{
+				"id":       job.ID,
+				"name":     job.Name,
+				"status":   job.Status,
+				"failed":   job.Failed,
+				"finished": job.Finished,
+			}
+		}
+	}
+
+	var created common.User
+	if err := db.Users().FindId(jt.CreatedByID).One(&created); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"User ID":                  jt.CreatedByID.Hex(),
+			"Workflow Job Template ID": ID,
+		}).Errorln("Error while getting created by User")
+	} else {
+		summary["created_by"] = gin.H{
+			"id":         created.ID,
+			"username":   created.Username,
+			"first_name": created.FirstName,
+			"last_name":  created.LastName,
+		}
+	}
+
+	var modified common.User
+	if err := db.Users().FindId(jt.ModifiedByID).One(&modified); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"User ID":                  jt.ModifiedByID.Hex(),
+			"Workflow Job Template ID": ID,
+		}).Errorln("Error while getting modified by User")
+	} else {
+		summary["modified_by"] = gin.H{
+			"id":         modified.ID,
+			"username":   modified.Username,
+			"first_name": modified.FirstName,
+			"last_name":  modified.LastName,
+		}
+	}
+
+	jt.Meta = summary
+}
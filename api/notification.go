@@ -0,0 +1,159 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/pearsonappeng/tensor/api/metadata"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/models/terraform"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/rbac"
+	"github.com/pearsonappeng/tensor/util"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Keys for notification related items stored in the Gin Context
+const (
+	cNotification   = "notification"
+	cNotificationID = "notification_id"
+)
+
+type NotificationController struct{}
+
+// Middleware generates a middleware handler function that works inside of a Gin request.
+// This function takes CTXNotificationID from Gin Context and retrieves notification data from the collection
+// and store notification data under key CTXNotification in Gin Context.
+// Notifications can be read by the users that can read their notification template.
+func (ctrl NotificationController) Middleware(c *gin.Context) {
+	objectID := c.Params.ByName(cNotificationID)
+	user := c.MustGet(cUser).(common.User)
+
+	if !bson.IsObjectIdHex(objectID) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound, Message: "Notification does not exist"})
+		return
+	}
+
+	var n common.Notification
+	if err := db.Notifications().FindId(bson.ObjectIdHex(objectID)).One(&n); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound, Message: "Notification does not exist",
+			Log: logrus.Fields{
+				"Notification ID": objectID,
+				"Error":           err.Error(),
+			},
+		})
+		return
+	}
+
+	var template common.NotificationTemplate
+	if err := db.NotificationTemplates().FindId(n.NotificationTemplateID).One(&template); err != nil && !rbac.HasGlobalRead(user) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+			Message: "You don't have sufficient permissions to perform this action.",
+		})
+		return
+	}
+
+	roles := new(rbac.NotificationTemplate)
+	if !roles.Read(user, template) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+			Message: "You don't have sufficient permissions to perform this action.",
+		})
+		return
+	}
+
+	c.Set(cNotification, n)
+	c.Next()
+}
+
+// One returns the notification as a JSON object
+func (ctrl NotificationController) One(c *gin.Context) {
+	n := c.MustGet(cNotification).(common.Notification)
+	metadata.NotificationMetadata(&n)
+	c.JSON(http.StatusOK, n)
+}
+
+// All returns a JSON array of the notifications of the notification templates the user can read
+func (ctrl NotificationController) All(c *gin.Context) {
+	user := c.MustGet(cUser).(common.User)
+
+	query := bson.M{}
+	if !rbac.HasGlobalRead(user) {
+		var templates []common.NotificationTemplate
+		if err := db.NotificationTemplates().Find(nil).All(&templates); err != nil {
+			AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+				Message: "Error while getting notifications",
+				Log:     logrus.Fields{"Error": err.Error()},
+			})
+			return
+		}
+
+		roles := new(rbac.NotificationTemplate)
+		ids := []bson.ObjectId{}
+		for _, template := range templates {
+			if roles.Read(user, template) {
+				ids = append(ids, template.ID)
+			}
+		}
+		query["notification_template_id"] = bson.M{"$in": ids}
+	}
+
+	listNotifications(c, query)
+}
+
+// Notifications is a Gin handler function which returns the notifications sent for the job
+func (ctrl JobController) Notifications(c *gin.Context) {
+	job := c.MustGet(cJob).(ansible.Job)
+	listNotifications(c, bson.M{"job_id": job.ID})
+}
+
+// Notifications is a Gin handler function which returns the notifications sent for the terraform job
+func (ctrl TerraformJobController) Notifications(c *gin.Context) {
+	job := c.MustGet(cTerraformJob).(terraform.Job)
+	listNotifications(c, bson.M{"job_id": job.ID})
+}
+
+// listNotifications writes a paginated response with the notifications matching query.
+// Notifications can be filtered by status and notification_type, the most recent
+// notifications are returned first unless order_by is given
+func listNotifications(c *gin.Context, query bson.M) {
+	parser := util.NewQueryParser(c)
+	query = parser.Match([]string{"status", "notification_type"}, query)
+
+	order := parser.OrderBy()
+	if order == "" {
+		order = "-created"
+	}
+
+	var notifications []common.Notification
+	if err := db.Notifications().Find(query).Sort(order).All(&notifications); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting notifications",
+			Log:     logrus.Fields{"Error": err.Error()},
+		})
+		return
+	}
+
+	for i := range notifications {
+		metadata.NotificationMetadata(&notifications[i])
+	}
+
+	count := len(notifications)
+	pgi := util.NewPagination(c, count)
+	if pgi.HasPage() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound,
+			Message: "#" + strconv.Itoa(pgi.Page()) + " page contains no results.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		Count:    count,
+		Next:     pgi.NextPage(),
+		Previous: pgi.PreviousPage(),
+		Data:     notifications[pgi.Skip():pgi.End()],
+	})
+}
@@ -0,0 +1,489 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pearsonappeng/tensor/api/metadata"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/models/terraform"
+	"github.com/pearsonappeng/tensor/notification"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/log/activity"
+	"github.com/pearsonappeng/tensor/rbac"
+	"github.com/pearsonappeng/tensor/util"
+	"github.com/pearsonappeng/tensor/validate"
+	"gopkg.in/gin-gonic/gin.v1/binding"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Keys for notification template related items stored in the Gin Context
+const (
+	cNotificationTemplate   = "notification_template"
+	cNotificationTemplateID = "notification_template_id"
+)
+
+// Fields of job templates and organizations holding the attached notification templates
+const (
+	notificationTemplatesSuccess = "notification_templates_success"
+	notificationTemplatesError   = "notification_templates_error"
+	notificationTemplatesAny     = "notification_templates_any"
+)
+
+type NotificationTemplateController struct{}
+
+// Middleware generates a middleware handler function that works inside of a Gin request.
+// This function takes CTXNotificationTemplateID from Gin Context and retrieves notification template data
+// from the collection and store notification template data under key CTXNotificationTemplate in Gin Context
+func (ctrl NotificationTemplateController) Middleware(c *gin.Context) {
+	objectID := c.Params.ByName(cNotificationTemplateID)
+	user := c.MustGet(cUser).(common.User)
+
+	if !bson.IsObjectIdHex(objectID) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound, Message: "Notification template does not exist"})
+		return
+	}
+
+	var template common.NotificationTemplate
+	if err := db.NotificationTemplates().FindId(bson.ObjectIdHex(objectID)).One(&template); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound, Message: "Notification template does not exist",
+			Log: logrus.Fields{
+				"Notification Template ID": objectID,
+				"Error":                    err.Error(),
+			},
+		})
+		return
+	}
+
+	roles := new(rbac.NotificationTemplate)
+	switch c.Request.Method {
+	case "GET":
+		{
+			if !roles.Read(user, template) {
+				AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+					Message: "You don't have sufficient permissions to perform this action.",
+				})
+				return
+			}
+		}
+	case "POST", "PUT", "DELETE":
+		{
+			// Reject the request if the user doesn't have write permissions
+			if !roles.Write(user, template) {
+				AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+					Message: "You don't have sufficient permissions to perform this action.",
+				})
+				return
+			}
+		}
+	}
+
+	c.Set(cNotificationTemplate, template)
+	c.Next()
+}
+
+// One returns the notification template as a JSON object
+func (ctrl NotificationTemplateController) One(c *gin.Context) {
+	template := c.MustGet(cNotificationTemplate).(common.NotificationTemplate)
+	hideNotificationSecrets(&template)
+	metadata.NotificationTemplateMetadata(&template)
+	c.JSON(http.StatusOK, template)
+}
+
+// All returns a JSON array of the notification templates the user can read
+func (ctrl NotificationTemplateController) All(c *gin.Context) {
+	user := c.MustGet(cUser).(common.User)
+	roles := new(rbac.NotificationTemplate)
+	listNotificationTemplates(c, bson.M{}, func(template common.NotificationTemplate) bool {
+		return roles.Read(user, template)
+	})
+}
+
+// Create is a Gin handler function which creates a new notification template using request payload.
+// The SMTP password is stored encrypted.
+func (ctrl NotificationTemplateController) Create(c *gin.Context) {
+	user := c.MustGet(cUser).(common.User)
+
+	var req common.NotificationTemplate
+	if err := binding.JSON.Bind(c.Request, &req); err != nil {
+		AbortWithErrors(c, http.StatusBadRequest,
+			"Invalid JSON body",
+			validate.GetValidationErrors(err)...)
+		return
+	}
+
+	if !req.OrganizationExist() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Organization does not exists.",
+		})
+		return
+	}
+
+	roles := new(rbac.NotificationTemplate)
+	if !roles.Write(user, req) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+			Message: "You don't have sufficient permissions to perform this action.",
+		})
+		return
+	}
+
+	req.ID = bson.NewObjectId()
+	req.Name = strings.Trim(req.Name, " ")
+	req.Description = strings.Trim(req.Description, " ")
+	req.NotificationConfiguration.Password = util.Cipher(req.NotificationConfiguration.Password)
+	req.CreatedByID = user.ID
+	req.ModifiedByID = user.ID
+	req.Created = time.Now()
+	req.Modified = time.Now()
+
+	if err := db.NotificationTemplates().Insert(req); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while creating notification template",
+			Log:     logrus.Fields{"Notification Template ID": req.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	activity.AddActivity(activity.Create, user.ID, req, nil)
+	hideNotificationSecrets(&req)
+	metadata.NotificationTemplateMetadata(&req)
+	c.JSON(http.StatusCreated, req)
+}
+
+// Update is a Gin handler function which updates a notification template using request payload.
+// The SMTP password and the webhook header values are kept when the payload contains them hidden.
+func (ctrl NotificationTemplateController) Update(c *gin.Context) {
+	template := c.MustGet(cNotificationTemplate).(common.NotificationTemplate)
+	tmpTemplate := template
+	user := c.MustGet(cUser).(common.User)
+
+	var req common.NotificationTemplate
+	if err := binding.JSON.Bind(c.Request, &req); err != nil {
+		AbortWithErrors(c, http.StatusBadRequest,
+			"Invalid JSON body",
+			validate.GetValidationErrors(err)...)
+		return
+	}
+
+	if !req.OrganizationExist() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Organization does not exists.",
+		})
+		return
+	}
+
+	// moving the template requires write permissions on the new organization
+	roles := new(rbac.NotificationTemplate)
+	if !roles.Write(user, req) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+			Message: "You don't have sufficient permissions to perform this action.",
+		})
+		return
+	}
+
+	password := template.NotificationConfiguration.Password
+	if req.NotificationConfiguration.Password != "$encrypted$" {
+		password = util.Cipher(req.NotificationConfiguration.Password)
+	}
+
+	template.Name = strings.Trim(req.Name, " ")
+	template.Description = strings.Trim(req.Description, " ")
+	template.OrganizationID = req.OrganizationID
+	template.NotificationsType = req.NotificationsType
+	template.NotificationConfiguration = req.NotificationConfiguration
+	template.NotificationConfiguration.Password = password
+	for k, v := range template.NotificationConfiguration.Headers {
+		if stored, ok := tmpTemplate.NotificationConfiguration.Headers[k]; ok && v == "$encrypted$" {
+			template.NotificationConfiguration.Headers[k] = stored
+		}
+	}
+	template.Subject = req.Subject
+	template.ModifiedByID = user.ID
+	template.Modified = time.Now()
+
+	if err := db.NotificationTemplates().UpdateId(template.ID, template); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while updating notification template",
+			Log:     logrus.Fields{"Notification Template ID": template.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	hideNotificationSecrets(&tmpTemplate)
+	hideNotificationSecrets(&template)
+	activity.AddActivity(activity.Update, user.ID, tmpTemplate, template)
+	metadata.NotificationTemplateMetadata(&template)
+	c.JSON(http.StatusOK, template)
+}
+
+// Delete is a Gin handler function which removes a notification template
+// and detaches it from job templates and organizations
+func (ctrl NotificationTemplateController) Delete(c *gin.Context) {
+	template := c.MustGet(cNotificationTemplate).(common.NotificationTemplate)
+	user := c.MustGet(cUser).(common.User)
+
+	if err := db.NotificationTemplates().RemoveId(template.ID); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while removing notification template",
+			Log:     logrus.Fields{"Notification Template ID": template.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	detach := bson.M{"$pull": bson.M{
+		notificationTemplatesSuccess: template.ID,
+		notificationTemplatesError:   template.ID,
+		notificationTemplatesAny:     template.ID,
+	}}
+	for _, collection := range []*mgo.Collection{db.JobTemplates(), db.TerrafromJobTemplates(), db.Organizations()} {
+		if _, err := collection.UpdateAll(bson.M{}, detach); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Notification Template ID": template.ID.Hex(),
+				"Collection":               collection.Name,
+				"Error":                    err.Error(),
+			}).Errorln("Error while detaching notification template")
+		}
+	}
+
+	hideNotificationSecrets(&template)
+	activity.AddActivity(activity.Delete, user.ID, template, nil)
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// Test is a Gin handler function which sends a test notification
+// and returns the recorded notification
+func (ctrl NotificationTemplateController) Test(c *gin.Context) {
+	template := c.MustGet(cNotificationTemplate).(common.NotificationTemplate)
+
+	n := notification.Deliver(template, notification.Message{
+		Subject: "Tensor Notification Test " + template.Name,
+		Body:    "Test notification sent by the notification template " + template.Name + ".\n",
+	}, nil, "")
+
+	metadata.NotificationMetadata(&n)
+	c.JSON(http.StatusOK, n)
+}
+
+// Notifications is a Gin handler function which returns the notifications
+// sent with the notification template
+func (ctrl NotificationTemplateController) Notifications(c *gin.Context) {
+	template := c.MustGet(cNotificationTemplate).(common.NotificationTemplate)
+	listNotifications(c, bson.M{"notification_template_id": template.ID})
+}
+
+// listNotificationTemplates writes a paginated response with the notification templates matching query,
+// when filter is not nil only the notification templates accepted by filter are returned
+func listNotificationTemplates(c *gin.Context, query bson.M, filter func(common.NotificationTemplate) bool) {
+	parser := util.NewQueryParser(c)
+	query = parser.Match([]string{"notification_type"}, query)
+	query = parser.Lookups([]string{"name", "description"}, query)
+
+	dbq := db.NotificationTemplates().Find(query)
+	if order := parser.OrderBy(); order != "" {
+		dbq.Sort(order)
+	}
+
+	var templates []common.NotificationTemplate
+	iter := dbq.Iter()
+	var tmpTemplate common.NotificationTemplate
+	for iter.Next(&tmpTemplate) {
+		if filter != nil && !filter(tmpTemplate) {
+			continue
+		}
+		hideNotificationSecrets(&tmpTemplate)
+		metadata.NotificationTemplateMetadata(&tmpTemplate)
+		templates = append(templates, tmpTemplate)
+	}
+	if err := iter.Close(); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting notification templates",
+			Log:     logrus.Fields{"Error": err.Error()},
+		})
+		return
+	}
+
+	count := len(templates)
+	pgi := util.NewPagination(c, count)
+	if pgi.HasPage() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound,
+			Message: "#" + strconv.Itoa(pgi.Page()) + " page contains no results.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		Count:    count,
+		Next:     pgi.NextPage(),
+		Previous: pgi.PreviousPage(),
+		Data:     templates[pgi.Skip():pgi.End()],
+	})
+}
+
+// listAttachedNotificationTemplates writes the notification templates of ids
+func listAttachedNotificationTemplates(c *gin.Context, ids []bson.ObjectId) {
+	if ids == nil {
+		ids = []bson.ObjectId{}
+	}
+	listNotificationTemplates(c, bson.M{"_id": bson.M{"$in": ids}}, nil)
+}
+
+// attachNotificationTemplate attaches the notification template of the request payload to,
+// or detaches it from, the field of the resource stored in collection
+func attachNotificationTemplate(c *gin.Context, collection *mgo.Collection, resource interface{}, resourceID bson.ObjectId, field string) {
+	user := c.MustGet(cUser).(common.User)
+
+	var req common.NotificationTemplateAttachment
+	if err := binding.JSON.Bind(c.Request, &req); err != nil {
+		AbortWithErrors(c, http.StatusBadRequest,
+			"Invalid JSON body",
+			validate.GetValidationErrors(err)...)
+		return
+	}
+
+	var template common.NotificationTemplate
+	if err := db.NotificationTemplates().FindId(req.ID).One(&template); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Notification template does not exists.",
+		})
+		return
+	}
+
+	roles := new(rbac.NotificationTemplate)
+	if !roles.Read(user, template) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+			Message: "You don't have sufficient permissions to perform this action.",
+		})
+		return
+	}
+
+	operation := activity.Associate
+	update := bson.M{"$addToSet": bson.M{field: template.ID}}
+	if req.Disassociate {
+		operation = activity.Disassociate
+		update = bson.M{"$pull": bson.M{field: template.ID}}
+	}
+
+	if err := collection.UpdateId(resourceID, update); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while updating notification templates",
+			Log:     logrus.Fields{"Resource ID": resourceID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	hideNotificationSecrets(&template)
+	activity.AddActivity(operation, user.ID, template, resource)
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// attachedNotificationTemplates returns the attached notification templates of field
+func attachedNotificationTemplates(field string, success, failed, any []bson.ObjectId) []bson.ObjectId {
+	switch field {
+	case notificationTemplatesSuccess:
+		return success
+	case notificationTemplatesError:
+		return failed
+	}
+	return any
+}
+
+// AttachedNotificationTemplates returns a Gin handler function which returns the
+// notification templates attached to the job template in field
+func (ctrl JobTemplateController) AttachedNotificationTemplates(field string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		template := c.MustGet(cJobTemplate).(ansible.JobTemplate)
+		listAttachedNotificationTemplates(c, attachedNotificationTemplates(field,
+			template.NotificationTemplatesSuccess, template.NotificationTemplatesError, template.NotificationTemplatesAny))
+	}
+}
+
+// AttachNotificationTemplate returns a Gin handler function which attaches a
+// notification template to the job template in field, or detaches it
+func (ctrl JobTemplateController) AttachNotificationTemplate(field string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		template := c.MustGet(cJobTemplate).(ansible.JobTemplate)
+		user := c.MustGet(cUser).(common.User)
+
+		roles := new(rbac.JobTemplate)
+		if !roles.Write(user, template) {
+			AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+				Message: "You don't have sufficient permissions to perform this action.",
+			})
+			return
+		}
+
+		attachNotificationTemplate(c, db.JobTemplates(), template, template.ID, field)
+	}
+}
+
+// AttachedNotificationTemplates returns a Gin handler function which returns the
+// notification templates attached to the terraform job template in field
+func (ctrl TJobTmplController) AttachedNotificationTemplates(field string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		template := c.MustGet(cTerraformJobTemplate).(terraform.JobTemplate)
+		listAttachedNotificationTemplates(c, attachedNotificationTemplates(field,
+			template.NotificationTemplatesSuccess, template.NotificationTemplatesError, template.NotificationTemplatesAny))
+	}
+}
+
+// AttachNotificationTemplate returns a Gin handler function which attaches a
+// notification template to the terraform job template in field, or detaches it
+func (ctrl TJobTmplController) AttachNotificationTemplate(field string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		template := c.MustGet(cTerraformJobTemplate).(terraform.JobTemplate)
+		user := c.MustGet(cUser).(common.User)
+
+		roles := new(rbac.TerraformJobTemplate)
+		if !roles.Write(user, template) {
+			AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+				Message: "You don't have sufficient permissions to perform this action.",
+			})
+			return
+		}
+
+		attachNotificationTemplate(c, db.TerrafromJobTemplates(), template, template.ID, field)
+	}
+}
+
+// NotificationTemplates is a Gin handler function which returns the
+// notification templates that belong to the organization
+func (ctrl OrganizationController) NotificationTemplates(c *gin.Context) {
+	organization := c.MustGet(cOrganization).(common.Organization)
+	listNotificationTemplates(c, bson.M{"organization_id": organization.ID}, nil)
+}
+
+// AttachedNotificationTemplates returns a Gin handler function which returns the
+// notification templates attached to the organization in field
+func (ctrl OrganizationController) AttachedNotificationTemplates(field string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		organization := c.MustGet(cOrganization).(common.Organization)
+		listAttachedNotificationTemplates(c, attachedNotificationTemplates(field,
+			organization.NotificationTemplatesSuccess, organization.NotificationTemplatesError, organization.NotificationTemplatesAny))
+	}
+}
+
+// AttachNotificationTemplate returns a Gin handler function which attaches a
+// notification template to the organization in field, or detaches it
+func (ctrl OrganizationController) AttachNotificationTemplate(field string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		organization := c.MustGet(cOrganization).(common.Organization)
+		user := c.MustGet(cUser).(common.User)
+
+		roles := new(rbac.Organization)
+		if !roles.Write(user, organization) {
+			AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+				Message: "You don't have sufficient permissions to perform this action.",
+			})
+			return
+		}
+
+		attachNotificationTemplate(c, db.Organizations(), organization, organization.ID, field)
+	}
+}
@@ -79,8 +79,20 @@ func (ctrl OrganizationController) Middleware(c *gin.Context) {
 }
 
 // GetOrganization is a Gin handler function which returns the organization as a JSON object
+// including the current use of its quotas
 func (ctrl OrganizationController) One(c *gin.Context) {
 	organization := c.MustGet(cOrganization).(common.Organization)
+
+	usage, err := organization.GetUsage()
+	if err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting organization usage",
+			Log:     logrus.Fields{"Organization ID": organization.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+	organization.Usage = &usage
+
 	metadata.OrganizationMetadata(&organization)
 	c.JSON(http.StatusOK, organization)
 }
@@ -340,6 +352,13 @@ func (ctrl OrganizationController) Update(c *gin.Context) {
 	// trim strings white space
 	organization.Name = strings.Trim(req.Name, " ")
 	organization.Description = strings.Trim(req.Description, " ")
+	// quotas are set by superusers, they are kept when other users update the organization
+	if user.IsSuperUser {
+		organization.MaxHosts = req.MaxHosts
+		organization.MaxConcurrentJobs = req.MaxConcurrentJobs
+		organization.MaxJobTimeout = req.MaxJobTimeout
+	}
+	organization.KerberosRealms = req.KerberosRealms
 	organization.Modified = time.Now()
 	organization.ModifiedByID = user.ID
 
@@ -685,3 +704,31 @@ func (ctrl OrganizationController) ObjectRoles(c *gin.Context) {
 	})
 
 }
+
+// checkHostQuota reports whether the number of hosts can be added to the inventories
+// of the organization, the request is aborted with 403 if it exceeds the host quota
+func checkHostQuota(c *gin.Context, organizationID bson.ObjectId, add int) bool {
+	var organization common.Organization
+	if err := db.Organizations().FindId(organizationID).One(&organization); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting organization",
+			Log:     logrus.Fields{"Organization ID": organizationID.Hex(), "Error": err.Error()},
+		})
+		return false
+	}
+
+	if err := organization.CheckHosts(add); err != nil {
+		if _, ok := err.(common.QuotaError); ok {
+			AbortWithError(LogFields{Context: c, Status: http.StatusForbidden,
+				Message: err.Error(),
+			})
+			return false
+		}
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting hosts",
+			Log:     logrus.Fields{"Organization ID": organizationID.Hex(), "Error": err.Error()},
+		})
+		return false
+	}
+	return true
+}
@@ -224,10 +224,10 @@ func (ctrl ProjectController) Create(c *gin.Context) {
 	}
 
 	// before set metadata update the project
-	if sysJobID, err := sync.UpdateProject(req); err != nil {
+	if _, err := sync.UpdateProject(req); err != nil {
 		logrus.WithFields(logrus.Fields{
-			"SystemJob ID": sysJobID.Job.ID.Hex(),
-			"Error":        err.Error(),
+			"Project ID": req.ID.Hex(),
+			"Error":      err.Error(),
 		}).Errorln("Error while scm update")
 	}
 
@@ -321,10 +321,10 @@ func (ctrl ProjectController) Update(c *gin.Context) {
 	}
 
 	// before set metadata update the project
-	if sysJobID, err := sync.UpdateProject(project); err != nil {
+	if _, err := sync.UpdateProject(project); err != nil {
 		logrus.WithFields(logrus.Fields{
-			"SystemJob ID": sysJobID.Job.ID.Hex(),
-			"Error":        err.Error(),
+			"Project ID": project.ID.Hex(),
+			"Error":      err.Error(),
 		}).Errorln("Error while scm update")
 	}
 
@@ -498,7 +498,7 @@ func (ctrl ProjectController) ProjectUpdates(c *gin.Context) {
 	match = parser.Match([]string{"status", "type", "failed"}, match)
 	match = parser.Lookups([]string{"id", "name", "labels"}, match)
 	match["job_type"] = "update_job"
-	query := db.Jobs().Find(match)
+	query := db.Jobs().Find(match).Select(bson.M{"result_stdout": 0})
 	if order := parser.OrderBy(); order != "" {
 		query.Sort(order)
 	}
@@ -574,6 +574,10 @@ func (ctrl ProjectController) SCMUpdate(c *gin.Context) {
 	updateID, err := sync.UpdateProject(project)
 
 	if err != nil {
+		if isQueueError(err) {
+			AbortWithError(LogFields{Context: c, Status: launchStatus(err), Message: err.Error()})
+			return
+		}
 		AbortWithError(LogFields{Context: c, Status: http.StatusMethodNotAllowed,
 			Message: "SCM Update failed",
 		})
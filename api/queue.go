@@ -0,0 +1,344 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/queue"
+	"github.com/pearsonappeng/tensor/rbac"
+	"github.com/pearsonappeng/tensor/util"
+	"github.com/pearsonappeng/tensor/validate"
+	"github.com/pearsonappeng/tensor/worker"
+	"gopkg.in/gin-gonic/gin.v1/binding"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// durationSamples is the number of finished jobs the duration of a job is estimated from
+const durationSamples = 20
+
+// Keys for queue related items stored in the Gin Context
+const (
+	cQueueName = "queue_name"
+)
+
+type QueueController struct{}
+
+// Middleware generates a middleware handler function that works inside of a Gin request.
+// This function checks that cQueueName is the name of a job queue and that the user
+// can read, or for POST requests write, every job
+func (ctrl QueueController) Middleware(c *gin.Context) {
+	name := c.Params.ByName(cQueueName)
+	user := c.MustGet(cUser).(common.User)
+
+	if name != queue.Ansible && name != queue.Terraform {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound, Message: "Queue does not exist"})
+		return
+	}
+
+	switch c.Request.Method {
+	case "GET":
+		{
+			if !rbac.HasGlobalRead(user) {
+				AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+					Message: "You don't have sufficient permissions to perform this action.",
+				})
+				return
+			}
+		}
+	case "POST":
+		{
+			if !rbac.HasGlobalWrite(user) {
+				AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+					Message: "You don't have sufficient permissions to perform this action.",
+				})
+				return
+			}
+		}
+	}
+
+	c.Set(cQueueName, name)
+	c.Next()
+}
+
+// DeadLetters returns the jobs of the dead letter queue of the queue as a JSON array.
+// The credentials of the jobs are not included, only the id, name and type of the job.
+func (ctrl QueueController) DeadLetters(c *gin.Context) {
+	name := c.MustGet(cQueueName).(string)
+
+	letters, err := queue.DeadLetters(name)
+	if err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusServiceUnavailable,
+			Message: "Error while getting dead letters",
+			Log:     logrus.Fields{"Queue": name, "Error": err.Error()},
+		})
+		return
+	}
+
+	data := []gin.H{}
+	for _, letter := range letters {
+		data = append(data, gin.H{
+			"id":        letter.ID,
+			"queue":     letter.Queue,
+			"reason":    letter.Reason,
+			"published": letter.Published,
+			"job":       deadLetterJob(letter.Body),
+		})
+	}
+
+	count := len(data)
+	pgi := util.NewPagination(c, count)
+	if pgi.HasPage() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound,
+			Message: "#" + strconv.Itoa(pgi.Page()) + " page contains no results.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		Count:    count,
+		Next:     pgi.NextPage(),
+		Previous: pgi.PreviousPage(),
+		Data:     data[pgi.Skip():pgi.End()],
+	})
+}
+
+// Requeue publishes dead lettered jobs to the queue again. The request body may
+// contain the ids of the dead letters to requeue, all of them are requeued otherwise.
+func (ctrl QueueController) Requeue(c *gin.Context) {
+	name := c.MustGet(cQueueName).(string)
+
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	if err := binding.JSON.Bind(c.Request, &req); err != nil && err != io.EOF {
+		AbortWithErrors(c, http.StatusBadRequest,
+			"Invalid JSON body",
+			validate.GetValidationErrors(err)...)
+		return
+	}
+
+	count, err := queue.Requeue(name, req.IDs)
+	if err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusServiceUnavailable,
+			Message: "Error while requeueing dead letters",
+			Log:     logrus.Fields{"Queue": name, "Requeued": count, "Error": err.Error()},
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"requeued": count})
+}
+
+// deadLetterJob returns the id, name and type of the job of a dead letter
+func deadLetterJob(body []byte) gin.H {
+	type ref struct {
+		ID      string `json:"id"`
+		Name    string `json:"name"`
+		JobType string `json:"job_type"`
+	}
+	var job struct {
+		Job          ref
+		AdHocCommand *ref
+	}
+
+	if err := json.Unmarshal(body, &job); err != nil {
+		return nil
+	}
+	if job.AdHocCommand != nil {
+		return gin.H{"id": job.AdHocCommand.ID, "name": job.AdHocCommand.Name, "type": "ad_hoc_command"}
+	}
+	return gin.H{"id": job.Job.ID, "name": job.Job.Name, "type": job.Job.JobType}
+}
+
+// Pending returns the jobs waiting in the queue as a JSON array, in the order they are
+// expected to start. Organizations take turns, the jobs of an organization start by
+// priority and then by age. Every job has its position in the queue and an estimated
+// start, which assumes that the queued jobs take as long as the recently finished
+// ones. The estimated start is null when no worker consumes the queue.
+func (ctrl QueueController) Pending(c *gin.Context) {
+	name := c.MustGet(cQueueName).(string)
+
+	queued := map[string]queuedJob{}
+	pending := []queue.Pending{}
+	running := map[string]int{}
+	for kind, jobs := range queueCollections(name) {
+		var job queuedJob
+		iter := jobs.Find(bson.M{"status": bson.M{"$in": common.ActiveJobStatus}}).Iter()
+		for iter.Next(&job) {
+			if kind != "" {
+				job.JobType = kind
+			}
+			group := job.OrganizationID.Hex()
+			if job.WorkerID != nil {
+				running[group]++
+				continue
+			}
+			queued[job.ID.Hex()] = job
+			pending = append(pending, queue.Pending{
+				ID:        job.ID.Hex(),
+				Options:   queue.Options{Priority: job.Priority, Group: group},
+				Published: job.Created,
+			})
+		}
+		if err := iter.Close(); err != nil {
+			AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+				Message: "Error while getting pending jobs",
+				Log:     logrus.Fields{"Queue": name, "Error": err.Error()},
+			})
+			return
+		}
+	}
+
+	workers, busy, err := queueCapacity(name)
+	if err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting workers",
+			Log:     logrus.Fields{"Queue": name, "Error": err.Error()},
+		})
+		return
+	}
+
+	duration, err := jobDuration(name)
+	if err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting finished jobs",
+			Log:     logrus.Fields{"Queue": name, "Error": err.Error()},
+		})
+		return
+	}
+
+	now := time.Now()
+	data := []gin.H{}
+	for i, p := range queue.Order(pending, running) {
+		job := queued[p.ID]
+		data = append(data, gin.H{
+			"position":        i + 1,
+			"id":              job.ID,
+			"name":            job.Name,
+			"type":            job.JobType,
+			"status":          job.Status,
+			"priority":        job.Priority,
+			"organization":    job.OrganizationID,
+			"created":         job.Created,
+			"estimated_start": estimatedStart(now, i, workers, busy, duration),
+		})
+	}
+
+	count := len(data)
+	pgi := util.NewPagination(c, count)
+	if pgi.HasPage() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound,
+			Message: "#" + strconv.Itoa(pgi.Page()) + " page contains no results.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		Count:    count,
+		Next:     pgi.NextPage(),
+		Previous: pgi.PreviousPage(),
+		Data:     data[pgi.Skip():pgi.End()],
+	})
+}
+
+// queuedJob holds the fields of a job that are needed to schedule it
+type queuedJob struct {
+	ID             bson.ObjectId  `bson:"_id"`
+	Name           string         `bson:"name"`
+	JobType        string         `bson:"job_type"`
+	Status         string         `bson:"status"`
+	Priority       uint8          `bson:"priority"`
+	OrganizationID bson.ObjectId  `bson:"organization_id,omitempty"`
+	WorkerID       *bson.ObjectId `bson:"worker_id,omitempty"`
+	Created        time.Time      `bson:"created"`
+	Started        time.Time      `bson:"started"`
+	Finished       time.Time      `bson:"finished"`
+}
+
+// queueCollections returns the collections of the jobs published to the queue by
+// the type of their jobs, the job_type of the job is used for an empty type
+func queueCollections(name string) map[string]*mgo.Collection {
+	if name == queue.Terraform {
+		return map[string]*mgo.Collection{"terraform_job": db.TerrafromJobs()}
+	}
+	return map[string]*mgo.Collection{"": db.Jobs(), "ad_hoc_command": db.AdHocCommands()}
+}
+
+// queueCapacity returns the number of workers of the active tensord
+// processes that consume the queue and how many of them are busy
+func queueCapacity(name string) (workers int, busy int, err error) {
+	active, err := worker.Active()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, w := range active {
+		for _, q := range w.Queues {
+			if q.Queue == name {
+				workers += q.Workers
+				busy += q.Busy
+			}
+		}
+	}
+	return
+}
+
+// jobDuration returns the average duration of the recently finished jobs of the queue
+func jobDuration(name string) (time.Duration, error) {
+	var total time.Duration
+	count := 0
+	for _, jobs := range queueCollections(name) {
+		var finished []queuedJob
+		if err := jobs.Find(bson.M{
+			"status":  bson.M{"$in": []string{"successful", "failed"}},
+			"started": bson.M{"$gt": time.Time{}},
+		}).Sort("-finished").Limit(durationSamples).All(&finished); err != nil {
+			return 0, err
+		}
+
+		for _, job := range finished {
+			if job.Finished.After(job.Started) {
+				total += job.Finished.Sub(job.Started)
+				count++
+			}
+		}
+	}
+
+	if count == 0 {
+		return 0, nil
+	}
+	return total / time.Duration(count), nil
+}
+
+// estimatedStart returns when the job at the position of the queue is expected to
+// start. The idle workers take the first jobs, every following round of workers
+// starts after the duration of a job. It returns nil when no worker consumes the
+// queue or the duration of a job is unknown.
+func estimatedStart(now time.Time, position int, workers int, busy int, duration time.Duration) *time.Time {
+	if workers <= 0 {
+		return nil
+	}
+
+	idle := workers - busy
+	if idle < 0 {
+		idle = 0
+	}
+	if position < idle {
+		return &now
+	}
+	if duration <= 0 {
+		return nil
+	}
+
+	rounds := (position-idle)/workers + 1
+	start := now.Add(time.Duration(rounds) * duration)
+	return &start
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimatedStart(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+
+	// two of four workers are idle
+	assert.Equal(now, *estimatedStart(now, 1, 4, 2, time.Minute))
+	assert.Equal(now.Add(time.Minute), *estimatedStart(now, 2, 4, 2, time.Minute))
+	assert.Equal(now.Add(time.Minute), *estimatedStart(now, 5, 4, 2, time.Minute))
+	assert.Equal(now.Add(2*time.Minute), *estimatedStart(now, 6, 4, 2, time.Minute))
+
+	assert.Nil(estimatedStart(now, 0, 0, 0, time.Minute))
+	assert.Nil(estimatedStart(now, 4, 4, 4, 0))
+}
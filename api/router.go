@@ -46,6 +46,7 @@ func Route(r *gin.Engine) {
 			users := new(UserController)
 			v1.GET("/refresh_token", jwt.HeaderAuthMiddleware.RefreshHandler)
 			v1.GET("/config", getSystemInfo)
+			v1.GET("/workers", GetWorkers)
 			v1.GET("/dashboard", dashboard.GetInfo)
 			v1.GET("/me", users.One)
 
@@ -67,11 +68,16 @@ func Route(r *gin.Engine) {
 					organization.GET("/teams", ctrl.GetTeams)
 					organization.GET("/credentials", ctrl.GetCredentials)
 					organization.GET("/object_roles", ctrl.ObjectRoles)
-					organization.GET("/access_list", notImplemented)                    //TODO: implement
-					organization.GET("/notification_templates_error", notImplemented)   //TODO: implement
-					organization.GET("/notification_templates_success", notImplemented) //TODO: implement
-					organization.GET("/notification_templates", notImplemented)         //TODO: implement
-					organization.GET("/notification_templates_any", notImplemented)     //TODO: implement
+					organization.GET("/access_list", notImplemented) //TODO: implement
+					organization.GET("/notification_templates", ctrl.NotificationTemplates)
+					organization.GET("/notification_templates_error", ctrl.AttachedNotificationTemplates(notificationTemplatesError))
+					organization.POST("/notification_templates_error", ctrl.AttachNotificationTemplate(notificationTemplatesError))
+					organization.GET("/notification_templates_success", ctrl.AttachedNotificationTemplates(notificationTemplatesSuccess))
+					organization.POST("/notification_templates_success", ctrl.AttachNotificationTemplate(notificationTemplatesSuccess))
+					organization.GET("/notification_templates_any", ctrl.AttachedNotificationTemplates(notificationTemplatesAny))
+					organization.POST("/notification_templates_any", ctrl.AttachNotificationTemplate(notificationTemplatesAny))
+					organization.GET("/inventory_scripts", ctrl.InventoryScripts)
+					organization.GET("/workflow_job_templates", ctrl.WorkflowJobTemplates)
 				}
 			}
 
@@ -114,7 +120,8 @@ func Route(r *gin.Engine) {
 					project.POST("/update", ctrl.SCMUpdate)
 					project.GET("/project_updates", ctrl.ProjectUpdates)
 					project.GET("/object_roles", ctrl.ObjectRoles)
-					project.GET("/schedules", notImplemented) //TODO: implement
+					project.GET("/schedules", ctrl.Schedules)
+					project.POST("/schedules", ctrl.CreateSchedule)
 				}
 			}
 
@@ -175,8 +182,10 @@ func Route(r *gin.Engine) {
 					inventory.GET("/groups", ctrl.Groups)
 					inventory.GET("/activity_stream", ctrl.ActivityStream)
 					inventory.GET("/object_roles", ctrl.ObjectRoles)
-					inventory.GET("/tree", ctrl.Tree)                   //TODO: implement
-					inventory.GET("/inventory_sources", notImplemented) //TODO: implement
+					inventory.GET("/ad_hoc_commands", ctrl.AdHocCommands)
+					inventory.POST("/ad_hoc_commands", ctrl.CreateAdHocCommand)
+					inventory.GET("/tree", ctrl.Tree) //TODO: implement
+					inventory.GET("/inventory_sources", ctrl.InventorySources)
 				}
 			}
 
@@ -194,9 +203,41 @@ func Route(r *gin.Engine) {
 					host.GET("/variable_data", ctrl.VariableData)
 					host.GET("/groups", ctrl.Groups)
 					host.GET("/all_groups", ctrl.AllGroups)
-					host.GET("/job_host_summaries", notImplemented) //TODO: implement
-					host.GET("/job_events", notImplemented)         //TODO: implement
-					host.GET("/inventory_sources", notImplemented)  //TODO: implement
+					host.GET("/job_host_summaries", ctrl.JobHostSummaries)
+					host.GET("/job_events", ctrl.JobEvents)
+					host.GET("/inventory_sources", ctrl.InventorySources)
+				}
+			}
+
+			inventorySources := v1.Group("/inventory_sources")
+			{
+				ctrl := new(InventorySourceController)
+				inventorySources.GET("", ctrl.All)
+				inventorySources.POST("", ctrl.Create)
+				source := inventorySources.Group("/:inventory_source_id", ctrl.Middleware)
+				{
+					source.GET("", ctrl.One)
+					source.PUT("", ctrl.Update)
+					source.DELETE("", ctrl.Delete)
+					source.GET("/update", ctrl.UpdateInfo)
+					source.POST("/update", ctrl.LaunchUpdate)
+					source.GET("/inventory_updates", ctrl.InventoryUpdates)
+					source.GET("/hosts", ctrl.Hosts)
+					source.GET("/groups", ctrl.Groups)
+				}
+			}
+
+			inventoryScripts := v1.Group("/inventory_scripts")
+			{
+				ctrl := new(InventoryScriptController)
+				inventoryScripts.GET("", ctrl.All)
+				inventoryScripts.POST("", ctrl.Create)
+				script := inventoryScripts.Group("/:inventory_script_id", ctrl.Middleware)
+				{
+					script.GET("", ctrl.One)
+					script.PUT("", ctrl.Update)
+					script.DELETE("", ctrl.Delete)
+					script.GET("/inventory_sources", ctrl.InventorySources)
 				}
 			}
 
@@ -217,7 +258,7 @@ func Route(r *gin.Engine) {
 					group.GET("/all_hosts", notImplemented)          //TODO: implement
 					group.GET("/hosts", notImplemented)              //TODO: implement
 					group.GET("/children", notImplemented)           //TODO: implement
-					group.GET("/job_host_summaries", notImplemented) //TODO: implement
+					group.GET("/job_host_summaries", ctrl.JobHostSummaries)
 				}
 			}
 
@@ -237,10 +278,14 @@ func Route(r *gin.Engine) {
 					template.GET("/launch", ctrl.LaunchInfo)
 					template.POST("/launch", ctrl.Launch)
 					template.GET("/activity_stream", ctrl.ActivityStream)
-					template.GET("/schedules", notImplemented)                      //TODO: implement
-					template.GET("/notification_templates_error", notImplemented)   //TODO: implement
-					template.GET("/notification_templates_success", notImplemented) //TODO: implement
-					template.GET("/notification_templates_any", notImplemented)     //TODO: implement
+					template.GET("/schedules", ctrl.Schedules)
+					template.POST("/schedules", ctrl.CreateSchedule)
+					template.GET("/notification_templates_error", ctrl.AttachedNotificationTemplates(notificationTemplatesError))
+					template.POST("/notification_templates_error", ctrl.AttachNotificationTemplate(notificationTemplatesError))
+					template.GET("/notification_templates_success", ctrl.AttachedNotificationTemplates(notificationTemplatesSuccess))
+					template.POST("/notification_templates_success", ctrl.AttachNotificationTemplate(notificationTemplatesSuccess))
+					template.GET("/notification_templates_any", ctrl.AttachedNotificationTemplates(notificationTemplatesAny))
+					template.POST("/notification_templates_any", ctrl.AttachNotificationTemplate(notificationTemplatesAny))
 				}
 			}
 
@@ -251,16 +296,20 @@ func Route(r *gin.Engine) {
 				job := ansibleJobs.Group("/:job_id", ctrl.Middleware)
 				{
 					job.GET("", ctrl.One)
-					job.GET("/cancel", ctrl.CancelInfo) //TODO: implement
-					job.POST("/cancel", ctrl.Cancel)    //TODO: implement
+					job.GET("/cancel", ctrl.CancelInfo)
+					job.POST("/cancel", ctrl.Cancel)
 					job.GET("/stdout", ctrl.StdOut)
-					job.GET("/job_tasks", notImplemented)       //TODO: implement
-					job.GET("/job_plays", notImplemented)       //TODO: implement
-					job.GET("/job_events", notImplemented)      //TODO: implement
-					job.GET("/notifications", notImplemented)   //TODO: implement
+					job.GET("/stdout/stream", ctrl.StdOutStream)
+					job.GET("/job_tasks", ctrl.Tasks)
+					job.GET("/job_plays", ctrl.Plays)
+					job.GET("/job_events", ctrl.Events)
+					job.GET("/job_host_summaries", ctrl.HostSummaries)
+					job.POST("/job_events", ctrl.CreateEvent)
+					job.GET("/notifications", ctrl.Notifications)
 					job.GET("/activity_stream", notImplemented) //TODO: implement
 					job.GET("/start", notImplemented)           //TODO: implement
-					job.GET("/relaunch", notImplemented)        //TODO: implement
+					job.GET("/relaunch", ctrl.RelaunchInfo)
+					job.POST("/relaunch", ctrl.Relaunch)
 				}
 			}
 
@@ -280,10 +329,14 @@ func Route(r *gin.Engine) {
 					template.POST("/launch", ctrl.Launch)
 					template.GET("/activity_stream", ctrl.ActivityStream)
 					template.GET("/object_roles", ctrl.ObjectRoles)
-					template.GET("/schedules", notImplemented)                      //TODO: implement
-					template.GET("/notification_templates_error", notImplemented)   //TODO: implement
-					template.GET("/notification_templates_success", notImplemented) //TODO: implement
-					template.GET("/notification_templates_any", notImplemented)     //TODO: implement
+					template.GET("/schedules", ctrl.Schedules)
+					template.POST("/schedules", ctrl.CreateSchedule)
+					template.GET("/notification_templates_error", ctrl.AttachedNotificationTemplates(notificationTemplatesError))
+					template.POST("/notification_templates_error", ctrl.AttachNotificationTemplate(notificationTemplatesError))
+					template.GET("/notification_templates_success", ctrl.AttachedNotificationTemplates(notificationTemplatesSuccess))
+					template.POST("/notification_templates_success", ctrl.AttachNotificationTemplate(notificationTemplatesSuccess))
+					template.GET("/notification_templates_any", ctrl.AttachedNotificationTemplates(notificationTemplatesAny))
+					template.POST("/notification_templates_any", ctrl.AttachNotificationTemplate(notificationTemplatesAny))
 				}
 			}
 
@@ -297,10 +350,107 @@ func Route(r *gin.Engine) {
 					job.GET("/cancel", ctrl.CancelInfo)
 					job.POST("/cancel", ctrl.Cancel)
 					job.GET("/stdout", ctrl.StdOut)
-					job.GET("/notifications", notImplemented)   //TODO: implement
+					job.GET("/stdout/stream", ctrl.StdOutStream)
+					job.GET("/notifications", ctrl.Notifications)
 					job.GET("/activity_stream", notImplemented) //TODO: implement
 					job.GET("/start", notImplemented)           //TODO: implement
-					job.GET("/relaunch", notImplemented)        //TODO: implement
+					job.GET("/relaunch", ctrl.RelaunchInfo)
+					job.POST("/relaunch", ctrl.Relaunch)
+				}
+			}
+
+			workflowJobTemplates := v1.Group("/workflow_job_templates")
+			{
+				ctrl := new(WorkflowJobTemplateController)
+				workflowJobTemplates.GET("", ctrl.All)
+				workflowJobTemplates.POST("", ctrl.Create)
+				template := workflowJobTemplates.Group("/:workflow_job_template_id", ctrl.Middleware)
+				{
+					template.GET("", ctrl.One)
+					template.PUT("", ctrl.Update)
+					template.DELETE("", ctrl.Delete)
+					template.GET("/launch", ctrl.LaunchInfo)
+					template.POST("/launch", ctrl.Launch)
+					template.GET("/workflow_jobs", ctrl.WorkflowJobs)
+				}
+			}
+
+			workflowJobs := v1.Group("/workflow_jobs")
+			{
+				ctrl := new(WorkflowJobController)
+				workflowJobs.GET("", ctrl.All)
+				job := workflowJobs.Group("/:workflow_job_id", ctrl.Middleware)
+				{
+					job.GET("", ctrl.One)
+					job.DELETE("", ctrl.Delete)
+					job.GET("/cancel", ctrl.CancelInfo)
+					job.POST("/cancel", ctrl.Cancel)
+				}
+			}
+
+			queues := v1.Group("/queues")
+			{
+				ctrl := new(QueueController)
+				q := queues.Group("/:queue_name", ctrl.Middleware)
+				{
+					q.GET("/pending", ctrl.Pending)
+					q.GET("/dead_letters", ctrl.DeadLetters)
+					q.POST("/dead_letters/requeue", ctrl.Requeue)
+				}
+			}
+
+			adHocCommands := v1.Group("/ad_hoc_commands")
+			{
+				ctrl := new(AdHocCommandController)
+				adHocCommands.GET("", ctrl.All)
+				adHocCommands.POST("", ctrl.Create)
+				command := adHocCommands.Group("/:ad_hoc_command_id", ctrl.Middleware)
+				{
+					command.GET("", ctrl.One)
+					command.DELETE("", ctrl.Delete)
+					command.GET("/cancel", ctrl.CancelInfo)
+					command.POST("/cancel", ctrl.Cancel)
+					command.GET("/stdout", ctrl.StdOut)
+					command.GET("/stdout/stream", ctrl.StdOutStream)
+					command.GET("/relaunch", ctrl.RelaunchInfo)
+					command.POST("/relaunch", ctrl.Relaunch)
+				}
+			}
+
+			notificationTemplates := v1.Group("/notification_templates")
+			{
+				ctrl := new(NotificationTemplateController)
+				notificationTemplates.GET("", ctrl.All)
+				notificationTemplates.POST("", ctrl.Create)
+				template := notificationTemplates.Group("/:notification_template_id", ctrl.Middleware)
+				{
+					template.GET("", ctrl.One)
+					template.PUT("", ctrl.Update)
+					template.DELETE("", ctrl.Delete)
+					template.POST("/test", ctrl.Test)
+					template.GET("/notifications", ctrl.Notifications)
+				}
+			}
+
+			notifications := v1.Group("/notifications")
+			{
+				ctrl := new(NotificationController)
+				notifications.GET("", ctrl.All)
+				notification := notifications.Group("/:notification_id", ctrl.Middleware)
+				{
+					notification.GET("", ctrl.One)
+				}
+			}
+
+			schedules := v1.Group("/schedules")
+			{
+				ctrl := new(ScheduleController)
+				schedules.GET("", ctrl.All)
+				schedule := schedules.Group("/:schedule_id", ctrl.Middleware)
+				{
+					schedule.GET("", ctrl.One)
+					schedule.PUT("", ctrl.Update)
+					schedule.DELETE("", ctrl.Delete)
 				}
 			}
 		}
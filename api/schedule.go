@@ -0,0 +1,358 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pearsonappeng/tensor/api/metadata"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/models/terraform"
+	"github.com/pearsonappeng/tensor/scheduler"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/log/activity"
+	"github.com/pearsonappeng/tensor/rbac"
+	"github.com/pearsonappeng/tensor/util"
+	"github.com/pearsonappeng/tensor/validate"
+	"gopkg.in/gin-gonic/gin.v1/binding"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Keys for schedule related items stored in the Gin Context
+const (
+	cSchedule   = "schedule"
+	cScheduleID = "schedule_id"
+)
+
+type ScheduleController struct{}
+
+// Middleware generates a middleware handler function that works inside of a Gin request.
+// This function takes CTXScheduleID from Gin Context and retrieves schedule data from the collection
+// and store schedule data under key CTXSchedule in Gin Context.
+// Permissions of a schedule are the permissions of the resource it launches.
+func (ctrl ScheduleController) Middleware(c *gin.Context) {
+	objectID := c.Params.ByName(cScheduleID)
+	user := c.MustGet(cUser).(common.User)
+
+	if !bson.IsObjectIdHex(objectID) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound, Message: "Schedule does not exist"})
+		return
+	}
+
+	var schedule common.Schedule
+	if err := db.Schedules().FindId(bson.ObjectIdHex(objectID)).One(&schedule); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound, Message: "Schedule does not exist",
+			Log: logrus.Fields{
+				"Schedule ID": objectID,
+				"Error":       err.Error(),
+			},
+		})
+		return
+	}
+
+	switch c.Request.Method {
+	case "GET":
+		{
+			if !scheduleAccess(user, schedule, false) {
+				AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+					Message: "You don't have sufficient permissions to perform this action.",
+				})
+				return
+			}
+		}
+	case "PUT", "DELETE", "PATCH":
+		{
+			// Reject the request if the user doesn't have write permissions
+			if !scheduleAccess(user, schedule, true) {
+				AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+					Message: "You don't have sufficient permissions to perform this action.",
+				})
+				return
+			}
+		}
+	}
+
+	c.Set(cSchedule, schedule)
+	c.Next()
+}
+
+// scheduleAccess reports whether the user can read, or write when write is true,
+// the job template, terraform job template or project of the schedule
+func scheduleAccess(user common.User, schedule common.Schedule, write bool) bool {
+	switch schedule.ResourceType {
+	case common.ScheduleJobTemplate:
+		roles := new(rbac.JobTemplate)
+		if write {
+			return roles.WriteByID(user, schedule.ResourceID)
+		}
+		return roles.ReadByID(user, schedule.ResourceID)
+	case common.ScheduleTerraformJobTemplate:
+		roles := new(rbac.TerraformJobTemplate)
+		if write {
+			return roles.WriteByID(user, schedule.ResourceID)
+		}
+		return roles.ReadByID(user, schedule.ResourceID)
+	case common.ScheduleProject:
+		var project common.Project
+		if err := db.Projects().FindId(schedule.ResourceID).One(&project); err != nil {
+			return false
+		}
+		roles := new(rbac.Project)
+		if write {
+			return roles.Write(user, project)
+		}
+		return roles.Read(user, project)
+	}
+	return false
+}
+
+// One returns the schedule as a JSON object
+func (ctrl ScheduleController) One(c *gin.Context) {
+	schedule := c.MustGet(cSchedule).(common.Schedule)
+	metadata.ScheduleMetadata(&schedule)
+	c.JSON(http.StatusOK, schedule)
+}
+
+// All returns a JSON array of the schedules the user can read
+func (ctrl ScheduleController) All(c *gin.Context) {
+	user := c.MustGet(cUser).(common.User)
+	listSchedules(c, bson.M{}, func(schedule common.Schedule) bool {
+		return scheduleAccess(user, schedule, false)
+	})
+}
+
+// Update is a Gin handler function which updates a schedule using request payload.
+// The resource launched by the schedule cannot be modified.
+func (ctrl ScheduleController) Update(c *gin.Context) {
+	schedule := c.MustGet(cSchedule).(common.Schedule)
+	tmpSchedule := schedule
+	user := c.MustGet(cUser).(common.User)
+
+	req := common.Schedule{Enabled: true}
+	if err := binding.JSON.Bind(c.Request, &req); err != nil {
+		AbortWithErrors(c, http.StatusBadRequest,
+			"Invalid JSON body",
+			validate.GetValidationErrors(err)...)
+		return
+	}
+
+	schedule.Name = strings.Trim(req.Name, " ")
+	schedule.Description = strings.Trim(req.Description, " ")
+	schedule.Enabled = req.Enabled
+	schedule.RRule = req.RRule
+	schedule.Cron = req.Cron
+	schedule.TimeZone = req.TimeZone
+	schedule.Start = req.Start
+	schedule.End = req.End
+	schedule.ExtraVars = req.ExtraVars
+	schedule.ModifiedByID = user.ID
+	schedule.Modified = time.Now()
+
+	next, err := scheduler.NextRun(schedule, time.Now())
+	if err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest, Message: err.Error()})
+		return
+	}
+	schedule.NextRun = next
+
+	if err := db.Schedules().UpdateId(schedule.ID, schedule); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while updating schedule",
+			Log:     logrus.Fields{"Schedule ID": schedule.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	scheduler.UpdateNextJobRun(schedule.ResourceType, schedule.ResourceID)
+
+	activity.AddActivity(activity.Update, user.ID, tmpSchedule, schedule)
+	metadata.ScheduleMetadata(&schedule)
+	c.JSON(http.StatusOK, schedule)
+}
+
+// Delete is a Gin handler function which removes a schedule object from the database
+func (ctrl ScheduleController) Delete(c *gin.Context) {
+	schedule := c.MustGet(cSchedule).(common.Schedule)
+	user := c.MustGet(cUser).(common.User)
+
+	if err := db.Schedules().RemoveId(schedule.ID); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while removing schedule",
+			Log:     logrus.Fields{"Schedule ID": schedule.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	scheduler.UpdateNextJobRun(schedule.ResourceType, schedule.ResourceID)
+
+	activity.AddActivity(activity.Delete, user.ID, schedule, nil)
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// Schedules is a Gin handler function which returns the schedules of a project
+func (ctrl ProjectController) Schedules(c *gin.Context) {
+	project := c.MustGet(cProject).(common.Project)
+	listSchedules(c, bson.M{"resource_type": common.ScheduleProject, "resource_id": project.ID}, nil)
+}
+
+// CreateSchedule is a Gin handler function which creates a schedule that updates the project
+func (ctrl ProjectController) CreateSchedule(c *gin.Context) {
+	project := c.MustGet(cProject).(common.Project)
+	user := c.MustGet(cUser).(common.User)
+
+	roles := new(rbac.Project)
+	if !roles.Write(user, project) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+			Message: "You don't have sufficient permissions to perform this action.",
+		})
+		return
+	}
+
+	createSchedule(c, common.ScheduleProject, project.ID)
+}
+
+// Schedules is a Gin handler function which returns the schedules of a job template
+func (ctrl JobTemplateController) Schedules(c *gin.Context) {
+	template := c.MustGet(cJobTemplate).(ansible.JobTemplate)
+	listSchedules(c, bson.M{"resource_type": common.ScheduleJobTemplate, "resource_id": template.ID}, nil)
+}
+
+// CreateSchedule is a Gin handler function which creates a schedule that launches the job template
+func (ctrl JobTemplateController) CreateSchedule(c *gin.Context) {
+	template := c.MustGet(cJobTemplate).(ansible.JobTemplate)
+	user := c.MustGet(cUser).(common.User)
+
+	roles := new(rbac.JobTemplate)
+	if !roles.Write(user, template) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+			Message: "You don't have sufficient permissions to perform this action.",
+		})
+		return
+	}
+
+	createSchedule(c, common.ScheduleJobTemplate, template.ID)
+}
+
+// Schedules is a Gin handler function which returns the schedules of a terraform job template
+func (ctrl TJobTmplController) Schedules(c *gin.Context) {
+	template := c.MustGet(cTerraformJobTemplate).(terraform.JobTemplate)
+	listSchedules(c, bson.M{"resource_type": common.ScheduleTerraformJobTemplate, "resource_id": template.ID}, nil)
+}
+
+// CreateSchedule is a Gin handler function which creates a schedule that launches the terraform job template
+func (ctrl TJobTmplController) CreateSchedule(c *gin.Context) {
+	template := c.MustGet(cTerraformJobTemplate).(terraform.JobTemplate)
+	user := c.MustGet(cUser).(common.User)
+
+	roles := new(rbac.TerraformJobTemplate)
+	if !roles.Write(user, template) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+			Message: "You don't have sufficient permissions to perform this action.",
+		})
+		return
+	}
+
+	createSchedule(c, common.ScheduleTerraformJobTemplate, template.ID)
+}
+
+// createSchedule creates a schedule for the resource using request payload.
+// Schedules are enabled unless the payload disables them.
+func createSchedule(c *gin.Context, resourceType string, resourceID bson.ObjectId) {
+	user := c.MustGet(cUser).(common.User)
+
+	req := common.Schedule{Enabled: true}
+	if err := binding.JSON.Bind(c.Request, &req); err != nil {
+		AbortWithErrors(c, http.StatusBadRequest,
+			"Invalid JSON body",
+			validate.GetValidationErrors(err)...)
+		return
+	}
+
+	req.ID = bson.NewObjectId()
+	req.Name = strings.Trim(req.Name, " ")
+	req.Description = strings.Trim(req.Description, " ")
+	req.ResourceType = resourceType
+	req.ResourceID = resourceID
+	req.LastRun = nil
+	req.LastJobID = nil
+	req.CreatedByID = user.ID
+	req.ModifiedByID = user.ID
+	req.Created = time.Now()
+	req.Modified = time.Now()
+
+	next, err := scheduler.NextRun(req, time.Now())
+	if err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest, Message: err.Error()})
+		return
+	}
+	req.NextRun = next
+
+	if err := db.Schedules().Insert(req); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while creating schedule",
+			Log:     logrus.Fields{"Schedule ID": req.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	scheduler.UpdateNextJobRun(resourceType, resourceID)
+
+	activity.AddActivity(activity.Create, user.ID, req, nil)
+	metadata.ScheduleMetadata(&req)
+	c.JSON(http.StatusCreated, req)
+}
+
+// listSchedules writes a paginated response with the schedules matching query,
+// when filter is not nil only the schedules accepted by filter are returned
+func listSchedules(c *gin.Context, query bson.M, filter func(common.Schedule) bool) {
+	parser := util.NewQueryParser(c)
+	query = parser.Match([]string{"resource_type"}, query)
+	query = parser.Lookups([]string{"name", "description"}, query)
+	if v, err := strconv.ParseBool(parser.RawQuery("enabled")); err == nil {
+		query["enabled"] = v
+	}
+
+	dbq := db.Schedules().Find(query)
+	if order := parser.OrderBy(); order != "" {
+		dbq.Sort(order)
+	}
+
+	var schedules []common.Schedule
+	iter := dbq.Iter()
+	var tmpSchedule common.Schedule
+	for iter.Next(&tmpSchedule) {
+		if filter != nil && !filter(tmpSchedule) {
+			continue
+		}
+		metadata.ScheduleMetadata(&tmpSchedule)
+		schedules = append(schedules, tmpSchedule)
+	}
+	if err := iter.Close(); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting schedules",
+			Log:     logrus.Fields{"Error": err.Error()},
+		})
+		return
+	}
+
+	count := len(schedules)
+	pgi := util.NewPagination(c, count)
+	if pgi.HasPage() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound,
+			Message: "#" + strconv.Itoa(pgi.Page()) + " page contains no results.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		Count:    count,
+		Next:     pgi.NextPage(),
+		Previous: pgi.PreviousPage(),
+		Data:     schedules[pgi.Skip():pgi.End()],
+	})
+}
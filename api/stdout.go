@@ -0,0 +1,250 @@
+package api
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/util"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// streamPollInterval is the time between two checks for new output
+// while following a running job
+const streamPollInterval = time.Second
+
+// stdoutChunks returns the stdout chunks of a job with a counter greater than
+// the given counter, ordered by counter
+func stdoutChunks(jobID bson.ObjectId, after uint64) ([]common.JobStdout, error) {
+	var chunks []common.JobStdout
+	err := db.JobStdout().Find(bson.M{
+		"job_id":  jobID,
+		"counter": bson.M{"$gt": after},
+	}).Sort("counter").All(&chunks)
+	return chunks, err
+}
+
+// Formats of the stdout endpoints
+const (
+	// stdoutJSON returns the output as a JSON string
+	stdoutJSON = "json"
+	// stdoutTxt returns the output as plain text without ANSI escape sequences
+	stdoutTxt = "txt"
+	// stdoutANSI returns the output as text with ANSI escape sequences
+	stdoutANSI = "ansi"
+	// stdoutHTML returns the output as a HTML document with colors
+	stdoutHTML = "html"
+)
+
+// jobStdout assembles the output persisted so far for a job from line start
+// up to but not including line end, a negative end selects all lines after
+// start. legacy is the output stored in the job document by older versions,
+// it is used when the job has no output chunks.
+func jobStdout(jobID bson.ObjectId, legacy string, start, end int) (string, error) {
+	query := bson.M{"job_id": jobID}
+	if start > 0 || end >= 0 {
+		lines := bson.M{"end_line": bson.M{"$gte": start}}
+		if end >= 0 {
+			lines["start_line"] = bson.M{"$lt": end}
+		}
+		// chunks written by older versions have no line numbers
+		query["$or"] = []bson.M{lines, {"data": bson.M{"$exists": false}}}
+	}
+
+	var chunks []common.JobStdout
+	if err := db.JobStdout().Find(query).Sort("counter").All(&chunks); err != nil {
+		return "", err
+	}
+	if len(chunks) == 0 {
+		return lineRange(legacy, 0, start, end), nil
+	}
+
+	offset := 0
+	if chunks[0].Compressed() {
+		offset = chunks[0].StartLine
+	}
+
+	var b bytes.Buffer
+	for _, chunk := range chunks {
+		text, err := chunk.Text()
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(text)
+	}
+	return lineRange(b.String(), offset, start, end), nil
+}
+
+// lineRange returns the lines from start up to but not including end of
+// text, which starts in line offset. A negative end selects all lines after start.
+func lineRange(text string, offset, start, end int) string {
+	if start <= offset && end < 0 {
+		return text
+	}
+
+	lines := strings.SplitAfter(text, "\n")
+	from := start - offset
+	if from < 0 {
+		from = 0
+	}
+	if from > len(lines) {
+		from = len(lines)
+	}
+	to := len(lines)
+	if end >= 0 && end-offset < to {
+		to = end - offset
+	}
+	if to < from {
+		to = from
+	}
+	return strings.Join(lines[from:to], "")
+}
+
+// stdoutLines parses the `start_line` and `end_line` query parameters, lines
+// are counted from 0 and end_line is not included. It aborts the request
+// and returns false when a parameter is invalid.
+func stdoutLines(c *gin.Context) (int, int, bool) {
+	start, end := 0, -1
+	if param := c.Query("start_line"); len(param) > 0 {
+		n, err := strconv.Atoi(param)
+		if err != nil || n < 0 {
+			AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+				Message: "Invalid start_line.",
+			})
+			return 0, 0, false
+		}
+		start = n
+	}
+	if param := c.Query("end_line"); len(param) > 0 {
+		n, err := strconv.Atoi(param)
+		if err != nil || n < start {
+			AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+				Message: "Invalid end_line.",
+			})
+			return 0, 0, false
+		}
+		end = n
+	}
+	return start, end, true
+}
+
+// writeStdout writes the output of a job in the format of the `format` query
+// parameter, which is one of json, txt, ansi and html and defaults to json.
+// With `download=true` the output is sent as an attachment named after name.
+func writeStdout(c *gin.Context, name string, stdout string) {
+	format := c.DefaultQuery("format", stdoutJSON)
+
+	var contentType, ext string
+	var body []byte
+	switch format {
+	case stdoutJSON:
+		c.JSON(http.StatusOK, stdout)
+		return
+	case stdoutTxt:
+		contentType, ext = "text/plain; charset=utf-8", ".txt"
+		body = []byte(util.StripANSI(stdout))
+	case stdoutANSI:
+		contentType, ext = "text/plain; charset=utf-8", ".ansi"
+		body = []byte(stdout)
+	case stdoutHTML:
+		contentType, ext = "text/html; charset=utf-8", ".html"
+		body = []byte("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>" +
+			html.EscapeString(name) + "</title>\n<style>\n" + util.ANSIStylesheet +
+			"\n</style>\n</head>\n<body>\n<pre>" + util.ANSIToHTML(stdout) +
+			"</pre>\n</body>\n</html>\n")
+	default:
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Invalid format, must be one of json, txt, ansi and html.",
+		})
+		return
+	}
+
+	if c.Query("download") == "true" {
+		c.Header("Content-Disposition", `attachment; filename="`+name+ext+`"`)
+	}
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// streamStdout writes the output of a job to the client as Server-Sent Events.
+// It replays the chunks written so far and follows new output until the job
+// finishes. Each `stdout` event carries a chunk and its counter, clients can
+// resume a stream by passing the last counter they received in the `counter`
+// query parameter. An `end` event with the final job status closes the stream.
+func streamStdout(c *gin.Context, jobID bson.ObjectId, jobs *mgo.Collection) {
+	var last uint64
+	if counter := c.Query("counter"); len(counter) > 0 {
+		n, err := strconv.ParseUint(counter, 10, 64)
+		if err != nil {
+			AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+				Message: "Invalid counter.",
+			})
+			return
+		}
+		last = n
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.Stream(func(w io.Writer) bool {
+		// read the status before the chunks, runners persist all output
+		// before they mark the job as finished
+		var job struct {
+			Status string `bson:"status"`
+		}
+		if err := jobs.FindId(jobID).Select(bson.M{"status": 1}).One(&job); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Job ID": jobID.Hex(),
+				"Error":  err.Error(),
+			}).Errorln("Error while getting job status")
+			c.SSEvent("error", "Error while getting job status")
+			return false
+		}
+
+		chunks, err := stdoutChunks(jobID, last)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Job ID": jobID.Hex(),
+				"Error":  err.Error(),
+			}).Errorln("Error while getting job stdout")
+			c.SSEvent("error", "Error while getting job stdout")
+			return false
+		}
+
+		for _, chunk := range chunks {
+			text, err := chunk.Text()
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"Job ID": jobID.Hex(),
+					"Error":  err.Error(),
+				}).Errorln("Error while decompressing job stdout")
+				c.SSEvent("error", "Error while getting job stdout")
+				return false
+			}
+			c.SSEvent("stdout", gin.H{
+				"counter": chunk.Counter,
+				"stdout":  text,
+			})
+			last = chunk.Counter
+		}
+
+		if !isActive(job.Status) {
+			c.SSEvent("end", gin.H{"status": job.Status})
+			return false
+		}
+
+		if len(chunks) == 0 {
+			time.Sleep(streamPollInterval)
+		}
+		return true
+	})
+}
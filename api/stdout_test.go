@@ -0,0 +1,23 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineRange(t *testing.T) {
+	assert := assert.New(t)
+	text := "a\nb\nc\nd\n"
+
+	assert.Equal(text, lineRange(text, 0, 0, -1))
+	assert.Equal("b\nc\n", lineRange(text, 0, 1, 3))
+	assert.Equal("c\nd\n", lineRange(text, 0, 2, -1))
+	assert.Equal("", lineRange(text, 0, 10, -1))
+	assert.Equal("", lineRange(text, 0, 2, 2))
+
+	// text of chunks that start in line 10
+	assert.Equal("b\n", lineRange(text, 10, 11, 12))
+	assert.Equal("a\nb\n", lineRange(text, 10, 0, 12))
+	assert.Equal("", lineRange(text, 10, 0, 5))
+}
@@ -1,7 +1,6 @@
 package api
 
 import (
-	"encoding/json"
 	"io"
 	"net/http"
 	"strconv"
@@ -10,18 +9,13 @@ import (
 
 	"github.com/pearsonappeng/tensor/api/metadata"
 	"github.com/pearsonappeng/tensor/db"
-	"github.com/pearsonappeng/tensor/jwt"
+	execansible "github.com/pearsonappeng/tensor/exec/ansible"
 	"github.com/pearsonappeng/tensor/models/ansible"
 	"github.com/pearsonappeng/tensor/models/common"
 
-	"os"
-
 	"github.com/Sirupsen/logrus"
 	"github.com/gin-gonic/gin"
-	"github.com/pearsonappeng/tensor/exec/sync"
-	"github.com/pearsonappeng/tensor/exec/types"
 	"github.com/pearsonappeng/tensor/log/activity"
-	"github.com/pearsonappeng/tensor/queue"
 	"github.com/pearsonappeng/tensor/rbac"
 	"github.com/pearsonappeng/tensor/util"
 	"github.com/pearsonappeng/tensor/validate"
@@ -205,6 +199,11 @@ func (ctrl JobTemplateController) All(c *gin.Context) {
 // credential:  bson.ObjectId, default=nil
 // cloud_credential:  bson.ObjectId, default=nil
 // network_credential:  bson.ObjectId, default=nil
+// network_connection:  choice, default=""
+//   - network_cli: CLI over SSH
+//   - netconf: NETCONF over SSH
+//   - httpapi: HTTP API
+// network_os:  ansible_network_os of the devices, e.g. ios, junos, eos, default=""
 // forks:  integer, default=0
 // limit:  string, default=""
 // verbosity:  choice
@@ -215,6 +214,8 @@ func (ctrl JobTemplateController) All(c *gin.Context) {
 //   - 4: 4 Connection Debug
 //   - 5: 5 WinRM Debug
 // extra_vars:  string, default=""
+// secret_vars:  names of extra_vars whose values are redacted from the job output, default=[]
+// vault_credentials:  vault credentials that decrypt vaulted files and variables, default=[]
 // job_tags:  string, default=""
 // force_handlers:  boolean, default=False
 // skip_tags:  string, default=""
@@ -330,6 +331,25 @@ func (ctrl JobTemplateController) Create(c *gin.Context) {
 		}
 	}
 
+	if len(req.VaultCredentialIDs) > 0 {
+		if !req.VaultCredentialsExist() {
+			c.JSON(http.StatusBadRequest, common.Error{
+				Code:   http.StatusBadRequest,
+				Errors: []string{"Vault credential does not exists"},
+			})
+			return
+		}
+
+		for _, id := range req.VaultCredentialIDs {
+			if !roles.ReadByID(user, id) {
+				AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+					Message: "You don't have sufficient permissions to perform this action.",
+				})
+				return
+			}
+		}
+	}
+
 	req.ID = bson.NewObjectId()
 	req.Created = time.Now()
 	req.Modified = time.Now()
@@ -447,6 +467,25 @@ func (ctrl JobTemplateController) Update(c *gin.Context) {
 		}
 	}
 
+	if len(req.VaultCredentialIDs) > 0 {
+		if !req.VaultCredentialsExist() {
+			c.JSON(http.StatusBadRequest, common.Error{
+				Code:   http.StatusBadRequest,
+				Errors: []string{"Vault credential does not exists"},
+			})
+			return
+		}
+
+		for _, id := range req.VaultCredentialIDs {
+			if !roles.ReadByID(user, id) {
+				AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+					Message: "You don't have sufficient permissions to perform this action.",
+				})
+				return
+			}
+		}
+	}
+
 	jobTemplate.Name = strings.Trim(req.Name, " ")
 	jobTemplate.JobType = req.JobType
 	jobTemplate.InventoryID = req.InventoryID
@@ -473,6 +512,11 @@ func (ctrl JobTemplateController) Update(c *gin.Context) {
 	jobTemplate.PromptTags = req.PromptTags
 	jobTemplate.PromptSkipTags = req.PromptSkipTags
 	jobTemplate.AllowSimultaneous = req.AllowSimultaneous
+	jobTemplate.Priority = req.Priority
+	jobTemplate.SecretVars = req.SecretVars
+	jobTemplate.VaultCredentialIDs = req.VaultCredentialIDs
+	jobTemplate.NetworkConnection = req.NetworkConnection
+	jobTemplate.NetworkOS = req.NetworkOS
 	jobTemplate.PolymorphicCtypeID = req.PolymorphicCtypeID
 	jobTemplate.Modified = time.Now()
 	jobTemplate.ModifiedByID = user.ID
@@ -580,7 +624,7 @@ func (ctrl JobTemplateController) Jobs(c *gin.Context) {
 	jobTemplate := c.MustGet(cJobTemplate).(ansible.JobTemplate)
 
 	var jbs []ansible.Job
-	iter := db.Jobs().Find(bson.M{"job_template_id": jobTemplate.ID}).Iter()
+	iter := db.Jobs().Find(bson.M{"job_template_id": jobTemplate.ID}).Select(bson.M{"result_stdout": 0}).Iter()
 	var tmpJob ansible.Job
 	for iter.Next(&tmpJob) {
 		metadata.JobMetadata(&tmpJob)
@@ -616,6 +660,9 @@ func (ctrl JobTemplateController) Jobs(c *gin.Context) {
 // be passed via POST data, with extra_vars given as a JSON string.
 // If `credential_needed_to_start` is `true` then the `credential` field is required
 // and if the `inventory_needed_to_start` is `True` then the `inventory` is required as well.
+// The `priority` of the job template can be overridden with a priority from 0 to 10.
+// Templates that prompt for the credential accept `vault_credentials` that replace
+// the vault credentials of the job template.
 // success returns JSON serialized Job model with 201 status code
 // if the request body is invalid returns JSON serialized Error model with 400 status code
 func (ctrl JobTemplateController) Launch(c *gin.Context) {
@@ -631,43 +678,7 @@ func (ctrl JobTemplateController) Launch(c *gin.Context) {
 	}
 
 	// create new Job
-	job := ansible.Job{
-		ID:                  bson.NewObjectId(),
-		Name:                template.Name,
-		Description:         template.Description,
-		LaunchType:          "manual",
-		CancelFlag:          false,
-		Status:              "new",
-		JobType:             ansible.JOBTYPE_ANSIBLE_JOB,
-		Playbook:            template.Playbook,
-		Forks:               template.Forks,
-		Limit:               template.Limit,
-		Verbosity:           template.Verbosity,
-		ExtraVars:           template.ExtraVars,
-		JobTags:             template.JobTags,
-		SkipTags:            template.SkipTags,
-		ForceHandlers:       template.ForceHandlers,
-		StartAtTask:         template.StartAtTask,
-		MachineCredentialID: template.MachineCredentialID,
-		InventoryID:         template.InventoryID,
-		JobTemplateID:       template.ID,
-		ProjectID:           template.ProjectID,
-		BecomeEnabled:       template.BecomeEnabled,
-		NetworkCredentialID: template.NetworkCredentialID,
-		CloudCredentialID:   template.CloudCredentialID,
-		SCMCredentialID:     "",
-		CreatedByID:         user.ID,
-		ModifiedByID:        user.ID,
-		Created:             time.Now(),
-		Modified:            time.Now(),
-		PromptCredential:    template.PromptCredential,
-		PromptInventory:     template.PromptInventory,
-		PromptJobType:       template.PromptJobType,
-		PromptLimit:         template.PromptLimit,
-		PromptTags:          template.PromptTags,
-		PromptVariables:     template.PromptVariables,
-		AllowSimultaneous:   template.AllowSimultaneous,
-	}
+	job := execansible.NewJob(template, user)
 
 	// if prompt is true override Job template
 	// if not provided return an error message
@@ -726,13 +737,6 @@ func (ctrl JobTemplateController) Launch(c *gin.Context) {
 		job.JobType = req.JobType
 	}
 
-	// create new Ansible runner Job
-	runnerJob := types.AnsibleJob{
-		Job:      job,
-		Template: template,
-		User:     user,
-	}
-
 	if template.PromptInventory {
 		if len(req.InventoryID) != 24 {
 			AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
@@ -751,113 +755,35 @@ func (ctrl JobTemplateController) Launch(c *gin.Context) {
 			return
 		}
 		job.MachineCredentialID = &req.MachineCredentialID
-	}
-
-	if job.NetworkCredentialID != nil {
-		var credential common.Credential
-		if err := db.Credentials().FindId(*job.NetworkCredentialID).One(&credential); err != nil {
-			AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
-				Message: "Error while getting network credential",
-				Log:     logrus.Fields{"Error": err.Error()},
-			})
-			return
-		}
-		runnerJob.Network = credential
-	}
 
-	if job.CloudCredentialID != nil {
-		var credential common.Credential
-		err := db.Credentials().FindId(*job.CloudCredentialID).One(&credential)
-		if err != nil {
-			AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
-				Message: "Error while getting cloud credential",
-				Log:     logrus.Fields{"Error": err.Error()},
-			})
-			return
-		}
-		runnerJob.Cloud = credential
-	}
-
-	var inventory ansible.Inventory
-	if err := db.Inventories().FindId(job.InventoryID).One(&inventory); err != nil {
-		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
-			Message: "Error while getting inventory",
-			Log:     logrus.Fields{"Error": err.Error()},
-		})
-		return
-	}
-	runnerJob.Inventory = inventory
-
-	if job.MachineCredentialID != nil {
-		var credential common.Credential
-		if err := db.Credentials().FindId(*job.MachineCredentialID).One(&credential); err != nil {
-			AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
-				Message: "Error while getting machine credential",
-				Log:     logrus.Fields{"Error": err.Error()},
-			})
-			return
-		}
-		runnerJob.Machine = credential
-	}
-
-	// get project information
-	var project common.Project
-	if err := db.Projects().FindId(job.ProjectID).One(&project); err != nil {
-		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
-			Message: "Error while getting project",
-			Log:     logrus.Fields{"Error": err.Error()},
-		})
-		return
-	}
-	runnerJob.Project = project
-
-	// Get jwt token for authorize Ansible inventory plugin
-	var token jwt.LocalToken
-	if err := jwt.NewAuthToken(&token); err != nil {
-		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
-			Message: "Error while getting token",
-			Log:     logrus.Fields{"Error": err.Error()},
-		})
-		return
-	}
-	runnerJob.Token = token.Token
-
-	// Insert new job into jobs collection
-	if err := db.Jobs().Insert(job); err != nil {
-		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
-			Message: "Error while creating job",
-			Log:     logrus.Fields{"Error": err.Error()},
-		})
-		return
-	}
+		if len(req.VaultCredentialIDs) > 0 {
+			if !ansible.VaultCredentialsExist(req.VaultCredentialIDs) {
+				AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+					Message: "Vault credential does not exists",
+				})
+				return
+			}
 
-	// update if requested
-	if _, err := os.Stat(project.LocalPath); os.IsNotExist(err) || runnerJob.Project.ScmUpdateOnLaunch {
-		tj, err := sync.UpdateProject(project)
-		runnerJob.PreviousJob = tj
-		if err != nil {
-			AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
-				Message: "Error while creating update job",
-				Log:     logrus.Fields{"Error": err.Error()},
-			})
-			return
+			roles := new(rbac.Credential)
+			for _, id := range req.VaultCredentialIDs {
+				if !roles.ReadByID(user, id) {
+					AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+						Message: "You don't have sufficient permissions to perform this action.",
+					})
+					return
+				}
+			}
+			job.VaultCredentialIDs = req.VaultCredentialIDs
 		}
 	}
 
-	jobBytes, err := json.Marshal(runnerJob)
-	if err != nil {
-		AbortWithError(LogFields{Context: c, Status: http.StatusInternalServerError,
-			Message: "Error while encoding the job",
-			Log:     logrus.Fields{"Error": err.Error()},
-		})
-		return
+	if req.Priority != nil {
+		job.Priority = *req.Priority
 	}
 
-	// publish bytes to ansible queue
-	if err := queue.Publish(queue.Ansible, jobBytes); err != nil {
-		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
-			Message: "Error while publishing to Queue",
-			Log:     logrus.Fields{"Error": err.Error()},
+	if err := execansible.Launch(job, template, user); err != nil {
+		AbortWithError(LogFields{Context: c, Status: launchStatus(err),
+			Message: err.Error(),
 		})
 		return
 	}
@@ -890,11 +816,13 @@ func (ctrl JobTemplateController) LaunchInfo(c *gin.Context) {
 	var isInventoryNeeded bool
 
 	defaults := gin.H{
-		"job_tags":   jt.JobTags,
-		"extra_vars": jt.ExtraVars,
-		"job_type":   jt.JobType,
-		"skip_tags":  jt.SkipTags,
-		"limit":      jt.Limit,
+		"job_tags":          jt.JobTags,
+		"extra_vars":        jt.ExtraVars,
+		"job_type":          jt.JobType,
+		"skip_tags":         jt.SkipTags,
+		"limit":             jt.Limit,
+		"priority":          jt.Priority,
+		"vault_credentials": jt.VaultCredentialIDs,
 		"inventory": gin.H{
 			"id":   jt.InventoryID,
 			"name": "Demo Inventory",
@@ -1,11 +1,14 @@
 package api
 
 import (
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	metadata "github.com/pearsonappeng/tensor/api/metadata/terraform"
 	"github.com/pearsonappeng/tensor/db"
+	execterraform "github.com/pearsonappeng/tensor/exec/terraform"
 	"github.com/pearsonappeng/tensor/models/common"
 	"github.com/pearsonappeng/tensor/models/terraform"
 
@@ -13,6 +16,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pearsonappeng/tensor/rbac"
 	"github.com/pearsonappeng/tensor/util"
+	"github.com/pearsonappeng/tensor/validate"
+	"gopkg.in/gin-gonic/gin.v1/binding"
+	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -90,7 +96,7 @@ func (ctrl TerraformJobController) All(c *gin.Context) {
 	match := bson.M{}
 	match = parser.Match([]string{"status", "type", "failed"}, match)
 	match = parser.Lookups([]string{"id", "name", "labels"}, match)
-	query := db.TerrafromJobs().Find(match)
+	query := db.TerrafromJobs().Find(match).Select(bson.M{"result_stdout": 0})
 	if order := parser.OrderBy(); order != "" {
 		query.Sort(order)
 	}
@@ -136,18 +142,174 @@ func (ctrl TerraformJobController) All(c *gin.Context) {
 // The response will include the following field:
 // can_cancel: [boolean] Indicates whether this job can be canceled
 func (ctrl TerraformJobController) CancelInfo(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"can_cancel": false})
+	job := c.MustGet(cTerraformJob).(terraform.Job)
+	c.JSON(http.StatusOK, gin.H{"can_cancel": isCancelable(job.Status, job.CancelFlag)})
 }
 
 // Cancel cancels the pending job.
 // The response status code will be 202 if successful, or 405 if the job cannot be
 // canceled.
+// A job that is still queued will not be started by the consumer, a running job
+// is killed by the runner and its partial output is saved.
 func (ctrl TerraformJobController) Cancel(c *gin.Context) {
-	c.AbortWithStatus(http.StatusMethodNotAllowed)
+	job := c.MustGet(cTerraformJob).(terraform.Job)
+
+	if !isCancelable(job.Status, job.CancelFlag) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusMethodNotAllowed,
+			Message: "Job cannot be canceled.",
+		})
+		return
+	}
+
+	// only flag jobs that are still in a cancelable state, the runner might
+	// have finished the job in the meantime
+	query := bson.M{
+		"_id":    job.ID,
+		"status": bson.M{"$in": common.ActiveJobStatus},
+	}
+	if err := db.TerrafromJobs().Update(query, bson.M{"$set": bson.M{"cancel_flag": true}}); err != nil {
+		if err == mgo.ErrNotFound {
+			AbortWithError(LogFields{Context: c, Status: http.StatusMethodNotAllowed,
+				Message: "Job cannot be canceled.",
+			})
+			return
+		}
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while canceling job",
+			Log:     logrus.Fields{"Job ID": job.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	c.AbortWithStatus(http.StatusAccepted)
 }
 
 // StdOut returns ANSI standard output of a Job
+// while the job is running the output persisted so far is returned.
+// The `start_line` and `end_line` query parameters select a range of lines and
+// the `format` query parameter selects json (default), txt, ansi or html output.
 func (ctrl TerraformJobController) StdOut(c *gin.Context) {
 	job := c.MustGet(cTerraformJob).(terraform.Job)
-	c.JSON(http.StatusOK, job.ResultStdout)
+
+	start, end, ok := stdoutLines(c)
+	if !ok {
+		return
+	}
+
+	stdout, err := jobStdout(job.ID, job.ResultStdout, start, end)
+	if err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting job stdout",
+			Log:     logrus.Fields{"Job ID": job.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	writeStdout(c, "job_"+job.ID.Hex(), stdout)
+}
+
+// StdOutStream streams the standard output of a Job as Server-Sent Events
+// and follows the output until the job is finished
+func (ctrl TerraformJobController) StdOutStream(c *gin.Context) {
+	job := c.MustGet(cTerraformJob).(terraform.Job)
+	streamStdout(c, job.ID, db.TerrafromJobs())
+}
+
+// RelaunchInfo determines if the job can be relaunched.
+// The response will include the following fields:
+// can_relaunch: [boolean] Indicates whether this job can be relaunched
+// credential_needed_to_start: [boolean] The credential of the job no longer exists and must be provided
+func (ctrl TerraformJobController) RelaunchInfo(c *gin.Context) {
+	job := c.MustGet(cTerraformJob).(terraform.Job)
+
+	var credentialNeeded bool
+	if job.MachineCredentialID != nil {
+		if n, err := db.Credentials().FindId(*job.MachineCredentialID).Count(); err != nil || n == 0 {
+			credentialNeeded = true
+		}
+	}
+
+	canRelaunch := true
+	template, err := job.GetJobTemplate()
+	if err != nil {
+		canRelaunch = false
+	} else {
+		canRelaunch = !credentialNeeded || template.PromptCredential
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"can_relaunch":               canRelaunch,
+		"passwords_needed_to_start":  []gin.H{},
+		"credential_needed_to_start": credentialNeeded,
+	})
+}
+
+// Relaunch creates a new job from the stored parameters of the job and
+// publishes it to the terraform queue. The job template must still exist,
+// its current permissions and settings are applied to the new job.
+func (ctrl TerraformJobController) Relaunch(c *gin.Context) {
+	job := c.MustGet(cTerraformJob).(terraform.Job)
+	user := c.MustGet(cUser).(common.User)
+
+	var req terraform.Relaunch
+	if err := binding.JSON.Bind(c.Request, &req); err != nil && err != io.EOF {
+		AbortWithErrors(c, http.StatusBadRequest,
+			"Invalid JSON body",
+			validate.GetValidationErrors(err)...)
+		return
+	}
+
+	template, err := job.GetJobTemplate()
+	if err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Job template does not exist.",
+		})
+		return
+	}
+
+	// reset the execution state, the stored launch parameters are kept
+	relaunch := job
+	relaunch.ID = bson.NewObjectId()
+	relaunch.LaunchType = terraform.JobLaunchTypeRelaunch
+	relaunch.CancelFlag = false
+	relaunch.Status = "new"
+	relaunch.Failed = false
+	relaunch.Started = time.Time{}
+	relaunch.Finished = time.Time{}
+	relaunch.Elapsed = 0
+	relaunch.ResultStdout = ""
+	relaunch.ResultGetStdout = ""
+	relaunch.ResultTraceback = ""
+	relaunch.JobExplanation = ""
+	relaunch.JobCWD = ""
+	relaunch.JobARGS = nil
+	relaunch.JobENV = nil
+	relaunch.WorkerID = nil
+	relaunch.AllowSimultaneous = template.AllowSimultaneous
+	relaunch.CreatedByID = user.ID
+	relaunch.ModifiedByID = user.ID
+	relaunch.Created = time.Now()
+	relaunch.Modified = time.Now()
+
+	if job.MachineCredentialID != nil {
+		if n, err := db.Credentials().FindId(*job.MachineCredentialID).Count(); err != nil || n == 0 {
+			if !template.PromptCredential || req.MachineCredentialID == nil {
+				AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+					Message: "Credential required.",
+				})
+				return
+			}
+			relaunch.MachineCredentialID = req.MachineCredentialID
+		}
+	}
+
+	if err := execterraform.Launch(relaunch, template, user); err != nil {
+		AbortWithError(LogFields{Context: c, Status: launchStatus(err),
+			Message: err.Error(),
+		})
+		return
+	}
+
+	metadata.JobMetadata(&relaunch)
+	c.JSON(http.StatusCreated, relaunch)
 }
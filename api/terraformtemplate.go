@@ -1,7 +1,6 @@
 package api
 
 import (
-	"encoding/json"
 	"io"
 	"net/http"
 	"strconv"
@@ -10,18 +9,13 @@ import (
 
 	metadata "github.com/pearsonappeng/tensor/api/metadata/terraform"
 	"github.com/pearsonappeng/tensor/db"
-	"github.com/pearsonappeng/tensor/exec/sync"
-	"github.com/pearsonappeng/tensor/exec/types"
-	"github.com/pearsonappeng/tensor/jwt"
+	execterraform "github.com/pearsonappeng/tensor/exec/terraform"
 	"github.com/pearsonappeng/tensor/models/common"
 	"github.com/pearsonappeng/tensor/models/terraform"
 
-	"os"
-
 	"github.com/Sirupsen/logrus"
 	"github.com/gin-gonic/gin"
 	"github.com/pearsonappeng/tensor/log/activity"
-	"github.com/pearsonappeng/tensor/queue"
 	"github.com/pearsonappeng/tensor/rbac"
 	"github.com/pearsonappeng/tensor/util"
 	"github.com/pearsonappeng/tensor/validate"
@@ -206,6 +200,7 @@ func (ctrl TJobTmplController) All(c *gin.Context) {
 //   - 4: 4 Connection Debug
 //   - 5: 5 WinRM Debug
 // extra_vars:  string, default=""
+// secret_vars:  names of vars whose values are redacted from the job output, default=[]
 // job_tags:  string, default=""
 // force_handlers:  boolean, default=False
 // skip_tags:  string, default=""
@@ -430,6 +425,8 @@ func (ctrl TJobTmplController) Update(c *gin.Context) {
 	jobTemplate.PromptCredential = req.PromptCredential
 	jobTemplate.PromptJobType = req.PromptJobType
 	jobTemplate.AllowSimultaneous = req.AllowSimultaneous
+	jobTemplate.Priority = req.Priority
+	jobTemplate.SecretVars = req.SecretVars
 	jobTemplate.Modified = time.Now()
 	jobTemplate.ModifiedByID = user.ID
 
@@ -550,7 +547,7 @@ func (ctrl TJobTmplController) Jobs(c *gin.Context) {
 	jobTemplate := c.MustGet(cTerraformJobTemplate).(terraform.JobTemplate)
 
 	var jbs []terraform.Job
-	iter := db.TerrafromJobs().Find(bson.M{"job_template_id": jobTemplate.ID}).Iter()
+	iter := db.TerrafromJobs().Find(bson.M{"job_template_id": jobTemplate.ID}).Select(bson.M{"result_stdout": 0}).Iter()
 	var tmpJob terraform.Job
 	for iter.Next(&tmpJob) {
 		metadata.JobMetadata(&tmpJob)
@@ -587,6 +584,7 @@ func (ctrl TJobTmplController) Jobs(c *gin.Context) {
 // be passed via POST data, with extra_vars given as a JSON string.
 // If `credential_needed_to_start` is `true` then the `credential` field is required
 // and if the `inventory_needed_to_start` is `True` then the `inventory` is required as well.
+// The `priority` of the job template can be overridden with a priority from 0 to 10.
 // success returns JSON serialized Job model with 201 status code
 // if the request body is invalid returns JSON serialized Error model with 400 status code
 func (ctrl TJobTmplController) Launch(c *gin.Context) {
@@ -601,34 +599,7 @@ func (ctrl TJobTmplController) Launch(c *gin.Context) {
 	}
 
 	// create new Job
-	job := terraform.Job{
-		ID:                  bson.NewObjectId(),
-		Name:                template.Name,
-		Description:         template.Description,
-		LaunchType:          "manual",
-		CancelFlag:          false,
-		Status:              "new",
-		JobType:             template.JobType,
-		Vars:                template.Vars,
-		Parallelism:         template.Parallelism,
-		UpdateOnLaunch:      template.UpdateOnLaunch,
-		MachineCredentialID: template.MachineCredentialID,
-		JobTemplateID:       template.ID,
-		Target:              template.Target,
-		ProjectID:           template.ProjectID,
-		NetworkCredentialID: template.NetworkCredentialID,
-		CloudCredentialID:   template.CloudCredentialID,
-		SCMCredentialID:     template.SCMCredentialID,
-		CreatedByID:         user.ID,
-		ModifiedByID:        user.ID,
-		Created:             time.Now(),
-		Modified:            time.Now(),
-		PromptCredential:    template.PromptCredential,
-		PromptJobType:       template.PromptJobType,
-		PromptVariables:     template.PromptVariables,
-		AllowSimultaneous:   template.AllowSimultaneous,
-		Directory:           template.Directory,
-	}
+	job := execterraform.NewJob(template, user)
 
 	// if prompt is true override Job template
 	// if not provided return an error message
@@ -654,13 +625,6 @@ func (ctrl TJobTmplController) Launch(c *gin.Context) {
 		job.JobType = req.JobType
 	}
 
-	// create new Ansible runner Job
-	runnerJob := types.TerraformJob{
-		Job:      job,
-		Template: template,
-		User:     user,
-	}
-
 	if template.PromptCredential {
 		if req.MachineCredentialID == nil {
 			c.JSON(http.StatusBadRequest, common.Error{
@@ -672,97 +636,13 @@ func (ctrl TJobTmplController) Launch(c *gin.Context) {
 		job.MachineCredentialID = req.MachineCredentialID
 	}
 
-	if job.NetworkCredentialID != nil {
-		var credential common.Credential
-		if err := db.Credentials().FindId(*job.NetworkCredentialID).One(&credential); err != nil {
-			AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
-				Message: "Error while getting network credential",
-				Log:     logrus.Fields{"Error": err.Error()},
-			})
-			return
-		}
-		runnerJob.Network = credential
-	}
-
-	if job.CloudCredentialID != nil {
-		var credential common.Credential
-		if err := db.Credentials().FindId(*job.CloudCredentialID).One(&credential); err != nil {
-			AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
-				Message: "Error while getting cloud credential",
-				Log:     logrus.Fields{"Error": err.Error()},
-			})
-			return
-		}
-		runnerJob.Cloud = credential
-	}
-
-	if job.MachineCredentialID != nil {
-		var credential common.Credential
-		if err := db.Credentials().FindId(*job.MachineCredentialID).One(&credential); err != nil {
-			AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
-				Message: "Error while getting machine credential",
-				Log:     logrus.Fields{"Error": err.Error()},
-			})
-			return
-		}
-		runnerJob.Machine = credential
-	}
-
-	var project common.Project
-	if err := db.Projects().FindId(job.ProjectID).One(&project); err != nil {
-		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
-			Message: "Error while getting project",
-			Log:     logrus.Fields{"Error": err.Error()},
-		})
-		return
-	}
-	runnerJob.Project = project
-
-	// Get jwt token for authorize API
-	var token jwt.LocalToken
-	if err := jwt.NewAuthToken(&token); err != nil {
-		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
-			Message: "Error while getting token",
-			Log:     logrus.Fields{"Error": err.Error()},
-		})
-		return
-	}
-	runnerJob.Token = token.Token
-
-	if err := db.TerrafromJobs().Insert(job); err != nil {
-		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
-			Message: "Error while creating job",
-			Log:     logrus.Fields{"Error": err.Error()},
-		})
-		return
+	if req.Priority != nil {
+		job.Priority = *req.Priority
 	}
 
-	if _, err := os.Stat(project.LocalPath); os.IsNotExist(err) || runnerJob.Project.ScmUpdateOnLaunch {
-		tj, err := sync.UpdateProject(project)
-		runnerJob.PreviousJob = tj
-		if err != nil {
-			AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
-				Message: "Error while creating update job",
-				Log:     logrus.Fields{"Error": err.Error()},
-			})
-			return
-		}
-	}
-
-	jobBytes, err := json.Marshal(runnerJob)
-	if err != nil {
-		AbortWithError(LogFields{Context: c, Status: http.StatusInternalServerError,
-			Message: "Error while encoding the job",
-			Log:     logrus.Fields{"Error": err.Error()},
-		})
-		return
-	}
-
-	// publish bytes to terraform queue
-	if err := queue.Publish(queue.Terraform, jobBytes); err != nil {
-		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
-			Message: "Error while publishing to Queue",
-			Log:     logrus.Fields{"Error": err.Error()},
+	if err := execterraform.Launch(job, template, user); err != nil {
+		AbortWithError(LogFields{Context: c, Status: launchStatus(err),
+			Message: err.Error(),
 		})
 		return
 	}
@@ -796,6 +676,7 @@ func (ctrl TJobTmplController) LaunchInfo(c *gin.Context) {
 	defaults := gin.H{
 		"vars":     jt.Vars,
 		"job_type": jt.JobType,
+		"priority": jt.Priority,
 	}
 
 	var cred common.Credential
@@ -5,7 +5,10 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/gin-gonic/gin"
 	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/queue"
+	"github.com/pearsonappeng/tensor/rbac"
 	"github.com/pearsonappeng/tensor/util"
+	"github.com/pearsonappeng/tensor/worker"
 	"net/http"
 )
 
@@ -54,6 +57,22 @@ func AbortWithErrors(c *gin.Context, status int, message string, emsgs ...string
 	c.Abort()
 }
 
+// isActive reports whether a job with the given status is queued or running
+func isActive(status string) bool {
+	for _, s := range common.ActiveJobStatus {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// isCancelable reports whether a job with the given status can be canceled,
+// a job that already has a pending cancel request can not be canceled again
+func isCancelable(status string, cancelFlag bool) bool {
+	return !cancelFlag && isActive(status)
+}
+
 // hideEncrypted is replaces encrypted fields by $encrypted$ string
 func hideEncrypted(c *common.Credential) {
 	encrypted := "$encrypted$"
@@ -64,6 +83,23 @@ func hideEncrypted(c *common.Credential) {
 	c.VaultPassword = encrypted
 	c.AuthorizePassword = encrypted
 	c.Secret = encrypted
+	c.SecurityToken = encrypted
+}
+
+// hideNotificationSecrets hides the SMTP password and the webhook
+// header values of a notification template
+func hideNotificationSecrets(t *common.NotificationTemplate) {
+	if len(t.NotificationConfiguration.Password) > 0 {
+		t.NotificationConfiguration.Password = "$encrypted$"
+	}
+	// copies of the template share the headers
+	if len(t.NotificationConfiguration.Headers) > 0 {
+		headers := make(map[string]string, len(t.NotificationConfiguration.Headers))
+		for k := range t.NotificationConfiguration.Headers {
+			headers[k] = "$encrypted$"
+		}
+		t.NotificationConfiguration.Headers = headers
+	}
 }
 
 func GetAPIVersion(c *gin.Context) {
@@ -80,6 +116,7 @@ func GetAPIInfo(c *gin.Context) {
 		"authtoken":               "/v1/authtoken",
 		"ping":                    "/v1/ping",
 		"config":                  "/v1/config",
+		"workers":                 "/v1/workers",
 		"queue":                   "/v1/queue",
 		"me":                      "/v1/me",
 		"dashboard":               "/v1/dashboard",
@@ -112,8 +149,61 @@ func GetAPIInfo(c *gin.Context) {
 	})
 }
 
+// GetPing returns the version, it is not authenticated
+// so it must not query the database
 func GetPing(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"version": util.Version,
 	})
 }
+
+// GetWorkers returns the active workers and their capacity,
+// only users with global read permission can list the workers
+func GetWorkers(c *gin.Context) {
+	user := c.MustGet(cUser).(common.User)
+	if !rbac.HasGlobalRead(user) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+			Message: "You don't have sufficient permissions to perform this action.",
+		})
+		return
+	}
+
+	workers, err := worker.Active()
+	if err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting workers",
+			Log:     logrus.Fields{"Error": err.Error()},
+		})
+		return
+	}
+
+	capacity, busy := 0, 0
+	for _, w := range workers {
+		capacity += w.Capacity
+		busy += w.Busy
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"capacity": capacity,
+		"busy":     busy,
+		"workers":  workers,
+	})
+}
+
+// isQueueError reports whether the job queue could not take the job
+func isQueueError(err error) bool {
+	return err == queue.ErrUnavailable || err == queue.ErrRefused
+}
+
+// launchStatus returns the status code of a failed launch, a job the
+// queue could not take is reported as service unavailable and a job
+// exceeding the quota of its organization as too many requests
+func launchStatus(err error) int {
+	if isQueueError(err) {
+		return http.StatusServiceUnavailable
+	}
+	if _, ok := err.(common.QuotaError); ok {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusGatewayTimeout
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	metadata "github.com/pearsonappeng/tensor/api/metadata/workflow"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/log/activity"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/models/workflow"
+	"github.com/pearsonappeng/tensor/rbac"
+	"github.com/pearsonappeng/tensor/util"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Keys for workflow job related items stored in the Gin Context
+const (
+	cWorkflowJob   = "workflow_job"
+	cWorkflowJobID = "workflow_job_id"
+)
+
+type WorkflowJobController struct{}
+
+// Middleware generates a middleware handler function that works inside of a Gin request.
+// This function takes cWorkflowJobID from Gin Context and retrieves workflow job data
+// from the collection and store workflow job data under key cWorkflowJob in Gin Context
+func (ctrl WorkflowJobController) Middleware(c *gin.Context) {
+	objectID := c.Params.ByName(cWorkflowJobID)
+	user := c.MustGet(cUser).(common.User)
+
+	if !bson.IsObjectIdHex(objectID) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound, Message: "Workflow Job does not exist"})
+		return
+	}
+
+	var job workflow.Job
+	if err := db.WorkflowJobs().FindId(bson.ObjectIdHex(objectID)).One(&job); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound, Message: "Workflow Job does not exist",
+			Log: logrus.Fields{
+				"Workflow Job ID": objectID,
+				"Error":           err.Error(),
+			},
+		})
+		return
+	}
+
+	roles := new(rbac.WorkflowJob)
+	switch c.Request.Method {
+	case "GET":
+		{
+			if !roles.Read(user, job) {
+				AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+					Message: "You don't have sufficient permissions to perform this action.",
+				})
+				return
+			}
+		}
+	case "POST", "DELETE":
+		{
+			// Reject the request if the user doesn't have write permissions
+			if !roles.Write(user, job) {
+				AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+					Message: "You don't have sufficient permissions to perform this action.",
+				})
+				return
+			}
+		}
+	}
+
+	c.Set(cWorkflowJob, job)
+	c.Next()
+}
+
+// One returns the workflow job and the state of its nodes as a JSON object
+func (ctrl WorkflowJobController) One(c *gin.Context) {
+	job := c.MustGet(cWorkflowJob).(workflow.Job)
+	metadata.JobMetadata(&job)
+	c.JSON(http.StatusOK, job)
+}
+
+// All returns a JSON array of the workflow jobs the user can read
+func (ctrl WorkflowJobController) All(c *gin.Context) {
+	user := c.MustGet(cUser).(common.User)
+	roles := new(rbac.WorkflowJob)
+	listWorkflowJobs(c, bson.M{}, func(job workflow.Job) bool {
+		return roles.Read(user, job)
+	})
+}
+
+// Delete removes a finished workflow job, the jobs of the nodes are kept
+func (ctrl WorkflowJobController) Delete(c *gin.Context) {
+	job := c.MustGet(cWorkflowJob).(workflow.Job)
+	user := c.MustGet(cUser).(common.User)
+
+	if isActive(job.Status) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusMethodNotAllowed,
+			Message: "Workflow Job is running, cancel it before deleting.",
+		})
+		return
+	}
+
+	if err := db.WorkflowJobs().RemoveId(job.ID); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while deleting workflow job",
+			Log:     logrus.Fields{"Workflow Job ID": job.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	activity.AddActivity(activity.Delete, user.ID, job, nil)
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// CancelInfo to determine if the workflow job can be cancelled.
+// The response will include the following field:
+// can_cancel: [boolean] Indicates whether this workflow job can be canceled
+func (ctrl WorkflowJobController) CancelInfo(c *gin.Context) {
+	job := c.MustGet(cWorkflowJob).(workflow.Job)
+	c.JSON(http.StatusOK, gin.H{"can_cancel": isCancelable(job.Status, job.CancelFlag)})
+}
+
+// Cancel cancels the workflow job.
+// The response status code will be 202 if successful, or 405 if the workflow job
+// cannot be canceled. Nodes that were not started are skipped and the jobs of the
+// running nodes are canceled.
+func (ctrl WorkflowJobController) Cancel(c *gin.Context) {
+	job := c.MustGet(cWorkflowJob).(workflow.Job)
+
+	if !isCancelable(job.Status, job.CancelFlag) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusMethodNotAllowed,
+			Message: "Workflow Job cannot be canceled.",
+		})
+		return
+	}
+
+	query := bson.M{
+		"_id":    job.ID,
+		"status": bson.M{"$in": common.ActiveJobStatus},
+	}
+	if err := db.WorkflowJobs().Update(query, bson.M{"$set": bson.M{"cancel_flag": true}}); err != nil {
+		if err == mgo.ErrNotFound {
+			AbortWithError(LogFields{Context: c, Status: http.StatusMethodNotAllowed,
+				Message: "Workflow Job cannot be canceled.",
+			})
+			return
+		}
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while canceling workflow job",
+			Log:     logrus.Fields{"Workflow Job ID": job.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	c.AbortWithStatus(http.StatusAccepted)
+}
+
+// listWorkflowJobs writes the workflow jobs that match the query and
+// pass the filter as a paginated response, a nil filter passes all jobs
+func listWorkflowJobs(c *gin.Context, query bson.M, filter func(workflow.Job) bool) {
+	parser := util.NewQueryParser(c)
+	query = parser.Match([]string{"status", "failed", "launch_type"}, query)
+	query = parser.Lookups([]string{"name", "description"}, query)
+
+	dbq := db.WorkflowJobs().Find(query)
+	if order := parser.OrderBy(); order != "" {
+		dbq.Sort(order)
+	}
+
+	var jobs []workflow.Job
+	iter := dbq.Iter()
+	var tmpJob workflow.Job
+	for iter.Next(&tmpJob) {
+		if filter != nil && !filter(tmpJob) {
+			continue
+		}
+		metadata.JobMetadata(&tmpJob)
+		jobs = append(jobs, tmpJob)
+	}
+	if err := iter.Close(); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting workflow jobs",
+			Log:     logrus.Fields{"Error": err.Error()},
+		})
+		return
+	}
+
+	count := len(jobs)
+	pgi := util.NewPagination(c, count)
+	if pgi.HasPage() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound,
+			Message: "#" + strconv.Itoa(pgi.Page()) + " page contains no results.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		Count:    count,
+		Next:     pgi.NextPage(),
+		Previous: pgi.PreviousPage(),
+		Data:     jobs[pgi.Skip():pgi.End()],
+	})
+}
@@ -0,0 +1,460 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	metadata "github.com/pearsonappeng/tensor/api/metadata/workflow"
+	"github.com/pearsonappeng/tensor/db"
+	execworkflow "github.com/pearsonappeng/tensor/exec/workflow"
+	"github.com/pearsonappeng/tensor/log/activity"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/models/terraform"
+	"github.com/pearsonappeng/tensor/models/workflow"
+	"github.com/pearsonappeng/tensor/rbac"
+	"github.com/pearsonappeng/tensor/util"
+	"github.com/pearsonappeng/tensor/validate"
+	"gopkg.in/gin-gonic/gin.v1/binding"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Keys for workflow job template related items stored in the Gin Context
+const (
+	cWorkflowJobTemplate   = "workflow_job_template"
+	cWorkflowJobTemplateID = "workflow_job_template_id"
+)
+
+type WorkflowJobTemplateController struct{}
+
+// Middleware generates a middleware handler function that works inside of a Gin request.
+// This function takes cWorkflowJobTemplateID from Gin Context and retrieves workflow job template data
+// from the collection and store workflow job template data under key cWorkflowJobTemplate in Gin Context
+func (ctrl WorkflowJobTemplateController) Middleware(c *gin.Context) {
+	objectID := c.Params.ByName(cWorkflowJobTemplateID)
+	user := c.MustGet(cUser).(common.User)
+
+	if !bson.IsObjectIdHex(objectID) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound, Message: "Workflow Job Template does not exist"})
+		return
+	}
+
+	var template workflow.JobTemplate
+	if err := db.WorkflowJobTemplates().FindId(bson.ObjectIdHex(objectID)).One(&template); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound, Message: "Workflow Job Template does not exist",
+			Log: logrus.Fields{
+				"Workflow Job Template ID": objectID,
+				"Error":                    err.Error(),
+			},
+		})
+		return
+	}
+
+	roles := new(rbac.WorkflowJobTemplate)
+	switch c.Request.Method {
+	case "GET", "POST":
+		{
+			// launch permissions are checked against the nodes
+			if !roles.Read(user, template) {
+				AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+					Message: "You don't have sufficient permissions to perform this action.",
+				})
+				return
+			}
+		}
+	case "PUT", "DELETE":
+		{
+			// Reject the request if the user doesn't have write permissions
+			if !roles.Write(user, template) {
+				AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+					Message: "You don't have sufficient permissions to perform this action.",
+				})
+				return
+			}
+		}
+	}
+
+	c.Set(cWorkflowJobTemplate, template)
+	c.Next()
+}
+
+// One returns the workflow job template as a JSON object
+func (ctrl WorkflowJobTemplateController) One(c *gin.Context) {
+	template := c.MustGet(cWorkflowJobTemplate).(workflow.JobTemplate)
+	metadata.JTemplateMetadata(&template)
+	c.JSON(http.StatusOK, template)
+}
+
+// All returns a JSON array of the workflow job templates the user can read
+func (ctrl WorkflowJobTemplateController) All(c *gin.Context) {
+	user := c.MustGet(cUser).(common.User)
+	roles := new(rbac.WorkflowJobTemplate)
+	listWorkflowJobTemplates(c, bson.M{}, func(template workflow.JobTemplate) bool {
+		return roles.Read(user, template)
+	})
+}
+
+// Create is a Gin handler function which creates a new workflow job template using request payload.
+func (ctrl WorkflowJobTemplateController) Create(c *gin.Context) {
+	user := c.MustGet(cUser).(common.User)
+
+	var req workflow.JobTemplate
+	if err := binding.JSON.Bind(c.Request, &req); err != nil {
+		AbortWithErrors(c, http.StatusBadRequest,
+			"Invalid JSON body",
+			validate.GetValidationErrors(err)...)
+		return
+	}
+
+	if !req.OrganizationExist() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Organization does not exists.",
+		})
+		return
+	}
+
+	if !new(rbac.WorkflowJobTemplate).Write(user, req) {
+		AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+			Message: "You don't have sufficient permissions to perform this action.",
+		})
+		return
+	}
+
+	req.Name = strings.Trim(req.Name, " ")
+	if !req.IsUnique() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Workflow Job Template with this name and organization already exists.",
+		})
+		return
+	}
+
+	if !validateWorkflowNodes(c, user, req.Nodes, false) {
+		return
+	}
+
+	req.ID = bson.NewObjectId()
+	req.Description = strings.Trim(req.Description, " ")
+	req.Status = ""
+	req.LastJobID = nil
+	req.LastJobRun = nil
+	req.LastJobFailed = false
+	req.CreatedByID = user.ID
+	req.ModifiedByID = user.ID
+	req.Created = time.Now()
+	req.Modified = time.Now()
+
+	if err := db.WorkflowJobTemplates().Insert(req); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while creating workflow job template",
+			Log:     logrus.Fields{"Workflow Job Template ID": req.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	activity.AddActivity(activity.Create, user.ID, req, nil)
+	metadata.JTemplateMetadata(&req)
+	c.JSON(http.StatusCreated, req)
+}
+
+// Update is a Gin handler function which updates a workflow job template using request payload.
+// The nodes of the workflow are replaced by the nodes of the request, running workflow jobs
+// keep the nodes they were launched with.
+func (ctrl WorkflowJobTemplateController) Update(c *gin.Context) {
+	template := c.MustGet(cWorkflowJobTemplate).(workflow.JobTemplate)
+	tmpTemplate := template
+	user := c.MustGet(cUser).(common.User)
+
+	var req workflow.JobTemplate
+	if err := binding.JSON.Bind(c.Request, &req); err != nil {
+		AbortWithErrors(c, http.StatusBadRequest,
+			"Invalid JSON body",
+			validate.GetValidationErrors(err)...)
+		return
+	}
+
+	if req.OrganizationID != template.OrganizationID {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Organization of a Workflow Job Template cannot be changed.",
+		})
+		return
+	}
+
+	req.Name = strings.Trim(req.Name, " ")
+	if req.Name != template.Name && !req.IsUnique() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Workflow Job Template with this name and organization already exists.",
+		})
+		return
+	}
+
+	if !validateWorkflowNodes(c, user, req.Nodes, false) {
+		return
+	}
+
+	template.Name = req.Name
+	template.Description = strings.Trim(req.Description, " ")
+	template.ExtraVars = req.ExtraVars
+	template.PromptVariables = req.PromptVariables
+	template.AllowSimultaneous = req.AllowSimultaneous
+	template.Nodes = req.Nodes
+	template.ModifiedByID = user.ID
+	template.Modified = time.Now()
+
+	if err := db.WorkflowJobTemplates().UpdateId(template.ID, template); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while updating workflow job template",
+			Log:     logrus.Fields{"Workflow Job Template ID": template.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	activity.AddActivity(activity.Update, user.ID, tmpTemplate, template)
+	metadata.JTemplateMetadata(&template)
+	c.JSON(http.StatusOK, template)
+}
+
+// Delete is a Gin handler function which removes a workflow job template,
+// templates with running workflow jobs cannot be removed
+func (ctrl WorkflowJobTemplateController) Delete(c *gin.Context) {
+	template := c.MustGet(cWorkflowJobTemplate).(workflow.JobTemplate)
+	user := c.MustGet(cUser).(common.User)
+
+	count, err := db.WorkflowJobs().Find(bson.M{
+		"workflow_job_template_id": template.ID,
+		"status":                   bson.M{"$in": common.ActiveJobStatus},
+	}).Count()
+	if err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while removing workflow job template",
+			Log:     logrus.Fields{"Workflow Job Template ID": template.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+	if count > 0 {
+		AbortWithError(LogFields{Context: c, Status: http.StatusConflict,
+			Message: "Workflow Job Template has running workflow jobs",
+		})
+		return
+	}
+
+	if err := db.WorkflowJobTemplates().RemoveId(template.ID); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while removing workflow job template",
+			Log:     logrus.Fields{"Workflow Job Template ID": template.ID.Hex(), "Error": err.Error()},
+		})
+		return
+	}
+
+	activity.AddActivity(activity.Delete, user.ID, template, nil)
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// LaunchInfo returns JSON serialized launch information of the workflow job template
+//
+// ask_variables_on_launch: Flag indicating whether the workflow prompts for extra variables upon launch
+// can_start_without_user_input: Flag indicating if the workflow can be launched without user-input
+// node_templates_missing: Ids of the nodes whose job template, terraform job template or project no longer exists
+func (ctrl WorkflowJobTemplateController) LaunchInfo(c *gin.Context) {
+	template := c.MustGet(cWorkflowJobTemplate).(workflow.JobTemplate)
+
+	missing := []string{}
+	for _, n := range template.Nodes {
+		if _, err := workflowNodeResource(n); err != nil {
+			missing = append(missing, n.ID)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ask_variables_on_launch":      template.PromptVariables,
+		"can_start_without_user_input": !template.PromptVariables && len(template.Nodes) > 0 && len(missing) == 0,
+		"variables_needed_to_start":    []gin.H{},
+		"node_templates_missing":       missing,
+		"workflow_job_template_data": gin.H{
+			"id":          template.ID.Hex(),
+			"name":        template.Name,
+			"description": template.Description,
+		},
+		"defaults": gin.H{
+			"extra_vars": template.ExtraVars,
+		},
+	})
+}
+
+// Launch creates a new workflow job, the jobs of the nodes are launched on
+// behalf of the user so the user must be able to launch every node
+func (ctrl WorkflowJobTemplateController) Launch(c *gin.Context) {
+	template := c.MustGet(cWorkflowJobTemplate).(workflow.JobTemplate)
+	user := c.MustGet(cUser).(common.User)
+
+	var req workflow.Launch
+	if err := binding.JSON.Bind(c.Request, &req); err != nil && err != io.EOF {
+		AbortWithErrors(c, http.StatusBadRequest,
+			"Invalid JSON body",
+			validate.GetValidationErrors(err)...)
+		return
+	}
+
+	if len(template.Nodes) == 0 {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: "Workflow Job Template does not have any nodes.",
+		})
+		return
+	}
+
+	if !validateWorkflowNodes(c, user, template.Nodes, true) {
+		return
+	}
+
+	job := execworkflow.NewJob(template, user)
+
+	// if prompt is true override the workflow variables
+	// if not provided return an error message
+	if template.PromptVariables {
+		if !(len(req.ExtraVars) > 0) {
+			AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+				Message: "Additional variables required.",
+			})
+			return
+		}
+
+		job.ExtraVars = req.ExtraVars
+	}
+
+	if err := execworkflow.Launch(job); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	metadata.JobMetadata(&job)
+	c.JSON(http.StatusCreated, job)
+}
+
+// WorkflowJobs returns the workflow jobs of the workflow job template
+func (ctrl WorkflowJobTemplateController) WorkflowJobs(c *gin.Context) {
+	template := c.MustGet(cWorkflowJobTemplate).(workflow.JobTemplate)
+	listWorkflowJobs(c, bson.M{"workflow_job_template_id": template.ID}, nil)
+}
+
+// WorkflowJobTemplates is a Gin handler function which returns the
+// workflow job templates that belong to the organization
+func (ctrl OrganizationController) WorkflowJobTemplates(c *gin.Context) {
+	organization := c.MustGet(cOrganization).(common.Organization)
+	listWorkflowJobTemplates(c, bson.M{"organization_id": organization.ID}, nil)
+}
+
+// workflowNodeResource returns the job template, terraform job template
+// or project launched by the node
+func workflowNodeResource(n workflow.Node) (interface{}, error) {
+	switch n.ResourceType {
+	case workflow.NodeJobTemplate:
+		var template ansible.JobTemplate
+		err := db.JobTemplates().FindId(n.ResourceID).One(&template)
+		return template, err
+	case workflow.NodeTerraformJobTemplate:
+		var template terraform.JobTemplate
+		err := db.TerrafromJobTemplates().FindId(n.ResourceID).One(&template)
+		return template, err
+	default:
+		var project common.Project
+		err := db.Projects().FindId(n.ResourceID).One(&project)
+		return project, err
+	}
+}
+
+// validateWorkflowNodes checks the graph of the nodes and the resources
+// they launch. The user must be able to read the resources, or to launch
+// them when launch is true.
+func validateWorkflowNodes(c *gin.Context, user common.User, nodes []workflow.Node, launch bool) bool {
+	if err := workflow.ValidateNodes(nodes); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return false
+	}
+
+	for _, n := range nodes {
+		resource, err := workflowNodeResource(n)
+		if err != nil {
+			AbortWithError(LogFields{Context: c, Status: http.StatusBadRequest,
+				Message: "Resource of node " + n.ID + " does not exists.",
+			})
+			return false
+		}
+
+		var allowed bool
+		switch r := resource.(type) {
+		case ansible.JobTemplate:
+			roles := new(rbac.JobTemplate)
+			allowed = (launch && roles.Write(user, r)) || (!launch && roles.Read(user, r))
+		case terraform.JobTemplate:
+			roles := new(rbac.TerraformJobTemplate)
+			allowed = (launch && roles.Write(user, r)) || (!launch && roles.Read(user, r))
+		case common.Project:
+			roles := new(rbac.Project)
+			allowed = (launch && roles.Update(user, r)) || (!launch && roles.Read(user, r))
+		}
+
+		if !allowed {
+			AbortWithError(LogFields{Context: c, Status: http.StatusUnauthorized,
+				Message: "You don't have sufficient permissions to perform this action.",
+			})
+			return false
+		}
+	}
+
+	return true
+}
+
+// listWorkflowJobTemplates writes the workflow job templates that match the
+// query and pass the filter as a paginated response, a nil filter passes all
+func listWorkflowJobTemplates(c *gin.Context, query bson.M, filter func(workflow.JobTemplate) bool) {
+	parser := util.NewQueryParser(c)
+	query = parser.Match([]string{"status"}, query)
+	query = parser.Lookups([]string{"name", "description"}, query)
+
+	dbq := db.WorkflowJobTemplates().Find(query)
+	if order := parser.OrderBy(); order != "" {
+		dbq.Sort(order)
+	}
+
+	var templates []workflow.JobTemplate
+	iter := dbq.Iter()
+	var tmpTemplate workflow.JobTemplate
+	for iter.Next(&tmpTemplate) {
+		if filter != nil && !filter(tmpTemplate) {
+			continue
+		}
+		metadata.JTemplateMetadata(&tmpTemplate)
+		templates = append(templates, tmpTemplate)
+	}
+	if err := iter.Close(); err != nil {
+		AbortWithError(LogFields{Context: c, Status: http.StatusGatewayTimeout,
+			Message: "Error while getting workflow job templates",
+			Log:     logrus.Fields{"Error": err.Error()},
+		})
+		return
+	}
+
+	count := len(templates)
+	pgi := util.NewPagination(c, count)
+	if pgi.HasPage() {
+		AbortWithError(LogFields{Context: c, Status: http.StatusNotFound,
+			Message: "#" + strconv.Itoa(pgi.Page()) + " page contains no results.",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, common.Response{
+		Count:    count,
+		Next:     pgi.NextPage(),
+		Previous: pgi.PreviousPage(),
+		Data:     templates[pgi.Skip():pgi.End()],
+	})
+}
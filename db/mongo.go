@@ -23,15 +23,25 @@ const (
 	CInventorySources      = "inventory_sources"
 	CJobs                  = "jobs"
 	CJobTemplates          = "job_templates"
+	CJobStdout             = "job_stdout"
+	CJobEvents             = "job_events"
+	CJobHostSummaries      = "job_host_summaries"
 	CTerraformJobTemplates = "terrafrom_job_templates"
 	CTerraformJobs         = "terraform_jobs"
 	CNotifications         = "notifications"
 	CNotificationTemplates = "notification_templates"
 	COrganizations         = "organizations"
 	CProjects              = "projects"
+	CSchedules             = "schedules"
 	CTeams                 = "teams"
 	CUsers                 = "users"
 	CActivityStream        = "activity_stream"
+	CWorkflowJobTemplates  = "workflow_job_templates"
+	CWorkflowJobs          = "workflow_jobs"
+	CWorkers               = "workers"
+	CQueueMessages         = "queue_messages"
+	CQueueGroups           = "queue_groups"
+	CQueueDeliveries       = "queue_deliveries"
 )
 
 // Connect will create a session to Mongodb database given in the Config file or env
@@ -106,6 +116,134 @@ func createIndexes() {
 		logrus.Errorln("Failed to create Unique Index for username of ", CUsers, "Collection")
 	}
 
+	// Unique index for job stdout chunks
+	if err := MongoDb.C(CJobStdout).EnsureIndex(mgo.Index{
+		Key:        []string{"job_id", "counter"},
+		Unique:     true,
+		Background: true,
+	}); err != nil {
+		logrus.Errorln("Failed to create Unique Index for job_id, counter of ", CJobStdout, "Collection")
+	}
+
+	// Index for job events, ordered by counter
+	if err := MongoDb.C(CJobEvents).EnsureIndex(mgo.Index{
+		Key:        []string{"job_id", "counter"},
+		Background: true,
+	}); err != nil {
+		logrus.Errorln("Failed to create Index for job_id, counter of ", CJobEvents, "Collection")
+	}
+
+	// Index for host job events
+	if err := MongoDb.C(CJobEvents).EnsureIndex(mgo.Index{
+		Key:        []string{"host_id"},
+		Background: true,
+	}); err != nil {
+		logrus.Errorln("Failed to create Index for host_id of ", CJobEvents, "Collection")
+	}
+
+	// Unique index for job host summaries, one summary per host and job
+	if err := MongoDb.C(CJobHostSummaries).EnsureIndex(mgo.Index{
+		Key:        []string{"job_id", "host_name"},
+		Unique:     true,
+		Background: true,
+	}); err != nil {
+		logrus.Errorln("Failed to create Unique Index for job_id, host_name of ", CJobHostSummaries, "Collection")
+	}
+
+	// Index for host job summaries
+	if err := MongoDb.C(CJobHostSummaries).EnsureIndex(mgo.Index{
+		Key:        []string{"host_id"},
+		Background: true,
+	}); err != nil {
+		logrus.Errorln("Failed to create Index for host_id of ", CJobHostSummaries, "Collection")
+	}
+
+	// Index for notifications of a job
+	if err := MongoDb.C(CNotifications).EnsureIndex(mgo.Index{
+		Key:        []string{"job_id"},
+		Background: true,
+	}); err != nil {
+		logrus.Errorln("Failed to create Index for job_id of ", CNotifications, "Collection")
+	}
+
+	// Index for due schedules
+	if err := MongoDb.C(CSchedules).EnsureIndex(mgo.Index{
+		Key:        []string{"enabled", "next_run"},
+		Background: true,
+	}); err != nil {
+		logrus.Errorln("Failed to create Index for enabled, next_run of ", CSchedules, "Collection")
+	}
+
+	// Index for hosts and groups imported by an inventory source
+	for _, c := range []string{CHosts, CGroups} {
+		if err := MongoDb.C(c).EnsureIndex(mgo.Index{
+			Key:        []string{"inventory_source_id"},
+			Background: true,
+		}); err != nil {
+			logrus.Errorln("Failed to create Index for inventory_source_id of ", c, "Collection")
+		}
+	}
+
+	// Index for active workflow jobs
+	if err := MongoDb.C(CWorkflowJobs).EnsureIndex(mgo.Index{
+		Key:        []string{"status"},
+		Background: true,
+	}); err != nil {
+		logrus.Errorln("Failed to create Index for status of ", CWorkflowJobs, "Collection")
+	}
+
+	// Index for jobs owned by a worker
+	for _, c := range []string{CJobs, CTerraformJobs, CAdHocCommands} {
+		if err := MongoDb.C(c).EnsureIndex(mgo.Index{
+			Key:        []string{"worker_id", "status"},
+			Sparse:     true,
+			Background: true,
+		}); err != nil {
+			logrus.Errorln("Failed to create Index for worker_id, status of ", c, "Collection")
+		}
+	}
+
+	// Index for the next message of a queue
+	if err := MongoDb.C(CQueueMessages).EnsureIndex(mgo.Index{
+		Key:        []string{"queue", "locked_until"},
+		Background: true,
+	}); err != nil {
+		logrus.Errorln("Failed to create Index for queue, locked_until of ", CQueueMessages, "Collection")
+	}
+
+	// Index for the messages of a queue ordered by priority
+	if err := MongoDb.C(CQueueMessages).EnsureIndex(mgo.Index{
+		Key:        []string{"queue", "-priority", "_id"},
+		Background: true,
+	}); err != nil {
+		logrus.Errorln("Failed to create Index for queue, priority of ", CQueueMessages, "Collection")
+	}
+
+	// Index for the pending and running messages of an AMQP queue
+	if err := MongoDb.C(CQueueDeliveries).EnsureIndex(mgo.Index{
+		Key:        []string{"queue", "locked_until"},
+		Background: true,
+	}); err != nil {
+		logrus.Errorln("Failed to create Index for queue, locked_until of ", CQueueDeliveries, "Collection")
+	}
+
+	// Index that removes the records of messages of stopped consumers
+	if err := MongoDb.C(CQueueDeliveries).EnsureIndex(mgo.Index{
+		Key:         []string{"locked_until"},
+		ExpireAfter: time.Hour,
+		Background:  true,
+	}); err != nil {
+		logrus.Errorln("Failed to create Index for locked_until of ", CQueueDeliveries, "Collection")
+	}
+
+	// Index for active workers
+	if err := MongoDb.C(CWorkers).EnsureIndex(mgo.Index{
+		Key:        []string{"heartbeat"},
+		Background: true,
+	}); err != nil {
+		logrus.Errorln("Failed to create Index for heartbeat of ", CWorkers, "Collection")
+	}
+
 }
 
 // Organizations returns a mgo.Collection for organizations
@@ -133,11 +271,31 @@ func Jobs() *mgo.Collection {
 	return MongoDb.C(CJobs)
 }
 
+// AdHocCommands returns a mgo.Collection for ad_hoc_commands
+func AdHocCommands() *mgo.Collection {
+	return MongoDb.C(CAdHocCommands)
+}
+
 // JobTemplates returns mgo.Collection for job_templates
 func JobTemplates() *mgo.Collection {
 	return MongoDb.C(CJobTemplates)
 }
 
+// JobStdout returns a mgo.Collection for job_stdout
+func JobStdout() *mgo.Collection {
+	return MongoDb.C(CJobStdout)
+}
+
+// JobEvents returns a mgo.Collection for job_events
+func JobEvents() *mgo.Collection {
+	return MongoDb.C(CJobEvents)
+}
+
+// JobHostSummaries returns a mgo.Collection for job_host_summaries
+func JobHostSummaries() *mgo.Collection {
+	return MongoDb.C(CJobHostSummaries)
+}
+
 // TerrafromJobs returns a mgo.Collection for terraform_jobs
 func TerrafromJobs() *mgo.Collection {
 	return MongoDb.C(CTerraformJobs)
@@ -158,6 +316,16 @@ func Inventories() *mgo.Collection {
 	return MongoDb.C(CInventories)
 }
 
+// InventoryScripts returns mgo.Collection for inventory_scripts
+func InventoryScripts() *mgo.Collection {
+	return MongoDb.C(CInventoryScripts)
+}
+
+// InventorySources returns mgo.Collection for inventory_sources
+func InventorySources() *mgo.Collection {
+	return MongoDb.C(CInventorySources)
+}
+
 // Groups returns mgo.Collection for groups
 func Groups() *mgo.Collection {
 	return MongoDb.C(CGroups)
@@ -168,7 +336,52 @@ func Projects() *mgo.Collection {
 	return MongoDb.C(CProjects)
 }
 
+// NotificationTemplates returns mgo.Collection for notification_templates
+func NotificationTemplates() *mgo.Collection {
+	return MongoDb.C(CNotificationTemplates)
+}
+
+// Notifications returns mgo.Collection for notifications
+func Notifications() *mgo.Collection {
+	return MongoDb.C(CNotifications)
+}
+
+// Schedules returns mgo.Collection for schedules
+func Schedules() *mgo.Collection {
+	return MongoDb.C(CSchedules)
+}
+
 // ActivityStream returns mgo.Collection for activity_stream
 func ActivityStream() *mgo.Collection {
 	return MongoDb.C(CActivityStream)
 }
+
+// WorkflowJobTemplates returns mgo.Collection for workflow_job_templates
+func WorkflowJobTemplates() *mgo.Collection {
+	return MongoDb.C(CWorkflowJobTemplates)
+}
+
+// WorkflowJobs returns mgo.Collection for workflow_jobs
+func WorkflowJobs() *mgo.Collection {
+	return MongoDb.C(CWorkflowJobs)
+}
+
+// Workers returns mgo.Collection for workers
+func Workers() *mgo.Collection {
+	return MongoDb.C(CWorkers)
+}
+
+// QueueMessages returns mgo.Collection for queue_messages
+func QueueMessages() *mgo.Collection {
+	return MongoDb.C(CQueueMessages)
+}
+
+// QueueGroups returns mgo.Collection for queue_groups
+func QueueGroups() *mgo.Collection {
+	return MongoDb.C(CQueueGroups)
+}
+
+// QueueDeliveries returns mgo.Collection for queue_deliveries
+func QueueDeliveries() *mgo.Collection {
+	return MongoDb.C(CQueueDeliveries)
+}
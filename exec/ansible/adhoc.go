@@ -0,0 +1,365 @@
+package ansible
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/adjust/uniuri"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/exec/misc"
+	"github.com/pearsonappeng/tensor/exec/types"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"github.com/pearsonappeng/tensor/ssh"
+	"github.com/pearsonappeng/tensor/util"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// adHocRun runs an ad hoc command using the ansible command
+// in the same sandbox as ansible jobs
+func adHocRun(j *types.AnsibleJob) {
+	command := j.AdHocCommand
+
+	logrus.WithFields(logrus.Fields{
+		"Ad Hoc Command ID": command.ID.Hex(),
+		"Module":            command.ModuleName,
+	}).Infoln("Ad hoc command received")
+
+	// command was canceled while it was waiting in the queue
+	if adHocCancelRequested(j) {
+		adHocCancel(j)
+		return
+	}
+
+	adHocStart(j)
+
+	// Start SSH agent
+	client, socket, pid, sshcleanup := ssh.StartAgent()
+
+	if err := misc.AddSSHKey(client, j.Machine); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while adding Machine Credential to SSH Agent")
+		sshcleanup()
+		command.JobExplanation = err.Error()
+		adHocFinish(j, "failed")
+		return
+	}
+
+	// secrets injected into the command are scrubbed from everything that is persisted
+	redactor := adHocRedactor(j)
+
+	cmd, cleanup, err := getAdHocCmd(j, socket, pid)
+	command.JobARGS = redactor.Strings(command.JobARGS)
+	command.JobENV = redactor.Strings(command.JobENV)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Running ad hoc command failed")
+		sshcleanup()
+		command.ResultStdout = "stdout capture is missing"
+		command.JobExplanation = err.Error()
+		adHocFinish(j, "failed")
+		return
+	}
+
+	// cleanup credential files
+	defer func() {
+		logrus.WithFields(logrus.Fields{
+			"Ad Hoc Command ID": command.ID.Hex(),
+			"Status":            command.Status,
+		}).Infoln("Stopped running ad hoc command")
+		sshcleanup()
+		cleanup()
+	}()
+
+	// output is persisted in chunks while the command is running
+	b := misc.NewStdoutWriter(command.ID, redactor)
+	defer b.Close()
+	cmd.Stdout = b
+	cmd.Stderr = b
+
+	// Set setsid to create a new session, The new process group has no controlling
+	// terminal which disables the stdin & will skip prompts
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	if err := cmd.Start(); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Running ad hoc command failed")
+		command.JobExplanation = err.Error()
+		command.ResultStdout = string(b.Bytes())
+		adHocFinish(j, "failed")
+		return
+	}
+
+	timer := time.AfterFunc(misc.Timeout(command.Timeout, util.Config.AnsibleJobTimeOut), func() {
+		logrus.Println("Killing the process. Execution exceeded threashold value")
+		misc.KillProcessGroup(cmd)
+	})
+	defer timer.Stop()
+
+	// kill the process group if the command is canceled through the API
+	stopWatch := misc.WatchCancel(cmd, func() bool {
+		return adHocCancelRequested(j)
+	})
+
+	err = cmd.Wait()
+	command.ResultStdout = string(b.Bytes())
+	if stopWatch() {
+		logrus.WithFields(logrus.Fields{
+			"Ad Hoc Command ID": command.ID.Hex(),
+		}).Infoln("Ad hoc command canceled")
+		adHocCancel(j)
+		return
+	}
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Running ad hoc command failed")
+		command.JobExplanation = err.Error()
+		adHocFinish(j, "failed")
+		return
+	}
+
+	adHocFinish(j, "successful")
+}
+
+// getAdHocCmd creates the ansible command of an ad hoc command,
+// the returned cleanup function removes the temporary directories
+func getAdHocCmd(j *types.AnsibleJob, socket string, pid int) (cmd *exec.Cmd, cleanup func(), err error) {
+	command := j.AdHocCommand
+
+	// Generate directory paths and create directories
+	tmp := "/tmp/tensor_proot_" + uniuri.New() + "/"
+	j.Paths = types.JobPaths{
+		Etc:             filepath.Join(tmp, uniuri.New()),
+		Tmp:             filepath.Join(tmp, uniuri.New()),
+		VarLib:          filepath.Join(tmp, uniuri.New()),
+		VarLibJobStatus: filepath.Join(tmp, uniuri.New()),
+		VarLibProjects:  filepath.Join(tmp, uniuri.New()),
+		VarLog:          filepath.Join(tmp, uniuri.New()),
+		TmpRand:         "/tmp/tensor__" + uniuri.New(),
+		CredentialPath:  "/tmp/tensor_" + uniuri.New(),
+	}
+
+	// add proot parameters, ad hoc commands do not have a project
+	// so the working directory is the sandboxed tmp directory
+	pargs := []string{"-v", "0", "-r", "/",
+		"-b", j.Paths.Etc + ":/etc/tensor",
+		"-b", j.Paths.Tmp + ":/tmp",
+		"-b", j.Paths.VarLib + ":/var/lib/tensor",
+		"-b", j.Paths.VarLibJobStatus + ":/var/lib/tensor/job_status",
+		"-b", j.Paths.VarLibProjects + ":" + util.Config.ProjectsHome,
+		"-b", j.Paths.VarLog + ":/var/log",
+		"-b", j.Paths.TmpRand + ":" + j.Paths.TmpRand,
+		"-b", j.Paths.CredentialPath + ":" + j.Paths.CredentialPath,
+		"-b", "/var/lib/tensor:/var/lib/tensor",
+		"-w", "/tmp",
+	}
+
+	// create job directories, they are removed on every error
+	createTmpDirs(j)
+	cleanup = func() {
+		removeTmpDirs(j, tmp)
+	}
+
+	pattern := "all"
+	if len(command.Limit) > 0 {
+		pattern = command.Limit
+	}
+
+	// ansible parameters
+	pAnsible := []string{
+		"ansible", pattern, "-i", "/var/lib/tensor/plugins/inventory/tensorrest.py",
+		"-m", command.ModuleName,
+	}
+	if len(command.ModuleArgs) > 0 {
+		pAnsible = append(pAnsible, "-a", command.ModuleArgs)
+	}
+	pAnsible = append(pAnsible, adHocParams(*command)...)
+
+	pMachine, pSecure := machineParams(j.Machine, command.BecomeEnabled)
+	pAnsible = append(pAnsible, pMachine...)
+	pAnsible = append(pAnsible, misc.WinRMParams(j.Machine)...)
+	pargs = append(pargs, pAnsible...)
+
+	// set command arguments, exclude unencrypted passwords etc.
+	command.JobARGS = []string{strings.Join(pargs, " ")}
+	command.JobCWD = "/tmp"
+	// should not included in any output
+	pargs = append(pargs, pSecure...)
+
+	cmd = exec.Command("proot", pargs...)
+	cmd.Dir = j.Paths.Tmp
+
+	env := []string{
+		"TERM=xterm",
+		"HOME_PATH=" + util.Config.ProjectsHome,
+		"PWD=/tmp",
+		"SHLVL=0",
+		"HOME=" + os.Getenv("HOME"),
+		"_=/usr/bin/tensord",
+		"PROOT_NO_SECCOMP=1",
+		"PATH=/bin:/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+		"ANSIBLE_PARAMIKO_RECORD_HOST_KEYS=False",
+		"ANSIBLE_HOST_KEY_CHECKING=False",
+		"ANSIBLE_FORCE_COLOR=True",
+		"REST_API_URL=" + util.Config.GetUrl(),
+		"INVENTORY_HOSTVARS=True",
+		"INVENTORY_ID=" + j.Inventory.ID.Hex(),
+		"SSH_AUTH_SOCK=" + socket,
+		"SSH_AGENT_PID=" + strconv.Itoa(pid),
+	}
+	// kerberos ticket of windows credentials, in a cache of the command
+	krbEnv, err := misc.Kinit(j.Machine, command.OrganizationID, j.Paths.CredentialPath)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	env = append(env, krbEnv...)
+	cmd.Env = append(env, "REST_API_TOKEN="+j.Token)
+	// Assign job env here to ensure that sensitive information will
+	// not be exposed
+	command.JobENV = append(env, "REST_API_TOKEN="+strings.Repeat("*", len(j.Token)))
+
+	logrus.WithFields(logrus.Fields{
+		"Dir":         cmd.Dir,
+		"Environment": append([]string{}, cmd.Env...),
+	}).Debugln("Ad hoc command Directory and Environment")
+
+	return cmd, cleanup, nil
+}
+
+// adHocParams returns the ansible parameters of the ad hoc command options
+func adHocParams(command ansible.AdHocCommand) []string {
+	var params []string
+	if command.JobType == "check" {
+		params = append(params, "--check")
+	}
+	// forks -f NUM, --forks=NUM
+	if command.Forks != 0 {
+		params = append(params, "-f", strconv.Itoa(int(command.Forks)))
+	}
+	// verbosity  -v, --verbose
+	if command.Verbosity > 0 {
+		v := int(command.Verbosity)
+		if v > 4 {
+			v = 4
+		}
+		params = append(params, "-"+strings.Repeat("v", v))
+	}
+	// extra variables -e EXTRA_VARS, --extra-vars=EXTRA_VARS
+	if len(command.ExtraVars) > 0 {
+		vars, err := json.Marshal(command.ExtraVars)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Error": err,
+			}).Errorln("Could not marshal extra vars")
+		} else {
+			params = append(params, "-e", string(vars))
+		}
+	}
+	return params
+}
+
+func adHocStart(j *types.AnsibleJob) {
+	command := j.AdHocCommand
+	command.Status = "running"
+	command.Started = time.Now()
+
+	d := bson.M{
+		"$set": bson.M{
+			"status":  command.Status,
+			"failed":  false,
+			"started": command.Started,
+		},
+	}
+
+	if err := db.AdHocCommands().UpdateId(command.ID, d); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Status": command.Status,
+			"Error":  err,
+		}).Errorln("Failed to update ad hoc command status")
+	}
+}
+
+// adHocCancelRequested reports whether a cancel has been requested for the
+// ad hoc command through the API since it was queued
+func adHocCancelRequested(j *types.AnsibleJob) bool {
+	var command struct {
+		CancelFlag bool `bson:"cancel_flag"`
+	}
+
+	if err := db.AdHocCommands().FindId(j.AdHocCommand.ID).Select(bson.M{"cancel_flag": 1}).One(&command); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Ad Hoc Command ID": j.AdHocCommand.ID.Hex(),
+			"Error":             err,
+		}).Errorln("Failed to get ad hoc command cancel flag")
+		return false
+	}
+
+	return command.CancelFlag
+}
+
+func adHocCancel(j *types.AnsibleJob) {
+	j.AdHocCommand.CancelFlag = true
+	j.AdHocCommand.JobExplanation = "Job Cancelled"
+	if len(j.AdHocCommand.ResultStdout) == 0 {
+		j.AdHocCommand.ResultStdout = "stdout capture is missing"
+	}
+	adHocFinish(j, "canceled")
+}
+
+// adHocRedactor returns the Redactor of the secrets injected into the ad hoc command
+func adHocRedactor(j *types.AnsibleJob) *misc.Redactor {
+	redactor := misc.NewRedactor(j.Machine)
+	redactor.Add(j.Token)
+	redactor.AddVars(j.AdHocCommand.ExtraVars, j.AdHocCommand.SecretVars)
+	return redactor
+}
+
+// adHocFinish stores the final status and the output of the ad hoc command
+func adHocFinish(j *types.AnsibleJob, status string) {
+	command := j.AdHocCommand
+	command.Status = status
+	command.Finished = time.Now()
+	command.Failed = status == "failed" || status == "error"
+	command.JobExplanation = adHocRedactor(j).String(command.JobExplanation)
+
+	misc.SaveStdout(command.ID, command.ResultStdout)
+
+	//get elapsed time in minutes
+	diff := command.Finished.Sub(command.Started)
+	if command.Started.IsZero() {
+		diff = 0
+	}
+
+	d := bson.M{
+		"$set": bson.M{
+			"status":          command.Status,
+			"cancel_flag":     command.CancelFlag,
+			"failed":          command.Failed,
+			"finished":        command.Finished,
+			"elapsed":         diff.Minutes(),
+			"job_explanation": command.JobExplanation,
+			"job_args":        command.JobARGS,
+			"job_env":         command.JobENV,
+			"job_cwd":         command.JobCWD,
+		},
+	}
+
+	if err := db.AdHocCommands().UpdateId(command.ID, d); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Status": command.Status,
+			"Error":  err,
+		}).Errorln("Failed to update ad hoc command status")
+	}
+}
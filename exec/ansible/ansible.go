@@ -1,7 +1,6 @@
 package ansible
 
 import (
-	"bytes"
 	"encoding/json"
 	"os"
 	"os/exec"
@@ -12,12 +11,12 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/pearsonappeng/tensor/db"
+	execinventory "github.com/pearsonappeng/tensor/exec/inventory"
 	"github.com/pearsonappeng/tensor/exec/misc"
 	"github.com/pearsonappeng/tensor/exec/sync"
 	"github.com/pearsonappeng/tensor/exec/types"
 	"github.com/pearsonappeng/tensor/models/ansible"
 	"github.com/pearsonappeng/tensor/models/common"
-	"github.com/streadway/amqp"
 
 	"path/filepath"
 
@@ -25,124 +24,95 @@ import (
 	"github.com/pearsonappeng/tensor/queue"
 	"github.com/pearsonappeng/tensor/ssh"
 	"github.com/pearsonappeng/tensor/util"
+	"github.com/pearsonappeng/tensor/worker"
 )
 
-// Run starts consuming jobs into a channel of size prefetchLimit
+// Run consumes the ansible queue with a pool of workers, it reconnects
+// when the queue backend can not be reached and never returns
 func Run() {
-	for {
-		logrus.Warningln("Starting Ansible consumer")
-		conn, err := amqp.Dial(util.Config.RabbitMQ)
-
-		if err != nil {
-			logrus.WithFields(logrus.Fields{
-				"Queue": queue.Ansible,
-				"Error": err.Error(),
-			}).Errorln("Could not contact RabbitMQ server")
-			return
-		}
-
-		defer conn.Close()
+	workers := worker.NewWorkers(queue.Ansible, util.Config.AnsibleWorkers)
+	queue.Consume(queue.Ansible, workers.Size(), func(d queue.Message) {
+		workers.Start()
+		defer workers.Done()
+		consume(d)
+	})
+}
 
-		ch, err := conn.Channel()
+// consume runs the job of the message and acknowledges it
+func consume(d queue.Message) {
+	jb := types.AnsibleJob{}
+	if err := json.Unmarshal(d.Body(), &jb); err != nil {
+		// handle error
+		logrus.Warningln("Job delivery rejected")
+		d.Reject("Invalid job: "+err.Error())
+		jobFail(&jb)
+		return
+	}
 
-		if err != nil {
-			logrus.WithFields(logrus.Fields{
-				"Queue": queue.Ansible,
-				"Error": err.Error(),
-			}).Errorln("Failed to open a channel")
-			return
-		}
+	// project updates are claimed by the sync runner
+	if jb.Job.JobType == ansible.JOBTYPE_UPDATE_JOB {
+		sync.Run(d)
+		return
+	}
 
-		defer ch.Close()
+	// a redelivered job must not run twice
+	if !claim(d, &jb) {
+		return
+	}
 
-		q, err := ch.QueueDeclare(
-			queue.Ansible, // name
-			true, // durable
-			false, // delete when unused
-			false, // exclusive
-			false, // no-wait
-			nil, // arguments
-		)
+	if jb.AdHocCommand != nil {
+		adHocRun(&jb)
+		d.Ack()
+		return
+	}
 
-		if err != nil {
-			logrus.WithFields(logrus.Fields{
-				"Queue": queue.Ansible,
-				"Error": err.Error(),
-			}).Errorln("Failed to declare a queue")
+	if jb.Job.JobType == ansible.JOBTYPE_INVENTORY_UPDATE {
+		update := types.InventoryUpdateJob{}
+		if err := json.Unmarshal(d.Body(), &update); err != nil {
+			logrus.Warningln("Inventory update delivery rejected")
+			d.Reject("Invalid inventory update: "+err.Error())
 			return
 		}
+		execinventory.Update(update)
+		d.Ack()
+		return
+	}
 
-		err = ch.Qos(
-			1, // prefetch count
-			0, // prefetch size
-			false, // global
-		)
-
-		if err != nil {
-			logrus.WithFields(logrus.Fields{
-				"Queue": queue.Ansible,
-				"Error": err.Error(),
-			}).Errorln("Failed to set QoS")
-			return
-		}
+	logrus.WithFields(logrus.Fields{
+		"Job ID": jb.Job.ID.Hex(),
+		"Name":   jb.Job.Name,
+	}).Infoln("Job successfuly received")
 
-		msgs, err := ch.Consume(
-			q.Name, // queue
-			"", // consumer
-			false, // auto-ack
-			false, // exclusive
-			false, // no-local
-			false, // no-wait
-			nil, // args
-		)
+	// job was canceled while it was waiting in the queue
+	if misc.CancelRequested(db.Jobs(), jb.Job.ID) {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": jb.Job.ID.Hex(),
+			"Name":   jb.Job.Name,
+		}).Infoln("Job canceled before start")
+		jobCancel(&jb)
+		d.Ack()
+		return
+	}
 
-		if err != nil {
-			logrus.WithFields(logrus.Fields{
-				"Queue": queue.Ansible,
-				"Error": err.Error(),
-			}).Errorln("Failed to register a consumer")
-			return
-		}
+	// the job waits in the queue while another job of its job template runs
+	if !jb.Job.AllowSimultaneous && misc.DeferTemplate(d, db.JobTemplates(), db.Jobs(), jb.Job.JobTemplateID, jb.Job.ID, "job") {
+		return
+	}
 
-		for d := range msgs {
-			jb := types.AnsibleJob{}
-			if err := json.Unmarshal(d.Body, &jb); err != nil {
-				// handle error
-				logrus.Warningln("Job delivery rejected")
-				d.Reject(false)
-				jobFail(&jb)
-				continue
-			}
+	// stale inventory sources are updated on the worker that runs the job
+	if !updateInventory(d, &jb) {
+		return
+	}
 
-			logrus.WithFields(logrus.Fields{
-				"Job ID": jb.Job.ID.Hex(),
-				"Name":   jb.Job.Name,
-			}).Infoln("Job successfuly received")
+	status(&jb, "pending")
 
-			status(&jb, "pending")
+	logrus.WithFields(logrus.Fields{
+		"Job ID": jb.Job.ID.Hex(),
+		"Name":   jb.Job.Name,
+	}).Infoln("Job changed status to pending")
 
-			logrus.WithFields(logrus.Fields{
-				"Job ID": jb.Job.ID.Hex(),
-				"Name":   jb.Job.Name,
-			}).Infoln("Job changed status to pending")
-
-			if jb.Job.JobType == ansible.JOBTYPE_UPDATE_JOB {
-				sync.Sync(types.SyncJob{
-					Job:           jb.Job,
-					JobTemplateID: jb.Template.ID,
-					ProjectID:     jb.Project.ID,
-					SCM:           jb.SCM,
-					Token:         jb.Token,
-					User:          jb.User,
-				})
-				d.Ack(false)
-				continue
-			}
-			ansibleRun(&jb)
-			d.Ack(false)
-		}
-		logrus.Warningln("Ansible consumer stopped")
-	}
+	ansibleRun(&jb)
+	d.Ack()
 }
 
 func ansibleRun(j *types.AnsibleJob) {
@@ -151,9 +121,8 @@ func ansibleRun(j *types.AnsibleJob) {
 		"Name":   j.Job.Name,
 	}).Infoln("Job starting")
 
-	// update if requested
-	if j.PreviousJob != nil {
-		// wait for scm update
+	// the checkout of the project is updated on the worker that runs the job
+	if _, err := os.Stat(j.Project.LocalPath); os.IsNotExist(err) || j.Project.ScmUpdateOnLaunch {
 		status(j, "waiting")
 
 		logrus.WithFields(logrus.Fields{
@@ -161,31 +130,25 @@ func ansibleRun(j *types.AnsibleJob) {
 			"Name":   j.Job.Name,
 		}).Infoln("Job changed status to waiting")
 
-		ticker := time.NewTicker(time.Second * 2)
-
-		for range ticker.C {
-			if err := db.Jobs().FindId(j.PreviousJob.Job.ID).One(&j.PreviousJob.Job); err != nil {
-				logrus.Warningln("Could not find Previous Job", err)
-				continue
+		update, err := sync.UpdateCheckout(j.Project)
+		j.PreviousJob = update
+		if err != nil || update.Job.Status != "successful" {
+			e := "Previous Task Failed: "
+			if update != nil {
+				e += "{\"job_type\": \"project_update\", \"job_name\": \"" + j.Job.Name + "\", \"job_id\": \"" + update.Job.ID.Hex() + "\"}"
+			} else {
+				e += err.Error()
 			}
+			logrus.Errorln(e)
+			j.Job.JobExplanation = e
+			j.Job.ResultStdout = "stdout capture is missing"
+			jobError(j)
+			return
+		}
 
-			if j.PreviousJob.Job.Status == "failed" || j.PreviousJob.Job.Status == "error" {
-				e := "Previous Task Failed: {\"job_type\": \"project_update\", \"job_name\": \"" + j.Job.Name + "\", \"job_id\": \"" + j.PreviousJob.Job.ID.Hex() + "\"}"
-				logrus.Errorln(e)
-				j.Job.JobExplanation = e
-				j.Job.ResultStdout = "stdout capture is missing"
-				jobError(j)
-				return
-			}
-			if j.PreviousJob.Job.Status == "successful" {
-				// stop the ticker and break the loop
-				logrus.WithFields(logrus.Fields{
-					"Job ID": j.PreviousJob.Job.ID.Hex(),
-					"Name":   j.PreviousJob.Job.Name,
-				}).Infoln("Update job successful")
-				ticker.Stop()
-				break
-			}
+		if misc.CancelRequested(db.Jobs(), j.Job.ID) {
+			jobCancel(j)
+			return
 		}
 	}
 
@@ -199,95 +162,37 @@ func ansibleRun(j *types.AnsibleJob) {
 	// Start SSH agent
 	client, socket, pid, sshcleanup := ssh.StartAgent()
 
-	if len(j.Machine.SSHKeyData) > 0 {
-		if len(j.Machine.SSHKeyUnlock) > 0 {
-			key, err := ssh.GetKey(util.Decipher(j.Machine.SSHKeyData), util.Decipher(j.Machine.SSHKeyUnlock))
-			if err != nil {
-				logrus.WithFields(logrus.Fields{
-					"Error": err.Error(),
-				}).Errorln("Error while decyrpting Machine Credential")
-				j.Job.JobExplanation = err.Error()
-				jobFail(j)
-				return
-			}
-			if client.Add(key); err != nil {
-				logrus.WithFields(logrus.Fields{
-					"Error": err.Error(),
-				}).Errorln("Error while adding decyrpted Machine Credential to SSH Agent")
-				j.Job.JobExplanation = err.Error()
-				jobFail(j)
-				return
-			}
-		}
-
-		key, err := ssh.GetKey(util.Decipher(j.Machine.SSHKeyData), nil)
-		if err != nil {
-			logrus.WithFields(logrus.Fields{
-				"Error": err.Error(),
-			}).Errorln("Error while decyrpting Machine Credential")
-			j.Job.JobExplanation = err.Error()
-			jobFail(j)
-			return
-		}
-
-		if client.Add(key); err != nil {
-			logrus.WithFields(logrus.Fields{
-				"Error": err.Error(),
-			}).Errorln("Error while adding decyrpted Machine Credential to SSH Agent")
-			j.Job.JobExplanation = err.Error()
-			jobFail(j)
-			return
-		}
-
+	if err := misc.AddSSHKey(client, j.Machine); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while adding Machine Credential to SSH Agent")
+		sshcleanup()
+		j.Job.JobExplanation = err.Error()
+		jobFail(j)
+		return
 	}
 
-	if len(j.Network.SSHKeyData) > 0 {
-		if len(j.Network.SSHKeyUnlock) > 0 {
-			key, err := ssh.GetKey(util.Decipher(j.Machine.SSHKeyData), util.Decipher(j.Network.SSHKeyUnlock))
-			if err != nil {
-				logrus.WithFields(logrus.Fields{
-					"Error": err.Error(),
-				}).Errorln("Error while decyrpting Machine Credential")
-				j.Job.JobExplanation = err.Error()
-				jobFail(j)
-				return
-			}
-			if client.Add(key); err != nil {
-				logrus.WithFields(logrus.Fields{
-					"Error": err.Error(),
-				}).Errorln("Error while adding decyrpted Machine Credential to SSH Agent")
-				j.Job.JobExplanation = err.Error()
-				jobFail(j)
-				return
-			}
-		}
-
-		key, err := ssh.GetKey(util.Decipher(j.Machine.SSHKeyData), nil)
-		if err != nil {
-			logrus.WithFields(logrus.Fields{
-				"Error": err.Error(),
-			}).Errorln("Error while decyrpting Machine Credential")
-			j.Job.JobExplanation = err.Error()
-			jobFail(j)
-			return
-		}
-
-		if client.Add(key); err != nil {
-			logrus.WithFields(logrus.Fields{
-				"Error": err.Error(),
-			}).Errorln("Error while adding decyrpted Machine Credential to SSH Agent")
-			j.Job.JobExplanation = err.Error()
-			jobFail(j)
-			return
-		}
-
+	if err := misc.AddSSHKey(client, j.Network); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while adding Network Credential to SSH Agent")
+		sshcleanup()
+		j.Job.JobExplanation = err.Error()
+		jobFail(j)
+		return
 	}
 
+	// secrets injected into the job are scrubbed from everything that is persisted
+	redactor := jobRedactor(j)
+
 	cmd, cleanup, err := getCmd(j, socket, pid)
+	j.Job.JobARGS = redactor.Strings(j.Job.JobARGS)
+	j.Job.JobENV = redactor.Strings(j.Job.JobENV)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"Error": err.Error(),
 		}).Errorln("Running playbook failed")
+		sshcleanup()
 		j.Job.ResultStdout = "stdout capture is missing"
 		j.Job.JobExplanation = err.Error()
 		jobFail(j)
@@ -305,9 +210,11 @@ func ansibleRun(j *types.AnsibleJob) {
 		cleanup()
 	}()
 
-	var b bytes.Buffer
-	cmd.Stdout = &b
-	cmd.Stderr = &b
+	// output is persisted in chunks while the job is running
+	b := misc.NewStdoutWriter(j.Job.ID, redactor)
+	defer b.Close()
+	cmd.Stdout = b
+	cmd.Stderr = b
 
 	// Set setsid to create a new session, The new process group has no controlling
 	// terminal which disables the stdin & will skip prompts
@@ -324,12 +231,29 @@ func ansibleRun(j *types.AnsibleJob) {
 	}
 
 	var timer *time.Timer
-	timer = time.AfterFunc(time.Duration(util.Config.AnsibleJobTimeOut) * time.Second, func() {
+	timer = time.AfterFunc(misc.Timeout(j.Job.Timeout, util.Config.AnsibleJobTimeOut), func() {
 		logrus.Println("Killing the process. Execution exceeded threashold value")
-		cmd.Process.Kill()
+		misc.KillProcessGroup(cmd)
 	})
 
-	if err := cmd.Wait(); err != nil {
+	// kill the process group if the job is canceled through the API
+	stopWatch := misc.WatchCancel(cmd, func() bool {
+		return misc.CancelRequested(db.Jobs(), j.Job.ID)
+	})
+
+	err = cmd.Wait()
+	if stopWatch() {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": j.Job.ID.Hex(),
+			"Name":   j.Job.Name,
+		}).Infoln("Job canceled")
+		timer.Stop()
+		j.Job.ResultStdout = string(b.Bytes())
+		jobCancel(j)
+		return
+	}
+
+	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"Error": err.Error(),
 		}).Errorln("Running playbook failed")
@@ -372,49 +296,36 @@ func getCmd(j *types.AnsibleJob, socket string, pid int) (cmd *exec.Cmd, cleanup
 		"-b", j.Paths.VarLibProjects + ":" + util.Config.ProjectsHome,
 		"-b", j.Paths.VarLog + ":/var/log",
 		"-b", j.Paths.TmpRand + ":" + j.Paths.TmpRand,
+		"-b", j.Paths.CredentialPath + ":" + j.Paths.CredentialPath,
 		"-b", filepath.Join(util.Config.ProjectsHome, j.Project.ID.Hex()) + ":" + filepath.Join(util.Config.ProjectsHome, j.Project.ID.Hex()),
 		"-b", "/var/lib/tensor:/var/lib/tensor",
 		"-w", filepath.Join(util.Config.ProjectsHome, j.Project.ID.Hex()),
 	}
 
-	// create job directories
+	// create job directories, they are removed on every error
 	createTmpDirs(j)
+	cleanup = func() {
+		removeTmpDirs(j, tmp)
+	}
 	// ansible-playbook parameters
 	pPlaybook := []string{
 		"ansible-playbook", "-i", "/var/lib/tensor/plugins/inventory/tensorrest.py",
 	}
 	pPlaybook = buildParams(*j, pPlaybook)
 	// parameters that are hidden from output
-	pSecure := []string{}
-	// check whether the username not empty
-	if len(j.Machine.Username) > 0 {
-		uname := j.Machine.Username
-		// append domain if exist
-		if len(j.Machine.Domain) > 0 {
-			uname = j.Machine.Username + "@" + j.Machine.Domain
-		}
-		pPlaybook = append(pPlaybook, "-u", uname)
-		pPlaybook = append(pPlaybook, "-e", "ansible_user=" + uname) // Windows
-		if len(j.Machine.Password) > 0 {
-			pSecure = append(pSecure, "-e", "ansible_password=" + string(util.Decipher(j.Machine.Password)))
-		}
-	}
-
-	if j.Job.BecomeEnabled {
-		pPlaybook = append(pPlaybook, "-b")
-		// default become method is sudo
-		if len(j.Machine.BecomeMethod) > 0 {
-			pPlaybook = append(pPlaybook, "--become-method=" + j.Machine.BecomeMethod)
-		}
-		// default become user is root
-		if len(j.Machine.BecomeUsername) > 0 {
-			pPlaybook = append(pPlaybook, "--become-user=" + j.Machine.BecomeUsername)
-		}
-		// for now this is more convenient than --ask-become-pass with sshpass
-		if len(j.Machine.BecomePassword) > 0 {
-			pSecure = append(pSecure, "-e", "ansible_become_password=" + string(util.Decipher(j.Machine.BecomePassword)))
-		}
+	pMachine, pSecure := machineParams(j.Machine, j.Job.BecomeEnabled)
+	pPlaybook = append(pPlaybook, pMachine...)
+	pPlaybook = append(pPlaybook, misc.WinRMParams(j.Machine)...)
+	pNetwork, pNetworkSecure := misc.NetworkParams(j.Network, j.Job.NetworkConnection, j.Job.NetworkOS)
+	pPlaybook = append(pPlaybook, pNetwork...)
+	pSecure = append(pSecure, pNetworkSecure...)
+	// vault passwords are read from scripts in the credential directory
+	pVault, err := misc.VaultParams(j.Vaults, j.Paths.CredentialPath)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
 	}
+	pPlaybook = append(pPlaybook, pVault...)
 	pargs = append(pargs, pPlaybook...)
 	j.Job.JobARGS = pargs
 	// should not included in any output
@@ -471,10 +382,23 @@ func getCmd(j *types.AnsibleJob, socket string, pid int) (cmd *exec.Cmd, cleanup
 		"SSH_AUTH_SOCK=" + socket,
 		"SSH_AGENT_PID=" + strconv.Itoa(pid),
 	}
-	var f *os.File
-	if j.Cloud.Cloud {
-		cmd.Env, f, err = misc.GetCloudCredential(cmd.Env, j.Cloud)
+	// network credentials for modules that take a provider argument
+	netEnv, netSecure := misc.NetworkEnv(j.Network)
+	cmd.Env = append(append(cmd.Env, netEnv...), netSecure...)
+	j.Job.JobENV = append(j.Job.JobENV, netEnv...)
+	// kerberos ticket of windows credentials, in a cache of the job
+	krbEnv, err := misc.Kinit(j.Machine, j.Job.OrganizationID, j.Paths.CredentialPath)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	cmd.Env = append(cmd.Env, krbEnv...)
+	j.Job.JobENV = append(j.Job.JobENV, krbEnv...)
+	// cloud credential files are created in the credential directory
+	if len(j.Cloud.ID) > 0 {
+		cmd.Env, _, err = misc.GetCloudCredential(cmd.Env, j.Cloud, j.Paths.CredentialPath)
 		if err != nil {
+			cleanup()
 			return nil, nil, err
 		}
 	}
@@ -482,30 +406,42 @@ func getCmd(j *types.AnsibleJob, socket string, pid int) (cmd *exec.Cmd, cleanup
 		"Dir":         cmd.Dir,
 		"Environment": append([]string{}, cmd.Env...),
 	}).Debugln("Job Directory and Environment")
-	return cmd, func() {
-		if f != nil {
-			if err := os.RemoveAll(f.Name()); err != nil {
-				logrus.Errorln("Unable to remove cloud credential")
-			}
-		}
+	return cmd, cleanup, nil
+}
 
-		if err := os.RemoveAll(tmp); err != nil {
-			logrus.Errorln("Unable to remove tmp directories")
+// machineParams returns the ansible parameters for the machine credential,
+// secure parameters contain passwords and must not be included in any output
+func machineParams(machine common.Credential, become bool) (params []string, secure []string) {
+	// check whether the username not empty
+	if len(machine.Username) > 0 {
+		uname := machine.Username
+		// append domain if exist
+		if len(machine.Domain) > 0 {
+			uname = machine.Username + "@" + machine.Domain
+		}
+		params = append(params, "-u", uname)
+		params = append(params, "-e", "ansible_user=" + uname) // Windows
+		if len(machine.Password) > 0 {
+			secure = append(secure, "-e", "ansible_password=" + string(util.Decipher(machine.Password)))
 		}
+	}
 
-		if err := os.RemoveAll(j.Paths.TmpRand); err != nil {
-			logrus.Errorln("Unable to remove tmp random tmp dir")
+	if become {
+		params = append(params, "-b")
+		// default become method is sudo
+		if len(machine.BecomeMethod) > 0 {
+			params = append(params, "--become-method=" + machine.BecomeMethod)
 		}
-		if err := os.RemoveAll(j.Paths.CredentialPath); err != nil {
-			logrus.Errorln("Unable to remove credential directories")
+		// default become user is root
+		if len(machine.BecomeUsername) > 0 {
+			params = append(params, "--become-user=" + machine.BecomeUsername)
 		}
-
-		if j.Machine.Kind == common.CredentialKindWIN {
-			if err := exec.Command("kdestroy").Run(); err != nil {
-				logrus.Errorln("kdestroy failed")
-			}
+		// for now this is more convenient than --ask-become-pass with sshpass
+		if len(machine.BecomePassword) > 0 {
+			secure = append(secure, "-e", "ansible_become_password=" + string(util.Decipher(machine.BecomePassword)))
 		}
-	}, nil
+	}
+	return
 }
 
 func buildParams(j types.AnsibleJob, params []string) []string {
@@ -607,3 +543,22 @@ func createTmpDirs(j *types.AnsibleJob) (err error) {
 	}
 	return
 }
+
+// removeTmpDirs destroys the kerberos ticket of the job and removes the
+// directories created by createTmpDirs below tmp and the credential files
+func removeTmpDirs(j *types.AnsibleJob, tmp string) {
+	if err := os.RemoveAll(tmp); err != nil {
+		logrus.Errorln("Unable to remove tmp directories")
+	}
+
+	if err := os.RemoveAll(j.Paths.TmpRand); err != nil {
+		logrus.Errorln("Unable to remove tmp random tmp dir")
+	}
+	// destroy the ticket before its cache is removed
+	if err := misc.Kdestroy(j.Paths.CredentialPath); err != nil {
+		logrus.Errorln("kdestroy failed")
+	}
+	if err := os.RemoveAll(j.Paths.CredentialPath); err != nil {
+		logrus.Errorln("Unable to remove credential directories")
+	}
+}
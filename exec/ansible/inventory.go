@@ -0,0 +1,62 @@
+package ansible
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/pearsonappeng/tensor/db"
+	execinventory "github.com/pearsonappeng/tensor/exec/inventory"
+	"github.com/pearsonappeng/tensor/exec/misc"
+	"github.com/pearsonappeng/tensor/exec/types"
+	"github.com/pearsonappeng/tensor/queue"
+)
+
+// updateInventory updates the stale inventory sources of the job on the
+// worker that runs the job. It returns false if the job can not run: the
+// message of a job that waits for the update of another job is deferred,
+// a job that was canceled or whose update failed is finished.
+func updateInventory(d queue.Message, j *types.AnsibleJob) bool {
+	updates, holder, err := execinventory.UpdateStaleSources(j.Inventory.ID, j.User)
+	if err != nil {
+		e := "Previous Task Failed: " + err.Error()
+		logrus.Errorln(e)
+		j.Job.JobExplanation = e
+		j.Job.ResultStdout = "stdout capture is missing"
+		jobError(j)
+		d.Ack()
+		return false
+	}
+
+	for _, update := range updates {
+		if update.Job.Status == "successful" {
+			logrus.WithFields(logrus.Fields{
+				"Job ID": update.Job.ID.Hex(),
+				"Name":   update.Job.Name,
+			}).Infoln("Inventory update successful")
+			continue
+		}
+
+		e := "Previous Task Failed: {\"job_type\": \"inventory_update\", \"job_name\": \"" + update.Job.Name + "\", \"job_id\": \"" + update.Job.ID.Hex() + "\"}"
+		logrus.Errorln(e)
+		j.Job.JobExplanation = e
+		j.Job.ResultStdout = "stdout capture is missing"
+		jobError(j)
+		d.Ack()
+		return false
+	}
+
+	if holder != nil {
+		logrus.WithFields(logrus.Fields{
+			"Job ID":     j.Job.ID.Hex(),
+			"Blocked By": holder.Hex(),
+		}).Debugln("Job waiting for an inventory update")
+		misc.DeferJob(d, db.Jobs(), j.Job.ID, "inventory_update", *holder)
+		return false
+	}
+	misc.StopWaiting(db.Jobs(), j.Job.ID)
+
+	if len(updates) > 0 && misc.CancelRequested(db.Jobs(), j.Job.ID) {
+		jobCancel(j)
+		d.Ack()
+		return false
+	}
+	return true
+}
@@ -0,0 +1,257 @@
+package ansible
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/exec/misc"
+	"github.com/pearsonappeng/tensor/exec/types"
+	"github.com/pearsonappeng/tensor/jwt"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/queue"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// NewJob creates a new job with the settings of the job template
+func NewJob(template ansible.JobTemplate, user common.User) ansible.Job {
+	return ansible.Job{
+		ID:                  bson.NewObjectId(),
+		Name:                template.Name,
+		Description:         template.Description,
+		LaunchType:          ansible.JOB_LAUNCH_TYPE_MANUAL,
+		CancelFlag:          false,
+		Status:              "new",
+		JobType:             ansible.JOBTYPE_ANSIBLE_JOB,
+		Playbook:            template.Playbook,
+		Forks:               template.Forks,
+		Limit:               template.Limit,
+		Verbosity:           template.Verbosity,
+		ExtraVars:           template.ExtraVars,
+		JobTags:             template.JobTags,
+		SkipTags:            template.SkipTags,
+		ForceHandlers:       template.ForceHandlers,
+		StartAtTask:         template.StartAtTask,
+		MachineCredentialID: template.MachineCredentialID,
+		InventoryID:         template.InventoryID,
+		JobTemplateID:       template.ID,
+		ProjectID:           template.ProjectID,
+		BecomeEnabled:       template.BecomeEnabled,
+		NetworkCredentialID: template.NetworkCredentialID,
+		CloudCredentialID:   template.CloudCredentialID,
+		SCMCredentialID:     "",
+		CreatedByID:         user.ID,
+		ModifiedByID:        user.ID,
+		Created:             time.Now(),
+		Modified:            time.Now(),
+		PromptCredential:    template.PromptCredential,
+		PromptInventory:     template.PromptInventory,
+		PromptJobType:       template.PromptJobType,
+		PromptLimit:         template.PromptLimit,
+		PromptTags:          template.PromptTags,
+		PromptVariables:     template.PromptVariables,
+		AllowSimultaneous:   template.AllowSimultaneous,
+		Priority:            template.Priority,
+		SecretVars:          template.SecretVars,
+		VaultCredentialIDs:  template.VaultCredentialIDs,
+		NetworkConnection:   template.NetworkConnection,
+		NetworkOS:           template.NetworkOS,
+	}
+}
+
+// Launch loads the credentials, inventory and project of the job,
+// stores the job and publishes it to the ansible queue
+func Launch(job ansible.Job, template ansible.JobTemplate, user common.User) error {
+	// create new Ansible runner Job
+	runnerJob := types.AnsibleJob{
+		Job:      job,
+		Template: template,
+		User:     user,
+	}
+
+	if job.NetworkCredentialID != nil {
+		var credential common.Credential
+		if err := db.Credentials().FindId(*job.NetworkCredentialID).One(&credential); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Error": err.Error(),
+			}).Errorln("Error while getting network credential")
+			return errors.New("Error while getting network credential")
+		}
+		runnerJob.Network = credential
+	}
+
+	if job.CloudCredentialID != nil {
+		var credential common.Credential
+		if err := db.Credentials().FindId(*job.CloudCredentialID).One(&credential); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Error": err.Error(),
+			}).Errorln("Error while getting cloud credential")
+			return errors.New("Error while getting cloud credential")
+		}
+		runnerJob.Cloud = credential
+	}
+
+	var inventory ansible.Inventory
+	if err := db.Inventories().FindId(job.InventoryID).One(&inventory); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while getting inventory")
+		return errors.New("Error while getting inventory")
+	}
+	runnerJob.Inventory = inventory
+
+	if job.MachineCredentialID != nil {
+		var credential common.Credential
+		if err := db.Credentials().FindId(*job.MachineCredentialID).One(&credential); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Error": err.Error(),
+			}).Errorln("Error while getting machine credential")
+			return errors.New("Error while getting machine credential")
+		}
+		runnerJob.Machine = credential
+	}
+
+	if len(job.VaultCredentialIDs) > 0 {
+		if err := db.Credentials().Find(bson.M{"_id": bson.M{"$in": job.VaultCredentialIDs}}).All(&runnerJob.Vaults); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Error": err.Error(),
+			}).Errorln("Error while getting vault credentials")
+			return errors.New("Error while getting vault credentials")
+		}
+	}
+
+	// get project information
+	var project common.Project
+	if err := db.Projects().FindId(job.ProjectID).One(&project); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while getting project")
+		return errors.New("Error while getting project")
+	}
+	runnerJob.Project = project
+
+	// jobs of an organization take turns with the jobs of other organizations
+	job.OrganizationID = project.OrganizationID
+	runnerJob.Job.OrganizationID = project.OrganizationID
+
+	// the organization limits the number of concurrent jobs and their run time
+	timeout, err := misc.CheckQuota(project.OrganizationID)
+	if err != nil {
+		return err
+	}
+	job.Timeout = timeout
+	runnerJob.Job.Timeout = timeout
+
+	// Get jwt token for authorize Ansible inventory plugin
+	var token jwt.LocalToken
+	if err := jwt.NewAuthToken(&token); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while getting token")
+		return errors.New("Error while getting token")
+	}
+	runnerJob.Token = token.Token
+
+	// Insert new job into jobs collection
+	if err := db.Jobs().Insert(job); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while creating job")
+		return errors.New("Error while creating job")
+	}
+
+	jobBytes, err := json.Marshal(runnerJob)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while encoding the job")
+		return errors.New("Error while encoding the job")
+	}
+
+	// publish bytes to ansible queue
+	opts := queue.Options{Priority: job.Priority, Group: job.OrganizationID.Hex()}
+	if err := queue.Publish(queue.Ansible, jobBytes, opts); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while publishing to Queue")
+		misc.PublishFailed(db.Jobs(), job.ID, err)
+		return err
+	}
+
+	return nil
+}
+
+// LaunchAdHocCommand loads the machine credential and the inventory of the
+// ad hoc command, stores the command and publishes it to the ansible queue
+func LaunchAdHocCommand(command ansible.AdHocCommand, user common.User) error {
+	runnerJob := types.AnsibleJob{
+		AdHocCommand: &command,
+		User:         user,
+	}
+
+	var credential common.Credential
+	if err := db.Credentials().FindId(command.CredentialID).One(&credential); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while getting machine credential")
+		return errors.New("Error while getting machine credential")
+	}
+	runnerJob.Machine = credential
+
+	var inventory ansible.Inventory
+	if err := db.Inventories().FindId(command.InventoryID).One(&inventory); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while getting inventory")
+		return errors.New("Error while getting inventory")
+	}
+	runnerJob.Inventory = inventory
+	command.OrganizationID = inventory.OrganizationID
+
+	// the organization limits the number of concurrent jobs and their run time
+	timeout, err := misc.CheckQuota(inventory.OrganizationID)
+	if err != nil {
+		return err
+	}
+	command.Timeout = timeout
+
+	// Get jwt token for authorize Ansible inventory plugin
+	var token jwt.LocalToken
+	if err := jwt.NewAuthToken(&token); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while getting token")
+		return errors.New("Error while getting token")
+	}
+	runnerJob.Token = token.Token
+
+	if err := db.AdHocCommands().Insert(command); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while creating ad hoc command")
+		return errors.New("Error while creating ad hoc command")
+	}
+
+	jobBytes, err := json.Marshal(runnerJob)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while encoding the ad hoc command")
+		return errors.New("Error while encoding the ad hoc command")
+	}
+
+	// publish bytes to ansible queue
+	opts := queue.Options{Priority: command.Priority, Group: command.OrganizationID.Hex()}
+	if err := queue.Publish(queue.Ansible, jobBytes, opts); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while publishing to Queue")
+		misc.PublishFailed(db.AdHocCommands(), command.ID, err)
+		return err
+	}
+
+	return nil
+}
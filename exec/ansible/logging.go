@@ -7,7 +7,10 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/exec/misc"
 	"github.com/pearsonappeng/tensor/exec/types"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/notification"
 )
 
 func start(t *types.AnsibleJob) {
@@ -46,10 +49,21 @@ func status(t *types.AnsibleJob, s string) {
 	}
 }
 
+// jobRedactor returns the Redactor of the secrets injected into the job
+func jobRedactor(t *types.AnsibleJob) *misc.Redactor {
+	redactor := misc.NewRedactor(append([]common.Credential{t.Machine, t.Network, t.Cloud}, t.Vaults...)...)
+	redactor.Add(t.Token)
+	redactor.AddVars(t.Job.ExtraVars, t.Job.SecretVars)
+	return redactor
+}
+
 func jobFail(t *types.AnsibleJob) {
 	t.Job.Status = "failed"
 	t.Job.Finished = time.Now()
 	t.Job.Failed = true
+	t.Job.JobExplanation = jobRedactor(t).String(t.Job.JobExplanation)
+
+	misc.SaveStdout(t.Job.ID, t.Job.ResultStdout)
 
 	//get elapsed time in minutes
 	diff := t.Job.Finished.Sub(t.Job.Started)
@@ -60,7 +74,6 @@ func jobFail(t *types.AnsibleJob) {
 			"failed":          t.Job.Failed,
 			"finished":        t.Job.Finished,
 			"elapsed":         diff.Minutes(),
-			"result_stdout":   t.Job.ResultStdout,
 			"job_explanation": t.Job.JobExplanation,
 			"job_args":        t.Job.JobARGS,
 			"job_env":         t.Job.JobENV,
@@ -75,26 +88,34 @@ func jobFail(t *types.AnsibleJob) {
 		}).Errorln("Failed to update job status")
 	}
 
+	updateHostSummaries(t)
 	updateProject(t)
 	updateJobTemplate(t)
+	notify(t)
 }
 
 func jobCancel(t *types.AnsibleJob) {
 	t.Job.Status = "canceled"
+	t.Job.CancelFlag = true
 	t.Job.Finished = time.Now()
 	t.Job.Failed = false
 
+	if len(t.Job.ResultStdout) == 0 {
+		t.Job.ResultStdout = "stdout capture is missing"
+	}
+
+	misc.SaveStdout(t.Job.ID, t.Job.ResultStdout)
+
 	//get elapsed time in minutes
 	diff := t.Job.Finished.Sub(t.Job.Started)
 
 	d := bson.M{
 		"$set": bson.M{
 			"status":          t.Job.Status,
-			"cancel_flag":     true,
+			"cancel_flag":     t.Job.CancelFlag,
 			"failed":          t.Job.Failed,
 			"finished":        t.Job.Finished,
 			"elapsed":         diff.Minutes(),
-			"result_stdout":   "stdout capture is missing",
 			"job_explanation": "Job Cancelled",
 			"job_args":        t.Job.JobARGS,
 			"job_env":         t.Job.JobENV,
@@ -109,6 +130,7 @@ func jobCancel(t *types.AnsibleJob) {
 		}).Errorln("Failed to update job status")
 	}
 
+	updateHostSummaries(t)
 	updateProject(t)
 	updateJobTemplate(t)
 }
@@ -117,6 +139,9 @@ func jobError(t *types.AnsibleJob) {
 	t.Job.Status = "error"
 	t.Job.Finished = time.Now()
 	t.Job.Failed = true
+	t.Job.JobExplanation = jobRedactor(t).String(t.Job.JobExplanation)
+
+	misc.SaveStdout(t.Job.ID, t.Job.ResultStdout)
 
 	//get elapsed time in minutes
 	diff := t.Job.Finished.Sub(t.Job.Started)
@@ -127,7 +152,6 @@ func jobError(t *types.AnsibleJob) {
 			"failed":          t.Job.Failed,
 			"finished":        t.Job.Finished,
 			"elapsed":         diff.Minutes(),
-			"result_stdout":   t.Job.ResultStdout,
 			"job_explanation": t.Job.JobExplanation,
 			"job_args":        t.Job.JobARGS,
 			"job_env":         t.Job.JobENV,
@@ -142,14 +166,19 @@ func jobError(t *types.AnsibleJob) {
 		}).Errorln("Failed to update job status")
 	}
 
+	updateHostSummaries(t)
 	updateProject(t)
 	updateJobTemplate(t)
+	notify(t)
 }
 
 func jobSuccess(t *types.AnsibleJob) {
 	t.Job.Status = "successful"
 	t.Job.Finished = time.Now()
 	t.Job.Failed = false
+	t.Job.JobExplanation = jobRedactor(t).String(t.Job.JobExplanation)
+
+	misc.SaveStdout(t.Job.ID, t.Job.ResultStdout)
 
 	//get elapsed time in minutes
 	diff := t.Job.Finished.Sub(t.Job.Started)
@@ -160,7 +189,6 @@ func jobSuccess(t *types.AnsibleJob) {
 			"failed":          t.Job.Failed,
 			"finished":        t.Job.Finished,
 			"elapsed":         diff.Minutes(),
-			"result_stdout":   t.Job.ResultStdout,
 			"job_explanation": t.Job.JobExplanation,
 			"job_args":        t.Job.JobARGS,
 			"job_env":         t.Job.JobENV,
@@ -175,8 +203,10 @@ func jobSuccess(t *types.AnsibleJob) {
 		}).Errorln("Failed to update job status")
 	}
 
+	updateHostSummaries(t)
 	updateProject(t)
 	updateJobTemplate(t)
+	notify(t)
 }
 
 func updateProject(t *types.AnsibleJob) {
@@ -209,4 +239,21 @@ func updateJobTemplate(t *types.AnsibleJob) {
 			"Error":  err,
 		}).Errorln("Failed to update JobTemplate")
 	}
+
+	misc.ReleaseTemplate(db.JobTemplates(), t.Template.ID, t.Job.ID)
+}
+
+// notify sends the notifications of the finished job in the background
+func notify(t *types.AnsibleJob) {
+	go notification.JobFinished(notification.Job{
+		ID:             t.Job.ID,
+		Type:           notification.JobTypeAnsible,
+		Name:           t.Job.Name,
+		Status:         t.Job.Status,
+		Failed:         t.Job.Failed,
+		Started:        t.Job.Started,
+		Finished:       t.Job.Finished,
+		TemplateID:     t.Template.ID,
+		OrganizationID: t.Project.OrganizationID,
+	})
 }
@@ -0,0 +1,129 @@
+package ansible
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/pearsonappeng/tensor/db"
+	execinventory "github.com/pearsonappeng/tensor/exec/inventory"
+	"github.com/pearsonappeng/tensor/exec/misc"
+	"github.com/pearsonappeng/tensor/exec/sync"
+	"github.com/pearsonappeng/tensor/exec/types"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"github.com/pearsonappeng/tensor/queue"
+	"github.com/pearsonappeng/tensor/worker"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// claim makes the worker of this process the owner of the job. It returns
+// false if the job was started before, the job is reaped when its worker
+// stops heartbeating. A message whose job could not be claimed is returned
+// to the queue, a message of a job that was started before is acknowledged.
+func claim(d queue.Message, j *types.AnsibleJob) bool {
+	jobs, id := db.Jobs(), j.Job.ID
+	if j.AdHocCommand != nil {
+		jobs, id = db.AdHocCommands(), j.AdHocCommand.ID
+	}
+
+	ok, err := misc.ClaimJob(jobs, id, worker.ID)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": id.Hex(),
+			"Error":  err.Error(),
+		}).Errorln("Failed to claim job")
+		d.Defer(misc.ClaimRetryDelay)
+		return false
+	}
+	if !ok {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": id.Hex(),
+		}).Warningln("Job was started before, skipping delivery")
+		d.Ack()
+	}
+	return ok
+}
+
+// Reap finishes the jobs and ad hoc commands of a stopped worker with
+// status error, the output captured so far is kept
+func Reap(workerID bson.ObjectId) {
+	var jobs []ansible.Job
+	if err := misc.OrphanedJobs(db.Jobs(), workerID, &jobs); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Worker ID": workerID.Hex(),
+			"Error":     err.Error(),
+		}).Errorln("Failed to get jobs of the worker")
+	}
+
+	for _, job := range jobs {
+		if !reapable(db.Jobs(), job.ID, workerID) {
+			continue
+		}
+
+		job.JobExplanation = misc.OrphanExplanation(workerID)
+		job.ResultStdout = capturedStdout(job.ID)
+
+		switch job.JobType {
+		case ansible.JOBTYPE_UPDATE_JOB:
+			sync.Reap(job)
+		case ansible.JOBTYPE_INVENTORY_UPDATE:
+			execinventory.Reap(job)
+		default:
+			t := types.AnsibleJob{Job: job}
+			t.Template.ID = job.JobTemplateID
+			t.Project.ID = job.ProjectID
+			db.JobTemplates().FindId(job.JobTemplateID).One(&t.Template)
+			db.Projects().FindId(job.ProjectID).One(&t.Project)
+			jobError(&t)
+		}
+	}
+
+	var commands []ansible.AdHocCommand
+	if err := misc.OrphanedJobs(db.AdHocCommands(), workerID, &commands); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Worker ID": workerID.Hex(),
+			"Error":     err.Error(),
+		}).Errorln("Failed to get ad hoc commands of the worker")
+	}
+
+	for i := range commands {
+		command := &commands[i]
+		if !reapable(db.AdHocCommands(), command.ID, workerID) {
+			continue
+		}
+
+		command.JobExplanation = misc.OrphanExplanation(workerID)
+		command.ResultStdout = capturedStdout(command.ID)
+		adHocFinish(&types.AnsibleJob{AdHocCommand: command}, "error")
+	}
+}
+
+// reapable marks the job as reaped, it returns false if the job was
+// finished or reaped by another process in the meantime
+func reapable(jobs *mgo.Collection, id bson.ObjectId, workerID bson.ObjectId) bool {
+	ok, err := misc.ReapJob(jobs, id, workerID)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": id.Hex(),
+			"Error":  err.Error(),
+		}).Errorln("Failed to reap job")
+		return false
+	}
+	if ok {
+		logrus.WithFields(logrus.Fields{
+			"Job ID":    id.Hex(),
+			"Worker ID": workerID.Hex(),
+		}).Warningln("Reaping job of a stopped worker")
+	}
+	return ok
+}
+
+func capturedStdout(id bson.ObjectId) string {
+	stdout, err := misc.CapturedStdout(id)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": id.Hex(),
+			"Error":  err.Error(),
+		}).Errorln("Failed to get captured stdout")
+		return "stdout capture is missing"
+	}
+	return stdout
+}
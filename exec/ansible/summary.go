@@ -0,0 +1,266 @@
+package ansible
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/exec/types"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// updateHostSummaries computes the per host recap of a finished job from its
+// job events, stores it in the job_host_summaries collection and updates the
+// last job and failure state of the hosts, groups and inventory of the job
+func updateHostSummaries(t *types.AnsibleJob) {
+	summaries, err := hostSummaries(t.Job.ID)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": t.Job.ID.Hex(),
+			"Error":  err,
+		}).Errorln("Failed to get job events")
+		return
+	}
+
+	if len(summaries) == 0 {
+		return
+	}
+
+	// a job is summarized once, remove leftovers of a previous attempt
+	if _, err := db.JobHostSummaries().RemoveAll(bson.M{"job_id": t.Job.ID}); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": t.Job.ID.Hex(),
+			"Error":  err,
+		}).Errorln("Failed to remove job host summaries")
+	}
+
+	now := time.Now()
+	for _, summary := range summaries {
+		summary.ID = bson.NewObjectId()
+		summary.JobID = t.Job.ID
+		summary.Failed = summary.Failures > 0 || summary.Unreachable > 0
+		summary.Created = now
+		summary.Modified = now
+
+		var host ansible.Host
+		err := db.Hosts().Find(bson.M{
+			"name":         summary.HostName,
+			"inventory_id": t.Inventory.ID,
+		}).Select(bson.M{"_id": 1}).One(&host)
+		if err == nil {
+			summary.HostID = &host.ID
+		}
+
+		if err := db.JobHostSummaries().Insert(summary); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Job ID": t.Job.ID.Hex(),
+				"Host":   summary.HostName,
+				"Error":  err,
+			}).Errorln("Failed to save job host summary")
+			continue
+		}
+
+		// hosts added at runtime are not part of the inventory
+		if summary.HostID == nil {
+			continue
+		}
+
+		d := bson.M{
+			"$set": bson.M{
+				"last_job_id":              t.Job.ID,
+				"last_job_host_summary_id": summary.ID,
+				"has_active_failures":      summary.Failed,
+			},
+		}
+		if err := db.Hosts().UpdateId(*summary.HostID, d); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Host ID": summary.HostID.Hex(),
+				"Error":   err,
+			}).Errorln("Failed to update host")
+		}
+	}
+
+	updateInventoryFailures(t)
+}
+
+// hostSummaries builds the recap of every host of a job. The playbook_on_stats
+// event is used when the playbook completed, otherwise the recap is counted
+// from the runner events that were received before the job ended.
+func hostSummaries(jobID bson.ObjectId) (map[string]*ansible.JobHostSummary, error) {
+	summaries := map[string]*ansible.JobHostSummary{}
+	get := func(host string) *ansible.JobHostSummary {
+		if _, ok := summaries[host]; !ok {
+			summaries[host] = &ansible.JobHostSummary{HostName: host}
+		}
+		return summaries[host]
+	}
+
+	var stats ansible.JobEvent
+	err := db.JobEvents().Find(bson.M{
+		"job_id": jobID,
+		"event":  ansible.EVENT_PLAYBOOK_ON_STATS,
+	}).Sort("-counter").One(&stats)
+	if err == nil {
+		for key, hosts := range stats.EventData {
+			for host, v := range toMap(hosts) {
+				n := toInt(v)
+				s := get(host)
+				switch key {
+				case "ok":
+					s.Ok = n
+				case "changed":
+					s.Changed = n
+				case "failures":
+					s.Failures = n
+				case "dark":
+					s.Unreachable = n
+				case "skipped":
+					s.Skipped = n
+				case "processed":
+					s.Processed = n
+				}
+			}
+		}
+		return summaries, nil
+	}
+
+	var events []ansible.JobEvent
+	if err := db.JobEvents().Find(bson.M{
+		"job_id":    jobID,
+		"host_name": bson.M{"$ne": ""},
+		"event": bson.M{"$in": []string{
+			ansible.EVENT_RUNNER_ON_OK,
+			ansible.EVENT_RUNNER_ON_FAILED,
+			ansible.EVENT_RUNNER_ON_UNREACHABLE,
+			ansible.EVENT_RUNNER_ON_SKIPPED,
+		}},
+	}).Select(bson.M{"host_name": 1, "event": 1, "failed": 1, "changed": 1}).All(&events); err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		s := get(event.HostName)
+		s.Processed = 1
+		switch event.Event {
+		case ansible.EVENT_RUNNER_ON_OK:
+			s.Ok++
+			if event.Changed {
+				s.Changed++
+			}
+		case ansible.EVENT_RUNNER_ON_FAILED:
+			if event.Failed {
+				s.Failures++
+			} else {
+				s.Ok++
+			}
+		case ansible.EVENT_RUNNER_ON_UNREACHABLE:
+			s.Unreachable++
+		case ansible.EVENT_RUNNER_ON_SKIPPED:
+			s.Skipped++
+		}
+	}
+
+	return summaries, nil
+}
+
+// updateInventoryFailures recalculates the failure counters of the groups
+// and the inventory of a job
+func updateInventoryFailures(t *types.AnsibleJob) {
+	var groups []ansible.Group
+	if err := db.Groups().Find(bson.M{"inventory_id": t.Inventory.ID}).
+		Select(bson.M{"_id": 1}).All(&groups); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Inventory ID": t.Inventory.ID.Hex(),
+			"Error":        err,
+		}).Errorln("Failed to get inventory groups")
+		return
+	}
+
+	var failedGroups int
+	for _, group := range groups {
+		failed, err := db.Hosts().Find(bson.M{
+			"group_id":            group.ID,
+			"has_active_failures": true,
+		}).Count()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Group ID": group.ID.Hex(),
+				"Error":    err,
+			}).Errorln("Failed to count failed hosts")
+			continue
+		}
+
+		if failed > 0 {
+			failedGroups++
+		}
+
+		d := bson.M{
+			"$set": bson.M{
+				"has_active_failures":        failed > 0,
+				"hosts_with_active_failures": failed,
+			},
+		}
+		if err := db.Groups().UpdateId(group.ID, d); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Group ID": group.ID.Hex(),
+				"Error":    err,
+			}).Errorln("Failed to update group")
+		}
+	}
+
+	failed, err := db.Hosts().Find(bson.M{
+		"inventory_id":        t.Inventory.ID,
+		"has_active_failures": true,
+	}).Count()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Inventory ID": t.Inventory.ID.Hex(),
+			"Error":        err,
+		}).Errorln("Failed to count failed hosts")
+		return
+	}
+
+	d := bson.M{
+		"$set": bson.M{
+			"has_active_failures":         failed > 0,
+			"hosts_with_active_failures":  failed,
+			"groups_with_active_failures": failedGroups,
+		},
+	}
+	if err := db.Inventories().UpdateId(t.Inventory.ID, d); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Inventory ID": t.Inventory.ID.Hex(),
+			"Error":        err,
+		}).Errorln("Failed to update inventory")
+	}
+}
+
+// toMap converts a document decoded from bson to a map
+func toMap(v interface{}) map[string]interface{} {
+	switch m := v.(type) {
+	case gin.H:
+		return m
+	case bson.M:
+		return m
+	case map[string]interface{}:
+		return m
+	}
+	return nil
+}
+
+// toInt converts a number decoded from bson to int
+func toInt(v interface{}) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	}
+	return 0
+}
@@ -0,0 +1,335 @@
+package inventory
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/exec/types"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"github.com/pearsonappeng/tensor/models/common"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// descendant reports whether the group with the given id is the ancestor
+// group or one of its descendants
+func descendant(id bson.ObjectId, ancestor bson.ObjectId) (bool, error) {
+	seen := map[bson.ObjectId]bool{}
+	for !seen[id] {
+		if id == ancestor {
+			return true, nil
+		}
+		seen[id] = true
+
+		var group ansible.Group
+		err := db.Groups().FindId(id).Select(bson.M{"parent_group_id": 1}).One(&group)
+		if err == mgo.ErrNotFound || (err == nil && group.ParentGroupID == nil) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		id = *group.ParentGroupID
+	}
+	return false, nil
+}
+
+// importData imports the groups, hosts and variables of the inventory script
+// output into the inventory of the source. Groups and hosts are matched by
+// name, the ones that do not exist are created and marked as imported by the
+// source. Imported groups without a parent and imported hosts without a group
+// are placed under the group of the source.
+func importData(j types.InventoryUpdateJob, d Data) error {
+	source := j.Source
+	now := time.Now()
+
+	if err := checkHostQuota(j, d); err != nil {
+		return err
+	}
+
+	groupIDs := map[string]bson.ObjectId{}
+	for _, name := range d.GroupNames() {
+		var group ansible.Group
+		err := db.Groups().Find(bson.M{"inventory_id": source.InventoryID, "name": name}).One(&group)
+		if err != nil && err != mgo.ErrNotFound {
+			return err
+		}
+		exists := err == nil
+
+		vars, err := mergeVars(group.Variables, d.Groups[name].Vars, source.OverwriteVars)
+		if err != nil {
+			return errors.New("Invalid variables of group " + name + ": " + err.Error())
+		}
+
+		if !exists {
+			group = ansible.Group{
+				ID:                  bson.NewObjectId(),
+				Name:                name,
+				Variables:           vars,
+				InventoryID:         source.InventoryID,
+				HasInventorySources: true,
+				InventorySourceID:   &source.ID,
+				CreatedByID:         j.User.ID,
+				ModifiedByID:        j.User.ID,
+				Created:             now,
+				Modified:            now,
+			}
+			if err := db.Groups().Insert(group); err != nil {
+				return err
+			}
+		} else if err := db.Groups().UpdateId(group.ID, bson.M{"$set": bson.M{
+			"variables":             vars,
+			"has_inventory_sources": true,
+			"modified":              now,
+		}}); err != nil {
+			return err
+		}
+
+		groupIDs[name] = group.ID
+	}
+
+	// only groups imported by the source are moved, groups created
+	// by users keep their parent
+	parents := d.Parents()
+	for _, name := range d.GroupNames() {
+		parent := source.GroupID
+		if p, ok := parents[name]; ok {
+			id := groupIDs[p]
+			parent = &id
+		}
+
+		update := bson.M{"$unset": bson.M{"parent_group_id": ""}}
+		if parent != nil {
+			// groups that are not imported by the source can be placed
+			// below the group, the group keeps its parent in that case
+			cycle, err := descendant(*parent, groupIDs[name])
+			if err != nil {
+				return err
+			}
+			if cycle {
+				continue
+			}
+			update = bson.M{"$set": bson.M{"parent_group_id": *parent}}
+		}
+
+		err := db.Groups().Update(bson.M{"_id": groupIDs[name], "inventory_source_id": source.ID}, update)
+		if err != nil && err != mgo.ErrNotFound {
+			return err
+		}
+	}
+
+	hostGroups := d.HostGroups()
+	for _, name := range d.HostNames() {
+		ids := []bson.ObjectId{}
+		for _, g := range hostGroups[name] {
+			ids = append(ids, groupIDs[g])
+		}
+		if len(ids) == 0 && source.GroupID != nil {
+			ids = append(ids, *source.GroupID)
+		}
+
+		var host ansible.Host
+		err := db.Hosts().Find(bson.M{"inventory_id": source.InventoryID, "name": name}).One(&host)
+		if err != nil && err != mgo.ErrNotFound {
+			return err
+		}
+		exists := err == nil
+
+		vars, err := mergeVars(host.Variables, d.HostVars[name], source.OverwriteVars)
+		if err != nil {
+			return errors.New("Invalid variables of host " + name + ": " + err.Error())
+		}
+
+		if !exists {
+			host = ansible.Host{
+				ID:                  bson.NewObjectId(),
+				Name:                name,
+				InventoryID:         source.InventoryID,
+				Variables:           vars,
+				Enabled:             true,
+				HasInventorySources: true,
+				InventorySourceID:   &source.ID,
+				CreatedByID:         j.User.ID,
+				ModifiedByID:        j.User.ID,
+				Created:             now,
+				Modified:            now,
+			}
+			if len(ids) > 0 {
+				host.GroupID = &ids[0]
+				host.GroupIDs = ids[1:]
+			}
+			if err := db.Hosts().Insert(host); err != nil {
+				return err
+			}
+			continue
+		}
+
+		set := bson.M{
+			"variables":             vars,
+			"has_inventory_sources": true,
+			"modified":              now,
+		}
+		unset := bson.M{}
+
+		if host.InventorySourceID != nil && *host.InventorySourceID == source.ID {
+			// the groups of hosts imported by the source are replaced
+			if len(ids) > 0 {
+				set["group_id"] = ids[0]
+				set["group_ids"] = ids[1:]
+			} else {
+				unset["group_id"] = ""
+				unset["group_ids"] = ""
+			}
+		} else {
+			// hosts created by users keep their group and
+			// become a member of the imported groups
+			primary, others := host.GroupID, host.GroupIDs
+			for i := range ids {
+				if primary == nil {
+					primary = &ids[i]
+				} else if ids[i] != *primary && !containsID(others, ids[i]) {
+					others = append(others, ids[i])
+				}
+			}
+			if primary != nil {
+				set["group_id"] = *primary
+			}
+			set["group_ids"] = others
+		}
+
+		update := bson.M{"$set": set}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+		if err := db.Hosts().UpdateId(host.ID, update); err != nil {
+			return err
+		}
+	}
+
+	if source.Overwrite {
+		return removeStale(source, d)
+	}
+
+	return nil
+}
+
+// checkHostQuota returns a common.QuotaError if the hosts created by the
+// import exceed the host quota of the organization of the inventory
+func checkHostQuota(j types.InventoryUpdateJob, d Data) error {
+	var organization common.Organization
+	if err := db.Organizations().FindId(j.Inventory.OrganizationID).One(&organization); err != nil {
+		return err
+	}
+	if organization.MaxHosts <= 0 {
+		return nil
+	}
+
+	names := d.HostNames()
+	existing, err := db.Hosts().Find(bson.M{
+		"inventory_id": j.Source.InventoryID,
+		"name":         bson.M{"$in": names},
+	}).Count()
+	if err != nil {
+		return err
+	}
+	add := len(names) - existing
+
+	// the stale hosts of the source are removed after the import
+	if j.Source.Overwrite {
+		stale, err := db.Hosts().Find(bson.M{
+			"inventory_source_id": j.Source.ID,
+			"name":                bson.M{"$nin": names},
+		}).Count()
+		if err != nil {
+			return err
+		}
+		add -= stale
+	}
+
+	return organization.CheckHosts(add)
+}
+
+// removeStale removes the hosts and groups imported by the source
+// which are no longer in the inventory script output
+func removeStale(source ansible.InventorySource, d Data) error {
+	if _, err := db.Hosts().RemoveAll(bson.M{
+		"inventory_source_id": source.ID,
+		"name":                bson.M{"$nin": d.HostNames()},
+	}); err != nil {
+		return err
+	}
+
+	var stale []ansible.Group
+	q := bson.M{"inventory_source_id": source.ID, "name": bson.M{"$nin": d.GroupNames()}}
+	if err := db.Groups().Find(q).Select(bson.M{"_id": 1}).All(&stale); err != nil {
+		return err
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	ids := []bson.ObjectId{}
+	for _, g := range stale {
+		ids = append(ids, g.ID)
+	}
+
+	if _, err := db.Groups().RemoveAll(bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+		return err
+	}
+
+	// move the remaining hosts and groups of the removed groups
+	// to the group of the source
+	moveHosts := bson.M{"$unset": bson.M{"group_id": ""}}
+	moveGroups := bson.M{"$unset": bson.M{"parent_group_id": ""}}
+	if source.GroupID != nil {
+		moveHosts = bson.M{"$set": bson.M{"group_id": *source.GroupID}}
+		moveGroups = bson.M{"$set": bson.M{"parent_group_id": *source.GroupID}}
+	}
+
+	if _, err := db.Hosts().UpdateAll(bson.M{"group_id": bson.M{"$in": ids}}, moveHosts); err != nil {
+		return err
+	}
+	if _, err := db.Hosts().UpdateAll(bson.M{"group_ids": bson.M{"$in": ids}},
+		bson.M{"$pull": bson.M{"group_ids": bson.M{"$in": ids}}}); err != nil {
+		return err
+	}
+	if _, err := db.Groups().UpdateAll(bson.M{"parent_group_id": bson.M{"$in": ids}}, moveGroups); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// mergeVars returns the JSON encoded variables of an imported host or group.
+// The current variables are replaced when overwrite is set, otherwise the
+// imported variables are added to them.
+func mergeVars(current string, imported map[string]interface{}, overwrite bool) (string, error) {
+	vars := map[string]interface{}{}
+	if !overwrite && len(current) > 0 {
+		if err := json.Unmarshal([]byte(current), &vars); err != nil {
+			return "", err
+		}
+	}
+
+	for k, v := range imported {
+		vars[k] = v
+	}
+
+	if len(vars) == 0 {
+		return "", nil
+	}
+
+	b, err := json.Marshal(vars)
+	return string(b), err
+}
+
+func containsID(ids []bson.ObjectId, id bson.ObjectId) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
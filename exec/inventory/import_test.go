@@ -0,0 +1,40 @@
+package inventory
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeVars(t *testing.T) {
+	assert := assert.New(t)
+	current := `{"ansible_user": "admin", "http_port": 8080}`
+	imported := map[string]interface{}{"http_port": 80, "region": "eu-west-1"}
+
+	actual, err := mergeVars(current, imported, false)
+	assert.NoError(err)
+	var vars map[string]interface{}
+	json.Unmarshal([]byte(actual), &vars)
+	assert.Equal(map[string]interface{}{
+		"ansible_user": "admin",
+		"http_port":    float64(80),
+		"region":       "eu-west-1",
+	}, vars, "Imported variables are not merged")
+
+	actual, err = mergeVars(current, imported, true)
+	assert.NoError(err)
+	vars = nil
+	json.Unmarshal([]byte(actual), &vars)
+	assert.Equal(map[string]interface{}{
+		"http_port": float64(80),
+		"region":    "eu-west-1",
+	}, vars, "Variables are not overwritten")
+
+	actual, err = mergeVars("", nil, false)
+	assert.NoError(err)
+	assert.Equal("", actual, "Empty variables are encoded")
+
+	_, err = mergeVars("invalid", imported, false)
+	assert.Error(err, "Invalid variables are merged")
+}
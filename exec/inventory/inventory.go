@@ -0,0 +1,555 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/adjust/uniuri"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/exec/misc"
+	"github.com/pearsonappeng/tensor/exec/types"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/queue"
+	"github.com/pearsonappeng/tensor/util"
+	"github.com/pearsonappeng/tensor/worker"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// pluginPath is the directory of the inventory scripts shipped with tensor
+const pluginPath = "/var/lib/tensor/plugins/inventory"
+
+// scripts maps the inventory sources to their inventory scripts
+var scripts = map[string]string{
+	ansible.INVENTORY_SOURCE_EC2:        "ec2.py",
+	ansible.INVENTORY_SOURCE_GCE:        "gce.py",
+	ansible.INVENTORY_SOURCE_AZURE_RM:   "azure_rm.py",
+	ansible.INVENTORY_SOURCE_OPENSTACK:  "openstack.py",
+	ansible.INVENTORY_SOURCE_VMWARE:     "vmware.py",
+	ansible.INVENTORY_SOURCE_RAX:        "rax.py",
+	ansible.INVENTORY_SOURCE_SATELLITE6: "foreman.py",
+	ansible.INVENTORY_SOURCE_CLOUDFORMS: "cloudforms.py",
+}
+
+// ec2GroupBy are the group_by options of ec2.py
+var ec2GroupBy = []string{
+	"ami_id", "availability_zone", "instance_id", "instance_type", "key_pair",
+	"region", "security_group", "tag_keys", "tag_none", "vpc_id",
+	"route53_names", "rds_engine", "rds_parameter_group", "elasticache_cluster",
+	"elasticache_engine", "elasticache_parameter_group", "elasticache_replication_group",
+}
+
+// Update runs the inventory script of the source and imports
+// the groups, hosts and variables into the inventory
+func Update(j types.InventoryUpdateJob) {
+	// job was canceled while it was waiting in the queue
+	if misc.CancelRequested(db.Jobs(), j.Job.ID) {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": j.Job.ID.Hex(),
+			"Name":   j.Job.Name,
+		}).Infoln("Inventory update canceled before start")
+		jobCancel(j)
+		return
+	}
+
+	start(j)
+
+	logrus.WithFields(logrus.Fields{
+		"Job ID": j.Job.ID.Hex(),
+		"Name":   j.Job.Name,
+	}).Infoln("Started inventory update")
+
+	// secrets injected into the update are scrubbed from everything that is persisted
+	redactor := jobRedactor(j)
+
+	cmd, cleanup, err := getCmd(&j)
+	j.Job.JobARGS = redactor.Strings(j.Job.JobARGS)
+	j.Job.JobENV = redactor.Strings(j.Job.JobENV)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Running inventory update failed")
+		j.Job.ResultStdout = "stdout capture is missing"
+		j.Job.JobExplanation = err.Error()
+		jobFail(j)
+		return
+	}
+
+	defer func() {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": j.Job.ID.Hex(),
+			"Name":   j.Job.Name,
+		}).Infoln("Stopped running inventory update")
+		cleanup()
+	}()
+
+	// the inventory is written to stdout, errors and warnings
+	// of the script are persisted as the job output
+	var out bytes.Buffer
+	b := misc.NewStdoutWriter(j.Job.ID, redactor)
+	defer b.Close()
+	cmd.Stdout = &out
+	cmd.Stderr = b
+
+	// Set setsid to create a new session, The new process group has no controlling
+	// terminal which disables the stdin & will skip prompts
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	if err := cmd.Start(); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Running inventory update failed")
+		j.Job.ResultStdout = string(b.Bytes())
+		j.Job.JobExplanation = err.Error()
+		jobFail(j)
+		return
+	}
+
+	timer := time.AfterFunc(time.Duration(util.Config.SyncJobTimeOut)*time.Second, func() {
+		logrus.Println("Killing the process. Execution exceeded threashold value")
+		misc.KillProcessGroup(cmd)
+	})
+
+	// kill the process group if the job is canceled through the API
+	stopWatch := misc.WatchCancel(cmd, func() bool {
+		return misc.CancelRequested(db.Jobs(), j.Job.ID)
+	})
+
+	err = cmd.Wait()
+	timer.Stop()
+	if stopWatch() {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": j.Job.ID.Hex(),
+			"Name":   j.Job.Name,
+		}).Infoln("Inventory update canceled")
+		j.Job.ResultStdout = string(b.Bytes())
+		jobCancel(j)
+		return
+	}
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Running inventory update failed")
+		j.Job.ResultStdout = string(b.Bytes())
+		j.Job.JobExplanation = err.Error()
+		jobFail(j)
+		return
+	}
+
+	d, err := Parse(out.Bytes())
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Parsing inventory script output failed")
+		j.Job.ResultStdout = string(b.Bytes())
+		j.Job.JobExplanation = err.Error()
+		jobFail(j)
+		return
+	}
+
+	if err := importData(j, d); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Importing inventory failed")
+		j.Job.ResultStdout = string(b.Bytes())
+		j.Job.JobExplanation = err.Error()
+		jobError(j)
+		return
+	}
+
+	fmt.Fprintf(b, "Imported %d groups and %d hosts from %s\n", len(d.GroupNames()), len(d.HostNames()), j.Source.Source)
+	j.Job.ResultStdout = string(b.Bytes())
+	jobSuccess(j)
+}
+
+func getCmd(j *types.InventoryUpdateJob) (cmd *exec.Cmd, cleanup func(), err error) {
+	custom := j.Source.Source == ansible.INVENTORY_SOURCE_CUSTOM
+	script, ok := scripts[j.Source.Source]
+	if !ok && !custom {
+		return nil, nil, errors.New("Unsupported inventory source " + j.Source.Source)
+	}
+
+	j.Paths = types.JobPaths{
+		CredentialPath: "/tmp/tensor_" + uniuri.New(),
+	}
+	// custom inventory scripts are sandboxed like playbooks
+	var tmp string
+	if custom {
+		tmp = "/tmp/tensor_proot_" + uniuri.New() + "/"
+		j.Paths.Etc = filepath.Join(tmp, uniuri.New())
+		j.Paths.Tmp = filepath.Join(tmp, uniuri.New())
+		j.Paths.VarLib = filepath.Join(tmp, uniuri.New())
+		j.Paths.VarLog = filepath.Join(tmp, uniuri.New())
+	}
+	var f *os.File
+	cleanup = func() {
+		if err := os.RemoveAll(j.Paths.CredentialPath); err != nil {
+			logrus.Errorln("Unable to remove credential directories")
+		}
+		if len(tmp) > 0 {
+			if err := os.RemoveAll(tmp); err != nil {
+				logrus.Errorln("Unable to remove sandbox directories")
+			}
+		}
+		if f != nil {
+			if err := os.Remove(f.Name()); err != nil {
+				logrus.Errorln("Unable to remove cloud credential file")
+			}
+		}
+	}
+
+	for _, dir := range []string{j.Paths.CredentialPath, j.Paths.Etc, j.Paths.Tmp, j.Paths.VarLib, j.Paths.VarLog} {
+		if len(dir) == 0 {
+			continue
+		}
+		if err = os.MkdirAll(dir, 0700); err != nil {
+			cleanup()
+			return nil, nil, errors.New("Unable to create directory: " + dir)
+		}
+	}
+
+	env := []string{
+		"TERM=xterm",
+		"PWD=" + j.Paths.CredentialPath,
+		"SHLVL=1",
+		"HOME=" + os.Getenv("HOME"),
+		"_=/usr/bin/tensord",
+		"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+		"JOB_ID=" + j.Job.ID.Hex(),
+	}
+
+	senv, err := sourceEnv(j.Source, j.Paths.CredentialPath)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	env = append(env, senv...)
+
+	arguments := []string{filepath.Join(pluginPath, script), "--list"}
+	if custom {
+		path, err := writeScript(*j)
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		arguments[0] = path
+	}
+	j.Job.JobARGS = arguments
+	j.Job.JobCWD = j.Paths.CredentialPath
+	// Assign job env here to ensure that sensitive information will
+	// not be exposed
+	j.Job.JobENV = env
+
+	if len(j.Credential.ID) > 0 {
+		env, f, err = misc.GetCloudCredential(env, j.Credential, j.Paths.CredentialPath)
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+	}
+
+	if custom {
+		files := []string{}
+		if f != nil {
+			files = append(files, f.Name())
+		}
+		pargs := append(sandboxArgs(j.Paths, files...), arguments...)
+		cmd = exec.Command("proot", pargs...)
+		cmd.Env = append(env, "PROOT_NO_SECCOMP=1")
+	} else {
+		cmd = exec.Command(arguments[0], arguments[1:]...)
+		cmd.Env = env
+	}
+	cmd.Dir = j.Paths.CredentialPath
+
+	return cmd, cleanup, nil
+}
+
+// sourceEnv returns the environment variables of the inventory script. The
+// ec2 script is configured with an ini file written to dir, the source
+// variables of other sources are passed as environment variables
+func sourceEnv(source ansible.InventorySource, dir string) ([]string, error) {
+	vars := map[string]interface{}{}
+	if len(source.SourceVars) > 0 {
+		if err := json.Unmarshal([]byte(source.SourceVars), &vars); err != nil {
+			return nil, errors.New("Invalid source variables: " + err.Error())
+		}
+	}
+
+	switch source.Source {
+	case ansible.INVENTORY_SOURCE_EC2:
+		ini := filepath.Join(dir, "ec2.ini")
+		if err := ioutil.WriteFile(ini, []byte(ec2Ini(source, vars, dir)), 0600); err != nil {
+			return nil, errors.New("Unable to create ec2.ini")
+		}
+		return []string{"EC2_INI_PATH=" + ini}, nil
+	case ansible.INVENTORY_SOURCE_GCE:
+		if len(source.SourceRegions) > 0 {
+			vars["GCE_ZONE"] = source.SourceRegions
+		}
+	}
+
+	env := []string{}
+	for k, v := range vars {
+		env = append(env, k+"="+fmt.Sprint(v))
+	}
+	sort.Strings(env)
+	return env, nil
+}
+
+// ec2Ini returns the ini file of the ec2 inventory script for the source.
+// Options of the source variables override the generated options.
+func ec2Ini(source ansible.InventorySource, vars map[string]interface{}, cachePath string) string {
+	options := map[string]string{
+		"regions":                  "all",
+		"regions_exclude":          "us-gov-west-1,cn-north-1",
+		"destination_variable":     "public_dns_name",
+		"vpc_destination_variable": "ip_address",
+		"route53":                  "False",
+		"rds":                      "False",
+		"elasticache":              "False",
+		"all_instances":            "False",
+		"cache_path":               cachePath,
+		"cache_max_age":            "0",
+	}
+
+	if len(source.SourceRegions) > 0 {
+		options["regions"] = source.SourceRegions
+	}
+
+	if len(source.InstanceFilters) > 0 {
+		options["instance_filters"] = source.InstanceFilters
+	}
+
+	// only the selected group by options are enabled
+	if len(source.GroupBy) > 0 {
+		for _, g := range ec2GroupBy {
+			options["group_by_"+g] = "False"
+		}
+		for _, g := range strings.Split(source.GroupBy, ",") {
+			if g = strings.TrimSpace(g); len(g) > 0 {
+				options["group_by_"+g] = "True"
+			}
+		}
+	}
+
+	for k, v := range vars {
+		options[k] = fmt.Sprint(v)
+	}
+
+	keys := []string{}
+	for k := range options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ini := "[ec2]\n"
+	for _, k := range keys {
+		ini += k + " = " + options[k] + "\n"
+	}
+	return ini
+}
+
+// newUpdateJob stores a new update job of the inventory source with the given id
+func newUpdateJob(id bson.ObjectId, source ansible.InventorySource, user common.User, launchType string) (*types.InventoryUpdateJob, error) {
+	job := ansible.Job{
+		ID:                id,
+		Name:              source.Name + " inventory update",
+		Description:       "Updates " + source.Name + " inventory source",
+		LaunchType:        launchType,
+		CancelFlag:        false,
+		Status:            "pending",
+		JobType:           ansible.JOBTYPE_INVENTORY_UPDATE,
+		InventoryID:       source.InventoryID,
+		InventorySourceID: &source.ID,
+		CloudCredentialID: source.CredentialID,
+		Created:           time.Now(),
+		Modified:          time.Now(),
+		CreatedByID:       user.ID,
+		ModifiedByID:      user.ID,
+	}
+	// updates run before the jobs of the organization that wait for them
+	job.Priority = queue.MaxPriority
+
+	runnerJob := types.InventoryUpdateJob{
+		Job:    job,
+		Source: source,
+		User:   user,
+	}
+
+	if err := db.Inventories().FindId(source.InventoryID).One(&runnerJob.Inventory); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while getting inventory")
+		return nil, errors.New("Error while getting inventory")
+	}
+	job.OrganizationID = runnerJob.Inventory.OrganizationID
+	runnerJob.Job.OrganizationID = job.OrganizationID
+
+	if source.CredentialID != nil {
+		if err := db.Credentials().FindId(*source.CredentialID).One(&runnerJob.Credential); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Error": err.Error(),
+			}).Errorln("Error while getting cloud credential")
+			return nil, errors.New("Error while getting cloud credential")
+		}
+	}
+
+	if source.Source == ansible.INVENTORY_SOURCE_CUSTOM {
+		if source.SourceScriptID == nil {
+			return nil, errors.New("Inventory source does not have a source script")
+		}
+		if err := db.InventoryScripts().FindId(*source.SourceScriptID).One(&runnerJob.Script); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Error": err.Error(),
+			}).Errorln("Error while getting inventory script")
+			return nil, errors.New("Error while getting inventory script")
+		}
+	}
+
+	// Insert new job into jobs collection
+	if err := db.Jobs().Insert(job); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while creating inventory update job")
+		return nil, errors.New("Error while creating inventory update job")
+	}
+
+	return &runnerJob, nil
+}
+
+// UpdateSource creates an update job for the inventory source
+// and publishes it to the ansible queue
+func UpdateSource(source ansible.InventorySource, user common.User, launchType string) (*types.InventoryUpdateJob, error) {
+	runnerJob, err := newUpdateJob(bson.NewObjectId(), source, user, launchType)
+	if err != nil {
+		return nil, err
+	}
+	job := runnerJob.Job
+
+	if err := db.InventorySources().UpdateId(source.ID, bson.M{
+		"$set": bson.M{"status": job.Status, "last_job_id": job.ID},
+	}); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Failed to update inventory source")
+	}
+
+	jobBytes, err := json.Marshal(runnerJob)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Unable to marshal Job")
+		return nil, err
+	}
+
+	// publish bytes to ansible queue
+	opts := queue.Options{Priority: job.Priority, Group: job.OrganizationID.Hex()}
+	if err := queue.Publish(queue.Ansible, jobBytes, opts); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while publishing to Queue")
+		misc.PublishFailed(db.Jobs(), job.ID, err)
+		return nil, err
+	}
+
+	return runnerJob, nil
+}
+
+// UpdateStaleSources updates every inventory source of the inventory which
+// is updated on launch and is stale, the updates run one after another in the
+// calling worker. It returns the finished updates, the caller checks their
+// status. When another job updates one of the sources its id is returned and
+// the caller has to wait for that update.
+func UpdateStaleSources(inventoryID bson.ObjectId, user common.User) ([]types.InventoryUpdateJob, *bson.ObjectId, error) {
+	var sources []ansible.InventorySource
+	if err := db.InventorySources().Find(bson.M{
+		"inventory_id":     inventoryID,
+		"update_on_launch": true,
+	}).All(&sources); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while getting inventory sources")
+		return nil, nil, errors.New("Error while getting inventory sources")
+	}
+
+	updates := []types.InventoryUpdateJob{}
+	now := time.Now()
+	for _, source := range sources {
+		if source.LastJobID != nil {
+			count, err := db.Jobs().Find(bson.M{
+				"_id":    *source.LastJobID,
+				"status": bson.M{"$in": common.ActiveJobStatus},
+			}).Count()
+			if err != nil {
+				return nil, nil, err
+			}
+			if count > 0 {
+				return updates, source.LastJobID, nil
+			}
+		}
+
+		if !source.IsStale(now) {
+			continue
+		}
+
+		// the update becomes the last job of the source, a job that found
+		// the source stale in the meantime waits for it
+		id := bson.NewObjectId()
+		err := db.InventorySources().Update(bson.M{"_id": source.ID, "last_job_id": source.LastJobID},
+			bson.M{"$set": bson.M{"status": "pending", "last_job_id": id}})
+		if err == mgo.ErrNotFound {
+			var current ansible.InventorySource
+			if err := db.InventorySources().FindId(source.ID).One(&current); err != nil {
+				return nil, nil, err
+			}
+			return updates, current.LastJobID, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		update, err := newUpdateJob(id, source, user, ansible.JOB_LAUNCH_TYPE_SYSTEM)
+		if err != nil {
+			if err := db.InventorySources().UpdateId(source.ID, bson.M{"$set": bson.M{"status": "error"}}); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"Error": err.Error(),
+				}).Errorln("Failed to update inventory source")
+			}
+			return nil, nil, err
+		}
+
+		// the update is reaped with the calling job if this worker stops
+		if _, err := misc.ClaimJob(db.Jobs(), id, worker.ID); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Job ID": id.Hex(),
+				"Error":  err.Error(),
+			}).Errorln("Failed to claim job")
+		}
+
+		Update(*update)
+
+		if err := db.Jobs().FindId(id).One(&update.Job); err != nil {
+			return nil, nil, err
+		}
+		updates = append(updates, *update)
+		if update.Job.Status != "successful" {
+			break
+		}
+	}
+
+	return updates, nil, nil
+}
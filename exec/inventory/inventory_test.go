@@ -0,0 +1,79 @@
+package inventory
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pearsonappeng/tensor/exec/types"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEC2Ini(t *testing.T) {
+	assert := assert.New(t)
+	source := ansible.InventorySource{
+		Source:          ansible.INVENTORY_SOURCE_EC2,
+		SourceRegions:   "us-east-1,eu-west-1",
+		InstanceFilters: "tag:env=prod",
+		GroupBy:         "region, tag_keys",
+	}
+
+	ini := ec2Ini(source, map[string]interface{}{"route53": "True"}, "/tmp/cache")
+
+	assert.True(strings.HasPrefix(ini, "[ec2]\n"), "ec2.ini has no ec2 section")
+	for _, option := range []string{
+		"regions = us-east-1,eu-west-1\n",
+		"instance_filters = tag:env=prod\n",
+		"group_by_region = True\n",
+		"group_by_tag_keys = True\n",
+		"group_by_instance_id = False\n",
+		"route53 = True\n",
+		"cache_path = /tmp/cache\n",
+		"vpc_destination_variable = ip_address\n",
+	} {
+		assert.Contains(ini, option)
+	}
+}
+
+func TestSourceEnv(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "tensor_inventory_test")
+	defer os.RemoveAll(dir)
+
+	env, err := sourceEnv(ansible.InventorySource{
+		Source:        ansible.INVENTORY_SOURCE_GCE,
+		SourceRegions: "us-central1-a",
+		SourceVars:    `{"GCE_INI_PATH": "/etc/gce.ini"}`,
+	}, dir)
+	assert.NoError(err)
+	assert.Equal([]string{"GCE_INI_PATH=/etc/gce.ini", "GCE_ZONE=us-central1-a"}, env)
+
+	env, err = sourceEnv(ansible.InventorySource{Source: ansible.INVENTORY_SOURCE_EC2}, dir)
+	assert.NoError(err)
+	assert.Equal([]string{"EC2_INI_PATH=" + filepath.Join(dir, "ec2.ini")}, env)
+	_, err = os.Stat(filepath.Join(dir, "ec2.ini"))
+	assert.NoError(err, "ec2.ini is not created")
+
+	_, err = sourceEnv(ansible.InventorySource{Source: ansible.INVENTORY_SOURCE_GCE, SourceVars: "invalid"}, dir)
+	assert.Error(err, "Invalid source variables are accepted")
+}
+
+func TestSandboxArgs(t *testing.T) {
+	assert := assert.New(t)
+	paths := types.JobPaths{
+		Etc:            "/tmp/p/etc",
+		Tmp:            "/tmp/p/tmp",
+		VarLib:         "/tmp/p/lib",
+		VarLog:         "/tmp/p/log",
+		CredentialPath: "/tmp/tensor_cred",
+	}
+
+	args := strings.Join(sandboxArgs(paths, "/tmp/cloud_cred"), " ")
+	assert.Contains(args, "-b /tmp/p/tmp:/tmp")
+	assert.Contains(args, "-b /tmp/tensor_cred:/tmp/tensor_cred")
+	assert.Contains(args, "-b /tmp/cloud_cred:/tmp/cloud_cred")
+	assert.True(strings.HasSuffix(args, "-w /tmp/tensor_cred"))
+}
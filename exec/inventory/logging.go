@@ -0,0 +1,189 @@
+package inventory
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/exec/misc"
+	"github.com/pearsonappeng/tensor/exec/types"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func start(t types.InventoryUpdateJob) {
+	t.Job.Status = "running"
+	t.Job.Started = time.Now()
+
+	d := bson.M{
+		"$set": bson.M{
+			"status":  t.Job.Status,
+			"failed":  false,
+			"started": t.Job.Started,
+		},
+	}
+
+	if err := db.Jobs().UpdateId(t.Job.ID, d); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Status": t.Job.Status,
+			"Error":  err,
+		}).Errorln("Failed to update job status")
+	}
+
+	if err := db.InventorySources().UpdateId(t.Source.ID, bson.M{"$set": bson.M{"status": t.Job.Status}}); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err,
+		}).Errorln("Failed to update inventory source")
+	}
+}
+
+// jobRedactor returns the Redactor of the secrets injected into the update
+func jobRedactor(t types.InventoryUpdateJob) *misc.Redactor {
+	return misc.NewRedactor(t.Credential)
+}
+
+func jobFail(t types.InventoryUpdateJob) {
+	t.Job.Status = "failed"
+	t.Job.Failed = true
+	finish(t)
+}
+
+func jobCancel(t types.InventoryUpdateJob) {
+	t.Job.Status = "canceled"
+	t.Job.CancelFlag = true
+	t.Job.Failed = false
+	t.Job.JobExplanation = "Job Cancelled"
+
+	if len(t.Job.ResultStdout) == 0 {
+		t.Job.ResultStdout = "stdout capture is missing"
+	}
+
+	finish(t)
+}
+
+func jobError(t types.InventoryUpdateJob) {
+	t.Job.Status = "error"
+	t.Job.Failed = true
+	finish(t)
+}
+
+func jobSuccess(t types.InventoryUpdateJob) {
+	t.Job.Status = "successful"
+	t.Job.Failed = false
+	finish(t)
+}
+
+// finish stores the final status of the job and updates the status
+// of the inventory source and the counters of the inventory
+func finish(t types.InventoryUpdateJob) {
+	t.Job.Finished = time.Now()
+	t.Job.JobExplanation = jobRedactor(t).String(t.Job.JobExplanation)
+
+	misc.SaveStdout(t.Job.ID, t.Job.ResultStdout)
+
+	//get elapsed time in minutes
+	diff := t.Job.Finished.Sub(t.Job.Started)
+
+	d := bson.M{
+		"$set": bson.M{
+			"status":          t.Job.Status,
+			"cancel_flag":     t.Job.CancelFlag,
+			"failed":          t.Job.Failed,
+			"finished":        t.Job.Finished,
+			"elapsed":         diff.Minutes(),
+			"job_explanation": t.Job.JobExplanation,
+			"job_args":        t.Job.JobARGS,
+			"job_env":         t.Job.JobENV,
+			"job_cwd":         t.Job.JobCWD,
+		},
+	}
+
+	if err := db.Jobs().UpdateId(t.Job.ID, d); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Status": t.Job.Status,
+			"Error":  err,
+		}).Errorln("Failed to update job status")
+	}
+
+	updateSource(t)
+	UpdateCounters(t.Source.InventoryID)
+}
+
+func updateSource(t types.InventoryUpdateJob) {
+	d := bson.M{
+		"$set": bson.M{
+			"last_updated":       t.Job.Finished,
+			"last_update_failed": t.Job.Failed,
+			"status":             t.Job.Status,
+		},
+	}
+
+	if err := db.InventorySources().UpdateId(t.Source.ID, d); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err,
+		}).Errorln("Failed to update inventory source")
+	}
+}
+
+// UpdateCounters recounts the hosts, groups and inventory sources of the inventory
+func UpdateCounters(inventoryID bson.ObjectId) {
+	q := bson.M{"inventory_id": inventoryID}
+
+	hosts, err := db.Hosts().Find(q).Count()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err,
+		}).Errorln("Failed to count hosts")
+		return
+	}
+
+	groups, err := db.Groups().Find(q).Count()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err,
+		}).Errorln("Failed to count groups")
+		return
+	}
+
+	sources, err := db.InventorySources().Find(q).Count()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err,
+		}).Errorln("Failed to count inventory sources")
+		return
+	}
+
+	failures, err := db.InventorySources().Find(bson.M{"inventory_id": inventoryID, "last_update_failed": true}).Count()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err,
+		}).Errorln("Failed to count inventory sources")
+		return
+	}
+
+	d := bson.M{
+		"$set": bson.M{
+			"total_hosts":                     hosts,
+			"total_groups":                    groups,
+			"has_inventory_sources":           sources > 0,
+			"total_inventory_sources":         sources,
+			"inventory_sources_with_failures": failures,
+		},
+	}
+
+	if err := db.Inventories().UpdateId(inventoryID, d); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err,
+		}).Errorln("Failed to update inventory")
+	}
+}
+
+// Reap finishes an inventory update of a stopped worker with status error
+func Reap(job ansible.Job) {
+	t := types.InventoryUpdateJob{Job: job}
+	t.Source.InventoryID = job.InventoryID
+	if job.InventorySourceID != nil {
+		t.Source.ID = *job.InventorySourceID
+	}
+	jobError(t)
+}
@@ -0,0 +1,166 @@
+package inventory
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+)
+
+// groups that are implicit in every ansible inventory,
+// they are not imported as groups
+const (
+	groupAll       = "all"
+	groupUngrouped = "ungrouped"
+)
+
+// Group is a group in the output of an inventory script
+type Group struct {
+	Hosts    []string               `json:"hosts"`
+	Children []string               `json:"children"`
+	Vars     map[string]interface{} `json:"vars"`
+}
+
+// Data is the parsed output of an inventory script
+// called with --list
+type Data struct {
+	Groups   map[string]*Group
+	HostVars map[string]map[string]interface{}
+}
+
+// Parse parses the JSON output of an inventory script. A group can
+// either be a list of hosts or an object with hosts, children and vars,
+// host variables are read from _meta.hostvars.
+func Parse(b []byte) (Data, error) {
+	d := Data{
+		Groups:   map[string]*Group{},
+		HostVars: map[string]map[string]interface{}{},
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return d, errors.New("Inventory script output is not a valid JSON object: " + err.Error())
+	}
+
+	for name, v := range raw {
+		if name == "_meta" {
+			var meta struct {
+				HostVars map[string]map[string]interface{} `json:"hostvars"`
+			}
+			if err := json.Unmarshal(v, &meta); err != nil {
+				return d, errors.New("Invalid _meta in inventory script output: " + err.Error())
+			}
+			for host, vars := range meta.HostVars {
+				d.HostVars[host] = vars
+			}
+			continue
+		}
+
+		g := &Group{}
+		if err := json.Unmarshal(v, &g.Hosts); err != nil {
+			g = &Group{}
+			if err := json.Unmarshal(v, g); err != nil {
+				return d, errors.New("Invalid group " + name + " in inventory script output")
+			}
+		}
+		d.Groups[name] = g
+	}
+
+	// make sure that every child group exists
+	for _, g := range d.Groups {
+		for _, child := range g.Children {
+			if _, ok := d.Groups[child]; !ok {
+				d.Groups[child] = &Group{}
+			}
+		}
+	}
+
+	return d, nil
+}
+
+// GroupNames returns the sorted names of the groups to import,
+// the implicit all and ungrouped groups are excluded
+func (d Data) GroupNames() []string {
+	names := []string{}
+	for name := range d.Groups {
+		if name != groupAll && name != groupUngrouped {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HostNames returns the sorted names of all hosts in the output
+func (d Data) HostNames() []string {
+	seen := map[string]bool{}
+	for _, g := range d.Groups {
+		for _, h := range g.Hosts {
+			seen[h] = true
+		}
+	}
+	for h := range d.HostVars {
+		seen[h] = true
+	}
+
+	names := []string{}
+	for h := range seen {
+		names = append(names, h)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parents maps every child group to its parent group. Groups can only have
+// a single parent in tensor, if a group is a child of more groups the first
+// parent in alphabetical order is used. A parent that is a descendant of the
+// group is skipped, the output may contain cycles. Groups without a parent
+// are not included.
+func (d Data) Parents() map[string]string {
+	parents := map[string]string{}
+	for _, name := range d.GroupNames() {
+		for _, child := range d.Groups[name].Children {
+			if child == groupAll || child == groupUngrouped {
+				continue
+			}
+			if _, ok := parents[child]; ok || descends(parents, name, child) {
+				continue
+			}
+			parents[child] = name
+		}
+	}
+	return parents
+}
+
+// descends reports whether the group is the ancestor group or one of its descendants
+func descends(parents map[string]string, group string, ancestor string) bool {
+	for {
+		if group == ancestor {
+			return true
+		}
+		parent, ok := parents[group]
+		if !ok {
+			return false
+		}
+		group = parent
+	}
+}
+
+// HostGroups maps every host to the sorted names of the groups it is a
+// direct member of. Hosts that are only members of the implicit groups
+// are mapped to an empty list.
+func (d Data) HostGroups() map[string][]string {
+	groups := map[string][]string{}
+	for _, h := range d.HostNames() {
+		groups[h] = []string{}
+	}
+	for _, name := range d.GroupNames() {
+		for _, h := range d.Groups[name].Hosts {
+			// a host can be listed twice in the same group
+			if n := len(groups[h]); n > 0 && groups[h][n-1] == name {
+				continue
+			}
+			groups[h] = append(groups[h], name)
+		}
+	}
+	return groups
+}
@@ -0,0 +1,89 @@
+package inventory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const output = `{
+	"web": {
+		"hosts": ["web1", "web2"],
+		"vars": {"http_port": 80},
+		"children": ["frontend"]
+	},
+	"db": ["db1", "web1", "db1"],
+	"lb": {"children": ["web", "db"]},
+	"ungrouped": ["standalone"],
+	"_meta": {
+		"hostvars": {
+			"web1": {"ansible_host": "10.0.0.1"},
+			"orphan": {"ansible_host": "10.0.0.9"}
+		}
+	}
+}`
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+
+	d, err := Parse([]byte(output))
+	assert.NoError(err)
+
+	assert.Equal([]string{"db1", "web1", "db1"}, d.Groups["db"].Hosts, "Group list shorthand is not parsed")
+	assert.Equal(float64(80), d.Groups["web"].Vars["http_port"], "Group vars are not parsed")
+	assert.Equal("10.0.0.1", d.HostVars["web1"]["ansible_host"], "Host vars are not parsed")
+	assert.NotNil(d.Groups["frontend"], "Missing child group is not created")
+}
+
+func TestParseInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := Parse([]byte(`["web1"]`))
+	assert.Error(err, "Output which is not an object is accepted")
+
+	_, err = Parse([]byte(`{"web": "web1"}`))
+	assert.Error(err, "Invalid group is accepted")
+}
+
+func TestGroupNames(t *testing.T) {
+	d, _ := Parse([]byte(output))
+	assert.Equal(t, []string{"db", "frontend", "lb", "web"}, d.GroupNames())
+}
+
+func TestHostNames(t *testing.T) {
+	d, _ := Parse([]byte(output))
+	assert.Equal(t, []string{"db1", "orphan", "standalone", "web1", "web2"}, d.HostNames())
+}
+
+func TestParents(t *testing.T) {
+	d, _ := Parse([]byte(output))
+	assert.Equal(t, map[string]string{
+		"db":       "lb",
+		"web":      "lb",
+		"frontend": "web",
+	}, d.Parents())
+}
+
+func TestParentsCycle(t *testing.T) {
+	d, err := Parse([]byte(`{
+		"a": {"children": ["b"]},
+		"b": {"children": ["c"]},
+		"c": {"children": ["a", "c"]}
+	}`))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"b": "a",
+		"c": "b",
+	}, d.Parents())
+}
+
+func TestHostGroups(t *testing.T) {
+	d, _ := Parse([]byte(output))
+	assert.Equal(t, map[string][]string{
+		"db1":        {"db"},
+		"orphan":     {},
+		"standalone": {},
+		"web1":       {"db", "web"},
+		"web2":       {"web"},
+	}, d.HostGroups())
+}
@@ -0,0 +1,37 @@
+package inventory
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/pearsonappeng/tensor/exec/types"
+)
+
+// writeScript writes the custom inventory script of the job to the
+// credential directory and returns the path of the executable
+func writeScript(j types.InventoryUpdateJob) (string, error) {
+	path := filepath.Join(j.Paths.CredentialPath, "inventory_script")
+	if err := ioutil.WriteFile(path, []byte(j.Script.Script), 0700); err != nil {
+		return "", errors.New("Unable to create inventory script")
+	}
+	return path, nil
+}
+
+// sandboxArgs returns the proot arguments which run a custom inventory
+// script with fresh /etc/tensor, /tmp, /var/lib/tensor and /var/log
+// directories. The credential directory and the files are bound to the
+// same paths inside the sandbox so that the script can read them.
+func sandboxArgs(paths types.JobPaths, files ...string) []string {
+	pargs := []string{"-v", "0", "-r", "/",
+		"-b", paths.Etc + ":/etc/tensor",
+		"-b", paths.Tmp + ":/tmp",
+		"-b", paths.VarLib + ":/var/lib/tensor",
+		"-b", paths.VarLog + ":/var/log",
+		"-b", paths.CredentialPath + ":" + paths.CredentialPath,
+	}
+	for _, f := range files {
+		pargs = append(pargs, "-b", f+":"+f)
+	}
+	return append(pargs, "-w", paths.CredentialPath)
+}
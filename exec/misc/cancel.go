@@ -0,0 +1,25 @@
+package misc
+
+import (
+	"github.com/Sirupsen/logrus"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// CancelRequested reports whether a cancel has been requested through the
+// API for the job since it was queued, jobs is the collection of the job
+func CancelRequested(jobs *mgo.Collection, jobID bson.ObjectId) bool {
+	var job struct {
+		CancelFlag bool `bson:"cancel_flag"`
+	}
+
+	if err := jobs.FindId(jobID).Select(bson.M{"cancel_flag": 1}).One(&job); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": jobID.Hex(),
+			"Error":  err,
+		}).Errorln("Failed to get job cancel flag")
+		return false
+	}
+
+	return job.CancelFlag
+}
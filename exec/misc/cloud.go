@@ -1,27 +1,29 @@
 package misc
 
 import (
+	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/pearsonappeng/tensor/models/common"
 	"github.com/pearsonappeng/tensor/util"
 )
 
-// raxCredFile creates a Rackspace credential file in the system temporary directory
-// and returns the resulting *os.File.
+// raxCredFile creates a Rackspace credential file in dir, the system temporary
+// directory if dir is empty, and returns the resulting *os.File.
 // Multiple programs calling raxCredFile simultaneously
 // will not choose the same file. The caller can use f.Name()
 // to find the pathname of the file. It is the caller's responsibility
 // to remove the file when no longer needed.
-func raxCredFile(c common.Credential) (f *os.File, err error) {
+func raxCredFile(c common.Credential, dir string) (f *os.File, err error) {
 	content := "#!/usr/bin/python\n[rackspace_cloud]" +
 		"\nusername=" + c.Username +
-		"\napi_key=" + c.Secret
+		"\napi_key=" + string(util.Decipher(c.Secret))
 
-	f, err = ioutil.TempFile("", "tensor_credential_rackspace")
+	f, err = ioutil.TempFile(dir, "tensor_credential_rackspace")
 	if err != nil {
 		logrus.Errorln("Rackspace credential file creation failed")
 		return
@@ -43,14 +45,14 @@ func raxCredFile(c common.Credential) (f *os.File, err error) {
 	return
 }
 
-// GCECredFile creates a Google Compute Engine credential file in the system
-// temporary directory and returns the resulting *os.File.
+// GCECredFile creates a Google Compute Engine credential file in dir, the system
+// temporary directory if dir is empty, and returns the resulting *os.File.
 // Multiple programs calling GCECredFile simultaneously
 // will not choose the same file. The caller can use f.Name()
 // to find the pathname of the file. It is the caller's responsibility
 // to remove the file when no longer needed.
-func GCECredFile(c common.Credential) (f *os.File, err error) {
-	f, err = ioutil.TempFile("", "tensor_credential_gce")
+func GCECredFile(c common.Credential, dir string) (f *os.File, err error) {
+	f, err = ioutil.TempFile(dir, "tensor_credential_gce")
 	if err != nil {
 		logrus.Errorln("GCE credential file creation failed")
 		return
@@ -67,11 +69,97 @@ func GCECredFile(c common.Credential) (f *os.File, err error) {
 	return
 }
 
+// openStackCloud is the name of the cloud in the generated clouds.yaml
+const openStackCloud = "tensor"
+
+// credFile writes content to a new credential file in dir, the system
+// temporary directory if dir is empty. Only the process user can read it.
+// It is the caller's responsibility to remove the file when no longer needed.
+func credFile(dir, prefix, content string) (f *os.File, err error) {
+	f, err = ioutil.TempFile(dir, prefix)
+	if err != nil {
+		return
+	}
+
+	if _, err = f.Write([]byte(content)); err != nil {
+		f.Close()
+		return
+	}
+	if err = f.Close(); err != nil {
+		return
+	}
+
+	err = os.Chmod(f.Name(), 0600)
+	return
+}
+
+// yamlString quotes s as a double quoted YAML string
+func yamlString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// iniValue escapes s for the python ConfigParser of the inventory scripts,
+// line breaks can not be represented and are removed
+func iniValue(s string) string {
+	s = strings.NewReplacer("\r", "", "\n", "").Replace(s)
+	return strings.Replace(s, "%", "%%", -1)
+}
+
+// openStackCredFile creates a clouds.yaml with the OpenStack credential in dir
+func openStackCredFile(c common.Credential, dir string) (*os.File, error) {
+	content := "clouds:\n" +
+		"  " + openStackCloud + ":\n" +
+		"    auth:\n" +
+		"      auth_url: " + yamlString(c.Host) + "\n" +
+		"      username: " + yamlString(c.Username) + "\n" +
+		"      password: " + yamlString(string(util.Decipher(c.Password))) + "\n" +
+		"      project_name: " + yamlString(c.Project) + "\n"
+	if len(c.Domain) > 0 {
+		content += "      domain_name: " + yamlString(c.Domain) + "\n"
+	}
+	return credFile(dir, "tensor_credential_openstack", content)
+}
+
+// sslVerify returns the ssl_verify value of an inventory script ini file,
+// certificates are verified unless the credential opts out
+func sslVerify(c common.Credential) string {
+	if c.SSLVerifyDisabled {
+		return "False"
+	}
+	return "True"
+}
+
+// satelliteCredFile creates the foreman.ini of the Satellite 6 inventory
+// script with the Satellite 6 credential in dir
+func satelliteCredFile(c common.Credential, dir string) (*os.File, error) {
+	content := "[foreman]\n" +
+		"url = " + iniValue(c.Host) + "\n" +
+		"user = " + iniValue(c.Username) + "\n" +
+		"password = " + iniValue(string(util.Decipher(c.Password))) + "\n" +
+		"ssl_verify = " + sslVerify(c) + "\n"
+	return credFile(dir, "tensor_credential_satellite6", content)
+}
+
+// cloudFormsCredFile creates the cloudforms.ini of the CloudForms inventory
+// script with the CloudForms credential in dir
+func cloudFormsCredFile(c common.Credential, dir string) (*os.File, error) {
+	content := "[cloudforms]\n" +
+		"url = " + iniValue(c.Host) + "\n" +
+		"username = " + iniValue(c.Username) + "\n" +
+		"password = " + iniValue(string(util.Decipher(c.Password))) + "\n" +
+		"ssl_verify = " + sslVerify(c) + "\n"
+	return credFile(dir, "tensor_credential_cloudforms", content)
+}
+
 // GetCloudCredential cloud credential files and generates environment variables,
 // This accepts string slice and common.Credential (cloud credential) interface
 // and returns slice of environment variables generated and file handler to the
-// credential file
-func GetCloudCredential(env []string, c common.Credential) (menv []string, f *os.File, err error) {
+// credential file. Credential files are created in dir, which must be visible
+// to the job, the system temporary directory is used if dir is empty.
+func GetCloudCredential(env []string, c common.Credential, dir string) (menv []string, f *os.File, err error) {
+	// credentials without environment variables keep the environment
+	menv = env
 	switch c.Kind {
 	//if Cloud Credential type is AWS
 	case common.CredentialKindAWS:
@@ -79,10 +167,15 @@ func GetCloudCredential(env []string, c common.Credential) (menv []string, f *os
 			// add environment variables for aws
 			menv = append(env, "AWS_SECRET_ACCESS_KEY="+string(util.Decipher(c.Secret)),
 				"AWS_ACCESS_KEY_ID="+c.Client)
+			// temporary credentials of AWS STS
+			if len(c.SecurityToken) > 0 {
+				token := string(util.Decipher(c.SecurityToken))
+				menv = append(menv, "AWS_SECURITY_TOKEN="+token, "AWS_SESSION_TOKEN="+token)
+			}
 		}
 	case common.CredentialKindRAX:
 		{
-			f, err = raxCredFile(c)
+			f, err = raxCredFile(c, dir)
 			if err != nil {
 				err = errors.New("Rackspace credential file creation failed")
 				return
@@ -93,9 +186,10 @@ func GetCloudCredential(env []string, c common.Credential) (menv []string, f *os
 		}
 	case common.CredentialKindGCE:
 		{
-			f, err = GCECredFile(c)
+			f, err = GCECredFile(c, dir)
 			if err != nil {
 				err = errors.New("GCE credential file creation failed")
+				return
 			}
 
 			// add environment variables for GCE credential
@@ -117,6 +211,47 @@ func GetCloudCredential(env []string, c common.Credential) (menv []string, f *os
 					"AZURE_TENANT="+c.Tenant)
 			}
 		}
+	case common.CredentialKindVMWARE:
+		{
+			// add environment variables for the vmware inventory script and modules,
+			// and for the vsphere provider of terraform
+			password := string(util.Decipher(c.Password))
+			menv = append(env, "VMWARE_HOST="+c.Host, "VMWARE_USER="+c.Username, "VMWARE_PASSWORD="+password,
+				"VSPHERE_SERVER="+c.Host, "VSPHERE_USER="+c.Username, "VSPHERE_PASSWORD="+password)
+		}
+	case common.CredentialKindOPENSTACK:
+		{
+			f, err = openStackCredFile(c, dir)
+			if err != nil {
+				err = errors.New("OpenStack credential file creation failed")
+				return
+			}
+
+			// add environment variables for OpenStack clouds.yaml
+			menv = append(env, "OS_CLIENT_CONFIG_FILE="+f.Name(), "OS_CLOUD="+openStackCloud)
+		}
+	case common.CredentialKindSATELLITE6:
+		{
+			f, err = satelliteCredFile(c, dir)
+			if err != nil {
+				err = errors.New("Satellite 6 credential file creation failed")
+				return
+			}
+
+			// add environment variables for Satellite 6 credential
+			menv = append(env, "FOREMAN_INI_PATH="+f.Name())
+		}
+	case common.CredentialKindCLOUDFORMS:
+		{
+			f, err = cloudFormsCredFile(c, dir)
+			if err != nil {
+				err = errors.New("CloudForms credential file creation failed")
+				return
+			}
+
+			// add environment variables for CloudForms credential
+			menv = append(env, "CLOUDFORMS_INI_PATH="+f.Name())
+		}
 	}
 
 	return
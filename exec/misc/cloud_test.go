@@ -6,6 +6,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -13,14 +14,14 @@ func TestRaxCredFile(t *testing.T) {
 	assert := assert.New(t)
 	c := common.Credential{
 		Username: "test",
-		Secret:   "test",
+		Secret:   util.Cipher("test"),
 	}
 
 	expected := "#!/usr/bin/python\n[rackspace_cloud]" +
 		"\nusername=" + c.Username +
-		"\napi_key=" + c.Secret
+		"\napi_key=test"
 
-	f, _ := raxCredFile(c)
+	f, _ := raxCredFile(c, "")
 	actual, _ := ioutil.ReadFile(f.Name())
 
 	assert.Equal(expected, string(actual), "Create racspace credential has invalid content")
@@ -39,7 +40,7 @@ func TestGCECredFile(t *testing.T) {
 		SSHKeyData: util.Cipher("test"),
 	}
 
-	f, _ := GCECredFile(c)
+	f, _ := GCECredFile(c, "")
 	actual, _ := ioutil.ReadFile(f.Name())
 
 	assert.Equal("test", string(actual), "Create GCE credential has invalid content")
@@ -57,7 +58,7 @@ func TestGetCloudCredential(t *testing.T) {
 		Kind:   common.CredentialKindAWS,
 	}
 
-	actual, _, _ := GetCloudCredential([]string{}, c)
+	actual, _, _ := GetCloudCredential([]string{}, c, "")
 	expected := []string{"AWS_SECRET_ACCESS_KEY=test", "AWS_ACCESS_KEY_ID=test"}
 	assert.Equal(expected, actual, "Must be equal")
 
@@ -68,7 +69,7 @@ func TestGetCloudCredential(t *testing.T) {
 		Kind:     common.CredentialKindRAX,
 	}
 
-	actual, f, _ := GetCloudCredential([]string{}, c)
+	actual, f, _ := GetCloudCredential([]string{}, c, "")
 	expected = []string{"RAX_CREDS_FILE=" + f.Name()}
 	os.Remove(f.Name())
 
@@ -82,7 +83,7 @@ func TestGetCloudCredential(t *testing.T) {
 		Kind:       common.CredentialKindGCE,
 	}
 
-	actual, f, _ = GetCloudCredential([]string{}, c)
+	actual, f, _ = GetCloudCredential([]string{}, c, "")
 	expected = []string{"GCE_EMAIL=test", "GCE_PROJECT=test", "GCE_CREDENTIALS_FILE_PATH=" + f.Name()}
 	os.Remove(f.Name())
 
@@ -97,7 +98,7 @@ func TestGetCloudCredential(t *testing.T) {
 		Kind:         common.CredentialKindAZURE,
 	}
 
-	actual, _, _ = GetCloudCredential([]string{}, c)
+	actual, _, _ = GetCloudCredential([]string{}, c, "")
 	expected = []string{"AZURE_AD_USER=test", "AZURE_PASSWORD=test", "AZURE_SUBSCRIPTION_ID=test"}
 	assert.Equal(expected, actual, "Must be equal")
 
@@ -110,7 +111,96 @@ func TestGetCloudCredential(t *testing.T) {
 		Kind:         common.CredentialKindAZURE,
 	}
 
-	actual, _, _ = GetCloudCredential([]string{}, c)
+	actual, _, _ = GetCloudCredential([]string{}, c, "")
 	expected = []string{"AZURE_CLIENT_ID=test", "AZURE_SECRET=test", "AZURE_SUBSCRIPTION_ID=test", "AZURE_TENANT=test"}
 	assert.Equal(expected, actual, "Must be equal")
 }
+
+func TestGetCloudCredentialInjectors(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "tensor_cloud_test")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	// Test AWS STS credentials
+	c := common.Credential{
+		Secret:        util.Cipher("secret"),
+		Client:        "client",
+		SecurityToken: util.Cipher("token"),
+		Kind:          common.CredentialKindAWS,
+	}
+
+	actual, _, _ := GetCloudCredential([]string{}, c, dir)
+	assert.Equal([]string{"AWS_SECRET_ACCESS_KEY=secret", "AWS_ACCESS_KEY_ID=client",
+		"AWS_SECURITY_TOKEN=token", "AWS_SESSION_TOKEN=token"}, actual)
+
+	// Test VMware credentials
+	c = common.Credential{
+		Host:     "vcenter.example.com",
+		Username: "admin",
+		Password: util.Cipher("secret"),
+		Kind:     common.CredentialKindVMWARE,
+	}
+
+	actual, _, _ = GetCloudCredential([]string{}, c, dir)
+	assert.Equal([]string{"VMWARE_HOST=vcenter.example.com", "VMWARE_USER=admin", "VMWARE_PASSWORD=secret",
+		"VSPHERE_SERVER=vcenter.example.com", "VSPHERE_USER=admin", "VSPHERE_PASSWORD=secret"}, actual)
+
+	// Test OpenStack credentials
+	c = common.Credential{
+		Host:     "https://keystone.example.com:5000/v3",
+		Username: "admin",
+		Password: util.Cipher(`se"cret`),
+		Project:  "demo",
+		Domain:   "Default",
+		Kind:     common.CredentialKindOPENSTACK,
+	}
+
+	actual, f, err := GetCloudCredential([]string{}, c, dir)
+	assert.Nil(err)
+	assert.Equal(dir, filepath.Dir(f.Name()))
+	assert.Equal([]string{"OS_CLIENT_CONFIG_FILE=" + f.Name(), "OS_CLOUD=tensor"}, actual)
+	content, _ := ioutil.ReadFile(f.Name())
+	assert.Equal(`clouds:
+  tensor:
+    auth:
+      auth_url: "https://keystone.example.com:5000/v3"
+      username: "admin"
+      password: "se\"cret"
+      project_name: "demo"
+      domain_name: "Default"
+`, string(content))
+	info, _ := os.Stat(f.Name())
+	assert.Equal(os.FileMode(0600), info.Mode())
+
+	// Test Satellite 6 credentials
+	c = common.Credential{
+		Host:     "https://satellite.example.com",
+		Username: "admin",
+		Password: util.Cipher("100%"),
+		Kind:     common.CredentialKindSATELLITE6,
+	}
+
+	actual, f, err = GetCloudCredential([]string{}, c, dir)
+	assert.Nil(err)
+	assert.Equal([]string{"FOREMAN_INI_PATH=" + f.Name()}, actual)
+	content, _ = ioutil.ReadFile(f.Name())
+	assert.Equal("[foreman]\nurl = https://satellite.example.com\nuser = admin\npassword = 100%%\nssl_verify = True\n",
+		string(content))
+
+	// Test CloudForms credentials that opt out of certificate verification
+	c = common.Credential{
+		Host:              "https://cloudforms.example.com",
+		Username:          "admin",
+		Password:          util.Cipher("secret"),
+		Kind:              common.CredentialKindCLOUDFORMS,
+		SSLVerifyDisabled: true,
+	}
+
+	actual, f, err = GetCloudCredential([]string{}, c, dir)
+	assert.Nil(err)
+	assert.Equal([]string{"CLOUDFORMS_INI_PATH=" + f.Name()}, actual)
+	content, _ = ioutil.ReadFile(f.Name())
+	assert.Equal("[cloudforms]\nurl = https://cloudforms.example.com\nusername = admin\npassword = secret\nssl_verify = False\n",
+		string(content))
+}
@@ -0,0 +1,148 @@
+package misc
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/util"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// WinRM transports of windows credentials
+const (
+	WinRMKerberos = "kerberos"
+	WinRMNTLM     = "ntlm"
+)
+
+const (
+	// krb5Cache is the name of the ticket cache of a job in its credential directory
+	krb5Cache = "krb5cc"
+	// krb5Config is the name of the krb5.conf of a job in its credential directory
+	krb5Config = "krb5.conf"
+)
+
+// WinRMTransport returns the WinRM transport of a windows credential,
+// credentials with a domain default to kerberos, the others to ntlm
+func WinRMTransport(machine common.Credential) string {
+	if len(machine.WinRMTransport) > 0 {
+		return machine.WinRMTransport
+	}
+	if len(machine.Domain) > 0 {
+		return WinRMKerberos
+	}
+	return WinRMNTLM
+}
+
+// WinRMParams returns the ansible parameters that connect to windows hosts
+// over WinRM. Kerberos tickets are obtained by Kinit before the job starts,
+// so ansible must not run kinit on its own.
+func WinRMParams(machine common.Credential) []string {
+	if machine.Kind != common.CredentialKindWIN {
+		return nil
+	}
+
+	transport := WinRMTransport(machine)
+	params := []string{"-e", "ansible_connection=winrm", "-e", "ansible_winrm_transport=" + transport}
+	if transport == WinRMKerberos {
+		params = append(params, "-e", "ansible_winrm_kinit_mode=manual")
+	}
+	return params
+}
+
+// KerberosPrincipal returns the principal of a windows credential,
+// the realm is the upper case domain
+func KerberosPrincipal(machine common.Credential) string {
+	username, realm := machine.Username, machine.Domain
+	if i := strings.LastIndex(username, "@"); i >= 0 {
+		username, realm = username[:i], username[i+1:]
+	}
+	return username + "@" + strings.ToUpper(realm)
+}
+
+// Krb5Config returns a krb5.conf with the given realms
+func Krb5Config(defaultRealm string, realms []common.KerberosRealm) string {
+	var b bytes.Buffer
+	b.WriteString("[libdefaults]\n")
+	b.WriteString("\tdefault_realm = " + defaultRealm + "\n")
+	b.WriteString("\tdns_lookup_realm = false\n")
+	b.WriteString("\tdns_lookup_kdc = true\n")
+	b.WriteString("\trdns = false\n")
+	b.WriteString("\tforwardable = true\n")
+
+	b.WriteString("\n[realms]\n")
+	for _, realm := range realms {
+		b.WriteString("\t" + realm.Realm + " = {\n")
+		for _, kdc := range realm.KDCs {
+			b.WriteString("\t\tkdc = " + kdc + "\n")
+		}
+		if len(realm.AdminServer) > 0 {
+			b.WriteString("\t\tadmin_server = " + realm.AdminServer + "\n")
+		}
+		b.WriteString("\t}\n")
+	}
+
+	b.WriteString("\n[domain_realm]\n")
+	for _, realm := range realms {
+		for _, domain := range realm.Domains {
+			domain = strings.TrimPrefix(domain, ".")
+			b.WriteString("\t." + domain + " = " + realm.Realm + "\n")
+			b.WriteString("\t" + domain + " = " + realm.Realm + "\n")
+		}
+	}
+	return b.String()
+}
+
+// Kinit obtains a kerberos ticket for a windows credential into a ticket
+// cache in dir and returns the environment variables that point the job to
+// it. The krb5.conf of the job contains the realms of the organization, jobs
+// of organizations without realms use the krb5.conf of the system.
+// Credentials that do not use kerberos need no ticket, the environment is nil then.
+// Kdestroy must be called to destroy the ticket.
+func Kinit(machine common.Credential, organizationID bson.ObjectId, dir string) ([]string, error) {
+	if machine.Kind != common.CredentialKindWIN || WinRMTransport(machine) != WinRMKerberos {
+		return nil, nil
+	}
+
+	principal := KerberosPrincipal(machine)
+	env := []string{"KRB5CCNAME=FILE:" + filepath.Join(dir, krb5Cache)}
+
+	if len(organizationID) > 0 {
+		var organization common.Organization
+		if err := db.Organizations().FindId(organizationID).One(&organization); err != nil {
+			return nil, err
+		}
+		if len(organization.KerberosRealms) > 0 {
+			config := filepath.Join(dir, krb5Config)
+			realm := principal[strings.LastIndex(principal, "@")+1:]
+			if err := ioutil.WriteFile(config, []byte(Krb5Config(realm, organization.KerberosRealms)), 0600); err != nil {
+				return nil, err
+			}
+			env = append(env, "KRB5_CONFIG="+config)
+		}
+	}
+
+	cmd := exec.Command("kinit", principal)
+	cmd.Env = append([]string{"PATH=" + os.Getenv("PATH")}, env...)
+	cmd.Stdin = strings.NewReader(string(util.Decipher(machine.Password)) + "\n")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, errors.New("kinit failed for " + principal + ": " + strings.TrimSpace(string(out)))
+	}
+	return env, nil
+}
+
+// Kdestroy destroys the ticket cache Kinit created in dir, the default
+// ticket cache of the system is left untouched
+func Kdestroy(dir string) error {
+	cache := filepath.Join(dir, krb5Cache)
+	if _, err := os.Stat(cache); os.IsNotExist(err) {
+		return nil
+	}
+	return exec.Command("kdestroy", "-c", "FILE:"+cache).Run()
+}
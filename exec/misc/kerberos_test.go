@@ -0,0 +1,68 @@
+package misc
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWinRMParams(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(WinRMParams(common.Credential{Kind: common.CredentialKindSSH, Domain: "example.com"}))
+	assert.Equal([]string{"-e", "ansible_connection=winrm", "-e", "ansible_winrm_transport=kerberos",
+		"-e", "ansible_winrm_kinit_mode=manual"},
+		WinRMParams(common.Credential{Kind: common.CredentialKindWIN, Domain: "example.com"}))
+	assert.Equal([]string{"-e", "ansible_connection=winrm", "-e", "ansible_winrm_transport=ntlm"},
+		WinRMParams(common.Credential{Kind: common.CredentialKindWIN}))
+	assert.Equal([]string{"-e", "ansible_connection=winrm", "-e", "ansible_winrm_transport=ntlm"},
+		WinRMParams(common.Credential{Kind: common.CredentialKindWIN, Domain: "example.com", WinRMTransport: WinRMNTLM}))
+}
+
+func TestKerberosPrincipal(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("admin@EXAMPLE.COM", KerberosPrincipal(common.Credential{Username: "admin", Domain: "example.com"}))
+	assert.Equal("admin@CORP.EXAMPLE.COM", KerberosPrincipal(common.Credential{Username: "admin@corp.example.com", Domain: "example.com"}))
+}
+
+func TestKrb5Config(t *testing.T) {
+	assert := assert.New(t)
+
+	config := Krb5Config("EXAMPLE.COM", []common.KerberosRealm{{
+		Realm:       "EXAMPLE.COM",
+		KDCs:        []string{"dc1.example.com", "dc2.example.com:88"},
+		AdminServer: "dc1.example.com",
+		Domains:     []string{".example.com"},
+	}})
+	assert.Equal(`[libdefaults]
+	default_realm = EXAMPLE.COM
+	dns_lookup_realm = false
+	dns_lookup_kdc = true
+	rdns = false
+	forwardable = true
+
+[realms]
+	EXAMPLE.COM = {
+		kdc = dc1.example.com
+		kdc = dc2.example.com:88
+		admin_server = dc1.example.com
+	}
+
+[domain_realm]
+	.example.com = EXAMPLE.COM
+	example.com = EXAMPLE.COM
+`, config)
+}
+
+func TestKdestroyWithoutTicket(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "tensor_kerberos_test")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(Kdestroy(dir))
+}
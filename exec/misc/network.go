@@ -0,0 +1,61 @@
+package misc
+
+import (
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/util"
+)
+
+// NetworkParams returns the ansible-playbook parameters for network devices.
+// connection and networkOS select the connection plugin and the platform,
+// the network credential logs in and enters enable mode when Authorize is set.
+// The login of a network credential takes precedence over the machine
+// credential. Secure parameters contain passwords and must not be included
+// in any output.
+func NetworkParams(network common.Credential, connection, networkOS string) (params []string, secure []string) {
+	if len(connection) > 0 {
+		params = append(params, "-e", "ansible_connection="+connection)
+	}
+	if len(networkOS) > 0 {
+		params = append(params, "-e", "ansible_network_os="+networkOS)
+	}
+	if network.Kind != common.CredentialKindNET {
+		return
+	}
+
+	if len(network.Username) > 0 {
+		params = append(params, "-e", "ansible_user="+network.Username)
+	}
+	if len(network.Password) > 0 {
+		secure = append(secure, "-e", "ansible_password="+string(util.Decipher(network.Password)))
+	}
+	if network.Authorize {
+		params = append(params, "-e", "ansible_become=yes", "-e", "ansible_become_method=enable")
+		if len(network.AuthorizePassword) > 0 {
+			secure = append(secure, "-e", "ansible_become_password="+string(util.Decipher(network.AuthorizePassword)))
+		}
+	}
+	return
+}
+
+// NetworkEnv returns the ANSIBLE_NET_* environment variables of a network
+// credential, used by network modules that take a provider argument.
+// Secure variables contain passwords and must not be included in any output.
+func NetworkEnv(network common.Credential) (env []string, secure []string) {
+	if network.Kind != common.CredentialKindNET {
+		return
+	}
+
+	if len(network.Username) > 0 {
+		env = append(env, "ANSIBLE_NET_USERNAME="+network.Username)
+	}
+	if len(network.Password) > 0 {
+		secure = append(secure, "ANSIBLE_NET_PASSWORD="+string(util.Decipher(network.Password)))
+	}
+	if network.Authorize {
+		env = append(env, "ANSIBLE_NET_AUTHORIZE=1")
+		if len(network.AuthorizePassword) > 0 {
+			secure = append(secure, "ANSIBLE_NET_AUTH_PASS="+string(util.Decipher(network.AuthorizePassword)))
+		}
+	}
+	return
+}
@@ -0,0 +1,52 @@
+package misc
+
+import (
+	"testing"
+
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkParams(t *testing.T) {
+	assert := assert.New(t)
+
+	params, secure := NetworkParams(common.Credential{}, "", "")
+	assert.Empty(params)
+	assert.Empty(secure)
+
+	// a machine credential is ignored
+	params, secure = NetworkParams(common.Credential{Kind: common.CredentialKindSSH, Username: "root"}, "network_cli", "ios")
+	assert.Equal([]string{"-e", "ansible_connection=network_cli", "-e", "ansible_network_os=ios"}, params)
+	assert.Empty(secure)
+
+	network := common.Credential{
+		Kind:              common.CredentialKindNET,
+		Username:          "admin",
+		Password:          util.Cipher("secret"),
+		Authorize:         true,
+		AuthorizePassword: util.Cipher("enable"),
+	}
+	params, secure = NetworkParams(network, "network_cli", "junos")
+	assert.Equal([]string{"-e", "ansible_connection=network_cli", "-e", "ansible_network_os=junos",
+		"-e", "ansible_user=admin", "-e", "ansible_become=yes", "-e", "ansible_become_method=enable"}, params)
+	assert.Equal([]string{"-e", "ansible_password=secret", "-e", "ansible_become_password=enable"}, secure)
+}
+
+func TestNetworkEnv(t *testing.T) {
+	assert := assert.New(t)
+
+	env, secure := NetworkEnv(common.Credential{Kind: common.CredentialKindSSH, Username: "root"})
+	assert.Empty(env)
+	assert.Empty(secure)
+
+	env, secure = NetworkEnv(common.Credential{
+		Kind:              common.CredentialKindNET,
+		Username:          "admin",
+		Password:          util.Cipher("secret"),
+		Authorize:         true,
+		AuthorizePassword: util.Cipher("enable"),
+	})
+	assert.Equal([]string{"ANSIBLE_NET_USERNAME=admin", "ANSIBLE_NET_AUTHORIZE=1"}, env)
+	assert.Equal([]string{"ANSIBLE_NET_PASSWORD=secret", "ANSIBLE_NET_AUTH_PASS=enable"}, secure)
+}
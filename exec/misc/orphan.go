@@ -0,0 +1,93 @@
+package misc
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/common"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ClaimRetryDelay is the time a message waits in its queue when its job
+// could not be claimed because the database could not be reached
+const ClaimRetryDelay = 5 * time.Second
+
+// claimableStatus are the statuses of jobs that did not start yet
+var claimableStatus = []string{"new", "pending", "waiting"}
+
+// ClaimJob records the worker as the owner of the job. It returns false if
+// a worker owns the job already or the job is no longer waiting to run, a
+// redelivered job must not run again. The owner is kept when the job is
+// finished, its final status keeps a redelivery from claiming it again.
+func ClaimJob(jobs *mgo.Collection, jobID bson.ObjectId, workerID bson.ObjectId) (bool, error) {
+	err := jobs.Update(bson.M{
+		"_id":       jobID,
+		"worker_id": bson.M{"$exists": false},
+		"status":    bson.M{"$in": claimableStatus},
+	}, bson.M{"$set": bson.M{"worker_id": workerID}})
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ReleaseJob removes the worker as the owner of the job, the job is
+// claimed again by the worker that receives its message next
+func ReleaseJob(jobs *mgo.Collection, jobID bson.ObjectId, workerID bson.ObjectId) error {
+	err := jobs.Update(bson.M{"_id": jobID, "worker_id": workerID},
+		bson.M{"$unset": bson.M{"worker_id": ""}})
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// OrphanedJobs returns the active jobs owned by the worker
+func OrphanedJobs(jobs *mgo.Collection, workerID bson.ObjectId, result interface{}) error {
+	return jobs.Find(bson.M{
+		"worker_id": workerID,
+		"status":    bson.M{"$in": common.ActiveJobStatus},
+	}).All(result)
+}
+
+// ReapJob marks an orphaned job as errored. It returns false if the job
+// finished or was reaped by another process in the meantime.
+func ReapJob(jobs *mgo.Collection, jobID bson.ObjectId, workerID bson.ObjectId) (bool, error) {
+	err := jobs.Update(bson.M{
+		"_id":       jobID,
+		"worker_id": workerID,
+		"status":    bson.M{"$in": common.ActiveJobStatus},
+	}, bson.M{"$set": bson.M{"status": "error"}})
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// OrphanExplanation is the job_explanation of the jobs of a stopped worker
+func OrphanExplanation(workerID bson.ObjectId) string {
+	return "Worker Stopped: {\"worker_id\": \"" + workerID.Hex() + "\"}"
+}
+
+// CapturedStdout returns the output persisted for the job so far
+func CapturedStdout(jobID bson.ObjectId) (string, error) {
+	var chunks []common.JobStdout
+	if err := db.JobStdout().Find(bson.M{"job_id": jobID}).Sort("counter").All(&chunks); err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	for _, chunk := range chunks {
+		text, err := chunk.Text()
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(text)
+	}
+	if b.Len() == 0 {
+		return "stdout capture is missing", nil
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,84 @@
+package misc
+
+import (
+	"errors"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// killGracePeriod is the time a job process group gets to exit after SIGTERM
+// before it is killed with SIGKILL
+const killGracePeriod = 10 * time.Second
+
+// cancelPollInterval is how often a running job checks for a cancel request
+const cancelPollInterval = 2 * time.Second
+
+// KillProcessGroup terminates every process in the process group of cmd.
+// Jobs are started with Setsid, so the process group id is the pid of the
+// proot process and signalling the group reaches ansible-playbook, terraform
+// and any forks they spawned.
+func KillProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return errors.New("process is not started")
+	}
+
+	pgid := cmd.Process.Pid
+	if err := syscall.Kill(-pgid, syscall.SIGTERM); err != nil {
+		return err
+	}
+
+	time.AfterFunc(killGracePeriod, func() {
+		// the group is usually gone by now, ESRCH is expected
+		syscall.Kill(-pgid, syscall.SIGKILL)
+	})
+
+	return nil
+}
+
+// WatchCancel polls canceled while cmd is running and kills the process group
+// of cmd as soon as it reports true. The returned function stops the watcher
+// and reports whether the process was killed because of a cancel request.
+func WatchCancel(cmd *exec.Cmd, canceled func() bool) (stop func() bool) {
+	var mu sync.Mutex
+	var killed bool
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(cancelPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if !canceled() {
+					continue
+				}
+
+				mu.Lock()
+				killed = true
+				mu.Unlock()
+
+				if err := KillProcessGroup(cmd); err != nil {
+					logrus.WithFields(logrus.Fields{
+						"Error": err.Error(),
+					}).Errorln("Unable to kill the job process group")
+				}
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() bool {
+		once.Do(func() { close(done) })
+		mu.Lock()
+		defer mu.Unlock()
+		return killed
+	}
+}
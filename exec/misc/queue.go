@@ -0,0 +1,35 @@
+package misc
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// PublishFailed finishes a job that could not be published to its queue
+// with status error, the job would stay pending otherwise
+func PublishFailed(jobs *mgo.Collection, jobID bson.ObjectId, err error) {
+	LaunchFailed(jobs, jobID, "Publishing Failed: "+err.Error())
+}
+
+// LaunchFailed finishes a job that was stored but could not be queued with
+// status error and the explanation, no runner would ever pick it up otherwise
+func LaunchFailed(jobs *mgo.Collection, jobID bson.ObjectId, explanation string) {
+	d := bson.M{
+		"$set": bson.M{
+			"status":          "error",
+			"failed":          true,
+			"finished":        time.Now(),
+			"job_explanation": explanation,
+		},
+	}
+
+	if err := jobs.UpdateId(jobID, d); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": jobID.Hex(),
+			"Error":  err.Error(),
+		}).Errorln("Failed to update job status")
+	}
+}
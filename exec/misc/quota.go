@@ -0,0 +1,41 @@
+package misc
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/common"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// CheckQuota checks the concurrent job quota of the organization that launches a job.
+// It returns the maximum job timeout of the organization in seconds, zero if the
+// organization has none, or a common.QuotaError if the quota is exhausted. The
+// quota is best-effort, concurrent launches may start a few jobs over it.
+func CheckQuota(organizationID bson.ObjectId) (int, error) {
+	var organization common.Organization
+	if err := db.Organizations().FindId(organizationID).One(&organization); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Organization ID": organizationID.Hex(),
+			"Error":           err.Error(),
+		}).Errorln("Error while getting organization")
+		return 0, errors.New("Error while getting organization")
+	}
+
+	if err := organization.CheckJobs(); err != nil {
+		return 0, err
+	}
+	return organization.MaxJobTimeout, nil
+}
+
+// Timeout returns the maximum run time of a job. The timeout of the job,
+// which is set from the quota of its organization, may shorten the
+// configured timeout. Both timeouts are in seconds.
+func Timeout(job int, configured int) time.Duration {
+	if job > 0 && (configured <= 0 || job < configured) {
+		return time.Duration(job) * time.Second
+	}
+	return time.Duration(configured) * time.Second
+}
@@ -0,0 +1,16 @@
+package misc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeout(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(3600*time.Second, Timeout(0, 3600))
+	assert.Equal(600*time.Second, Timeout(600, 3600))
+	assert.Equal(3600*time.Second, Timeout(7200, 3600))
+	assert.Equal(600*time.Second, Timeout(600, 0))
+}
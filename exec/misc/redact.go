@@ -0,0 +1,245 @@
+package misc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/util"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Redacted replaces secrets in the output of jobs, it is the same
+// placeholder the API and the activity stream show for encrypted fields
+const Redacted = "$encrypted$"
+
+// minSecretLength is the length of the shortest secret that is redacted,
+// replacing every occurrence of a shorter string would garble the output
+const minSecretLength = 4
+
+// Redactor replaces the secrets injected into a job with Redacted.
+// A nil Redactor leaves everything untouched.
+type Redactor struct {
+	// secrets are ordered longest first so that a secret that contains
+	// another secret is replaced as a whole
+	secrets [][]byte
+	longest int
+}
+
+// NewRedactor creates a Redactor for the decrypted secrets of the credentials
+func NewRedactor(credentials ...common.Credential) *Redactor {
+	r := &Redactor{}
+	for _, c := range credentials {
+		r.AddCredential(c)
+	}
+	return r
+}
+
+// LoadRedactor creates a Redactor for the credentials with the given ids
+// and the extra variables of a job that are flagged as secret
+func LoadRedactor(credentialIDs []*bson.ObjectId, vars gin.H, secretVars []string) (*Redactor, error) {
+	ids := []bson.ObjectId{}
+	for _, id := range credentialIDs {
+		if id != nil && len(*id) > 0 {
+			ids = append(ids, *id)
+		}
+	}
+
+	var credentials []common.Credential
+	if len(ids) > 0 {
+		if err := db.Credentials().Find(bson.M{"_id": bson.M{"$in": ids}}).All(&credentials); err != nil {
+			return nil, err
+		}
+	}
+
+	r := NewRedactor(credentials...)
+	r.AddVars(vars, secretVars)
+	return r, nil
+}
+
+// AddCredential adds the decrypted secrets of a credential
+func (r *Redactor) AddCredential(c common.Credential) {
+	for _, secret := range []string{c.Password, c.SSHKeyData, c.SSHKeyUnlock, c.BecomePassword,
+		c.VaultPassword, c.AuthorizePassword, c.Secret, c.SecurityToken} {
+		if len(secret) > 0 {
+			r.Add(string(util.Decipher(secret)))
+		}
+	}
+}
+
+// AddVars adds the values of the variables with the given names
+func (r *Redactor) AddVars(vars gin.H, names []string) {
+	for _, name := range names {
+		if value, ok := vars[name]; ok {
+			r.addValue(value)
+		}
+	}
+}
+
+// addValue adds a variable value, all scalar values of lists and
+// dictionaries are secret
+func (r *Redactor) addValue(value interface{}) {
+	switch v := value.(type) {
+	case nil:
+	case string:
+		r.Add(v)
+	case map[string]interface{}:
+		for _, item := range v {
+			r.addValue(item)
+		}
+	case gin.H:
+		for _, item := range v {
+			r.addValue(item)
+		}
+	case []interface{}:
+		for _, item := range v {
+			r.addValue(item)
+		}
+	default:
+		r.Add(fmt.Sprint(v))
+	}
+}
+
+// Add adds a secret. The JSON encoded form and the lines of a multiline
+// secret, like a private key, are added as well since they show up in
+// the output of verbose jobs.
+func (r *Redactor) Add(secret string) {
+	if r == nil {
+		return
+	}
+
+	forms := []string{secret}
+	if b, err := json.Marshal(secret); err == nil {
+		forms = append(forms, string(b[1:len(b)-1]))
+	}
+	if strings.Contains(secret, "\n") {
+		for _, line := range strings.Split(secret, "\n") {
+			forms = append(forms, strings.TrimSpace(line))
+		}
+	}
+
+	for _, form := range forms {
+		r.add([]byte(form))
+	}
+}
+
+func (r *Redactor) add(secret []byte) {
+	if len(secret) < minSecretLength {
+		return
+	}
+	for _, s := range r.secrets {
+		if bytes.Equal(s, secret) {
+			return
+		}
+	}
+
+	r.secrets = append(r.secrets, secret)
+	sort.SliceStable(r.secrets, func(i, j int) bool {
+		return len(r.secrets[i]) > len(r.secrets[j])
+	})
+	if len(secret) > r.longest {
+		r.longest = len(secret)
+	}
+}
+
+// Bytes returns b with the secrets replaced, b is not modified
+func (r *Redactor) Bytes(b []byte) []byte {
+	if r == nil || len(r.secrets) == 0 {
+		return b
+	}
+	for _, secret := range r.secrets {
+		b = bytes.Replace(b, secret, []byte(Redacted), -1)
+	}
+	return b
+}
+
+// String returns s with the secrets replaced
+func (r *Redactor) String(s string) string {
+	if r == nil || len(r.secrets) == 0 {
+		return s
+	}
+	return string(r.Bytes([]byte(s)))
+}
+
+// Strings returns a copy of s with the secrets replaced in every element
+func (r *Redactor) Strings(s []string) []string {
+	if r == nil || len(r.secrets) == 0 {
+		return s
+	}
+	redacted := make([]string, len(s))
+	for i := range s {
+		redacted[i] = r.String(s[i])
+	}
+	return redacted
+}
+
+// Value returns a copy of a decoded JSON value with the secrets replaced
+// in all strings, including the keys of dictionaries
+func (r *Redactor) Value(value interface{}) interface{} {
+	if r == nil || len(r.secrets) == 0 {
+		return value
+	}
+
+	switch v := value.(type) {
+	case string:
+		return r.String(v)
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			redacted[r.String(key)] = r.Value(item)
+		}
+		return redacted
+	case gin.H:
+		redacted := make(gin.H, len(v))
+		for key, item := range v {
+			redacted[r.String(key)] = r.Value(item)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = r.Value(item)
+		}
+		return redacted
+	}
+	return value
+}
+
+// complete returns the length of the prefix of b that can be redacted on
+// its own. The rest of b may hold the beginning of a secret that continues
+// in output that is not written yet.
+func (r *Redactor) complete(b []byte) int {
+	if r == nil || len(r.secrets) == 0 {
+		return len(b)
+	}
+
+	n := len(b) - (r.longest - 1)
+	if n < 0 {
+		n = 0
+	}
+	// move the end before secrets that span it
+	for moved := true; moved; {
+		moved = false
+		for _, secret := range r.secrets {
+			for i := 0; i < n; {
+				j := bytes.Index(b[i:], secret)
+				if j < 0 {
+					break
+				}
+				start := i + j
+				if start < n && start+len(secret) > n {
+					n = start
+					moved = true
+					break
+				}
+				i = start + 1
+			}
+		}
+	}
+	return n
+}
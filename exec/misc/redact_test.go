@@ -0,0 +1,44 @@
+package misc
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactor(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRedactor(common.Credential{
+		Password:       util.Cipher("hunter22"),
+		BecomePassword: util.Cipher("x"),
+	})
+	r.AddVars(gin.H{"db_password": "s3cr\"et", "port": 5432, "user": "admin"}, []string{"db_password", "port"})
+
+	assert.Equal("ansible_password=$encrypted$", r.String("ansible_password=hunter22"))
+	// short secrets are not redacted
+	assert.Equal("x", r.String("x"))
+	assert.Equal(`{"db_password": "$encrypted$", "port": $encrypted$}`,
+		r.String(`{"db_password": "s3cr\"et", "port": 5432}`))
+	assert.Equal([]string{"PASSWORD=$encrypted$", "USER=admin"}, r.Strings([]string{"PASSWORD=hunter22", "USER=admin"}))
+	assert.Equal(gin.H{"cmd": "echo $encrypted$", "results": []interface{}{"$encrypted$", 1.0}},
+		r.Value(gin.H{"cmd": "echo hunter22", "results": []interface{}{"hunter22", 1.0}}))
+
+	var none *Redactor
+	assert.Equal("hunter22", none.String("hunter22"))
+}
+
+func TestRedactorComplete(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRedactor()
+	r.Add("hunter22")
+
+	// the end may be the beginning of the secret
+	assert.Equal(2, r.complete([]byte("ok hunter")))
+	// a secret that spans the end is kept as a whole
+	assert.Equal(3, r.complete([]byte("ok hunter22 ok")))
+	assert.Equal(13, r.complete([]byte("ok hunter22 ok ok ok")))
+	assert.Equal(0, r.complete([]byte("hunt")))
+}
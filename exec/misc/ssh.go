@@ -0,0 +1,27 @@
+package misc
+
+import (
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/ssh"
+	"github.com/pearsonappeng/tensor/util"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// AddSSHKey decrypts the private key of the credential and adds it to the
+// ssh agent, credentials without a private key are ignored
+func AddSSHKey(client agent.Agent, credential common.Credential) error {
+	if len(credential.SSHKeyData) == 0 {
+		return nil
+	}
+
+	var unlock []byte
+	if len(credential.SSHKeyUnlock) > 0 {
+		unlock = util.Decipher(credential.SSHKeyUnlock)
+	}
+
+	key, err := ssh.GetKey(util.Decipher(credential.SSHKeyData), unlock)
+	if err != nil {
+		return err
+	}
+	return client.Add(key)
+}
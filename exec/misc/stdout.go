@@ -0,0 +1,174 @@
+package misc
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/common"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// stdoutFlushInterval is the maximum time output stays in memory
+	// before it is written to the job_stdout collection
+	stdoutFlushInterval = time.Second
+	// stdoutChunkSize is the amount of pending output that triggers
+	// an immediate flush
+	stdoutChunkSize = 64 * 1024
+)
+
+// StdoutWriter is an io.Writer that persists the output of a job incrementally
+// as numbered, gzip compressed chunks into the job_stdout collection, so that
+// the output can be followed while the job is running. Only the output that
+// is not persisted yet is kept in memory and the output is not stored in the
+// job document. Secrets known to the redactor are replaced before the output
+// is persisted.
+type StdoutWriter struct {
+	mu       sync.Mutex
+	jobID    bson.ObjectId
+	redactor *Redactor
+	counter  uint64
+	lines    int
+	pending  bytes.Buffer
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewStdoutWriter creates a StdoutWriter for the given job and starts
+// flushing the output periodically. Close must be called to stop it.
+// The redactor may be nil for output without secrets.
+func NewStdoutWriter(jobID bson.ObjectId, redactor *Redactor) *StdoutWriter {
+	w := &StdoutWriter{
+		jobID:    jobID,
+		redactor: redactor,
+		done:     make(chan struct{}),
+	}
+
+	go func() {
+		ticker := time.NewTicker(stdoutFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.done:
+				return
+			case <-ticker.C:
+				w.mu.Lock()
+				w.flush(false)
+				w.mu.Unlock()
+			}
+		}
+	}()
+
+	return w
+}
+
+// Write appends p to the job output
+func (w *StdoutWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending.Write(p)
+	if w.pending.Len() >= stdoutChunkSize {
+		w.flush(false)
+	}
+	return len(p), nil
+}
+
+// Bytes flushes the pending output and returns the redacted output that
+// could not be persisted, SaveStdout stores it for a job without chunks
+func (w *StdoutWriter) Bytes() []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.flush(true)
+	return w.redactor.Bytes(w.pending.Bytes())
+}
+
+// Close stops the periodic flush and persists the remaining output
+func (w *StdoutWriter) Close() error {
+	w.once.Do(func() { close(w.done) })
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.flush(true)
+	return nil
+}
+
+// flush writes the pending output as a new chunk, the caller must hold w.mu.
+// Unless final is set, output that may end in the beginning of a secret
+// stays pending until the rest of the secret is written.
+func (w *StdoutWriter) flush(final bool) {
+	n := w.pending.Len()
+	if !final {
+		n = w.redactor.complete(w.pending.Bytes())
+	}
+	if n == 0 {
+		return
+	}
+
+	stdout := w.redactor.Bytes(w.pending.Bytes()[:n])
+	data, err := common.CompressStdout(stdout)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": w.jobID.Hex(),
+			"Error":  err.Error(),
+		}).Errorln("Failed to compress job stdout chunk")
+		return
+	}
+
+	lines := bytes.Count(stdout, []byte("\n"))
+	chunk := common.JobStdout{
+		ID:        bson.NewObjectId(),
+		JobID:     w.jobID,
+		Counter:   w.counter + 1,
+		Data:      data,
+		StartLine: w.lines,
+		EndLine:   w.lines + lines,
+		Created:   time.Now(),
+	}
+
+	if err := db.JobStdout().Insert(chunk); err != nil {
+		// keep the output pending, next flush will retry
+		logrus.WithFields(logrus.Fields{
+			"Job ID": w.jobID.Hex(),
+			"Error":  err.Error(),
+		}).Errorln("Failed to save job stdout chunk")
+		return
+	}
+
+	w.counter = chunk.Counter
+	w.lines = chunk.EndLine
+	w.pending.Next(n)
+}
+
+// SaveStdout persists the output of a job that has no output chunks, like
+// the output of a job that failed before its output was captured. The
+// output of a job that already has chunks is left untouched.
+func SaveStdout(jobID bson.ObjectId, stdout string) {
+	n, err := db.JobStdout().Find(bson.M{"job_id": jobID}).Count()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": jobID.Hex(),
+			"Error":  err.Error(),
+		}).Errorln("Failed to count job stdout chunks")
+		return
+	}
+	if n > 0 || len(stdout) == 0 {
+		return
+	}
+
+	w := NewStdoutWriter(jobID, nil)
+	for b := []byte(stdout); len(b) > 0; {
+		n := stdoutChunkSize
+		if len(b) < n {
+			n = len(b)
+		}
+		w.Write(b[:n])
+		b = b[n:]
+	}
+	w.Close()
+}
@@ -0,0 +1,176 @@
+package misc
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/queue"
+	"github.com/pearsonappeng/tensor/worker"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// deferDelay is the time a deferred job waits in its queue before
+	// it tries again to run
+	deferDelay = 2 * time.Second
+	// waitingExplanation starts the job_explanation of a job that waits
+	// for another job
+	waitingExplanation = "Waiting for job: "
+)
+
+// AcquireTemplate makes the job the current job of its template, templates
+// is the collection of the template and jobs the collection of its jobs.
+// When another job is the current job of the template its id is returned
+// and the job has to wait. A current job that is no longer active, because
+// its runner stopped without finishing it, is replaced.
+func AcquireTemplate(templates *mgo.Collection, jobs *mgo.Collection, templateID bson.ObjectId, jobID bson.ObjectId) (*bson.ObjectId, error) {
+	err := templates.Update(bson.M{
+		"_id": templateID,
+		"$or": []bson.M{
+			{"current_job_id": bson.M{"$exists": false}},
+			{"current_job_id": nil},
+			{"current_job_id": jobID},
+		},
+	}, bson.M{"$set": bson.M{"current_job_id": jobID}})
+	if err != mgo.ErrNotFound {
+		return nil, err
+	}
+
+	var template struct {
+		CurrentJobID *bson.ObjectId `bson:"current_job_id"`
+	}
+	if err := templates.FindId(templateID).Select(bson.M{"current_job_id": 1}).One(&template); err != nil {
+		// the template was removed, nothing to hold
+		if err == mgo.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	// released in the meantime
+	if template.CurrentJobID == nil {
+		return AcquireTemplate(templates, jobs, templateID, jobID)
+	}
+
+	holder := *template.CurrentJobID
+	count, err := jobs.Find(bson.M{"_id": holder, "status": bson.M{"$in": common.ActiveJobStatus}}).Count()
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return &holder, nil
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"Template ID": templateID.Hex(),
+		"Job ID":      holder.Hex(),
+	}).Warningln("Replacing the current job of the template, the job is no longer active")
+
+	err = templates.Update(bson.M{"_id": templateID, "current_job_id": holder},
+		bson.M{"$set": bson.M{"current_job_id": jobID}})
+	if err == mgo.ErrNotFound {
+		return &holder, nil
+	}
+	return nil, err
+}
+
+// ReleaseTemplate removes the job from the current job of its template
+func ReleaseTemplate(templates *mgo.Collection, templateID bson.ObjectId, jobID bson.ObjectId) {
+	err := templates.Update(bson.M{"_id": templateID, "current_job_id": jobID},
+		bson.M{"$unset": bson.M{"current_job_id": ""}})
+	if err != nil && err != mgo.ErrNotFound {
+		logrus.WithFields(logrus.Fields{
+			"Template ID": templateID.Hex(),
+			"Job ID":      jobID.Hex(),
+			"Error":       err.Error(),
+		}).Errorln("Failed to release the template")
+	}
+}
+
+// DeferTemplate defers the message of a job while another job of its template
+// is running, the job waits in the queue without holding a worker. It returns
+// false if the job became the current job of its template and can run, jobType
+// is the job_type of the jobs of the template.
+func DeferTemplate(d queue.Message, templates *mgo.Collection, jobs *mgo.Collection, templateID bson.ObjectId, jobID bson.ObjectId, jobType string) bool {
+	if templateID == "" {
+		return false
+	}
+
+	holder, err := AcquireTemplate(templates, jobs, templateID, jobID)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": jobID.Hex(),
+			"Error":  err.Error(),
+		}).Warningln("Could not acquire the job template")
+		deferJob(d, jobs, jobID)
+		return true
+	}
+	if holder == nil {
+		StopWaiting(jobs, jobID)
+		return false
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"Job ID":     jobID.Hex(),
+		"Blocked By": holder.Hex(),
+	}).Debugln("Job waiting for another job of the job template")
+
+	DeferJob(d, jobs, jobID, jobType, *holder)
+	return true
+}
+
+// DeferJob defers the message of a job that waits for another job, the job
+// waits in the queue without holding a worker. holder is the job it waits
+// for and jobType the job_type of the holder.
+func DeferJob(d queue.Message, jobs *mgo.Collection, jobID bson.ObjectId, jobType string, holder bson.ObjectId) {
+	Explanation(jobs, jobID, "waiting", waitingExplanation+"{\"job_type\": \""+jobType+"\", \"job_id\": \""+holder.Hex()+"\"}")
+	deferJob(d, jobs, jobID)
+}
+
+// deferJob releases the job and returns its message to the queue
+func deferJob(d queue.Message, jobs *mgo.Collection, jobID bson.ObjectId) {
+	// the job is claimed again by the worker that receives it next
+	if err := ReleaseJob(jobs, jobID, worker.ID); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": jobID.Hex(),
+			"Error":  err.Error(),
+		}).Errorln("Failed to release job")
+	}
+
+	if err := d.Defer(deferDelay); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": jobID.Hex(),
+			"Error":  err.Error(),
+		}).Errorln("Failed to defer job")
+	}
+}
+
+// StopWaiting clears the job_explanation of a job that waited for another job
+func StopWaiting(jobs *mgo.Collection, jobID bson.ObjectId) {
+	err := jobs.Update(bson.M{"_id": jobID, "job_explanation": bson.M{"$regex": "^" + waitingExplanation}},
+		bson.M{"$set": bson.M{"job_explanation": ""}})
+	if err != nil && err != mgo.ErrNotFound {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": jobID.Hex(),
+			"Error":  err.Error(),
+		}).Errorln("Failed to update job explanation")
+	}
+}
+
+// Explanation sets the status of the job and stores the reason in job_explanation
+func Explanation(jobs *mgo.Collection, jobID bson.ObjectId, status string, explanation string) {
+	d := bson.M{
+		"$set": bson.M{
+			"status":          status,
+			"job_explanation": explanation,
+		},
+	}
+
+	if err := jobs.UpdateId(jobID, d); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Status": status,
+			"Error":  err,
+		}).Errorln("Failed to update job explanation")
+	}
+}
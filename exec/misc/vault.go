@@ -0,0 +1,40 @@
+package misc
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/util"
+)
+
+// VaultParams writes a password script for every vault credential into dir
+// and returns the ansible-playbook parameters that use them. The scripts
+// print the vault password, so that the password never shows up on the
+// command line or in the environment of the job. Credentials with a vault id
+// are passed as --vault-id, the others as --vault-password-file.
+// It is the caller's responsibility to remove dir when no longer needed.
+func VaultParams(vaults []common.Credential, dir string) ([]string, error) {
+	var params []string
+	for i, vault := range vaults {
+		script := filepath.Join(dir, "vault_"+strconv.Itoa(i))
+		content := "#!/bin/sh\nprintf '%s\\n' " + shellQuote(string(util.Decipher(vault.VaultPassword))) + "\n"
+		if err := ioutil.WriteFile(script, []byte(content), 0700); err != nil {
+			return nil, err
+		}
+
+		if len(vault.VaultID) > 0 {
+			params = append(params, "--vault-id", vault.VaultID+"@"+script)
+		} else {
+			params = append(params, "--vault-password-file", script)
+		}
+	}
+	return params, nil
+}
+
+// shellQuote quotes s as a single argument of a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,37 @@
+package misc
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVaultParams(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "tensor_vault_test")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	vaults := []common.Credential{
+		{VaultPassword: util.Cipher("it's $ecret")},
+		{VaultPassword: util.Cipher("prod"), VaultID: "prod"},
+	}
+
+	params, err := VaultParams(vaults, dir)
+	assert.Nil(err)
+	first, second := filepath.Join(dir, "vault_0"), filepath.Join(dir, "vault_1")
+	assert.Equal([]string{"--vault-password-file", first, "--vault-id", "prod@" + second}, params)
+
+	out, err := exec.Command(first).Output()
+	assert.Nil(err)
+	assert.Equal("it's $ecret\n", string(out))
+
+	info, _ := os.Stat(second)
+	assert.Equal(os.FileMode(0700), info.Mode())
+}
@@ -7,7 +7,9 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/exec/misc"
 	"github.com/pearsonappeng/tensor/exec/types"
+	"github.com/pearsonappeng/tensor/models/ansible"
 )
 
 func start(t types.SyncJob) {
@@ -46,10 +48,20 @@ func status(t types.SyncJob, s string) {
 	}
 }
 
+// jobRedactor returns the Redactor of the secrets injected into the job
+func jobRedactor(t types.SyncJob) *misc.Redactor {
+	redactor := misc.NewRedactor(t.SCM)
+	redactor.Add(t.Token)
+	return redactor
+}
+
 func jobFail(t types.SyncJob) {
 	t.Job.Status = "failed"
 	t.Job.Finished = time.Now()
 	t.Job.Failed = true
+	t.Job.JobExplanation = jobRedactor(t).String(t.Job.JobExplanation)
+
+	misc.SaveStdout(t.Job.ID, t.Job.ResultStdout)
 
 	//get elapsed time in minutes
 	diff := t.Job.Finished.Sub(t.Job.Started)
@@ -60,7 +72,6 @@ func jobFail(t types.SyncJob) {
 			"failed":          t.Job.Failed,
 			"finished":        t.Job.Finished,
 			"elapsed":         diff.Minutes(),
-			"result_stdout":   t.Job.ResultStdout,
 			"job_explanation": t.Job.JobExplanation,
 			"job_args":        t.Job.JobARGS,
 			"job_env":         t.Job.JobENV,
@@ -80,20 +91,26 @@ func jobFail(t types.SyncJob) {
 
 func jobCancel(t types.SyncJob) {
 	t.Job.Status = "canceled"
+	t.Job.CancelFlag = true
 	t.Job.Finished = time.Now()
 	t.Job.Failed = false
 
+	if len(t.Job.ResultStdout) == 0 {
+		t.Job.ResultStdout = "stdout capture is missing"
+	}
+
+	misc.SaveStdout(t.Job.ID, t.Job.ResultStdout)
+
 	//get elapsed time in minutes
 	diff := t.Job.Finished.Sub(t.Job.Started)
 
 	d := bson.M{
 		"$set": bson.M{
 			"status":          t.Job.Status,
-			"cancel_flag":     true,
+			"cancel_flag":     t.Job.CancelFlag,
 			"failed":          t.Job.Failed,
 			"finished":        t.Job.Finished,
 			"elapsed":         diff.Minutes(),
-			"result_stdout":   "stdout capture is missing",
 			"job_explanation": "Job Cancelled",
 			"job_args":        t.Job.JobARGS,
 			"job_env":         t.Job.JobENV,
@@ -115,6 +132,9 @@ func jobError(t types.SyncJob) {
 	t.Job.Status = "error"
 	t.Job.Finished = time.Now()
 	t.Job.Failed = true
+	t.Job.JobExplanation = jobRedactor(t).String(t.Job.JobExplanation)
+
+	misc.SaveStdout(t.Job.ID, t.Job.ResultStdout)
 
 	//get elapsed time in minutes
 	diff := t.Job.Finished.Sub(t.Job.Started)
@@ -125,7 +145,6 @@ func jobError(t types.SyncJob) {
 			"failed":          t.Job.Failed,
 			"finished":        t.Job.Finished,
 			"elapsed":         diff.Minutes(),
-			"result_stdout":   t.Job.ResultStdout,
 			"job_explanation": t.Job.JobExplanation,
 			"job_args":        t.Job.JobARGS,
 			"job_env":         t.Job.JobENV,
@@ -147,6 +166,9 @@ func jobSuccess(t types.SyncJob) {
 	t.Job.Status = "successful"
 	t.Job.Finished = time.Now()
 	t.Job.Failed = false
+	t.Job.JobExplanation = jobRedactor(t).String(t.Job.JobExplanation)
+
+	misc.SaveStdout(t.Job.ID, t.Job.ResultStdout)
 
 	//get elapsed time in minutes
 	diff := t.Job.Finished.Sub(t.Job.Started)
@@ -157,7 +179,6 @@ func jobSuccess(t types.SyncJob) {
 			"failed":          t.Job.Failed,
 			"finished":        t.Job.Finished,
 			"elapsed":         diff.Minutes(),
-			"result_stdout":   t.Job.ResultStdout,
 			"job_explanation": t.Job.JobExplanation,
 			"job_args":        t.Job.JobARGS,
 			"job_env":         t.Job.JobENV,
@@ -190,3 +211,8 @@ func updateProject(t types.SyncJob) {
 		}).Errorln("Failed to update project")
 	}
 }
+
+// Reap finishes a project update of a stopped worker with status error
+func Reap(job ansible.Job) {
+	jobError(types.SyncJob{Job: job, ProjectID: job.ProjectID})
+}
@@ -1,7 +1,6 @@
 package sync
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"os"
@@ -17,12 +16,14 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/exec/misc"
 	"github.com/pearsonappeng/tensor/exec/types"
 	"github.com/pearsonappeng/tensor/models/ansible"
 	"github.com/pearsonappeng/tensor/models/common"
 	"github.com/pearsonappeng/tensor/queue"
 	"github.com/pearsonappeng/tensor/ssh"
 	"github.com/pearsonappeng/tensor/util"
+	"github.com/pearsonappeng/tensor/worker"
 )
 
 func Sync(j types.SyncJob) {
@@ -90,8 +91,12 @@ func Sync(j types.SyncJob) {
 		cleanup()
 	}()
 
-	cmd, err := getCmd(&j, socket, pid)
+	// secrets injected into the job are scrubbed from everything that is persisted
+	redactor := jobRedactor(j)
 
+	cmd, err := getCmd(&j, socket, pid)
+	j.Job.JobARGS = redactor.Strings(j.Job.JobARGS)
+	j.Job.JobENV = redactor.Strings(j.Job.JobENV)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"Error": err.Error(),
@@ -101,9 +106,11 @@ func Sync(j types.SyncJob) {
 		return
 	}
 
-	var b bytes.Buffer
-	cmd.Stdout = &b
-	cmd.Stderr = &b
+	// output is persisted in chunks while the job is running
+	b := misc.NewStdoutWriter(j.Job.ID, redactor)
+	defer b.Close()
+	cmd.Stdout = b
+	cmd.Stderr = b
 
 	// Set setsid to create a new session, The new process group has no controlling
 	// terminal which disables the stdin & will skip prompts
@@ -122,10 +129,27 @@ func Sync(j types.SyncJob) {
 	var timer *time.Timer
 	timer = time.AfterFunc(time.Duration(util.Config.SyncJobTimeOut)*time.Second, func() {
 		logrus.Println("Killing the process. Execution exceeded threashold value")
-		cmd.Process.Kill()
+		misc.KillProcessGroup(cmd)
+	})
+
+	// kill the process group if the job is canceled through the API
+	stopWatch := misc.WatchCancel(cmd, func() bool {
+		return misc.CancelRequested(db.Jobs(), j.Job.ID)
 	})
 
-	if err := cmd.Wait(); err != nil {
+	err = cmd.Wait()
+	if stopWatch() {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": j.Job.ID.Hex(),
+			"Name":   j.Job.Name,
+		}).Infoln("Project update canceled")
+		timer.Stop()
+		j.Job.ResultStdout = string(b.Bytes())
+		jobCancel(j)
+		return
+	}
+
+	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"Error": err.Error(),
 		}).Errorln("Running Project update task failed")
@@ -195,8 +219,116 @@ func createJobDirs(j types.SyncJob) {
 // UpdateProject will create and start a update system job
 // using ansible playbook project_update.yml
 func UpdateProject(p common.Project) (*types.SyncJob, error) {
+	return UpdateProjectJob(p, bson.NewObjectId())
+}
+
+// UpdateProjectJob starts the update system job of the project
+// with the given id, the id may be stored before the job exists
+func UpdateProjectJob(p common.Project, id bson.ObjectId) (*types.SyncJob, error) {
+	runnerJob, err := newUpdateJob(p, id)
+	if err != nil {
+		return nil, err
+	}
+
+	jobBytes, err := json.Marshal(runnerJob)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Unable to marshal Job")
+		misc.LaunchFailed(db.Jobs(), runnerJob.Job.ID, "Unable to marshal Job: "+err.Error())
+		return nil, err
+	}
+
+	// terraform projects are checked out by the terraform workers
+	name := queue.Ansible
+	if p.Kind == "terraform" {
+		name = queue.Terraform
+	}
+
+	// publish bytes to the queue of the project
+	opts := queue.Options{Priority: runnerJob.Job.Priority, Group: runnerJob.Job.OrganizationID.Hex()}
+	if err := queue.Publish(name, jobBytes, opts); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while publishing to Queue")
+		misc.PublishFailed(db.Jobs(), runnerJob.Job.ID, err)
+		return nil, err
+	}
+
+	return runnerJob, nil
+}
+
+// UpdateCheckout updates the checkout of the project on this worker, the
+// update job runs in the calling worker. It returns the finished update job.
+func UpdateCheckout(p common.Project) (*types.SyncJob, error) {
+	runnerJob, err := newUpdateJob(p, bson.NewObjectId())
+	if err != nil {
+		return nil, err
+	}
+
+	// the update is reaped with the calling job if this worker stops
+	if _, err := misc.ClaimJob(db.Jobs(), runnerJob.Job.ID, worker.ID); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": runnerJob.Job.ID.Hex(),
+			"Error":  err.Error(),
+		}).Errorln("Failed to claim job")
+	}
+
+	Sync(*runnerJob)
+
+	if err := db.Jobs().FindId(runnerJob.Job.ID).One(&runnerJob.Job); err != nil {
+		return runnerJob, err
+	}
+	return runnerJob, nil
+}
+
+// Run runs the project update of a message and acknowledges it, project
+// updates are received by the runners of the queue of their project
+func Run(d queue.Message) {
+	j := types.SyncJob{}
+	if err := json.Unmarshal(d.Body(), &j); err != nil {
+		logrus.Warningln("Project update delivery rejected")
+		d.Reject("Invalid project update: " + err.Error())
+		return
+	}
+
+	// a redelivered update must not run twice
+	ok, err := misc.ClaimJob(db.Jobs(), j.Job.ID, worker.ID)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": j.Job.ID.Hex(),
+			"Error":  err.Error(),
+		}).Errorln("Failed to claim job")
+		d.Defer(misc.ClaimRetryDelay)
+		return
+	}
+	if !ok {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": j.Job.ID.Hex(),
+		}).Warningln("Project update was started before, skipping delivery")
+		d.Ack()
+		return
+	}
+
+	// update was canceled while it was waiting in the queue
+	if misc.CancelRequested(db.Jobs(), j.Job.ID) {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": j.Job.ID.Hex(),
+			"Name":   j.Job.Name,
+		}).Infoln("Project update canceled before start")
+		jobCancel(j)
+		d.Ack()
+		return
+	}
+
+	Sync(j)
+	d.Ack()
+}
+
+// newUpdateJob stores a new update job of the project with the id
+func newUpdateJob(p common.Project, id bson.ObjectId) (*types.SyncJob, error) {
 	job := ansible.Job{
-		ID:           bson.NewObjectId(),
+		ID:           id,
 		Name:         p.Name + " update Job",
 		Description:  "Updates " + p.Name + " Project",
 		LaunchType:   ansible.JOB_LAUNCH_TYPE_MANUAL,
@@ -211,6 +343,9 @@ func UpdateProject(p common.Project) (*types.SyncJob, error) {
 		CreatedByID:  p.CreatedByID,
 		ModifiedByID: p.ModifiedByID,
 	}
+	// updates run before the jobs of the organization that wait for them
+	job.Priority = queue.MaxPriority
+	job.OrganizationID = p.OrganizationID
 
 	if p.ScmCredentialID != "" {
 		job.SCMCredentialID = p.ScmCredentialID
@@ -252,26 +387,11 @@ func UpdateProject(p common.Project) (*types.SyncJob, error) {
 			logrus.WithFields(logrus.Fields{
 				"Error": err.Error(),
 			}).Errorln("Error while getting SCM Credential")
+			misc.LaunchFailed(db.Jobs(), job.ID, "Error while getting SCM Credential")
 			return nil, errors.New("Error while getting SCM Credential")
 		}
 		runnerJob.SCM = credential
 	}
 
-	jobBytes, err := json.Marshal(runnerJob)
-	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"Error": err.Error(),
-		}).Errorln("Unable to marshal Job")
-		return nil, err
-	}
-
-	// publish bytes to ansible queue
-	if err := queue.Publish(queue.Ansible, jobBytes); err != nil {
-		logrus.WithFields(logrus.Fields{
-			"Error": err.Error(),
-		}).Errorln("Error while publishing to Queue")
-		return nil, err
-	}
-
 	return &runnerJob, nil
 }
@@ -0,0 +1,153 @@
+package terraform
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/exec/misc"
+	"github.com/pearsonappeng/tensor/exec/types"
+	"github.com/pearsonappeng/tensor/jwt"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/models/terraform"
+	"github.com/pearsonappeng/tensor/queue"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// NewJob creates a new job with the settings of the job template
+func NewJob(template terraform.JobTemplate, user common.User) terraform.Job {
+	return terraform.Job{
+		ID:                  bson.NewObjectId(),
+		Name:                template.Name,
+		Description:         template.Description,
+		LaunchType:          terraform.JobLaunchTypeManual,
+		CancelFlag:          false,
+		Status:              "new",
+		JobType:             template.JobType,
+		Vars:                template.Vars,
+		Parallelism:         template.Parallelism,
+		UpdateOnLaunch:      template.UpdateOnLaunch,
+		MachineCredentialID: template.MachineCredentialID,
+		JobTemplateID:       template.ID,
+		Target:              template.Target,
+		ProjectID:           template.ProjectID,
+		NetworkCredentialID: template.NetworkCredentialID,
+		CloudCredentialID:   template.CloudCredentialID,
+		SCMCredentialID:     template.SCMCredentialID,
+		CreatedByID:         user.ID,
+		ModifiedByID:        user.ID,
+		Created:             time.Now(),
+		Modified:            time.Now(),
+		PromptCredential:    template.PromptCredential,
+		PromptJobType:       template.PromptJobType,
+		PromptVariables:     template.PromptVariables,
+		AllowSimultaneous:   template.AllowSimultaneous,
+		Directory:           template.Directory,
+		Priority:            template.Priority,
+		SecretVars:          template.SecretVars,
+	}
+}
+
+// Launch loads the credentials and project of the job, stores the job
+// and publishes it to the terraform queue
+func Launch(job terraform.Job, template terraform.JobTemplate, user common.User) error {
+	// create new Terraform runner Job
+	runnerJob := types.TerraformJob{
+		Job:      job,
+		Template: template,
+		User:     user,
+	}
+
+	if job.NetworkCredentialID != nil {
+		var credential common.Credential
+		if err := db.Credentials().FindId(*job.NetworkCredentialID).One(&credential); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Error": err.Error(),
+			}).Errorln("Error while getting network credential")
+			return errors.New("Error while getting network credential")
+		}
+		runnerJob.Network = credential
+	}
+
+	if job.CloudCredentialID != nil {
+		var credential common.Credential
+		if err := db.Credentials().FindId(*job.CloudCredentialID).One(&credential); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Error": err.Error(),
+			}).Errorln("Error while getting cloud credential")
+			return errors.New("Error while getting cloud credential")
+		}
+		runnerJob.Cloud = credential
+	}
+
+	if job.MachineCredentialID != nil {
+		var credential common.Credential
+		if err := db.Credentials().FindId(*job.MachineCredentialID).One(&credential); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Error": err.Error(),
+			}).Errorln("Error while getting machine credential")
+			return errors.New("Error while getting machine credential")
+		}
+		runnerJob.Machine = credential
+	}
+
+	var project common.Project
+	if err := db.Projects().FindId(job.ProjectID).One(&project); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while getting project")
+		return errors.New("Error while getting project")
+	}
+	runnerJob.Project = project
+
+	// jobs of an organization take turns with the jobs of other organizations
+	job.OrganizationID = project.OrganizationID
+	runnerJob.Job.OrganizationID = project.OrganizationID
+
+	// the organization limits the number of concurrent jobs and their run time
+	timeout, err := misc.CheckQuota(project.OrganizationID)
+	if err != nil {
+		return err
+	}
+	job.Timeout = timeout
+	runnerJob.Job.Timeout = timeout
+
+	// Get jwt token for authorize API
+	var token jwt.LocalToken
+	if err := jwt.NewAuthToken(&token); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while getting token")
+		return errors.New("Error while getting token")
+	}
+	runnerJob.Token = token.Token
+
+	if err := db.TerrafromJobs().Insert(job); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while creating job")
+		return errors.New("Error while creating job")
+	}
+
+	jobBytes, err := json.Marshal(runnerJob)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while encoding the job")
+		return errors.New("Error while encoding the job")
+	}
+
+	// publish bytes to terraform queue
+	opts := queue.Options{Priority: job.Priority, Group: job.OrganizationID.Hex()}
+	if err := queue.Publish(queue.Terraform, jobBytes, opts); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while publishing to Queue")
+		misc.PublishFailed(db.TerrafromJobs(), job.ID, err)
+		return err
+	}
+
+	return nil
+}
@@ -7,7 +7,9 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/exec/misc"
 	"github.com/pearsonappeng/tensor/exec/types"
+	"github.com/pearsonappeng/tensor/notification"
 )
 
 func start(t *types.TerraformJob) {
@@ -46,10 +48,21 @@ func status(t *types.TerraformJob, s string) {
 	}
 }
 
+// jobRedactor returns the Redactor of the secrets injected into the job
+func jobRedactor(t *types.TerraformJob) *misc.Redactor {
+	redactor := misc.NewRedactor(t.Machine, t.Network, t.SCM, t.Cloud)
+	redactor.Add(t.Token)
+	redactor.AddVars(t.Job.Vars, t.Job.SecretVars)
+	return redactor
+}
+
 func jobFail(t *types.TerraformJob) {
 	t.Job.Status = "failed"
 	t.Job.Finished = time.Now()
 	t.Job.Failed = true
+	t.Job.JobExplanation = jobRedactor(t).String(t.Job.JobExplanation)
+
+	misc.SaveStdout(t.Job.ID, t.Job.ResultStdout)
 
 	//get elapsed time in minutes
 	diff := t.Job.Finished.Sub(t.Job.Started)
@@ -60,7 +73,6 @@ func jobFail(t *types.TerraformJob) {
 			"failed":          t.Job.Failed,
 			"finished":        t.Job.Finished,
 			"elapsed":         diff.Minutes(),
-			"result_stdout":   t.Job.ResultStdout,
 			"job_explanation": t.Job.JobExplanation,
 			"job_args":        t.Job.JobARGS,
 			"job_env":         t.Job.JobENV,
@@ -77,24 +89,31 @@ func jobFail(t *types.TerraformJob) {
 
 	updateProject(t)
 	updateJobTemplate(t)
+	notify(t)
 }
 
 func jobCancel(t *types.TerraformJob) {
 	t.Job.Status = "canceled"
+	t.Job.CancelFlag = true
 	t.Job.Finished = time.Now()
 	t.Job.Failed = false
 
+	if len(t.Job.ResultStdout) == 0 {
+		t.Job.ResultStdout = "stdout capture is missing"
+	}
+
+	misc.SaveStdout(t.Job.ID, t.Job.ResultStdout)
+
 	//get elapsed time in minutes
 	diff := t.Job.Finished.Sub(t.Job.Started)
 
 	d := bson.M{
 		"$set": bson.M{
 			"status":          t.Job.Status,
-			"cancel_flag":     true,
+			"cancel_flag":     t.Job.CancelFlag,
 			"failed":          t.Job.Failed,
 			"finished":        t.Job.Finished,
 			"elapsed":         diff.Minutes(),
-			"result_stdout":   "stdout capture is missing",
 			"job_explanation": "Job Cancelled",
 			"job_args":        t.Job.JobARGS,
 			"job_env":         t.Job.JobENV,
@@ -117,6 +136,9 @@ func jobError(t *types.TerraformJob) {
 	t.Job.Status = "error"
 	t.Job.Finished = time.Now()
 	t.Job.Failed = true
+	t.Job.JobExplanation = jobRedactor(t).String(t.Job.JobExplanation)
+
+	misc.SaveStdout(t.Job.ID, t.Job.ResultStdout)
 
 	//get elapsed time in minutes
 	diff := t.Job.Finished.Sub(t.Job.Started)
@@ -127,7 +149,6 @@ func jobError(t *types.TerraformJob) {
 			"failed":          t.Job.Failed,
 			"finished":        t.Job.Finished,
 			"elapsed":         diff.Minutes(),
-			"result_stdout":   t.Job.ResultStdout,
 			"job_explanation": t.Job.JobExplanation,
 			"job_args":        t.Job.JobARGS,
 			"job_env":         t.Job.JobENV,
@@ -144,12 +165,16 @@ func jobError(t *types.TerraformJob) {
 
 	updateProject(t)
 	updateJobTemplate(t)
+	notify(t)
 }
 
 func jobSuccess(t *types.TerraformJob) {
 	t.Job.Status = "successful"
 	t.Job.Finished = time.Now()
 	t.Job.Failed = false
+	t.Job.JobExplanation = jobRedactor(t).String(t.Job.JobExplanation)
+
+	misc.SaveStdout(t.Job.ID, t.Job.ResultStdout)
 
 	//get elapsed time in minutes
 	diff := t.Job.Finished.Sub(t.Job.Started)
@@ -160,11 +185,11 @@ func jobSuccess(t *types.TerraformJob) {
 			"failed":          t.Job.Failed,
 			"finished":        t.Job.Finished,
 			"elapsed":         diff.Minutes(),
-			"result_stdout":   t.Job.ResultStdout,
 			"job_explanation": t.Job.JobExplanation,
 			"job_args":        t.Job.JobARGS,
 			"job_env":         t.Job.JobENV,
 			"job_cwd":         t.Job.JobCWD,
+			"artifacts":       t.Job.Artifacts,
 		},
 	}
 
@@ -177,6 +202,7 @@ func jobSuccess(t *types.TerraformJob) {
 
 	updateProject(t)
 	updateJobTemplate(t)
+	notify(t)
 }
 
 func updateProject(t *types.TerraformJob) {
@@ -209,4 +235,21 @@ func updateJobTemplate(t *types.TerraformJob) {
 			"Error":  err,
 		}).Errorln("Failed to update JobTemplate")
 	}
+
+	misc.ReleaseTemplate(db.TerrafromJobTemplates(), t.Template.ID, t.Job.ID)
+}
+
+// notify sends the notifications of the finished job in the background
+func notify(t *types.TerraformJob) {
+	go notification.JobFinished(notification.Job{
+		ID:             t.Job.ID,
+		Type:           notification.JobTypeTerraform,
+		Name:           t.Job.Name,
+		Status:         t.Job.Status,
+		Failed:         t.Job.Failed,
+		Started:        t.Job.Started,
+		Finished:       t.Job.Finished,
+		TemplateID:     t.Template.ID,
+		OrganizationID: t.Project.OrganizationID,
+	})
 }
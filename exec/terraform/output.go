@@ -0,0 +1,29 @@
+package terraform
+
+import (
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+)
+
+// output is an output of terraform output -json
+type output struct {
+	Sensitive bool        `json:"sensitive"`
+	Type      string      `json:"type"`
+	Value     interface{} `json:"value"`
+}
+
+// parseOutputs returns the values of the outputs
+// printed by terraform output -json by name
+func parseOutputs(b []byte) (gin.H, error) {
+	outputs := map[string]output{}
+	if err := json.Unmarshal(b, &outputs); err != nil {
+		return nil, err
+	}
+
+	vars := gin.H{}
+	for name, o := range outputs {
+		vars[name] = o.Value
+	}
+	return vars, nil
+}
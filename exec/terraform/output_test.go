@@ -0,0 +1,28 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOutputs(t *testing.T) {
+	assert := assert.New(t)
+
+	vars, err := parseOutputs([]byte(`{
+		"address": {"sensitive": false, "type": "string", "value": "10.0.0.1"},
+		"ids": {"sensitive": false, "type": "list", "value": ["i-1", "i-2"]},
+		"password": {"sensitive": true, "type": "string", "value": "secret"}
+	}`))
+	assert.NoError(err)
+	assert.Equal("10.0.0.1", vars["address"])
+	assert.Equal([]interface{}{"i-1", "i-2"}, vars["ids"])
+	assert.Equal("secret", vars["password"])
+
+	vars, err = parseOutputs([]byte("{}"))
+	assert.NoError(err)
+	assert.Empty(vars)
+
+	_, err = parseOutputs([]byte("The state file either has no outputs defined"))
+	assert.Error(err)
+}
@@ -0,0 +1,78 @@
+package terraform
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/exec/misc"
+	"github.com/pearsonappeng/tensor/exec/types"
+	"github.com/pearsonappeng/tensor/models/terraform"
+	"github.com/pearsonappeng/tensor/queue"
+	"github.com/pearsonappeng/tensor/worker"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// claim makes the worker of this process the owner of the job. It returns
+// false if the job was started before, the job is reaped when its worker
+// stops heartbeating. A message whose job could not be claimed is returned
+// to the queue, a message of a job that was started before is acknowledged.
+func claim(d queue.Message, j *types.TerraformJob) bool {
+	ok, err := misc.ClaimJob(db.TerrafromJobs(), j.Job.ID, worker.ID)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Terraform Job ID": j.Job.ID.Hex(),
+			"Error":            err.Error(),
+		}).Errorln("Failed to claim job")
+		d.Defer(misc.ClaimRetryDelay)
+		return false
+	}
+	if !ok {
+		logrus.WithFields(logrus.Fields{
+			"Terraform Job ID": j.Job.ID.Hex(),
+		}).Warningln("Job was started before, skipping delivery")
+		d.Ack()
+	}
+	return ok
+}
+
+// Reap finishes the jobs of a stopped worker with status error,
+// the output captured so far is kept
+func Reap(workerID bson.ObjectId) {
+	var jobs []terraform.Job
+	if err := misc.OrphanedJobs(db.TerrafromJobs(), workerID, &jobs); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Worker ID": workerID.Hex(),
+			"Error":     err.Error(),
+		}).Errorln("Failed to get terraform jobs of the worker")
+	}
+
+	for _, job := range jobs {
+		ok, err := misc.ReapJob(db.TerrafromJobs(), job.ID, workerID)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Terraform Job ID": job.ID.Hex(),
+				"Error":            err.Error(),
+			}).Errorln("Failed to reap job")
+		}
+		if !ok {
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"Terraform Job ID": job.ID.Hex(),
+			"Worker ID":        workerID.Hex(),
+		}).Warningln("Reaping job of a stopped worker")
+
+		job.JobExplanation = misc.OrphanExplanation(workerID)
+		job.ResultStdout, err = misc.CapturedStdout(job.ID)
+		if err != nil {
+			job.ResultStdout = "stdout capture is missing"
+		}
+
+		t := types.TerraformJob{Job: job}
+		t.Template.ID = job.JobTemplateID
+		t.Project.ID = job.ProjectID
+		db.TerrafromJobTemplates().FindId(job.JobTemplateID).One(&t.Template)
+		db.Projects().FindId(job.ProjectID).One(&t.Project)
+		jobError(&t)
+	}
+}
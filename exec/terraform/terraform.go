@@ -1,7 +1,6 @@
 package terraform
 
 import (
-	"bytes"
 	"encoding/json"
 	"os"
 	"os/exec"
@@ -13,8 +12,9 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/pearsonappeng/tensor/db"
 	"github.com/pearsonappeng/tensor/exec/misc"
+	"github.com/pearsonappeng/tensor/exec/sync"
 	"github.com/pearsonappeng/tensor/exec/types"
-	"github.com/streadway/amqp"
+	"github.com/pearsonappeng/tensor/models/ansible"
 
 	"io/ioutil"
 	"path"
@@ -24,113 +24,73 @@ import (
 	"github.com/pearsonappeng/tensor/queue"
 	"github.com/pearsonappeng/tensor/ssh"
 	"github.com/pearsonappeng/tensor/util"
+	"github.com/pearsonappeng/tensor/worker"
 	"github.com/rodaine/hclencoder"
 )
 
-// Run starts consuming jobs into a channel of size prefetchLimit
+// Run consumes the terraform queue with a pool of workers, it reconnects
+// when the queue backend can not be reached and never returns
 func Run() {
-	for {
-		logrus.Infoln("Starting Terraform consumer")
-		conn, err := amqp.Dial(util.Config.RabbitMQ)
-
-		if err != nil {
-			logrus.WithFields(logrus.Fields{
-				"Queue": queue.Terraform,
-				"Error": err.Error(),
-			}).Errorln("Could not contact RabbitMQ server")
-			return
-		}
-
-		defer conn.Close()
-
-		ch, err := conn.Channel()
-
-		if err != nil {
-			logrus.WithFields(logrus.Fields{
-				"Queue": queue.Terraform,
-				"Error": err.Error(),
-			}).Errorln("Failed to open a channel")
-			return
-		}
-
-		defer ch.Close()
-
-		q, err := ch.QueueDeclare(
-			queue.Terraform, // name
-			true, // durable
-			false, // delete when unused
-			false, // exclusive
-			false, // no-wait
-			nil, // arguments
-		)
-
-		if err != nil {
-			logrus.WithFields(logrus.Fields{
-				"Queue": queue.Terraform,
-				"Error": err.Error(),
-			}).Errorln("Failed to declare a queue")
-			return
-		}
+	workers := worker.NewWorkers(queue.Terraform, util.Config.TerraformWorkers)
+	queue.Consume(queue.Terraform, workers.Size(), func(d queue.Message) {
+		workers.Start()
+		defer workers.Done()
+		consume(d)
+	})
+}
 
-		err = ch.Qos(
-			1, // prefetch count
-			0, // prefetch size
-			false, // global
-		)
+// consume runs the job of the message and acknowledges it
+func consume(d queue.Message) {
+	jb := types.TerraformJob{}
+	if err := json.Unmarshal(d.Body(), &jb); err != nil {
+		// handle error
+		logrus.Warningln("TerraformJob delivery rejected")
+		d.Reject("Invalid job: "+err.Error())
+		jobFail(&jb)
+		return
+	}
 
-		if err != nil {
-			logrus.WithFields(logrus.Fields{
-				"Queue": queue.Terraform,
-				"Error": err.Error(),
-			}).Errorln("Failed to set QoS")
-			return
-		}
+	// terraform projects are updated by the terraform workers
+	if jb.Job.JobType == ansible.JOBTYPE_UPDATE_JOB {
+		sync.Run(d)
+		return
+	}
 
-		msgs, err := ch.Consume(
-			q.Name, // queue
-			"", // consumer
-			false, // auto-ack
-			false, // exclusive
-			false, // no-local
-			false, // no-wait
-			nil, // args
-		)
+	// a redelivered job must not run twice
+	if !claim(d, &jb) {
+		return
+	}
 
-		if err != nil {
-			logrus.WithFields(logrus.Fields{
-				"Queue": queue.Terraform,
-				"Error": err.Error(),
-			}).Errorln("Failed to register a consumer")
-			return
-		}
+	logrus.WithFields(logrus.Fields{
+		"Job ID": jb.Job.ID.Hex(),
+		"Name":   jb.Job.Name,
+	}).Infoln("TerraformJob successfuly received")
 
-		for d := range msgs {
-			jb := types.TerraformJob{}
-			if err := json.Unmarshal(d.Body, &jb); err != nil {
-				// handle error
-				logrus.Warningln("TerraformJob delivery rejected")
-				d.Reject(false)
-				jobFail(&jb)
-				continue
-			}
+	// job was canceled while it was waiting in the queue
+	if misc.CancelRequested(db.TerrafromJobs(), jb.Job.ID) {
+		logrus.WithFields(logrus.Fields{
+			"Terraform Job ID": jb.Job.ID.Hex(),
+			"Name":             jb.Job.Name,
+		}).Infoln("Terraform Job canceled before start")
+		jobCancel(&jb)
+		d.Ack()
+		return
+	}
 
-			logrus.WithFields(logrus.Fields{
-				"Job ID": jb.Job.ID.Hex(),
-				"Name":   jb.Job.Name,
-			}).Infoln("TerraformJob successfuly received")
+	// the job waits in the queue while another job of its job template runs
+	if !jb.Job.AllowSimultaneous && misc.DeferTemplate(d, db.TerrafromJobTemplates(), db.TerrafromJobs(), jb.Job.JobTemplateID, jb.Job.ID, "terraform_job") {
+		return
+	}
 
-			status(&jb, "pending")
+	status(&jb, "pending")
 
-			logrus.WithFields(logrus.Fields{
-				"Terraform Job ID": jb.Job.ID.Hex(),
-				"Name":             jb.Job.Name,
-			}).Infoln("Terraform Job changed status to pending")
+	logrus.WithFields(logrus.Fields{
+		"Terraform Job ID": jb.Job.ID.Hex(),
+		"Name":             jb.Job.Name,
+	}).Infoln("Terraform Job changed status to pending")
 
-			terraformRun(&jb)
-			d.Ack(false)
-		}
-		logrus.Warningln("Terraform consumer stopped")
-	}
+	terraformRun(&jb)
+	d.Ack()
 }
 
 func terraformRun(j *types.TerraformJob) {
@@ -139,9 +99,8 @@ func terraformRun(j *types.TerraformJob) {
 		"Name":             j.Job.Name,
 	}).Infoln("Terraform Job starting")
 
-	// update if requested
-	if j.PreviousJob != nil {
-		// wait for scm update
+	// the checkout of the project is updated on the worker that runs the job
+	if _, err := os.Stat(j.Project.LocalPath); os.IsNotExist(err) || j.Project.ScmUpdateOnLaunch {
 		status(j, "waiting")
 
 		logrus.WithFields(logrus.Fields{
@@ -149,31 +108,25 @@ func terraformRun(j *types.TerraformJob) {
 			"Name":   j.Job.Name,
 		}).Infoln("Terraform Job changed status to waiting")
 
-		ticker := time.NewTicker(time.Second * 2)
-
-		for range ticker.C {
-			if err := db.Jobs().FindId(j.PreviousJob.Job.ID).One(&j.PreviousJob.Job); err != nil {
-				logrus.Warningln("Could not find Previous Job", err)
-				continue
+		update, err := sync.UpdateCheckout(j.Project)
+		j.PreviousJob = update
+		if err != nil || update.Job.Status != "successful" {
+			e := "Previous Task Failed: "
+			if update != nil {
+				e += "{\"job_type\": \"project_update\", \"job_name\": \"" + j.Job.Name + "\", \"job_id\": \"" + update.Job.ID.Hex() + "\"}"
+			} else {
+				e += err.Error()
 			}
+			logrus.Errorln(e)
+			j.Job.JobExplanation = e
+			j.Job.ResultStdout = "stdout capture is missing"
+			jobError(j)
+			return
+		}
 
-			if j.PreviousJob.Job.Status == "failed" || j.PreviousJob.Job.Status == "error" {
-				e := "Previous Task Failed: {\"job_type\": \"project_update\", \"job_name\": \"" + j.Job.Name + "\", \"job_id\": \"" + j.PreviousJob.Job.ID.Hex() + "\"}"
-				logrus.Errorln(e)
-				j.Job.JobExplanation = e
-				j.Job.ResultStdout = "stdout capture is missing"
-				jobError(j)
-				return
-			}
-			if j.PreviousJob.Job.Status == "successful" {
-				// stop the ticker and break the loop
-				logrus.WithFields(logrus.Fields{
-					"Job ID": j.PreviousJob.Job.ID.Hex(),
-					"Name":   j.PreviousJob.Job.Name,
-				}).Infoln("Update job successful")
-				ticker.Stop()
-				break
-			}
+		if misc.CancelRequested(db.TerrafromJobs(), j.Job.ID) {
+			jobCancel(j)
+			return
 		}
 	}
 
@@ -187,92 +140,32 @@ func terraformRun(j *types.TerraformJob) {
 	// Start SSH agent
 	client, socket, pid, sshcleanup := ssh.StartAgent()
 
-	if len(j.Machine.SSHKeyData) > 0 {
-		if len(j.Machine.SSHKeyUnlock) > 0 {
-			key, err := ssh.GetKey([]byte(util.Decipher(j.Machine.SSHKeyData)),
-				util.Decipher(j.Machine.SSHKeyUnlock))
-			if err != nil {
-				logrus.WithFields(logrus.Fields{
-					"Error": err.Error(),
-				}).Errorln("Error while decrypting Machine Credential")
-				j.Job.JobExplanation = err.Error()
-				jobFail(j)
-				return
-			}
-			if client.Add(key); err != nil {
-				logrus.WithFields(logrus.Fields{
-					"Error": err.Error(),
-				}).Errorln("Error while adding decrypted Machine Credential to SSH Agent")
-				j.Job.JobExplanation = err.Error()
-				jobFail(j)
-				return
-			}
-		}
-
-		key, err := ssh.GetKey([]byte(util.Decipher(j.Machine.SSHKeyData)), nil)
-		if err != nil {
-			logrus.WithFields(logrus.Fields{
-				"Error": err.Error(),
-			}).Errorln("Error while decrypting Machine Credential")
-			j.Job.JobExplanation = err.Error()
-			jobFail(j)
-			return
-		}
-
-		if client.Add(key); err != nil {
-			logrus.WithFields(logrus.Fields{
-				"Error": err.Error(),
-			}).Errorln("Error while adding decrypted Machine Credential to SSH Agent")
-			j.Job.JobExplanation = err.Error()
-			jobFail(j)
-			return
-		}
-
+	if err := misc.AddSSHKey(client, j.Machine); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while adding Machine Credential to SSH Agent")
+		sshcleanup()
+		j.Job.JobExplanation = err.Error()
+		jobFail(j)
+		return
 	}
 
-	if len(j.Network.SSHKeyData) > 0 {
-		if len(j.Network.SSHKeyUnlock) > 0 {
-			key, err := ssh.GetKey(util.Decipher(j.Machine.SSHKeyData), util.Decipher(j.Network.SSHKeyUnlock))
-			if err != nil {
-				logrus.WithFields(logrus.Fields{
-					"Error": err.Error(),
-				}).Errorln("Error while decrypting Machine Credential")
-				j.Job.JobExplanation = err.Error()
-				jobFail(j)
-				return
-			}
-			if client.Add(key); err != nil {
-				logrus.WithFields(logrus.Fields{
-					"Error": err.Error(),
-				}).Errorln("Error while adding decrypted Machine Credential to SSH Agent")
-				j.Job.JobExplanation = err.Error()
-				jobFail(j)
-				return
-			}
-		}
-
-		key, err := ssh.GetKey(util.Decipher(j.Machine.SSHKeyData), nil)
-		if err != nil {
-			logrus.WithFields(logrus.Fields{
-				"Error": err.Error(),
-			}).Errorln("Error while decyrpting Machine Credential")
-			j.Job.JobExplanation = err.Error()
-			jobFail(j)
-			return
-		}
-
-		if client.Add(key); err != nil {
-			logrus.WithFields(logrus.Fields{
-				"Error": err.Error(),
-			}).Errorln("Error while adding decyrpted Machine Credential to SSH Agent")
-			j.Job.JobExplanation = err.Error()
-			jobFail(j)
-			return
-		}
-
+	if err := misc.AddSSHKey(client, j.Network); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while adding Network Credential to SSH Agent")
+		sshcleanup()
+		j.Job.JobExplanation = err.Error()
+		jobFail(j)
+		return
 	}
 
-	cmd, getCmd, cleanup, err := getCmd(j, socket, pid)
+	// secrets injected into the job are scrubbed from everything that is persisted
+	redactor := jobRedactor(j)
+
+	cmd, getCmd, outputCmd, cleanup, err := getCmd(j, socket, pid)
+	j.Job.JobARGS = redactor.Strings(j.Job.JobARGS)
+	j.Job.JobENV = redactor.Strings(j.Job.JobENV)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"Error": err.Error(),
@@ -292,9 +185,11 @@ func terraformRun(j *types.TerraformJob) {
 		sshcleanup()
 		cleanup()
 	}()
-	var b bytes.Buffer
-	cmd.Stdout = &b
-	cmd.Stderr = &b
+	// output is persisted in chunks while the job is running
+	b := misc.NewStdoutWriter(j.Job.ID, redactor)
+	defer b.Close()
+	cmd.Stdout = b
+	cmd.Stderr = b
 	// Set setsid to create a new session, The new process group has no controlling
 	// terminal which disables the stdin & will skip prompts
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
@@ -306,11 +201,18 @@ func terraformRun(j *types.TerraformJob) {
 			"Error": err.Error(),
 		}).Errorln("Running terraform " + j.Job.JobType + " failed")
 		j.Job.JobExplanation = "terraform get failed"
-		j.Job.ResultStdout = string(getOutput)
+		j.Job.ResultStdout = redactor.String(string(getOutput))
 		jobFail(j)
 		return
 	}
 
+	// terraform get may take a while, do not start if canceled meanwhile
+	if misc.CancelRequested(db.TerrafromJobs(), j.Job.ID) {
+		j.Job.ResultStdout = redactor.String(string(getOutput))
+		jobCancel(j)
+		return
+	}
+
 	if err := cmd.Start(); err != nil {
 		logrus.WithFields(logrus.Fields{
 			"Error": err.Error(),
@@ -321,11 +223,26 @@ func terraformRun(j *types.TerraformJob) {
 		return
 	}
 	var timer *time.Timer
-	timer = time.AfterFunc(time.Duration(util.Config.TerraformJobTimeOut) * time.Second, func() {
+	timer = time.AfterFunc(misc.Timeout(j.Job.Timeout, util.Config.TerraformJobTimeOut), func() {
 		logrus.Println("Killing the process. Execution exceeded threashold value")
-		cmd.Process.Kill()
+		misc.KillProcessGroup(cmd)
+	})
+	// kill the process group if the job is canceled through the API
+	stopWatch := misc.WatchCancel(cmd, func() bool {
+		return misc.CancelRequested(db.TerrafromJobs(), j.Job.ID)
 	})
-	if err := cmd.Wait(); err != nil {
+	err = cmd.Wait()
+	if stopWatch() {
+		logrus.WithFields(logrus.Fields{
+			"Terraform Job ID": j.Job.ID.Hex(),
+			"Name":             j.Job.Name,
+		}).Infoln("Terraform Job canceled")
+		timer.Stop()
+		j.Job.ResultStdout = string(b.Bytes())
+		jobCancel(j)
+		return
+	}
+	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"Error": err.Error(),
 		}).Errorln("Running terraform " + j.Job.JobType + " failed")
@@ -338,12 +255,28 @@ func terraformRun(j *types.TerraformJob) {
 	timer.Stop()
 	// set stdout
 	j.Job.ResultStdout = string(b.Bytes())
+
+	// outputs of the applied configuration are kept as artifacts
+	// so that workflows can pass them to the following jobs
+	if j.Job.JobType == "apply" {
+		outputCmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+		out, err := outputCmd.Output()
+		if err == nil {
+			j.Job.Artifacts, err = parseOutputs(out)
+		}
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Terraform Job ID": j.Job.ID.Hex(),
+				"Error":            err.Error(),
+			}).Warningln("Unable to get terraform outputs")
+		}
+	}
 	//success
 	jobSuccess(j)
 }
 
 // getCmd returns cmd
-func getCmd(j *types.TerraformJob, socket string, pid int) (cmd *exec.Cmd, getCmd *exec.Cmd, cleanup func(), err error) {
+func getCmd(j *types.TerraformJob, socket string, pid int) (cmd *exec.Cmd, getCmd *exec.Cmd, outputCmd *exec.Cmd, cleanup func(), err error) {
 	// Generate directory paths and create directories
 	tmp := "/tmp/tensor_proot_" + uniuri.New() + "/"
 	j.Paths = types.JobPaths{
@@ -367,6 +300,7 @@ func getCmd(j *types.TerraformJob, socket string, pid int) (cmd *exec.Cmd, getCm
 		"-b", j.Paths.VarLibProjects + ":" + util.Config.ProjectsHome,
 		"-b", j.Paths.VarLog + ":/var/log",
 		"-b", j.Paths.TmpRand + ":" + j.Paths.TmpRand,
+		"-b", j.Paths.CredentialPath + ":" + j.Paths.CredentialPath,
 		"-b", filepath.Join(util.Config.ProjectsHome, j.Project.ID.Hex()) + ":" + filepath.Join(util.Config.ProjectsHome, j.Project.ID.Hex()),
 		"-b", "/var/lib/tensor:/var/lib/tensor",
 		"-w", filepath.Join(util.Config.ProjectsHome, j.Project.ID.Hex()),
@@ -411,10 +345,10 @@ func getCmd(j *types.TerraformJob, socket string, pid int) (cmd *exec.Cmd, getCm
 		"SSH_AGENT_PID=" + strconv.Itoa(pid),
 	}
 	var f *os.File
-	if j.Cloud.Cloud {
-		cmd.Env, f, err = misc.GetCloudCredential(cmd.Env, j.Cloud)
+	if len(j.Cloud.ID) > 0 {
+		cmd.Env, f, err = misc.GetCloudCredential(cmd.Env, j.Cloud, j.Paths.CredentialPath)
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 	}
 
@@ -432,12 +366,16 @@ func getCmd(j *types.TerraformJob, socket string, pid int) (cmd *exec.Cmd, getCm
 	getCmd.Env = cmd.Env
 	getCmd.Dir = cmd.Dir
 
+	outputCmd = exec.Command("proot", append(args, "terraform", "output", "-json")...)
+	outputCmd.Env = cmd.Env
+	outputCmd.Dir = cmd.Dir
+
 	logrus.WithFields(logrus.Fields{
 		"Dir":         cmd.Dir,
 		"Environment": append([]string{}, cmd.Env...),
 	}).Debugln("Job Directory and Environment")
 
-	return cmd, getCmd, func() {
+	return cmd, getCmd, outputCmd, func() {
 		if f != nil {
 			if err := os.RemoveAll(f.Name()); err != nil {
 				logrus.Errorln("Unable to remove cloud credential")
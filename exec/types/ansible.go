@@ -13,12 +13,16 @@ type AnsibleJob struct {
 	Network     common.Credential
 	SCM         common.Credential
 	Cloud       common.Credential
+	Vaults      []common.Credential
 	Inventory   ansible.Inventory
 	Project     common.Project
 	User        common.User
 	PreviousJob *SyncJob
 	Token       string
 	Paths       JobPaths
+	// AdHocCommand is set when the job runs an ad hoc command,
+	// Job, Template and Project are empty in that case
+	AdHocCommand *ansible.AdHocCommand
 }
 
 type JobPaths struct {
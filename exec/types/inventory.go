@@ -0,0 +1,18 @@
+package types
+
+import (
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"github.com/pearsonappeng/tensor/models/common"
+)
+
+// InventoryUpdateJob contains all the information required to
+// update an inventory source
+type InventoryUpdateJob struct {
+	Job        ansible.Job
+	Source     ansible.InventorySource
+	Inventory  ansible.Inventory
+	Credential common.Credential
+	Script     ansible.InventoryScript
+	User       common.User
+	Paths      JobPaths
+}
@@ -0,0 +1,156 @@
+package workflow
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/models/workflow"
+)
+
+// Edge kinds between a node and its children
+const (
+	edgeSuccess = "success"
+	edgeFailure = "failure"
+	edgeAlways  = "always"
+)
+
+// edge is an incoming edge of a node
+type edge struct {
+	parent int
+	kind   string
+}
+
+// isFinal reports whether a node with the status will not change anymore
+func isFinal(status string) bool {
+	switch status {
+	case "successful", "failed", "error", "canceled", workflow.NodeSkipped:
+		return true
+	}
+	return false
+}
+
+// isFailed reports whether the job of a node with the status did not succeed
+func isFailed(status string) bool {
+	return status == "failed" || status == "error" || status == "canceled"
+}
+
+// fires reports whether an edge of the kind fires
+// when the parent finished with the status
+func fires(kind string, status string) bool {
+	switch kind {
+	case edgeSuccess:
+		return status == "successful"
+	case edgeFailure:
+		return isFailed(status)
+	case edgeAlways:
+		return isFinal(status) && status != workflow.NodeSkipped
+	}
+	return false
+}
+
+// parents returns the incoming edges of the nodes by node id
+func parents(nodes []workflow.JobNode) map[string][]edge {
+	p := map[string][]edge{}
+	for i, n := range nodes {
+		for _, id := range n.SuccessNodes {
+			p[id] = append(p[id], edge{parent: i, kind: edgeSuccess})
+		}
+		for _, id := range n.FailureNodes {
+			p[id] = append(p[id], edge{parent: i, kind: edgeFailure})
+		}
+		for _, id := range n.AlwaysNodes {
+			p[id] = append(p[id], edge{parent: i, kind: edgeAlways})
+		}
+	}
+	return p
+}
+
+// next returns the nodes that can be started and the nodes that will never
+// run. A node runs once all of its parents are final and at least one of the
+// incoming edges fired, nodes without parents run immediately. Nodes whose
+// incoming edges did not fire are skipped, which may skip their children too.
+func next(nodes []workflow.JobNode) (run []int, skip []int) {
+	p := parents(nodes)
+	status := make([]string, len(nodes))
+	for i, n := range nodes {
+		status[i] = n.Status
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for i, n := range nodes {
+			if len(status[i]) > 0 {
+				continue
+			}
+
+			decided, fired := true, len(p[n.ID]) == 0
+			for _, e := range p[n.ID] {
+				if !isFinal(status[e.parent]) {
+					decided = false
+					break
+				}
+				if fires(e.kind, status[e.parent]) {
+					fired = true
+				}
+			}
+			if !decided {
+				continue
+			}
+
+			if fired {
+				status[i] = "pending"
+				run = append(run, i)
+			} else {
+				status[i] = workflow.NodeSkipped
+				skip = append(skip, i)
+				changed = true
+			}
+		}
+	}
+
+	return run, skip
+}
+
+// inherited returns the artifacts the node receives from the parents
+// whose edges fired, parents later in the workflow take precedence
+func inherited(nodes []workflow.JobNode, i int) gin.H {
+	vars := gin.H{}
+	for _, e := range parents(nodes)[nodes[i].ID] {
+		if fires(e.kind, nodes[e.parent].Status) {
+			vars = mergeVars(vars, nodes[e.parent].Artifacts)
+		}
+	}
+	return vars
+}
+
+// done reports whether all the nodes are final
+func done(nodes []workflow.JobNode) bool {
+	for _, n := range nodes {
+		if !isFinal(n.Status) {
+			return false
+		}
+	}
+	return true
+}
+
+// failedNodes returns the ids of the nodes that failed
+// without a failure or always node to handle the failure
+func failedNodes(nodes []workflow.JobNode) []string {
+	ids := []string{}
+	for _, n := range nodes {
+		if isFailed(n.Status) && len(n.FailureNodes) == 0 && len(n.AlwaysNodes) == 0 {
+			ids = append(ids, n.ID)
+		}
+	}
+	return ids
+}
+
+// mergeVars returns the variables of a overridden by the variables of b
+func mergeVars(a, b map[string]interface{}) gin.H {
+	vars := gin.H{}
+	for k, v := range a {
+		vars[k] = v
+	}
+	for k, v := range b {
+		vars[k] = v
+	}
+	return vars
+}
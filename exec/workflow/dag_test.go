@@ -0,0 +1,101 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/models/workflow"
+	"github.com/stretchr/testify/assert"
+)
+
+// provision -> configure on success, provision -> cleanup on failure,
+// configure -> report always
+func testNodes() []workflow.JobNode {
+	return []workflow.JobNode{
+		{Node: workflow.Node{ID: "provision", SuccessNodes: []string{"configure"}, FailureNodes: []string{"cleanup"}}},
+		{Node: workflow.Node{ID: "configure", AlwaysNodes: []string{"report"}}},
+		{Node: workflow.Node{ID: "cleanup"}},
+		{Node: workflow.Node{ID: "report"}},
+	}
+}
+
+func TestNextRoots(t *testing.T) {
+	assert := assert.New(t)
+	run, skip := next(testNodes())
+	assert.Equal([]int{0}, run)
+	assert.Empty(skip)
+}
+
+func TestNextSuccess(t *testing.T) {
+	assert := assert.New(t)
+	nodes := testNodes()
+	nodes[0].Status = "successful"
+
+	run, skip := next(nodes)
+	assert.Equal([]int{1}, run)
+	assert.Equal([]int{2}, skip)
+
+	nodes[1].Status = "failed"
+	nodes[2].Status = workflow.NodeSkipped
+	run, skip = next(nodes)
+	assert.Equal([]int{3}, run)
+	assert.Empty(skip)
+}
+
+func TestNextFailureSkipsDescendants(t *testing.T) {
+	assert := assert.New(t)
+	nodes := testNodes()
+	nodes[0].Status = "failed"
+
+	run, skip := next(nodes)
+	assert.Equal([]int{2}, run)
+	assert.Equal([]int{1, 3}, skip)
+}
+
+func TestNextWaitsForAllParents(t *testing.T) {
+	assert := assert.New(t)
+	nodes := []workflow.JobNode{
+		{Node: workflow.Node{ID: "a", SuccessNodes: []string{"c"}}},
+		{Node: workflow.Node{ID: "b", SuccessNodes: []string{"c"}}},
+		{Node: workflow.Node{ID: "c"}},
+	}
+	nodes[0].Status = "successful"
+	nodes[1].Status = "running"
+
+	run, skip := next(nodes)
+	assert.Empty(run)
+	assert.Empty(skip)
+
+	nodes[1].Status = "failed"
+	run, skip = next(nodes)
+	assert.Equal([]int{2}, run)
+	assert.Empty(skip)
+}
+
+func TestInherited(t *testing.T) {
+	assert := assert.New(t)
+	nodes := []workflow.JobNode{
+		{Node: workflow.Node{ID: "a", SuccessNodes: []string{"c"}}, Status: "successful", Artifacts: gin.H{"ip": "10.0.0.1", "zone": "a"}},
+		{Node: workflow.Node{ID: "b", SuccessNodes: []string{"c"}}, Status: "successful", Artifacts: gin.H{"zone": "b"}},
+		{Node: workflow.Node{ID: "d", SuccessNodes: []string{"c"}}, Status: "failed", Artifacts: gin.H{"ip": "unused"}},
+		{Node: workflow.Node{ID: "c"}},
+	}
+
+	assert.Equal(gin.H{"ip": "10.0.0.1", "zone": "b"}, inherited(nodes, 3))
+	assert.Equal(gin.H{}, inherited(nodes, 0))
+}
+
+func TestFailedNodes(t *testing.T) {
+	assert := assert.New(t)
+	nodes := testNodes()
+	nodes[0].Status = "failed"
+	nodes[1].Status = workflow.NodeSkipped
+	nodes[2].Status = "successful"
+	nodes[3].Status = workflow.NodeSkipped
+
+	assert.True(done(nodes))
+	assert.Empty(failedNodes(nodes))
+
+	nodes[2].Status = "error"
+	assert.Equal([]string{"cleanup"}, failedNodes(nodes))
+}
@@ -0,0 +1,416 @@
+// Package workflow runs workflow jobs, it launches the job of every node once
+// its parents finished and passes the artifacts of the parents to the node
+package workflow
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/db"
+	execansible "github.com/pearsonappeng/tensor/exec/ansible"
+	"github.com/pearsonappeng/tensor/exec/sync"
+	execterraform "github.com/pearsonappeng/tensor/exec/terraform"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/models/terraform"
+	"github.com/pearsonappeng/tensor/models/workflow"
+	"github.com/pearsonappeng/tensor/rbac"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// interval between two lookups of active workflow jobs
+const interval = 5 * time.Second
+
+// launchTimeout is the time after which a claimed node
+// whose job was never stored is in error
+const launchTimeout = time.Minute
+
+// errPermission is returned when the owner of the workflow
+// job can no longer launch the resource of a node
+var errPermission = errors.New("Workflow job owner can no longer launch the resource of the node")
+
+// NewJob creates a new workflow job with the nodes of the workflow job template
+func NewJob(template workflow.JobTemplate, user common.User) workflow.Job {
+	nodes := make([]workflow.JobNode, len(template.Nodes))
+	for i, n := range template.Nodes {
+		nodes[i] = workflow.JobNode{Node: n}
+	}
+
+	return workflow.Job{
+		ID:                    bson.NewObjectId(),
+		Name:                  template.Name,
+		Description:           template.Description,
+		WorkflowJobTemplateID: template.ID,
+		OrganizationID:        template.OrganizationID,
+		LaunchType:            workflow.JobLaunchTypeManual,
+		CancelFlag:            false,
+		Status:                "pending",
+		ExtraVars:             template.ExtraVars,
+		AllowSimultaneous:     template.AllowSimultaneous,
+		Nodes:                 nodes,
+		CreatedByID:           user.ID,
+		ModifiedByID:          user.ID,
+		Created:               time.Now(),
+		Modified:              time.Now(),
+	}
+}
+
+// Launch stores the workflow job, the nodes are started by Run
+func Launch(job workflow.Job) error {
+	if err := db.WorkflowJobs().Insert(job); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while creating workflow job")
+		return errors.New("Error while creating workflow job")
+	}
+
+	if err := db.WorkflowJobTemplates().UpdateId(job.WorkflowJobTemplateID, bson.M{
+		"$set": bson.M{"status": job.Status, "last_job_id": job.ID},
+	}); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Failed to update workflow job template")
+	}
+
+	return nil
+}
+
+// Run advances the active workflow jobs until the process exits
+func Run() {
+	logrus.Infoln("Starting workflow runner")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		var jobs []workflow.Job
+		if err := db.WorkflowJobs().Find(bson.M{
+			"status": bson.M{"$in": []string{"pending", "running"}},
+		}).All(&jobs); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Error": err.Error(),
+			}).Errorln("Error while getting active workflow jobs")
+			continue
+		}
+
+		for _, job := range jobs {
+			advance(job)
+		}
+	}
+}
+
+// advance refreshes the status of the running nodes of the workflow job,
+// starts the nodes that are ready and finishes the job when all the nodes
+// are final. Nodes are claimed before they are started so that a node is
+// started once when several instances of tensor share the database.
+func advance(j workflow.Job) {
+	if j.Status == "pending" {
+		j.Status = "running"
+		j.Started = time.Now()
+		setJob(j.ID, bson.M{"status": j.Status, "started": j.Started})
+		setTemplate(j.WorkflowJobTemplateID, bson.M{"status": j.Status})
+	}
+
+	for i, n := range j.Nodes {
+		if n.JobID == nil || isFinal(n.Status) {
+			continue
+		}
+
+		status, artifacts := jobStatus(n)
+		if status == n.Status {
+			continue
+		}
+
+		j.Nodes[i].Status = status
+		set := bson.M{nodeField(i, "status"): status}
+		if isFinal(status) {
+			j.Nodes[i].Artifacts = mergeVars(inherited(j.Nodes, i), artifacts)
+			set[nodeField(i, "artifacts")] = j.Nodes[i].Artifacts
+		}
+		setJob(j.ID, set)
+	}
+
+	if j.CancelFlag {
+		cancelNodes(&j)
+	} else {
+		startNodes(&j)
+	}
+
+	if !done(j.Nodes) {
+		return
+	}
+
+	switch failed := failedNodes(j.Nodes); {
+	case j.CancelFlag:
+		j.Status = "canceled"
+		j.JobExplanation = "Workflow Job Cancelled"
+	case len(failed) > 0:
+		j.Status = "failed"
+		j.Failed = true
+		j.JobExplanation = "Nodes without failure handling failed: " + strings.Join(failed, ", ")
+	default:
+		j.Status = "successful"
+	}
+	finish(j)
+}
+
+// startNodes skips the nodes that will never run and
+// launches the jobs of the nodes that are ready
+func startNodes(j *workflow.Job) {
+	user, err := owner(*j)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Workflow Job ID": j.ID.Hex(),
+			"Error":           err.Error(),
+		}).Errorln("Error while getting workflow job owner")
+		return
+	}
+
+	run, skip := next(j.Nodes)
+	for _, i := range skip {
+		j.Nodes[i].Status = workflow.NodeSkipped
+		setJob(j.ID, bson.M{nodeField(i, "status"): workflow.NodeSkipped})
+	}
+
+	for _, i := range run {
+		// the job id is stored with the claim so that a node
+		// is never pending without a job
+		jobID := bson.NewObjectId()
+		claimed, err := claim(j.ID, i, jobID)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Workflow Job ID": j.ID.Hex(),
+				"Node":            j.Nodes[i].ID,
+				"Error":           err.Error(),
+			}).Errorln("Error while claiming workflow node")
+			continue
+		}
+		// another instance of tensor started the node
+		if !claimed {
+			j.Nodes[i].Status = "pending"
+			continue
+		}
+		j.Nodes[i].Status = "pending"
+		j.Nodes[i].JobID = &jobID
+
+		// workflow variables override the artifacts of the
+		// parents which override the variables of the node
+		n := j.Nodes[i]
+		vars := mergeVars(mergeVars(n.ExtraVars, inherited(j.Nodes, i)), j.ExtraVars)
+
+		if err := launchNode(*j, n, jobID, vars, user); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Workflow Job ID": j.ID.Hex(),
+				"Node":            n.ID,
+				"Error":           err.Error(),
+			}).Errorln("Error while launching workflow node")
+			j.Nodes[i].Status = "error"
+			j.Nodes[i].Artifacts = inherited(j.Nodes, i)
+			setJob(j.ID, bson.M{
+				nodeField(i, "status"):    "error",
+				nodeField(i, "artifacts"): j.Nodes[i].Artifacts,
+			})
+			continue
+		}
+	}
+}
+
+// cancelNodes requests the cancel of the running node jobs
+// and skips the nodes that were not started
+func cancelNodes(j *workflow.Job) {
+	for i, n := range j.Nodes {
+		if len(n.Status) == 0 {
+			j.Nodes[i].Status = workflow.NodeSkipped
+			setJob(j.ID, bson.M{nodeField(i, "status"): workflow.NodeSkipped})
+			continue
+		}
+		if n.JobID == nil || isFinal(n.Status) {
+			continue
+		}
+
+		c := db.Jobs()
+		if n.ResourceType == workflow.NodeTerraformJobTemplate {
+			c = db.TerrafromJobs()
+		}
+		err := c.Update(bson.M{
+			"_id":    *n.JobID,
+			"status": bson.M{"$in": common.ActiveJobStatus},
+		}, bson.M{"$set": bson.M{"cancel_flag": true}})
+		if err != nil && err != mgo.ErrNotFound {
+			logrus.WithFields(logrus.Fields{
+				"Workflow Job ID": j.ID.Hex(),
+				"Node":            n.ID,
+				"Error":           err.Error(),
+			}).Errorln("Error while canceling workflow node")
+		}
+	}
+}
+
+// claim marks the node as pending with the id of its job, false
+// is returned when the node was already claimed
+func claim(workflowJobID bson.ObjectId, i int, jobID bson.ObjectId) (bool, error) {
+	err := db.WorkflowJobs().Update(bson.M{
+		"_id":                  workflowJobID,
+		nodeField(i, "status"): bson.M{"$exists": false},
+	}, bson.M{"$set": bson.M{
+		nodeField(i, "status"): "pending",
+		nodeField(i, "job_id"): jobID,
+	}})
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// launchNode launches the job of the node with the id and the variables
+// on behalf of the user, the permissions of the user are checked again
+// since they may have changed after the workflow job was launched
+func launchNode(j workflow.Job, n workflow.JobNode, jobID bson.ObjectId, vars gin.H, user common.User) error {
+	switch n.ResourceType {
+	case workflow.NodeJobTemplate:
+		var template ansible.JobTemplate
+		if err := db.JobTemplates().FindId(n.ResourceID).One(&template); err != nil {
+			return errors.New("Error while getting job template")
+		}
+		if !new(rbac.JobTemplate).Launch(user, template) {
+			return errPermission
+		}
+
+		job := execansible.NewJob(template, user)
+		job.ID = jobID
+		job.LaunchType = ansible.JOB_LAUNCH_TYPE_WORKFLOW
+		job.WorkflowJobID = &j.ID
+		job.ExtraVars = mergeVars(template.ExtraVars, vars)
+		return execansible.Launch(job, template, user)
+
+	case workflow.NodeTerraformJobTemplate:
+		var template terraform.JobTemplate
+		if err := db.TerrafromJobTemplates().FindId(n.ResourceID).One(&template); err != nil {
+			return errors.New("Error while getting terraform job template")
+		}
+		if !new(rbac.TerraformJobTemplate).Launch(user, template) {
+			return errPermission
+		}
+
+		job := execterraform.NewJob(template, user)
+		job.ID = jobID
+		job.LaunchType = terraform.JobLaunchTypeWorkflow
+		job.WorkflowJobID = &j.ID
+		job.Vars = mergeVars(template.Vars, vars)
+		return execterraform.Launch(job, template, user)
+
+	case workflow.NodeProject:
+		var project common.Project
+		if err := db.Projects().FindId(n.ResourceID).One(&project); err != nil {
+			return errors.New("Error while getting project")
+		}
+		if !new(rbac.Project).Update(user, project) {
+			return errPermission
+		}
+
+		_, err := sync.UpdateProjectJob(project, jobID)
+		return err
+	}
+
+	return errors.New("Unknown workflow node resource type " + n.ResourceType)
+}
+
+// jobStatus returns the status and the artifacts of the job of the node,
+// a node whose job no longer exists or was not stored in time is in error
+func jobStatus(n workflow.JobNode) (string, gin.H) {
+	var job struct {
+		Status    string `bson:"status"`
+		Artifacts gin.H  `bson:"artifacts"`
+	}
+
+	c := db.Jobs()
+	if n.ResourceType == workflow.NodeTerraformJobTemplate {
+		c = db.TerrafromJobs()
+	}
+
+	if err := c.FindId(*n.JobID).Select(bson.M{"status": 1, "artifacts": 1}).One(&job); err != nil {
+		if err == mgo.ErrNotFound {
+			// the job of a node claimed by another instance may not be stored yet
+			if time.Since(n.JobID.Time()) < launchTimeout {
+				return n.Status, nil
+			}
+			return "error", nil
+		}
+		logrus.WithFields(logrus.Fields{
+			"Job ID": n.JobID.Hex(),
+			"Error":  err.Error(),
+		}).Errorln("Error while getting workflow node job")
+		return n.Status, nil
+	}
+
+	return job.Status, job.Artifacts
+}
+
+// owner returns the user that launched the workflow job, the jobs
+// of the nodes are launched on behalf of the user if the user can
+// still launch them
+func owner(j workflow.Job) (common.User, error) {
+	var user common.User
+	err := db.Users().FindId(j.CreatedByID).One(&user)
+	return user, err
+}
+
+// finish stores the final status of the workflow job
+// and updates the workflow job template
+func finish(j workflow.Job) {
+	j.Finished = time.Now()
+
+	// only the instance that moves the job out of running finishes it
+	err := db.WorkflowJobs().Update(bson.M{"_id": j.ID, "status": "running"}, bson.M{
+		"$set": bson.M{
+			"status":          j.Status,
+			"failed":          j.Failed,
+			"finished":        j.Finished,
+			"elapsed":         j.Finished.Sub(j.Started).Minutes(),
+			"job_explanation": j.JobExplanation,
+		},
+	})
+	if err == mgo.ErrNotFound {
+		return
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Workflow Job ID": j.ID.Hex(),
+			"Error":           err.Error(),
+		}).Errorln("Failed to update workflow job status")
+		return
+	}
+
+	setTemplate(j.WorkflowJobTemplateID, bson.M{
+		"status":          j.Status,
+		"last_job_run":    j.Finished,
+		"last_job_failed": j.Failed,
+	})
+}
+
+func setJob(id bson.ObjectId, set bson.M) {
+	if err := db.WorkflowJobs().UpdateId(id, bson.M{"$set": set}); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Workflow Job ID": id.Hex(),
+			"Error":           err.Error(),
+		}).Errorln("Failed to update workflow job")
+	}
+}
+
+func setTemplate(id bson.ObjectId, set bson.M) {
+	if err := db.WorkflowJobTemplates().UpdateId(id, bson.M{"$set": set}); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Workflow Job Template ID": id.Hex(),
+			"Error":                    err.Error(),
+		}).Errorln("Failed to update workflow job template")
+	}
+}
+
+// nodeField returns the path of a field of the i-th node
+func nodeField(i int, field string) string {
+	return "nodes." + strconv.Itoa(i) + "." + field
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
 
 	"gopkg.in/mgo.v2/bson"
 	"reflect"
@@ -52,6 +53,10 @@ func AddActivity(operation string, userID bson.ObjectId, object1, object2 interf
 									changes[tag] = "$encrypted$"
 									break
 								}
+							case "ExtraVars", "Vars":
+								{
+									changes[tag] = secretVars(v2, v2.Field(i).Interface())
+								}
 							default:
 								{
 									changes[tag] = v2.Field(i).Interface()
@@ -71,3 +76,28 @@ func AddActivity(operation string, userID bson.ObjectId, object1, object2 interf
 		}).Errorln("Failed to add new Activity")
 	}
 }
+
+// secretVars replaces the values of the variables that the SecretVars
+// field of object flags as secret
+func secretVars(object reflect.Value, vars interface{}) interface{} {
+	field := object.FieldByName("SecretVars")
+	if !field.IsValid() {
+		return vars
+	}
+	names, _ := field.Interface().([]string)
+	values, ok := vars.(gin.H)
+	if !ok || len(names) == 0 {
+		return vars
+	}
+
+	redacted := gin.H{}
+	for name, value := range values {
+		redacted[name] = value
+	}
+	for _, name := range names {
+		if _, ok := redacted[name]; ok {
+			redacted[name] = "$encrypted$"
+		}
+	}
+	return redacted
+}
@@ -9,26 +9,57 @@ import (
 
 type AdHocCommand struct {
 	ID            bson.ObjectId `bson:"_id" json:"id"`
+	Name          string        `bson:"name" json:"name"`
 	ModuleName    string        `bson:"module_name" json:"module_name" binding:"required"`
-	Limit         string        `bson:"limit" json:"limit"`
+	Limit         string        `bson:"limit" json:"limit" binding:"max=1024"`
 	ModuleArgs    string        `bson:"module_args" json:"module_args"`
-	JobType       string        `bson:"job_type" json:"job_type"`
+	JobType       string        `bson:"job_type" json:"job_type" binding:"omitempty,jobtype"`
 	Forks         uint8         `bson:"forks" json:"forks"`
-	Verbosity     uint8         `bson:"verbosity" json:"verbosity"`
+	Verbosity     uint8         `bson:"verbosity" json:"verbosity" binding:"omitempty,max=5"`
 	BecomeEnabled bool          `bson:"become_enabled" json:"become_enabled"`
-	CredentialID  bson.ObjectId `bson:"credential_id" json:"credential"`
+	CredentialID  bson.ObjectId `bson:"credential_id" json:"credential" binding:"required"`
 	InventoryID   bson.ObjectId `bson:"inventory_id" json:"inventory"`
 	ExtraVars     gin.H         `bson:"extra_vars" json:"extra_vars"`
-	CreatedByID   bson.ObjectId `bson:"created_by_id" json:"created_by"`
-	ModifiedByID  bson.ObjectId `bson:"modified_by_id" json:"modified_by"`
-	Created       time.Time     `bson:"created" json:"created"`
-	Modified      time.Time     `bson:"modified" json:"modified"`
+	Priority      uint8         `bson:"priority" json:"priority" binding:"omitempty,max=10"`
+	// names of the extra variables whose values are redacted from the output of the command
+	SecretVars []string `bson:"secret_vars,omitempty" json:"secret_vars"`
+
+	LaunchType     string    `bson:"launch_type" json:"launch_type"`
+	CancelFlag     bool      `bson:"cancel_flag" json:"cancel_flag"`
+	Status         string    `bson:"status" json:"status"`
+	Failed         bool      `bson:"failed" json:"failed"`
+	Started        time.Time `bson:"started" json:"started"`
+	Finished       time.Time `bson:"finished" json:"finished"`
+	Elapsed        uint32    `bson:"elapsed" json:"elapsed"`
+	ResultStdout   string    `bson:"result_stdout,omitempty" json:"-"`
+	JobExplanation string    `bson:"job_explanation" json:"job_explanation"`
+
+	// worker running the command
+	WorkerID *bson.ObjectId `bson:"worker_id,omitempty" json:"worker" binding:"omitempty,naproperty"`
+	// organization of the inventory
+	OrganizationID bson.ObjectId `bson:"organization_id,omitempty" json:"organization" binding:"omitempty,naproperty"`
+	// maximum run time in seconds set by the quota of the organization
+	Timeout int `bson:"timeout,omitempty" json:"timeout" binding:"omitempty,naproperty"`
+
+	// system generated items
+	JobCWD  string   `bson:"job_cwd" json:"job_cwd"`
+	JobARGS []string `bson:"job_args" json:"job_args"`
+	JobENV  []string `bson:"job_env" json:"job_env"`
+
+	CreatedByID  bson.ObjectId `bson:"created_by_id" json:"created_by"`
+	ModifiedByID bson.ObjectId `bson:"modified_by_id" json:"modified_by"`
+	Created      time.Time     `bson:"created" json:"created"`
+	Modified     time.Time     `bson:"modified" json:"modified"`
 
 	Type  string `bson:"-" json:"type"`
 	Links gin.H  `bson:"-" json:"links"`
 	Meta  gin.H  `bson:"-" json:"meta"`
 }
 
+func (AdHocCommand) GetType() string {
+	return "ad_hoc_command"
+}
+
 type AdHocCommandEvent struct {
 	ID             bson.ObjectId `bson:"_id" json:"id"`
 	HostName       string        `bson:"host_name" json:"host_name" binding:"required"`
@@ -23,12 +23,16 @@ type Host struct {
 	LastJobID            *bson.ObjectId `bson:"last_job_id,omitempty" json:"last_job" binding:"omitempty,naproperty"`
 	LastJobHostSummaryID *bson.ObjectId `bson:"last_job_host_summary_id,omitempty" json:"last_job_host_summary" binding:"omitempty,naproperty"`
 
-	HasActiveFailures   bool          `bson:"has_active_failures,omitempty" json:"has_active_failures" binding:"omitempty,naproperty"`
-	HasInventorySources bool          `bson:"has_inventory_sources,omitempty" json:"has_inventory_sources" binding:"omitempty,naproperty"`
-	CreatedByID         bson.ObjectId `bson:"created_by_id" json:"-"`
-	ModifiedByID        bson.ObjectId `bson:"modified_by_id" json:"-"`
-	Created             time.Time     `bson:"created" json:"created" binding:"omitempty,naproperty"`
-	Modified            time.Time     `bson:"modified" json:"modified" binding:"omitempty,naproperty"`
+	HasActiveFailures   bool `bson:"has_active_failures,omitempty" json:"has_active_failures" binding:"omitempty,naproperty"`
+	HasInventorySources bool `bson:"has_inventory_sources,omitempty" json:"has_inventory_sources" binding:"omitempty,naproperty"`
+	// set for hosts imported by an inventory source, an imported host can be
+	// a member of more than one group, GroupIDs holds the additional groups
+	InventorySourceID *bson.ObjectId  `bson:"inventory_source_id,omitempty" json:"inventory_source" binding:"omitempty,naproperty"`
+	GroupIDs          []bson.ObjectId `bson:"group_ids,omitempty" json:"groups" binding:"omitempty,naproperty"`
+	CreatedByID       bson.ObjectId   `bson:"created_by_id" json:"-"`
+	ModifiedByID      bson.ObjectId   `bson:"modified_by_id" json:"-"`
+	Created           time.Time       `bson:"created" json:"created" binding:"omitempty,naproperty"`
+	Modified          time.Time       `bson:"modified" json:"modified" binding:"omitempty,naproperty"`
 
 	Type  string `bson:"-" json:"type"`
 	Links gin.H  `bson:"-" json:"links"`
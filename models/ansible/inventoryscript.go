@@ -4,15 +4,18 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/db"
 	"gopkg.in/mgo.v2/bson"
 )
 
-// InventoryScript is the model for organization collection
+// InventoryScript is the model for inventory_scripts collection,
+// a custom inventory script is owned by an organization
 type InventoryScript struct {
-	ID          bson.ObjectId `bson:"_id" json:"id"`
-	Name        string        `bson:"name" json:"name" binding:"required"`
-	Description string        `bson:"description" json:"description"`
-	Script      string        `bson:"script" json:"script" binding:"required"`
+	ID             bson.ObjectId `bson:"_id" json:"id"`
+	Name           string        `bson:"name" json:"name" binding:"required,min=1,max=500"`
+	Description    string        `bson:"description" json:"description"`
+	Script         string        `bson:"script" json:"script" binding:"required"`
+	OrganizationID bson.ObjectId `bson:"organization_id" json:"organization" binding:"required"`
 
 	CreatedByID  bson.ObjectId `bson:"created_by_id" json:"-"`
 	ModifiedByID bson.ObjectId `bson:"modified_by_id" json:"-"`
@@ -28,3 +31,19 @@ type InventoryScript struct {
 func (InventoryScript) GetType() string {
 	return "inventory_script"
 }
+
+func (script *InventoryScript) IsUnique() bool {
+	count, err := db.InventoryScripts().Find(bson.M{"name": script.Name, "organization_id": script.OrganizationID}).Count()
+	if err == nil && count > 0 {
+		return false
+	}
+	return true
+}
+
+func (script *InventoryScript) OrganizationExist() bool {
+	count, err := db.Organizations().FindId(script.OrganizationID).Count()
+	if err == nil && count > 0 {
+		return true
+	}
+	return false
+}
@@ -1,30 +1,62 @@
 package ansible
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/common"
 	"gopkg.in/mgo.v2/bson"
 )
 
-// Inventory is the model for
-// Inventory collection
-// TODO: not implemented
+// Inventory source constants
+const (
+	INVENTORY_SOURCE_EC2        = "ec2"
+	INVENTORY_SOURCE_GCE        = "gce"
+	INVENTORY_SOURCE_AZURE_RM   = "azure_rm"
+	INVENTORY_SOURCE_OPENSTACK  = "openstack"
+	INVENTORY_SOURCE_VMWARE     = "vmware"
+	INVENTORY_SOURCE_RAX        = "rax"
+	INVENTORY_SOURCE_SATELLITE6 = "satellite6"
+	INVENTORY_SOURCE_CLOUDFORMS = "cloudforms"
+	INVENTORY_SOURCE_CUSTOM     = "custom"
+)
+
+// InventorySource is the model for
+// inventory_sources collection
 type InventorySource struct {
 	ID bson.ObjectId `bson:"_id" json:"id"`
 
-	Source             string        `bson:"source" json:"source"`
-	SourcePath         string        `bson:"source_path" json:"source_path"`
-	SourceVars         string        `bson:"source_vars" json:"source_regions"`
-	SourceRegions      string        `bson:"source_regions" json:"has_active_failures"`
-	InstanceFilters    string        `bson:"instance_filters" json:"instance_filters"`
-	GroupBy            string        `bson:"group_by" json:"group_by"`
-	Overwrite          bool          `bson:"overwrite" json:"overwrite"`
-	OverwriteVars      bool          `bson:"overwrite_vars" json:"overwrite_vars"`
-	UpdateOnLaunch     bool          `bson:"update_on_launch" json:"update_on_launch"`
-	UpdateCacheTimeout uint32        `bson:"update_cache_timeout" json:"update_cache_timeout"`
-	CredentialID       bson.ObjectId `bson:"credential_id" json:"credential"`
-	GroupID            bson.ObjectId `bson:"group_id" json:"group"`
-	InventoryID        bson.ObjectId `bson:"inventory_id" json:"inventory"`
-	SourceScriptID     bson.ObjectId `bson:"source_script_id" json:"source_script"`
+	// required fields
+	Name        string        `bson:"name" json:"name" binding:"required,min=1,max=500"`
+	Source      string        `bson:"source" json:"source" binding:"required,inventory_source"`
+	InventoryID bson.ObjectId `bson:"inventory_id" json:"inventory" binding:"required"`
+
+	Description        string         `bson:"description,omitempty" json:"description"`
+	SourcePath         string         `bson:"source_path,omitempty" json:"source_path"`
+	SourceVars         string         `bson:"source_vars,omitempty" json:"source_vars"`
+	SourceRegions      string         `bson:"source_regions,omitempty" json:"source_regions"`
+	InstanceFilters    string         `bson:"instance_filters,omitempty" json:"instance_filters"`
+	GroupBy            string         `bson:"group_by,omitempty" json:"group_by"`
+	Overwrite          bool           `bson:"overwrite" json:"overwrite"`
+	OverwriteVars      bool           `bson:"overwrite_vars" json:"overwrite_vars"`
+	UpdateOnLaunch     bool           `bson:"update_on_launch" json:"update_on_launch"`
+	UpdateCacheTimeout uint32         `bson:"update_cache_timeout" json:"update_cache_timeout"`
+	CredentialID       *bson.ObjectId `bson:"credential_id,omitempty" json:"credential"`
+	GroupID            *bson.ObjectId `bson:"group_id,omitempty" json:"group"`
+	SourceScriptID     *bson.ObjectId `bson:"source_script_id,omitempty" json:"source_script"`
+
+	// only output
+	Status           string         `bson:"status,omitempty" json:"status" binding:"omitempty,naproperty"`
+	LastJobID        *bson.ObjectId `bson:"last_job_id,omitempty" json:"last_job" binding:"omitempty,naproperty"`
+	LastUpdated      *time.Time     `bson:"last_updated,omitempty" json:"last_updated" binding:"omitempty,naproperty"`
+	LastUpdateFailed bool           `bson:"last_update_failed" json:"last_update_failed" binding:"omitempty,naproperty"`
+
+	CreatedByID  bson.ObjectId `bson:"created_by_id" json:"-"`
+	ModifiedByID bson.ObjectId `bson:"modified_by_id" json:"-"`
+
+	Created  time.Time `bson:"created" json:"created" binding:"omitempty,naproperty"`
+	Modified time.Time `bson:"modified" json:"modified" binding:"omitempty,naproperty"`
 
 	Type  string `bson:"-" json:"type"`
 	Links gin.H  `bson:"-" json:"links"`
@@ -34,3 +66,53 @@ type InventorySource struct {
 func (InventorySource) GetType() string {
 	return "inventory_source"
 }
+
+func (source *InventorySource) IsUnique() bool {
+	count, err := db.InventorySources().Find(bson.M{"name": source.Name, "inventory_id": source.InventoryID}).Count()
+	if err == nil && count > 0 {
+		return false
+	}
+	return true
+}
+
+func (source *InventorySource) InventoryExist() bool {
+	count, err := db.Inventories().FindId(source.InventoryID).Count()
+	if err == nil && count > 0 {
+		return true
+	}
+	return false
+}
+
+func (source *InventorySource) GroupExist() bool {
+	count, err := db.Groups().Find(bson.M{"_id": source.GroupID, "inventory_id": source.InventoryID}).Count()
+	if err == nil && count == 1 {
+		return true
+	}
+	return false
+}
+
+// CredentialKind returns the kind of the cloud credential used by the
+// inventory script of the source, custom inventory scripts accept any
+// kind of cloud credential and an empty string is returned for them
+func (source InventorySource) CredentialKind() string {
+	switch source.Source {
+	case INVENTORY_SOURCE_EC2:
+		return common.CredentialKindAWS
+	case INVENTORY_SOURCE_AZURE_RM:
+		return common.CredentialKindAZURE
+	case INVENTORY_SOURCE_CUSTOM:
+		return ""
+	}
+	return source.Source
+}
+
+// IsStale reports whether the source has to be updated before a job
+// launched at now can use it. A source which was never updated or whose
+// last update failed is always stale.
+func (source InventorySource) IsStale(now time.Time) bool {
+	if source.LastUpdated == nil || source.LastUpdateFailed {
+		return true
+	}
+	timeout := time.Duration(source.UpdateCacheTimeout) * time.Second
+	return !now.Before(source.LastUpdated.Add(timeout))
+}
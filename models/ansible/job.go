@@ -10,11 +10,15 @@ import (
 
 // Job constants
 const (
-	JOBTYPE_ANSIBLE_JOB = "ansible_job" // A ansible job
-	JOBTYPE_UPDATE_JOB  = "update_job"  // A project scm update job
-
-	JOB_LAUNCH_TYPE_MANUAL = "manual"
-	JOB_LAUNCH_TYPE_SYSTEM = "system"
+	JOBTYPE_ANSIBLE_JOB      = "ansible_job"      // A ansible job
+	JOBTYPE_UPDATE_JOB       = "update_job"       // A project scm update job
+	JOBTYPE_INVENTORY_UPDATE = "inventory_update" // A inventory source update job
+
+	JOB_LAUNCH_TYPE_MANUAL    = "manual"
+	JOB_LAUNCH_TYPE_SYSTEM    = "system"
+	JOB_LAUNCH_TYPE_RELAUNCH  = "relaunch"
+	JOB_LAUNCH_TYPE_SCHEDULED = "scheduled"
+	JOB_LAUNCH_TYPE_WORKFLOW  = "workflow"
 )
 
 type Job struct {
@@ -30,7 +34,7 @@ type Job struct {
 	Started         time.Time `bson:"started" json:"started"`
 	Finished        time.Time `bson:"finished" json:"finished"`
 	Elapsed         uint32    `bson:"elapsed" json:"elapsed"`
-	ResultStdout    string    `bson:"result_stdout" json:"result_stdout"`
+	ResultStdout    string    `bson:"result_stdout,omitempty" json:"-"`
 	ResultTraceback string    `bson:"result_traceback" json:"result_traceback"`
 	JobExplanation  string    `bson:"job_explanation" json:"job_explanation"`
 	JobType         string    `bson:"job_type" json:"job_type"`
@@ -45,10 +49,24 @@ type Job struct {
 	ForceHandlers     bool   `bson:"force_handlers" json:"force_handlers"`
 	StartAtTask       string `bson:"start_at_task,omitempty" json:"start_at_task"`
 	AllowSimultaneous bool   `bson:"allow_simultaneous,omitempty" json:"allow_simultaneous"`
+	Priority          uint8  `bson:"priority" json:"priority"`
+	// maximum run time in seconds set by the quota of the organization
+	Timeout int `bson:"timeout,omitempty" json:"timeout" binding:"omitempty,naproperty"`
+	// names of the extra variables of the job whose values are redacted from the output
+	SecretVars []string `bson:"secret_vars,omitempty" json:"secret_vars"`
+	// vault credentials that decrypt the vaulted files and variables of the playbook
+	VaultCredentialIDs []bson.ObjectId `bson:"vault_credential_ids,omitempty" json:"vault_credentials"`
+	// connection plugin and platform of the network devices the playbook manages
+	NetworkConnection string `bson:"network_connection,omitempty" json:"network_connection"`
+	NetworkOS         string `bson:"network_os,omitempty" json:"network_os"`
 
 	InventoryID         bson.ObjectId  `bson:"inventory_id,omitempty" json:"inventory"`
 	JobTemplateID       bson.ObjectId  `bson:"job_template_id,omitempty" json:"job_template"`
 	ProjectID           bson.ObjectId  `bson:"project_id,omitempty" json:"project"`
+	InventorySourceID   *bson.ObjectId `bson:"inventory_source_id,omitempty" json:"inventory_source"`
+	WorkflowJobID       *bson.ObjectId `bson:"workflow_job_id,omitempty" json:"workflow_job"`
+	WorkerID            *bson.ObjectId `bson:"worker_id,omitempty" json:"worker" binding:"omitempty,naproperty"`
+	OrganizationID      bson.ObjectId  `bson:"organization_id,omitempty" json:"organization" binding:"omitempty,naproperty"`
 	BecomeEnabled       bool           `bson:"become_enabled" json:"become_enabled"`
 	SCMCredentialID     bson.ObjectId `bson:"scm_credential_id,omitempty" json:"scm_credential"`
 	NetworkCredentialID *bson.ObjectId `bson:"network_credential_id,omitempty" json:"network_credential"`
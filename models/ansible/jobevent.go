@@ -0,0 +1,84 @@
+package ansible
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Job event constants, emitted by the tensor callback plugin
+const (
+	EVENT_PLAYBOOK_ON_START         = "playbook_on_start"
+	EVENT_PLAYBOOK_ON_PLAY_START    = "playbook_on_play_start"
+	EVENT_PLAYBOOK_ON_TASK_START    = "playbook_on_task_start"
+	EVENT_PLAYBOOK_ON_HANDLER_START = "playbook_on_handler_task_start"
+	EVENT_PLAYBOOK_ON_STATS         = "playbook_on_stats"
+	EVENT_RUNNER_ON_OK              = "runner_on_ok"
+	EVENT_RUNNER_ON_FAILED          = "runner_on_failed"
+	EVENT_RUNNER_ON_UNREACHABLE     = "runner_on_unreachable"
+	EVENT_RUNNER_ON_SKIPPED         = "runner_on_skipped"
+)
+
+// JobEvent is a structured event of an ansible job, the callback plugin
+// posts one event for every playbook, play, task and host result
+type JobEvent struct {
+	ID         bson.ObjectId  `bson:"_id" json:"id"`
+	JobID      bson.ObjectId  `bson:"job_id" json:"job"`
+	Event      string         `bson:"event" json:"event" binding:"required"`
+	Counter    uint64         `bson:"counter" json:"counter"`
+	EventData  gin.H          `bson:"event_data" json:"event_data"`
+	Failed     bool           `bson:"failed" json:"failed"`
+	Changed    bool           `bson:"changed" json:"changed"`
+	UUID       string         `bson:"uuid" json:"uuid"`
+	ParentUUID string         `bson:"parent_uuid" json:"parent_uuid"`
+	HostName   string         `bson:"host_name" json:"host_name"`
+	HostID     *bson.ObjectId `bson:"host_id,omitempty" json:"host"`
+	Playbook   string         `bson:"playbook" json:"playbook"`
+	Play       string         `bson:"play" json:"play"`
+	PlayUUID   string         `bson:"play_uuid" json:"play_uuid"`
+	Task       string         `bson:"task" json:"task"`
+	TaskUUID   string         `bson:"task_uuid" json:"task_uuid"`
+	Role       string         `bson:"role" json:"role"`
+	Created    time.Time      `bson:"created" json:"created"`
+
+	Type  string `bson:"-" json:"type"`
+	Links gin.H  `bson:"-" json:"links"`
+	Meta  gin.H  `bson:"-" json:"meta"`
+}
+
+func (JobEvent) GetType() string {
+	return "job_event"
+}
+
+// JobPlay is a summary of a play of a job, built from job events
+type JobPlay struct {
+	ID       string    `bson:"_id" json:"uuid"`
+	Play     string    `bson:"play" json:"play"`
+	Counter  uint64    `bson:"counter" json:"counter"`
+	Failed   bool      `bson:"failed" json:"failed"`
+	Changed  bool      `bson:"changed" json:"changed"`
+	Started  time.Time `bson:"started" json:"started"`
+	Finished time.Time `bson:"finished" json:"finished"`
+}
+
+// JobTask is a summary of a task of a job with per host result counts,
+// built from job events
+type JobTask struct {
+	ID               string    `bson:"_id" json:"uuid"`
+	Task             string    `bson:"task" json:"task"`
+	Play             string    `bson:"play" json:"play"`
+	PlayUUID         string    `bson:"play_uuid" json:"play_uuid"`
+	Role             string    `bson:"role" json:"role"`
+	Counter          uint64    `bson:"counter" json:"counter"`
+	Failed           bool      `bson:"failed" json:"failed"`
+	Changed          bool      `bson:"changed" json:"changed"`
+	HostCount        int       `bson:"host_count" json:"host_count"`
+	SuccessfulCount  int       `bson:"successful_count" json:"successful_count"`
+	ChangedCount     int       `bson:"changed_count" json:"changed_count"`
+	FailedCount      int       `bson:"failed_count" json:"failed_count"`
+	UnreachableCount int       `bson:"unreachable_count" json:"unreachable_count"`
+	SkippedCount     int       `bson:"skipped_count" json:"skipped_count"`
+	Started          time.Time `bson:"started" json:"started"`
+	Finished         time.Time `bson:"finished" json:"finished"`
+}
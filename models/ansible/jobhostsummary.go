@@ -0,0 +1,33 @@
+package ansible
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// JobHostSummary is the recap of a host at the end of an ansible job
+type JobHostSummary struct {
+	ID          bson.ObjectId  `bson:"_id" json:"id"`
+	JobID       bson.ObjectId  `bson:"job_id" json:"job"`
+	HostID      *bson.ObjectId `bson:"host_id,omitempty" json:"host"`
+	HostName    string         `bson:"host_name" json:"host_name"`
+	Ok          int            `bson:"ok" json:"ok"`
+	Changed     int            `bson:"changed" json:"changed"`
+	Failures    int            `bson:"failures" json:"failures"`
+	Unreachable int            `bson:"unreachable" json:"unreachable"`
+	Skipped     int            `bson:"skipped" json:"skipped"`
+	Processed   int            `bson:"processed" json:"processed"`
+	Failed      bool           `bson:"failed" json:"failed"`
+	Created     time.Time      `bson:"created" json:"created"`
+	Modified    time.Time      `bson:"modified" json:"modified"`
+
+	Type  string `bson:"-" json:"type"`
+	Links gin.H  `bson:"-" json:"links"`
+	Meta  gin.H  `bson:"-" json:"meta"`
+}
+
+func (JobHostSummary) GetType() string {
+	return "job_host_summary"
+}
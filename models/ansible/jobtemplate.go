@@ -41,6 +41,14 @@ type JobTemplate struct {
 	PromptTags          bool           `bson:"prompt_tags,omitempty" json:"ask_tags_on_launch"`
 	PromptSkipTags      bool           `bson:"prompt_skip_tags,omitempty" json:"ask_skip_tags_on_launch"`
 	AllowSimultaneous   bool           `bson:"allow_simultaneous,omitempty" json:"allow_simultaneous"`
+	Priority            uint8          `bson:"priority,omitempty" json:"priority" binding:"omitempty,max=10"`
+	// names of the extra variables whose values are redacted from the output of jobs
+	SecretVars []string `bson:"secret_vars,omitempty" json:"secret_vars"`
+	// vault credentials that decrypt the vaulted files and variables of the playbook
+	VaultCredentialIDs []bson.ObjectId `bson:"vault_credential_ids,omitempty" json:"vault_credentials"`
+	// connection plugin and platform of the network devices the playbook manages
+	NetworkConnection string `bson:"network_connection,omitempty" json:"network_connection" binding:"omitempty,network_connection"`
+	NetworkOS         string `bson:"network_os,omitempty" json:"network_os" binding:"omitempty,network_os"`
 
 	PolymorphicCtypeID *bson.ObjectId `bson:"polymorphic_ctype_id,omitempty" json:"polymorphic_ctype"`
 
@@ -68,6 +76,11 @@ type JobTemplate struct {
 	Meta  gin.H  `bson:"-" json:"meta"`
 
 	Roles []common.AccessControl `bson:"roles" json:"-"`
+
+	// notification templates sent when a job succeeds, fails or in both cases
+	NotificationTemplatesSuccess []bson.ObjectId `bson:"notification_templates_success,omitempty" json:"-"`
+	NotificationTemplatesError   []bson.ObjectId `bson:"notification_templates_error,omitempty" json:"-"`
+	NotificationTemplatesAny     []bson.ObjectId `bson:"notification_templates_any,omitempty" json:"-"`
 }
 
 func (JobTemplate) GetType() string {
@@ -168,6 +181,27 @@ func (jt *JobTemplate) NetworkCredentialExist() bool {
 	return false
 }
 
+// VaultCredentialsExist reports whether all vault credentials of the
+// job template exist
+func (jt *JobTemplate) VaultCredentialsExist() bool {
+	return VaultCredentialsExist(jt.VaultCredentialIDs)
+}
+
+// VaultCredentialsExist reports whether all credentials with the given ids
+// exist and are vault credentials
+func VaultCredentialsExist(ids []bson.ObjectId) bool {
+	unique := map[bson.ObjectId]bool{}
+	for _, id := range ids {
+		unique[id] = true
+	}
+	query := bson.M{
+		"_id":  bson.M{"$in": ids},
+		"kind": common.CredentialKindVAULT,
+	}
+	count, err := db.Credentials().Find(query).Count()
+	return err == nil && count == len(unique)
+}
+
 func (jt *JobTemplate) CloudCredentialExist() bool {
 	query := bson.M{
 		"_id": jt.CloudCredentialID,
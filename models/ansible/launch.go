@@ -13,4 +13,22 @@ type Launch struct {
 	JobType             string        `bson:"job_type,omitempty" json:"job_type,omitempty" binding:"omitempty,jobtype"`
 	InventoryID         bson.ObjectId `bson:"inventory_id,omitempty" json:"inventory,omitempty"`
 	MachineCredentialID bson.ObjectId `bson:"credential_id,omitempty" json:"credential,omitempty"`
+	Priority            *uint8        `bson:"priority,omitempty" json:"priority,omitempty" binding:"omitempty,max=10"`
+	// vault credentials replace the ones of the job template
+	VaultCredentialIDs []bson.ObjectId `bson:"vault_credential_ids,omitempty" json:"vault_credentials,omitempty"`
+}
+
+// Relaunch host constants
+const (
+	RELAUNCH_HOSTS_ALL    = "all"    // relaunch on all hosts of the job
+	RELAUNCH_HOSTS_FAILED = "failed" // relaunch on failed and unreachable hosts
+)
+
+// Relaunch is the request body of a job relaunch. Inventory and credential
+// are only accepted when the ones of the original job no longer exist and
+// the job template prompts for them
+type Relaunch struct {
+	Hosts               string        `json:"hosts,omitempty"`
+	InventoryID         bson.ObjectId `json:"inventory,omitempty"`
+	MachineCredentialID bson.ObjectId `json:"credential,omitempty"`
 }
@@ -21,6 +21,7 @@ const (
 	CredentialKindGCE        = "gce"
 	CredentialKindAZURE      = "azure"
 	CredentialKindOPENSTACK  = "openstack"
+	CredentialKindVAULT      = "vault"
 )
 
 // Credential is the model for Credential collection
@@ -40,18 +41,21 @@ type Credential struct {
 	Project           string         `bson:"project,omitempty" json:"project"`
 	Email             string         `bson:"email,omitempty" json:"email" binding:"omitempty,email"`
 	Domain            string         `bson:"domain,omitempty" json:"domain"`
+	WinRMTransport    string         `bson:"winrm_transport,omitempty" json:"winrm_transport" binding:"omitempty,winrm_transport"`
 	SSHKeyData        string         `bson:"ssh_key_data,omitempty" json:"ssh_key_data"`
 	SSHKeyUnlock      string         `bson:"ssh_key_unlock,omitempty" json:"ssh_key_unlock"`
 	BecomeMethod      string         `bson:"become_method,omitempty" json:"become_method" binding:"omitempty,become_method"`
 	BecomeUsername    string         `bson:"become_username,omitempty" json:"become_username"`
 	BecomePassword    string         `bson:"become_password,omitempty" json:"become_password"`
 	VaultPassword     string         `bson:"vault_password,omitempty" json:"vault_password"`
+	VaultID           string         `bson:"vault_id,omitempty" json:"vault_id" binding:"omitempty,max=100,excludesall=@"`
 	Subscription      string         `bson:"subscription,omitempty" json:"subscription"`
 	Tenant            string         `bson:"tenant,omitempty" json:"tenant"`
 	Secret            string         `bson:"secret,omitempty" json:"secret"`
 	Client            string         `bson:"client,omitempty" json:"client"`
 	Authorize         bool           `bson:"authorize,omitempty" json:"authorize"`
 	AuthorizePassword string         `bson:"authorize_password,omitempty" json:"authorize_password"`
+	SSLVerifyDisabled bool           `bson:"ssl_verify_disabled,omitempty" json:"ssl_verify_disabled"`
 	OrganizationID    *bson.ObjectId `bson:"organization_id,omitempty" json:"organization"`
 
 	Created  time.Time `bson:"created" json:"created"`
@@ -0,0 +1,64 @@
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// JobStdout is a chunk of the standard output of a job. Chunks are written
+// while the job is running and are ordered by Counter, which starts at 1
+// for every job. The output of a chunk is gzip compressed in Data, chunks
+// written by older versions hold the plain output in Stdout. A chunk starts
+// in line StartLine of the job output and ends in line EndLine, lines are
+// counted from 0.
+type JobStdout struct {
+	ID        bson.ObjectId `bson:"_id" json:"-"`
+	JobID     bson.ObjectId `bson:"job_id" json:"job"`
+	Counter   uint64        `bson:"counter" json:"counter"`
+	Stdout    string        `bson:"stdout,omitempty" json:"stdout"`
+	Data      []byte        `bson:"data,omitempty" json:"-"`
+	StartLine int           `bson:"start_line" json:"start_line"`
+	EndLine   int           `bson:"end_line" json:"end_line"`
+	Created   time.Time     `bson:"created" json:"created"`
+}
+
+// Compressed reports whether the output of the chunk is gzip compressed
+func (chunk JobStdout) Compressed() bool {
+	return chunk.Data != nil
+}
+
+// Text returns the output of the chunk
+func (chunk JobStdout) Text() (string, error) {
+	if !chunk.Compressed() {
+		return chunk.Stdout, nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(chunk.Data))
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// CompressStdout gzip compresses the output of a chunk
+func CompressStdout(stdout []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	if _, err := w.Write(stdout); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
@@ -7,17 +7,28 @@ import (
 	"gopkg.in/mgo.v2/bson"
 )
 
+// Notification statuses
+const (
+	NotificationStatusSuccessful = "successful"
+	NotificationStatusFailed     = "failed"
+)
+
+// Notification is a delivery of a notification template
 type Notification struct {
 	ID bson.ObjectId `bson:"_id" json:"id"`
 
-	Status                 string `bson:"status" json:"status"`
-	Error                  string `bson:"error" json:"error"`
-	NotificationsSent      uint64 `bson:"notifications_sent" json:"notifications_sent"`
-	NotificationsType      string `bson:"notification_type" json:"notification_type"`
-	Recipients             string `bson:"recipients" json:"recipients"`
-	Subject                string `bson:"subject" json:"subject"`
-	Body                   string `bson:"body" json:"body"`
-	NotificationTemplateID string `bson:"notification_template_id" json:"notification_template_id"`
+	Status                 string        `bson:"status" json:"status"`
+	Error                  string        `bson:"error" json:"error"`
+	NotificationsSent      uint64        `bson:"notifications_sent" json:"notifications_sent"`
+	NotificationsType      string        `bson:"notification_type" json:"notification_type"`
+	Recipients             string        `bson:"recipients" json:"recipients"`
+	Subject                string        `bson:"subject" json:"subject"`
+	Body                   string        `bson:"body" json:"body"`
+	NotificationTemplateID bson.ObjectId `bson:"notification_template_id" json:"notification_template"`
+
+	// job that triggered the notification, empty for test notifications
+	JobID   *bson.ObjectId `bson:"job_id,omitempty" json:"job"`
+	JobType string         `bson:"job_type,omitempty" json:"job_type"`
 
 	CreatedByID  bson.ObjectId `bson:"created_by_id" json:"-"`
 	ModifiedByID bson.ObjectId `bson:"modified_by_id" json:"-"`
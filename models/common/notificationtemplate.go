@@ -4,17 +4,26 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/db"
 	"gopkg.in/mgo.v2/bson"
 )
 
+// Notification types
+const (
+	NotificationTypeEmail   = "email"
+	NotificationTypeWebhook = "webhook"
+	NotificationTypeSlack   = "slack"
+)
+
 type NotificationTemplate struct {
 	ID bson.ObjectId `bson:"_id" json:"id"`
 
-	Description               string `bson:"description" json:"description"`
-	Name                      string `bson:"name" json:"name"`
-	NotificationsType         string `bson:"notification_type" json:"notification_type"`
-	NotificationConfiguration string `bson:"notification_configuration" json:"notification_configuration"`
-	Subject                   string `bson:"subject" json:"subject"`
+	Description               string                    `bson:"description" json:"description"`
+	Name                      string                    `bson:"name" json:"name" binding:"required,min=1,max=500"`
+	OrganizationID            bson.ObjectId             `bson:"organization_id" json:"organization" binding:"required"`
+	NotificationsType         string                    `bson:"notification_type" json:"notification_type" binding:"required,notification_type"`
+	NotificationConfiguration NotificationConfiguration `bson:"notification_configuration" json:"notification_configuration"`
+	Subject                   string                    `bson:"subject" json:"subject"`
 
 	CreatedByID  bson.ObjectId `bson:"created_by_id" json:"-"`
 	ModifiedByID bson.ObjectId `bson:"modified_by_id" json:"-"`
@@ -29,6 +38,35 @@ type NotificationTemplate struct {
 	Roles []AccessControl `bson:"access" json:"-"`
 }
 
+// NotificationConfiguration holds the settings of the notification type,
+// email uses the SMTP settings, webhook and slack use the URL settings
+type NotificationConfiguration struct {
+	// email
+	Host       string   `bson:"host,omitempty" json:"host,omitempty"`
+	Port       int      `bson:"port,omitempty" json:"port,omitempty" binding:"omitempty,min=1,max=65535"`
+	Username   string   `bson:"username,omitempty" json:"username,omitempty"`
+	Password   string   `bson:"password,omitempty" json:"password,omitempty"`
+	Sender     string   `bson:"sender,omitempty" json:"sender,omitempty" binding:"omitempty,email"`
+	Recipients []string `bson:"recipients,omitempty" json:"recipients,omitempty" binding:"omitempty,dive,email"`
+	UseTLS     bool     `bson:"use_tls,omitempty" json:"use_tls,omitempty"`
+	UseSSL     bool     `bson:"use_ssl,omitempty" json:"use_ssl,omitempty"`
+
+	// webhook and slack
+	URL     string            `bson:"url,omitempty" json:"url,omitempty" binding:"omitempty,url"`
+	Headers map[string]string `bson:"headers,omitempty" json:"headers,omitempty"`
+	Channel string            `bson:"channel,omitempty" json:"channel,omitempty"`
+
+	// timeout in seconds of a delivery, defaults to 30
+	Timeout int `bson:"timeout,omitempty" json:"timeout,omitempty" binding:"omitempty,min=1,max=300"`
+}
+
+// NotificationTemplateAttachment is the payload used to attach a notification
+// template to, or detach it from, a job template or an organization
+type NotificationTemplateAttachment struct {
+	ID           bson.ObjectId `json:"id" binding:"required"`
+	Disassociate bool          `json:"disassociate"`
+}
+
 func (NotificationTemplate) GetType() string {
 	return "notification_template"
 }
@@ -36,3 +74,11 @@ func (NotificationTemplate) GetType() string {
 func (n NotificationTemplate) GetRoles() []AccessControl {
 	return n.Roles
 }
+
+func (n NotificationTemplate) OrganizationExist() bool {
+	count, err := db.Organizations().FindId(n.OrganizationID).Count()
+	if err == nil && count > 0 {
+		return true
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -25,6 +26,47 @@ type Organization struct {
 	Modified time.Time `bson:"modified" json:"modified"`
 
 	Roles []AccessControl `bson:"roles" json:"-"`
+
+	// notification templates sent when a job of the organization succeeds, fails or in both cases
+	NotificationTemplatesSuccess []bson.ObjectId `bson:"notification_templates_success,omitempty" json:"-"`
+	NotificationTemplatesError   []bson.ObjectId `bson:"notification_templates_error,omitempty" json:"-"`
+	NotificationTemplatesAny     []bson.ObjectId `bson:"notification_templates_any,omitempty" json:"-"`
+
+	// quotas of the organization, zero is unlimited. The job timeout is in seconds.
+	MaxHosts          int `bson:"max_hosts,omitempty" json:"max_hosts" binding:"omitempty,min=0"`
+	MaxConcurrentJobs int `bson:"max_concurrent_jobs,omitempty" json:"max_concurrent_jobs" binding:"omitempty,min=0"`
+	MaxJobTimeout     int `bson:"max_job_timeout,omitempty" json:"max_job_timeout" binding:"omitempty,min=0"`
+
+	// kerberos realms of the windows domains the hosts of the organization belong to
+	KerberosRealms []KerberosRealm `bson:"kerberos_realms,omitempty" json:"kerberos_realms" binding:"omitempty,dive"`
+
+	// output only
+	Usage *OrganizationUsage `bson:"-" json:"usage,omitempty" binding:"omitempty,naproperty"`
+}
+
+// KerberosRealm is a realm of the krb5.conf that jobs of the organization
+// use to obtain tickets for windows credentials
+type KerberosRealm struct {
+	Realm       string   `bson:"realm" json:"realm" binding:"required,krb5"`
+	KDCs        []string `bson:"kdcs" json:"kdcs" binding:"required,min=1,dive,krb5"`
+	AdminServer string   `bson:"admin_server,omitempty" json:"admin_server" binding:"omitempty,krb5"`
+	// DNS domains of the hosts in the realm, mapped to the realm in [domain_realm]
+	Domains []string `bson:"domains,omitempty" json:"domains" binding:"omitempty,dive,krb5"`
+}
+
+// OrganizationUsage is the current use of the quotas of an organization
+type OrganizationUsage struct {
+	Hosts      int `json:"hosts"`
+	ActiveJobs int `json:"active_jobs"`
+}
+
+// QuotaError is returned when an action would exceed a quota of an organization
+type QuotaError struct {
+	Message string
+}
+
+func (e QuotaError) Error() string {
+	return e.Message
 }
 
 func (Organization) GetType() string {
@@ -55,3 +97,97 @@ func (org Organization) Exist() bool {
 	}
 	return false
 }
+
+// HostCount returns the number of hosts in the inventories of the organization
+func (org Organization) HostCount() (int, error) {
+	var inventories []bson.ObjectId
+	if err := db.Inventories().Find(bson.M{"organization_id": org.ID}).Distinct("_id", &inventories); err != nil {
+		return 0, err
+	}
+	return db.Hosts().Find(bson.M{"inventory_id": bson.M{"$in": inventories}}).Count()
+}
+
+// ActiveJobCount returns the number of queued and running jobs, terraform jobs
+// and ad hoc commands of the organization. Project and inventory updates are
+// not counted, they are started by the jobs.
+func (org Organization) ActiveJobCount() (int, error) {
+	query := bson.M{"organization_id": org.ID, "status": bson.M{"$in": ActiveJobStatus}}
+
+	jobs, err := db.Jobs().Find(bson.M{
+		"organization_id": org.ID,
+		"status":          bson.M{"$in": ActiveJobStatus},
+		"job_type":        bson.M{"$nin": []string{"update_job", "inventory_update"}},
+	}).Count()
+	if err != nil {
+		return 0, err
+	}
+
+	terraformJobs, err := db.TerrafromJobs().Find(query).Count()
+	if err != nil {
+		return 0, err
+	}
+
+	commands, err := db.AdHocCommands().Find(query).Count()
+	if err != nil {
+		return 0, err
+	}
+
+	return jobs + terraformJobs + commands, nil
+}
+
+// GetUsage returns the current use of the quotas of the organization
+func (org Organization) GetUsage() (OrganizationUsage, error) {
+	hosts, err := org.HostCount()
+	if err != nil {
+		return OrganizationUsage{}, err
+	}
+
+	jobs, err := org.ActiveJobCount()
+	if err != nil {
+		return OrganizationUsage{}, err
+	}
+
+	return OrganizationUsage{Hosts: hosts, ActiveJobs: jobs}, nil
+}
+
+// CheckHosts returns a QuotaError if adding the number of hosts to the
+// inventories of the organization exceeds its host quota
+func (org Organization) CheckHosts(add int) error {
+	if org.MaxHosts <= 0 || add <= 0 {
+		return nil
+	}
+
+	count, err := org.HostCount()
+	if err != nil {
+		return err
+	}
+
+	if count+add > org.MaxHosts {
+		return QuotaError{Message: "Host quota exceeded, organization " + org.Name + " has " +
+			strconv.Itoa(count) + " of " + strconv.Itoa(org.MaxHosts) + " hosts and can not add " +
+			strconv.Itoa(add) + " more"}
+	}
+	return nil
+}
+
+// CheckJobs returns a QuotaError if the organization already runs
+// the maximum number of concurrent jobs. The check is best-effort, jobs
+// launched at the same time are counted before any of them is stored
+// and may exceed the quota together.
+func (org Organization) CheckJobs() error {
+	if org.MaxConcurrentJobs <= 0 {
+		return nil
+	}
+
+	count, err := org.ActiveJobCount()
+	if err != nil {
+		return err
+	}
+
+	if count >= org.MaxConcurrentJobs {
+		return QuotaError{Message: "Concurrent job quota exceeded, organization " + org.Name + " runs " +
+			strconv.Itoa(count) + " of " + strconv.Itoa(org.MaxConcurrentJobs) +
+			" jobs, launch again when a job has finished"}
+	}
+	return nil
+}
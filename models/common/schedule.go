@@ -0,0 +1,51 @@
+package common
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Schedule resource types, the kind of object a schedule launches
+const (
+	ScheduleProject              = "project"
+	ScheduleJobTemplate          = "job_template"
+	ScheduleTerraformJobTemplate = "terraform_job_template"
+)
+
+// Schedule launches a job template, a terraform job template or a project
+// update at the occurrences of an iCal recurrence rule or a cron expression.
+// Occurrences are computed in TimeZone starting at Start, until End when set.
+type Schedule struct {
+	ID          bson.ObjectId `bson:"_id" json:"id"`
+	Name        string        `bson:"name" json:"name" binding:"required,min=1,max=500"`
+	Description string        `bson:"description" json:"description"`
+	Enabled     bool          `bson:"enabled" json:"enabled"`
+	RRule       string        `bson:"rrule,omitempty" json:"rrule"`
+	Cron        string        `bson:"cron,omitempty" json:"cron"`
+	TimeZone    string        `bson:"timezone" json:"timezone"`
+	Start       time.Time     `bson:"dtstart" json:"dtstart" binding:"required"`
+	End         *time.Time    `bson:"dtend,omitempty" json:"dtend"`
+	ExtraVars   gin.H         `bson:"extra_vars,omitempty" json:"extra_vars"`
+
+	ResourceType string        `bson:"resource_type" json:"resource_type"`
+	ResourceID   bson.ObjectId `bson:"resource_id" json:"resource"`
+
+	NextRun   *time.Time     `bson:"next_run,omitempty" json:"next_run"`
+	LastRun   *time.Time     `bson:"last_run,omitempty" json:"last_run"`
+	LastJobID *bson.ObjectId `bson:"last_job_id,omitempty" json:"last_job"`
+
+	CreatedByID  bson.ObjectId `bson:"created_by_id" json:"-"`
+	ModifiedByID bson.ObjectId `bson:"modified_by_id" json:"-"`
+	Created      time.Time     `bson:"created" json:"created"`
+	Modified     time.Time     `bson:"modified" json:"modified"`
+
+	Type  string `bson:"-" json:"type"`
+	Links gin.H  `bson:"-" json:"links"`
+	Meta  gin.H  `bson:"-" json:"meta"`
+}
+
+func (Schedule) GetType() string {
+	return "schedule"
+}
@@ -0,0 +1,4 @@
+package common
+
+// ActiveJobStatus lists the statuses of jobs that are queued or running
+var ActiveJobStatus = []string{"new", "pending", "waiting", "running"}
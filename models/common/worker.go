@@ -0,0 +1,41 @@
+package common
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Worker is a tensord process that runs jobs. It is registered when the
+// process starts and refreshes its heartbeat while it is running.
+type Worker struct {
+	ID bson.ObjectId `bson:"_id" json:"id"`
+
+	Hostname string        `bson:"hostname" json:"hostname"`
+	PID      int           `bson:"pid" json:"pid"`
+	Version  string        `bson:"version" json:"version"`
+	Queues   []WorkerQueue `bson:"queues" json:"queues"`
+
+	// number of jobs the worker runs at the same time and the running jobs
+	Capacity int `bson:"capacity" json:"capacity"`
+	Busy     int `bson:"busy" json:"busy"`
+
+	Started   time.Time `bson:"started" json:"started"`
+	Heartbeat time.Time `bson:"heartbeat" json:"heartbeat"`
+
+	Type  string `bson:"-" json:"type"`
+	Links gin.H  `bson:"-" json:"links"`
+	Meta  gin.H  `bson:"-" json:"meta"`
+}
+
+// WorkerQueue is the worker pool of a queue
+type WorkerQueue struct {
+	Queue   string `bson:"queue" json:"queue"`
+	Workers int    `bson:"workers" json:"workers"`
+	Busy    int    `bson:"busy" json:"busy"`
+}
+
+func (Worker) GetType() string {
+	return "worker"
+}
@@ -11,9 +11,12 @@ import (
 
 // Job constants
 const (
-	JobTypeTerraformJob = "terraform_job" // A terraform job
-	JobLaunchTypeManual = "manual"
-	JobLaunchTypeSystem = "system"
+	JobTypeTerraformJob    = "terraform_job" // A terraform job
+	JobLaunchTypeManual    = "manual"
+	JobLaunchTypeSystem    = "system"
+	JobLaunchTypeRelaunch  = "relaunch"
+	JobLaunchTypeScheduled = "scheduled"
+	JobLaunchTypeWorkflow  = "workflow"
 )
 
 type Job struct {
@@ -29,7 +32,7 @@ type Job struct {
 	Started         time.Time `bson:"started" json:"started"`
 	Finished        time.Time `bson:"finished" json:"finished"`
 	Elapsed         uint32    `bson:"elapsed" json:"elapsed"`
-	ResultStdout    string    `bson:"result_stdout" json:"result_stdout"`
+	ResultStdout    string    `bson:"result_stdout,omitempty" json:"-"`
 	ResultGetStdout string    `bson:"result_get_stdout" json:"result_get_stdout"`
 	ResultTraceback string    `bson:"result_traceback" json:"result_traceback"`
 	JobExplanation  string    `bson:"job_explanation" json:"job_explanation"`
@@ -39,6 +42,13 @@ type Job struct {
 	UpdateOnLaunch  bool      `bson:"update_on_launch" json:"update_on_launch"`
 	Target          string    `bson:"target" json:"target"`
 	Directory       string    `bson:"directory" json:"directory"`
+	Priority        uint8     `bson:"priority" json:"priority"`
+	// maximum run time in seconds set by the quota of the organization
+	Timeout int `bson:"timeout,omitempty" json:"timeout" binding:"omitempty,naproperty"`
+	// names of the variables of the job whose values are redacted from the output
+	SecretVars []string `bson:"secret_vars,omitempty" json:"secret_vars"`
+	// Artifacts are the outputs of an applied configuration
+	Artifacts gin.H `bson:"artifacts,omitempty" json:"artifacts"`
 
 	MachineCredentialID *bson.ObjectId `bson:"credential_id,omitempty" json:"credential"`
 	JobTemplateID       bson.ObjectId  `bson:"job_template_id,omitempty" json:"job_template"`
@@ -46,6 +56,9 @@ type Job struct {
 	SCMCredentialID     *bson.ObjectId `bson:"scm_credential_id,omitempty" json:"scm_credential"`
 	NetworkCredentialID *bson.ObjectId `bson:"network_credential_id,omitempty" json:"network_credential"`
 	CloudCredentialID   *bson.ObjectId `bson:"cloud_credential_id,omitempty" json:"cloud_credential"`
+	WorkflowJobID       *bson.ObjectId `bson:"workflow_job_id,omitempty" json:"workflow_job"`
+	WorkerID            *bson.ObjectId `bson:"worker_id,omitempty" json:"worker" binding:"omitempty,naproperty"`
+	OrganizationID      bson.ObjectId  `bson:"organization_id,omitempty" json:"organization" binding:"omitempty,naproperty"`
 
 	PromptCredential  bool `bson:"prompt_credential" json:"ask_credential_on_launch"`
 	PromptJobType     bool `bson:"prompt_job_type" json:"ask_job_type_on_launch"`
@@ -27,10 +27,13 @@ type JobTemplate struct {
 	PromptCredential    bool           `bson:"prompt_credential,omitempty" json:"ask_credential_on_launch"`
 	PromptJobType       bool           `bson:"prompt_job_type,omitempty" json:"ask_job_type_on_launch"`
 	AllowSimultaneous   bool           `bson:"allow_simultaneous,omitempty" json:"allow_simultaneous"`
+	Priority            uint8          `bson:"priority,omitempty" json:"priority" binding:"omitempty,max=10"`
 	Parallelism         uint8          `bson:"parallelism,omitempty" json:"parallelism"`
 	UpdateOnLaunch      bool           `bson:"update_on_launch" json:"update_on_launch"`
 	Target              string         `bson:"target" json:"target"`
 	Directory           string         `bson:"directory" json:"directory"`
+	// names of the variables whose values are redacted from the output of jobs
+	SecretVars []string `bson:"secret_vars,omitempty" json:"secret_vars"`
 	// output only
 	LastJobRun      *time.Time     `bson:"last_job_run,omitempty" json:"last_job_run" binding:"omitempty,naproperty"`
 	NextJobRun      *time.Time     `bson:"next_job_run,omitempty" json:"next_job_run" binding:"omitempty,naproperty"`
@@ -55,6 +58,11 @@ type JobTemplate struct {
 	Meta  gin.H  `bson:"-" json:"meta"`
 
 	Roles []common.AccessControl `bson:"roles" json:"-"`
+
+	// notification templates sent when a job succeeds, fails or in both cases
+	NotificationTemplatesSuccess []bson.ObjectId `bson:"notification_templates_success,omitempty" json:"-"`
+	NotificationTemplatesError   []bson.ObjectId `bson:"notification_templates_error,omitempty" json:"-"`
+	NotificationTemplatesAny     []bson.ObjectId `bson:"notification_templates_any,omitempty" json:"-"`
 }
 
 func (JobTemplate) GetType() string {
@@ -9,4 +9,12 @@ type Launch struct {
 	Vars                gin.H          `bson:"vars,omitempty" json:"vars,omitempty"`
 	JobType             string         `bson:"job_type,omitempty" json:"job_type,omitempty" binding:"omitempty,terraform_jobtype"`
 	MachineCredentialID *bson.ObjectId `bson:"credential_id,omitempty" json:"credential,omitempty"`
+	Priority            *uint8         `bson:"priority,omitempty" json:"priority,omitempty" binding:"omitempty,max=10"`
+}
+
+// Relaunch is the request body of a job relaunch. The credential is only
+// accepted when the one of the original job no longer exists and the job
+// template prompts for it
+type Relaunch struct {
+	MachineCredentialID *bson.ObjectId `json:"credential,omitempty"`
 }
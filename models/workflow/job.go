@@ -0,0 +1,72 @@
+package workflow
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Workflow job constants
+const (
+	JobLaunchTypeManual = "manual"
+
+	// NodeSkipped is the status of a node that was not run
+	// because none of the edges of its parents fired
+	NodeSkipped = "skipped"
+)
+
+// JobNode is a node of a workflow job, it tracks the
+// job launched for the node and its artifacts
+type JobNode struct {
+	Node `bson:",inline"`
+
+	JobID  *bson.ObjectId `bson:"job_id,omitempty" json:"job"`
+	Status string         `bson:"status,omitempty" json:"status"`
+	// Artifacts are the artifacts inherited from the parents merged with
+	// the outputs of the job, they are passed as extra variables to the
+	// children of the node
+	Artifacts gin.H `bson:"artifacts,omitempty" json:"artifacts"`
+}
+
+// Job is the model for
+// workflow_jobs collection
+type Job struct {
+	ID bson.ObjectId `bson:"_id" json:"id"`
+
+	Name string `bson:"name" json:"name"`
+
+	Description           string        `bson:"description,omitempty" json:"description"`
+	WorkflowJobTemplateID bson.ObjectId `bson:"workflow_job_template_id" json:"workflow_job_template"`
+	OrganizationID        bson.ObjectId `bson:"organization_id" json:"organization"`
+	LaunchType            string        `bson:"launch_type" json:"launch_type"`
+	CancelFlag            bool          `bson:"cancel_flag" json:"cancel_flag"`
+	Status                string        `bson:"status" json:"status"`
+	Failed                bool          `bson:"failed" json:"failed"`
+	Started               time.Time     `bson:"started" json:"started"`
+	Finished              time.Time     `bson:"finished" json:"finished"`
+	Elapsed               uint32        `bson:"elapsed" json:"elapsed"`
+	JobExplanation        string        `bson:"job_explanation" json:"job_explanation"`
+	ExtraVars             gin.H         `bson:"extra_vars,omitempty" json:"extra_vars"`
+	AllowSimultaneous     bool          `bson:"allow_simultaneous,omitempty" json:"allow_simultaneous"`
+	Nodes                 []JobNode     `bson:"nodes" json:"nodes"`
+
+	CreatedByID  bson.ObjectId `bson:"created_by_id" json:"-"`
+	ModifiedByID bson.ObjectId `bson:"modified_by_id" json:"-"`
+
+	Created  time.Time `bson:"created" json:"created"`
+	Modified time.Time `bson:"modified" json:"modified"`
+
+	Type  string `bson:"-" json:"type"`
+	Links gin.H  `bson:"-" json:"links"`
+	Meta  gin.H  `bson:"-" json:"meta"`
+}
+
+func (Job) GetType() string {
+	return "workflow_job"
+}
+
+// Launch is the request body of a workflow job template launch
+type Launch struct {
+	ExtraVars gin.H `json:"extra_vars,omitempty"`
+}
@@ -0,0 +1,153 @@
+package workflow
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pearsonappeng/tensor/db"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Node resource types, the kind of template a workflow node launches
+const (
+	NodeJobTemplate          = "job_template"
+	NodeTerraformJobTemplate = "terraform_job_template"
+	NodeProject              = "project"
+)
+
+// Node is a vertex of the workflow graph. It launches a job template, a
+// terraform job template or a project update and starts its success, failure
+// and always nodes depending on the outcome of the job.
+type Node struct {
+	ID           string        `bson:"id" json:"id" binding:"required,min=1,max=100"`
+	ResourceType string        `bson:"resource_type" json:"resource_type" binding:"required"`
+	ResourceID   bson.ObjectId `bson:"resource_id" json:"resource" binding:"required"`
+	ExtraVars    gin.H         `bson:"extra_vars,omitempty" json:"extra_vars"`
+	SuccessNodes []string      `bson:"success_nodes,omitempty" json:"success_nodes"`
+	FailureNodes []string      `bson:"failure_nodes,omitempty" json:"failure_nodes"`
+	AlwaysNodes  []string      `bson:"always_nodes,omitempty" json:"always_nodes"`
+}
+
+// JobTemplate is the model for
+// workflow_job_templates collection
+type JobTemplate struct {
+	ID bson.ObjectId `bson:"_id" json:"id"`
+
+	// required fields
+	Name           string        `bson:"name" json:"name" binding:"required,min=1,max=500"`
+	OrganizationID bson.ObjectId `bson:"organization_id" json:"organization" binding:"required"`
+
+	Description       string `bson:"description,omitempty" json:"description"`
+	ExtraVars         gin.H  `bson:"extra_vars,omitempty" json:"extra_vars"`
+	PromptVariables   bool   `bson:"ask_variables_on_launch,omitempty" json:"ask_variables_on_launch"`
+	AllowSimultaneous bool   `bson:"allow_simultaneous,omitempty" json:"allow_simultaneous"`
+	Nodes             []Node `bson:"nodes" json:"nodes" binding:"omitempty,dive"`
+
+	// output only
+	Status        string         `bson:"status,omitempty" json:"status" binding:"omitempty,naproperty"`
+	LastJobID     *bson.ObjectId `bson:"last_job_id,omitempty" json:"last_job" binding:"omitempty,naproperty"`
+	LastJobRun    *time.Time     `bson:"last_job_run,omitempty" json:"last_job_run" binding:"omitempty,naproperty"`
+	LastJobFailed bool           `bson:"last_job_failed,omitempty" json:"last_job_failed" binding:"omitempty,naproperty"`
+
+	CreatedByID  bson.ObjectId `bson:"created_by_id" json:"-"`
+	ModifiedByID bson.ObjectId `bson:"modified_by_id" json:"-"`
+
+	Created  time.Time `bson:"created" json:"created" binding:"omitempty,naproperty"`
+	Modified time.Time `bson:"modified" json:"modified" binding:"omitempty,naproperty"`
+
+	Type  string `bson:"-" json:"type"`
+	Links gin.H  `bson:"-" json:"links"`
+	Meta  gin.H  `bson:"-" json:"meta"`
+}
+
+func (JobTemplate) GetType() string {
+	return "workflow_job_template"
+}
+
+func (jt JobTemplate) IsUnique() bool {
+	count, err := db.WorkflowJobTemplates().Find(bson.M{"name": jt.Name, "organization_id": jt.OrganizationID}).Count()
+	if err == nil && count > 0 {
+		return false
+	}
+	return true
+}
+
+func (jt JobTemplate) OrganizationExist() bool {
+	count, err := db.Organizations().FindId(jt.OrganizationID).Count()
+	if err == nil && count > 0 {
+		return true
+	}
+	return false
+}
+
+// ValidateNodes checks that the nodes form a directed acyclic graph,
+// node ids are unique and every edge points to a node of the workflow
+func ValidateNodes(nodes []Node) error {
+	index := map[string]int{}
+	for i, n := range nodes {
+		switch n.ResourceType {
+		case NodeJobTemplate, NodeTerraformJobTemplate, NodeProject:
+		default:
+			return errors.New("Node " + n.ID + " has an unknown resource type " + n.ResourceType)
+		}
+		if _, ok := index[n.ID]; ok {
+			return errors.New("Node id " + n.ID + " is not unique")
+		}
+		index[n.ID] = i
+	}
+
+	for _, n := range nodes {
+		for _, child := range n.Children() {
+			if _, ok := index[child]; !ok {
+				return errors.New("Node " + n.ID + " refers to unknown node " + child)
+			}
+			if child == n.ID {
+				return errors.New("Node " + n.ID + " refers to itself")
+			}
+		}
+	}
+
+	// depth first search, a node reached again while it is
+	// still on the stack closes a cycle
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(nodes))
+	var visit func(i int) error
+	visit = func(i int) error {
+		state[i] = visiting
+		for _, child := range nodes[i].Children() {
+			j := index[child]
+			switch state[j] {
+			case visiting:
+				return errors.New("Node " + child + " is part of a cycle")
+			case unvisited:
+				if err := visit(j); err != nil {
+					return err
+				}
+			}
+		}
+		state[i] = visited
+		return nil
+	}
+
+	for i := range nodes {
+		if state[i] == unvisited {
+			if err := visit(i); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Children returns the ids of the success, failure and always nodes
+func (n Node) Children() []string {
+	children := append([]string{}, n.SuccessNodes...)
+	children = append(children, n.FailureNodes...)
+	return append(children, n.AlwaysNodes...)
+}
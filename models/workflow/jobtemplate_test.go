@@ -0,0 +1,28 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestValidateNodes(t *testing.T) {
+	assert := assert.New(t)
+	id := bson.NewObjectId()
+	node := func(nid string, success ...string) Node {
+		return Node{ID: nid, ResourceType: NodeJobTemplate, ResourceID: id, SuccessNodes: success}
+	}
+
+	assert.NoError(ValidateNodes(nil))
+	assert.NoError(ValidateNodes([]Node{node("a", "b", "c"), node("b", "c"), node("c")}))
+
+	assert.Error(ValidateNodes([]Node{node("a"), node("a")}), "duplicate id")
+	assert.Error(ValidateNodes([]Node{node("a", "missing")}), "unknown node")
+	assert.Error(ValidateNodes([]Node{node("a", "a")}), "self reference")
+	assert.Error(ValidateNodes([]Node{node("a", "b"), node("b", "c"), node("c", "a")}), "cycle")
+
+	bad := node("a")
+	bad.ResourceType = "inventory"
+	assert.Error(ValidateNodes([]Node{bad}), "resource type")
+}
@@ -0,0 +1,180 @@
+package notification
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/util"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Job types of notifications
+const (
+	JobTypeAnsible   = "job"
+	JobTypeTerraform = "terraform_job"
+)
+
+// Job describes a finished job
+type Job struct {
+	ID             bson.ObjectId
+	Type           string
+	Name           string
+	Status         string
+	Failed         bool
+	Started        time.Time
+	Finished       time.Time
+	TemplateID     bson.ObjectId
+	OrganizationID bson.ObjectId
+}
+
+// attachments are the notification templates attached
+// to a job template or an organization
+type attachments struct {
+	Success []bson.ObjectId `bson:"notification_templates_success"`
+	Error   []bson.ObjectId `bson:"notification_templates_error"`
+	Any     []bson.ObjectId `bson:"notification_templates_any"`
+}
+
+// JobFinished sends the notification templates attached to the job template
+// and the organization of a finished job. Success templates are sent when the
+// job succeeded, error templates when it failed and any templates in both cases.
+func JobFinished(j Job) {
+	templates := db.JobTemplates()
+	if j.Type == JobTypeTerraform {
+		templates = db.TerrafromJobTemplates()
+	}
+
+	var ids []bson.ObjectId
+	seen := map[bson.ObjectId]bool{}
+	collect := func(c *mgo.Collection, id bson.ObjectId) {
+		var a attachments
+		if err := c.FindId(id).Select(bson.M{
+			"notification_templates_success": 1,
+			"notification_templates_error":   1,
+			"notification_templates_any":     1,
+		}).One(&a); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"ID":    id.Hex(),
+				"Error": err.Error(),
+			}).Errorln("Error while getting notification templates")
+			return
+		}
+
+		attached := a.Any
+		if j.Failed {
+			attached = append(attached, a.Error...)
+		} else {
+			attached = append(attached, a.Success...)
+		}
+		for _, nt := range attached {
+			if !seen[nt] {
+				seen[nt] = true
+				ids = append(ids, nt)
+			}
+		}
+	}
+
+	collect(templates, j.TemplateID)
+	if len(j.OrganizationID) > 0 {
+		collect(db.Organizations(), j.OrganizationID)
+	}
+
+	if len(ids) == 0 {
+		return
+	}
+
+	var nts []common.NotificationTemplate
+	if err := db.NotificationTemplates().Find(bson.M{"_id": bson.M{"$in": ids}}).All(&nts); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Job ID": j.ID.Hex(),
+			"Error":  err.Error(),
+		}).Errorln("Error while getting notification templates")
+		return
+	}
+
+	m := jobMessage(j)
+	for _, nt := range nts {
+		msg := m
+		if len(nt.Subject) > 0 {
+			msg.Subject = nt.Subject
+		}
+		Deliver(nt, msg, &j.ID, j.Type)
+	}
+}
+
+// Deliver sends the message with the notification template and records the
+// delivery in the notifications collection. jobID is nil for notifications
+// that are not related to a job.
+func Deliver(t common.NotificationTemplate, m Message, jobID *bson.ObjectId, jobType string) common.Notification {
+	if len(t.NotificationConfiguration.Password) > 0 {
+		t.NotificationConfiguration.Password = string(util.Decipher(t.NotificationConfiguration.Password))
+	}
+
+	n := common.Notification{
+		ID:                     bson.NewObjectId(),
+		Status:                 common.NotificationStatusSuccessful,
+		NotificationsType:      t.NotificationsType,
+		Recipients:             Recipients(t),
+		Subject:                m.Subject,
+		Body:                   m.Body,
+		NotificationTemplateID: t.ID,
+		JobID:                  jobID,
+		JobType:                jobType,
+		CreatedByID:            t.CreatedByID,
+		ModifiedByID:           t.ModifiedByID,
+		Created:                time.Now(),
+		Modified:               time.Now(),
+	}
+
+	sent, err := Send(t, m)
+	n.NotificationsSent = uint64(sent)
+	if err != nil {
+		n.Status = common.NotificationStatusFailed
+		n.Error = err.Error()
+		logrus.WithFields(logrus.Fields{
+			"Notification Template ID": t.ID.Hex(),
+			"Error":                    err.Error(),
+		}).Errorln("Error while sending notification")
+	}
+
+	if err := db.Notifications().Insert(n); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Notification Template ID": t.ID.Hex(),
+			"Error":                    err.Error(),
+		}).Errorln("Error while saving notification")
+	}
+
+	return n
+}
+
+// jobMessage describes the result of a job
+func jobMessage(j Job) Message {
+	url := util.Config.GetUrl() + "/v1/jobs/" + j.ID.Hex()
+	if j.Type == JobTypeTerraform {
+		url = util.Config.GetUrl() + "/v1/terraform_jobs/" + j.ID.Hex()
+	}
+
+	elapsed := j.Finished.Sub(j.Started)
+
+	return Message{
+		Subject: fmt.Sprintf("Job #%s '%s' %s", j.ID.Hex(), j.Name, j.Status),
+		Body: fmt.Sprintf("Job: %s\nStatus: %s\nStarted: %s\nFinished: %s\nElapsed: %s\nDetails: %s\n",
+			j.Name, j.Status, j.Started.Format(time.RFC3339), j.Finished.Format(time.RFC3339),
+			elapsed-elapsed%time.Second, url),
+		Data: map[string]interface{}{
+			"id":       j.ID.Hex(),
+			"type":     j.Type,
+			"name":     j.Name,
+			"status":   j.Status,
+			"failed":   j.Failed,
+			"started":  j.Started,
+			"finished": j.Finished,
+			"elapsed":  elapsed.Seconds(),
+			"url":      url,
+		},
+	}
+}
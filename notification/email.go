@@ -0,0 +1,98 @@
+package notification
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pearsonappeng/tensor/models/common"
+)
+
+// sendEmail sends the message to every recipient through the SMTP server
+// of the configuration. use_ssl connects over TLS, use_tls upgrades the
+// connection with STARTTLS.
+func sendEmail(config common.NotificationConfiguration, m Message) (int, error) {
+	if len(config.Recipients) == 0 {
+		return 0, errors.New("email notification has no recipients")
+	}
+
+	port := config.Port
+	if port == 0 {
+		port = 25
+		if config.UseSSL {
+			port = 465
+		}
+	}
+
+	d := timeout(config)
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(config.Host, strconv.Itoa(port)), d)
+	if err != nil {
+		return 0, err
+	}
+	conn.SetDeadline(time.Now().Add(d))
+
+	tlsConfig := &tls.Config{ServerName: config.Host}
+	if config.UseSSL {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	c, err := smtp.NewClient(conn, config.Host)
+	if err != nil {
+		conn.Close()
+		return 0, err
+	}
+	defer c.Close()
+
+	if config.UseTLS {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(config.Username) > 0 {
+		if err := c.Auth(smtp.PlainAuth("", config.Username, config.Password, config.Host)); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := c.Mail(config.Sender); err != nil {
+		return 0, err
+	}
+	for _, recipient := range config.Recipients {
+		if err := c.Rcpt(recipient); err != nil {
+			return 0, err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := w.Write(emailBody(config, m)); err != nil {
+		return 0, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+
+	return len(config.Recipients), c.Quit()
+}
+
+// emailBody formats the message as a plain text email
+func emailBody(config common.NotificationConfiguration, m Message) []byte {
+	var b bytes.Buffer
+	b.WriteString("From: " + config.Sender + "\r\n")
+	b.WriteString("To: " + strings.Join(config.Recipients, ", ") + "\r\n")
+	b.WriteString("Subject: " + strings.Replace(m.Subject, "\n", " ", -1) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(m.Body)
+	return b.Bytes()
+}
@@ -0,0 +1,69 @@
+// Package notification delivers notification templates over SMTP email,
+// generic HTTP webhooks and Slack compatible incoming webhooks
+package notification
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pearsonappeng/tensor/models/common"
+)
+
+// defaultTimeout is the timeout of a delivery when the
+// notification template does not set one
+const defaultTimeout = 30 * time.Second
+
+// Message is the content of a notification
+type Message struct {
+	Subject string                 `json:"subject"`
+	Body    string                 `json:"body"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+}
+
+// Send delivers the message using the configuration of the notification
+// template and returns the number of notifications that were sent.
+// The password of the configuration must be deciphered by the caller.
+func Send(t common.NotificationTemplate, m Message) (int, error) {
+	config := t.NotificationConfiguration
+
+	switch t.NotificationsType {
+	case common.NotificationTypeEmail:
+		return sendEmail(config, m)
+	case common.NotificationTypeWebhook:
+		return sendWebhook(config, m)
+	case common.NotificationTypeSlack:
+		return sendSlack(config, m)
+	}
+
+	return 0, errors.New("unsupported notification type " + t.NotificationsType)
+}
+
+// Recipients returns a description of the recipients of the notification template.
+// Only the host of webhook urls is returned since their path often holds a token.
+func Recipients(t common.NotificationTemplate) string {
+	config := t.NotificationConfiguration
+
+	switch t.NotificationsType {
+	case common.NotificationTypeEmail:
+		return strings.Join(config.Recipients, ",")
+	case common.NotificationTypeSlack:
+		if len(config.Channel) > 0 {
+			return config.Channel
+		}
+	}
+
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+func timeout(config common.NotificationConfiguration) time.Duration {
+	if config.Timeout > 0 {
+		return time.Duration(config.Timeout) * time.Second
+	}
+	return defaultTimeout
+}
@@ -0,0 +1,192 @@
+package notification
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/stretchr/testify/suite"
+)
+
+type NotificationTestSuite struct {
+	suite.Suite
+}
+
+func (suite *NotificationTestSuite) TestWebhook() {
+	var header http.Header
+	var received Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		suite.NoError(json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	t := common.NotificationTemplate{
+		NotificationsType: common.NotificationTypeWebhook,
+		NotificationConfiguration: common.NotificationConfiguration{
+			URL:     server.URL + "/hook/secret",
+			Headers: map[string]string{"X-Token": "token"},
+		},
+	}
+
+	sent, err := Send(t, Message{
+		Subject: "Job #1 'deploy' successful",
+		Body:    "details",
+		Data:    map[string]interface{}{"status": "successful"},
+	})
+	suite.NoError(err)
+	suite.Equal(1, sent)
+	suite.Equal("token", header.Get("X-Token"))
+	suite.Equal("application/json", header.Get("Content-Type"))
+	suite.Equal("Job #1 'deploy' successful", received.Subject)
+	suite.Equal("details", received.Body)
+	suite.Equal("successful", received.Data["status"])
+
+	// the path of the url is not disclosed
+	suite.Equal(server.URL, Recipients(t))
+}
+
+func (suite *NotificationTestSuite) TestWebhookError() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid token", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	sent, err := Send(common.NotificationTemplate{
+		NotificationsType:         common.NotificationTypeWebhook,
+		NotificationConfiguration: common.NotificationConfiguration{URL: server.URL},
+	}, Message{Subject: "subject"})
+	suite.Error(err)
+	suite.Contains(err.Error(), "invalid token")
+	suite.Equal(0, sent)
+}
+
+func (suite *NotificationTestSuite) TestSlack() {
+	var received slackMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suite.NoError(json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	t := common.NotificationTemplate{
+		NotificationsType: common.NotificationTypeSlack,
+		NotificationConfiguration: common.NotificationConfiguration{
+			URL:     server.URL,
+			Channel: "#ops",
+		},
+	}
+
+	sent, err := Send(t, Message{Subject: "subject", Body: "body"})
+	suite.NoError(err)
+	suite.Equal(1, sent)
+	suite.Equal("subject\nbody", received.Text)
+	suite.Equal("#ops", received.Channel)
+	suite.Equal("#ops", Recipients(t))
+}
+
+func (suite *NotificationTestSuite) TestEmail() {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().NoError(err)
+	defer l.Close()
+
+	commands := make(chan []string, 1)
+	data := make(chan string, 1)
+	go fakeSMTP(l, commands, data)
+
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	p, _ := strconv.Atoi(port)
+
+	t := common.NotificationTemplate{
+		NotificationsType: common.NotificationTypeEmail,
+		NotificationConfiguration: common.NotificationConfiguration{
+			Host:       host,
+			Port:       p,
+			Sender:     "tensor@example.com",
+			Recipients: []string{"ops@example.com", "dev@example.com"},
+		},
+	}
+
+	sent, err := Send(t, Message{Subject: "Job failed", Body: "line 1\nline 2"})
+	suite.NoError(err)
+	suite.Equal(2, sent)
+
+	suite.Equal([]string{
+		"MAIL FROM:<tensor@example.com>",
+		"RCPT TO:<ops@example.com>",
+		"RCPT TO:<dev@example.com>",
+	}, <-commands)
+
+	msg := <-data
+	suite.Contains(msg, "Subject: Job failed\r\n")
+	suite.Contains(msg, "To: ops@example.com, dev@example.com\r\n")
+	suite.Contains(msg, "\r\n\r\nline 1\r\nline 2")
+	suite.Equal("ops@example.com,dev@example.com", Recipients(t))
+}
+
+func (suite *NotificationTestSuite) TestUnsupportedType() {
+	_, err := Send(common.NotificationTemplate{NotificationsType: "irc"}, Message{})
+	suite.Error(err)
+}
+
+// fakeSMTP serves a single SMTP session, it sends the envelope
+// commands and the message data it received to the channels
+func fakeSMTP(l net.Listener, commands chan<- []string, data chan<- string) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+
+	var envelope []string
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch cmd {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL", "RCPT":
+			envelope = append(envelope, line)
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var msg []string
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				msg = append(msg, l)
+			}
+			commands <- envelope
+			data <- strings.Join(msg, "")
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestNotificationTestSuite(t *testing.T) {
+	suite.Run(t, new(NotificationTestSuite))
+}
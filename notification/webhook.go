@@ -0,0 +1,71 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pearsonappeng/tensor/models/common"
+)
+
+// slackMessage is the payload of a Slack compatible incoming webhook
+type slackMessage struct {
+	Text    string `json:"text"`
+	Channel string `json:"channel,omitempty"`
+}
+
+// sendWebhook posts the message as a JSON document to the url of the configuration
+func sendWebhook(config common.NotificationConfiguration, m Message) (int, error) {
+	if err := post(config, m); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// sendSlack posts the message to a Slack compatible incoming webhook
+func sendSlack(config common.NotificationConfiguration, m Message) (int, error) {
+	text := m.Subject
+	if len(m.Body) > 0 {
+		text += "\n" + m.Body
+	}
+
+	if err := post(config, slackMessage{Text: text, Channel: config.Channel}); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// post sends payload encoded as JSON with the headers of the configuration,
+// any response status other than 2xx is an error
+func post(config common.NotificationConfiguration, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Tensor")
+	for k, v := range config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := &http.Client{Timeout: timeout(config)}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+# Copyright (c) 2017 Pearson
+#
+# Redistribution and use in source and binary forms, with or without
+# modification, are permitted provided that the following conditions are met:
+#
+# Redistributions of source code must retain the above copyright notice, this
+# list of conditions and the following disclaimer.
+#
+#    Redistributions in binary form must reproduce the above copyright notice,
+#    this list of conditions and the following disclaimer in the documentation
+#    and/or other materials provided with the distribution.
+#
+# THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+# AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+# IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+# ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+# LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+# CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+# SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+# INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+# CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+# ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+# POSSIBILITY OF SUCH DAMAGE.
+
+# Tensor callback plugin, posts a structured event for every playbook,
+# play, task and host result to the Tensor REST API. The plugin is a no-op
+# outside of a Tensor job and never fails the playbook it is attached to.
+
+from __future__ import (absolute_import, division, print_function)
+__metaclass__ = type
+
+import json
+import os
+import uuid
+
+import requests
+
+from ansible.plugins.callback import CallbackBase
+
+try:
+    requests.packages.urllib3.disable_warnings()
+except AttributeError:
+    pass
+
+
+class TokenAuth(requests.auth.AuthBase):
+    def __init__(self, token):
+        self.token = token
+
+    def __call__(self, request):
+        request.headers['Authorization'] = 'Bearer %s' % self.token
+        return request
+
+
+class CallbackModule(CallbackBase):
+
+    CALLBACK_VERSION = 2.0
+    CALLBACK_TYPE = 'notification'
+    CALLBACK_NAME = 'tensor'
+    CALLBACK_NEEDS_WHITELIST = False
+
+    def __init__(self, display=None):
+        super(CallbackModule, self).__init__(display)
+        self.base_url = os.environ.get('REST_API_URL', '').rstrip('/')
+        self.auth_token = os.environ.get('REST_API_TOKEN', '')
+        self.job_id = os.environ.get('JOB_ID', '')
+        self.disabled = not (self.base_url and self.auth_token and self.job_id)
+        self.session = requests.Session()
+        self.session.auth = TokenAuth(self.auth_token)
+        self.session.verify = False
+        self.counter = 0
+        self.playbook = None
+        self.playbook_uuid = None
+        self.play = None
+        self.play_uuid = None
+        self.task = None
+        self.task_uuid = None
+        self.role = None
+
+    def _post(self, event, event_data=None, host=None, parent_uuid=None):
+        if self.disabled:
+            return
+        self.counter += 1
+        data = {
+            'event': event,
+            'counter': self.counter,
+            'uuid': str(uuid.uuid4()),
+            'parent_uuid': parent_uuid or '',
+            'playbook': self.playbook or '',
+            'play': self.play or '',
+            'play_uuid': self.play_uuid or '',
+            'task': self.task or '',
+            'task_uuid': self.task_uuid or '',
+            'role': self.role or '',
+            'host_name': host or '',
+            'event_data': event_data or {},
+        }
+        url = '%s/v1/jobs/%s/job_events' % (self.base_url, self.job_id)
+        try:
+            body = json.dumps(data, default=str)
+            self.session.post(url, data=body, timeout=30,
+                              headers={'Content-Type': 'application/json'})
+        except Exception as e:
+            # events are best effort, a failing API must not break the job
+            self._display.warning('tensor callback: %s' % e)
+
+    def _result_event(self, event, result, **kwargs):
+        res = dict(result._result)
+        res.pop('invocation', None)
+        event_data = {
+            'res': res,
+            'changed': bool(res.get('changed', False)),
+        }
+        event_data.update(kwargs)
+        self._post(event, event_data, host=result._host.get_name(),
+                   parent_uuid=self.task_uuid)
+
+    def v2_playbook_on_start(self, playbook):
+        self.playbook = os.path.basename(playbook._file_name)
+        self.playbook_uuid = str(uuid.uuid4())
+        self._post('playbook_on_start')
+
+    def v2_playbook_on_play_start(self, play):
+        self.play = play.get_name().strip()
+        self.play_uuid = str(play._uuid)
+        self.task = None
+        self.task_uuid = None
+        self.role = None
+        self._post('playbook_on_play_start', {
+            'pattern': ','.join(play.hosts) if isinstance(play.hosts, list) else play.hosts,
+        }, parent_uuid=self.playbook_uuid)
+
+    def _task_start(self, event, task, **kwargs):
+        self.task = task.get_name().strip()
+        self.task_uuid = str(task._uuid)
+        self.role = task._role.get_name() if task._role else None
+        event_data = {
+            'is_conditional': bool(kwargs.get('is_conditional', False)),
+            'module': task.action,
+        }
+        self._post(event, event_data, parent_uuid=self.play_uuid)
+
+    def v2_playbook_on_task_start(self, task, is_conditional):
+        self._task_start('playbook_on_task_start', task,
+                         is_conditional=is_conditional)
+
+    def v2_playbook_on_handler_task_start(self, task):
+        self._task_start('playbook_on_handler_task_start', task)
+
+    def v2_playbook_on_no_hosts_matched(self):
+        self._post('playbook_on_no_hosts_matched', parent_uuid=self.play_uuid)
+
+    def v2_playbook_on_no_hosts_remaining(self):
+        self._post('playbook_on_no_hosts_remaining', parent_uuid=self.play_uuid)
+
+    def v2_playbook_on_notify(self, handler, host):
+        self._post('playbook_on_notify', {'handler': handler.get_name()},
+                   host=host.get_name(), parent_uuid=self.task_uuid)
+
+    def v2_runner_on_ok(self, result):
+        self._result_event('runner_on_ok', result)
+
+    def v2_runner_on_failed(self, result, ignore_errors=False):
+        self._result_event('runner_on_failed', result,
+                           ignore_errors=ignore_errors)
+
+    def v2_runner_on_unreachable(self, result):
+        self._result_event('runner_on_unreachable', result)
+
+    def v2_runner_on_skipped(self, result):
+        self._result_event('runner_on_skipped', result)
+
+    def v2_runner_on_async_poll(self, result):
+        self._result_event('runner_on_async_poll', result)
+
+    def v2_runner_on_async_ok(self, result):
+        self._result_event('runner_on_async_ok', result)
+
+    def v2_runner_on_async_failed(self, result):
+        self._result_event('runner_on_async_failed', result)
+
+    def v2_runner_item_on_ok(self, result):
+        self._result_event('runner_item_on_ok', result)
+
+    def v2_runner_item_on_failed(self, result):
+        self._result_event('runner_item_on_failed', result)
+
+    def v2_runner_item_on_skipped(self, result):
+        self._result_event('runner_item_on_skipped', result)
+
+    def v2_runner_retry(self, result):
+        self._result_event('runner_retry', result)
+
+    def v2_playbook_on_stats(self, stats):
+        event_data = {}
+        for key in ('changed', 'dark', 'failures', 'ok', 'processed', 'skipped'):
+            event_data[key] = dict(getattr(stats, key, {}))
+        self.task = None
+        self.task_uuid = None
+        self._post('playbook_on_stats', event_data,
+                   parent_uuid=self.playbook_uuid)
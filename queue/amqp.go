@@ -0,0 +1,764 @@
+package queue
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/streadway/amqp"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// publishAttempts is the number of attempts to publish a message
+	// while the broker can not be reached
+	publishAttempts = 3
+	// confirmTimeout is the maximum time to wait for a publisher confirm
+	confirmTimeout = 10 * time.Second
+	// groupsInterval is the interval between two loads of the groups of a queue
+	groupsInterval = 10 * time.Second
+	// groupSeparator separates the queue and the group in the name of a group queue
+	groupSeparator = ".group."
+	// delaySuffix is appended to the name of a queue to get its delay queue
+	delaySuffix = ".delay"
+)
+
+// Headers of dead lettered messages
+const (
+	headerQueue  = "x-tensor-queue"
+	headerReason = "x-tensor-reason"
+)
+
+// amqpBackend stores the messages in durable RabbitMQ queues. Every group has
+// its own priority queue, the groups are registered in the queue_groups
+// collection so that consumers can take messages from each group.
+type amqpBackend struct {
+	mu         sync.Mutex
+	registered map[string]bool
+}
+
+// amqpMessage is a delivery of a RabbitMQ queue. The record of the message is
+// locked until the message is handled, so that the consumers know the running
+// messages of every group.
+type amqpMessage struct {
+	d     amqp.Delivery
+	queue string
+	id    string
+	done  chan struct{}
+	once  sync.Once
+}
+
+// messageRecord is the record of a message of a queue in the queue_deliveries
+// collection, the consumers choose the next group from the records instead of
+// reading every group queue. A record without a valid lock is a pending message
+// which is ready at ReadyAt, a locked record is a running message. The record
+// is removed once its message is handled.
+type messageRecord struct {
+	ID          string     `bson:"_id"`
+	Queue       string     `bson:"queue"`
+	Group       string     `bson:"group"`
+	Priority    uint8      `bson:"priority"`
+	Published   time.Time  `bson:"published"`
+	ReadyAt     time.Time  `bson:"ready_at"`
+	LockedUntil *time.Time `bson:"locked_until,omitempty"`
+}
+
+// record adds a published message to the pending messages of its group
+func record(name string, group string, msg amqp.Publishing) {
+	if _, err := db.QueueDeliveries().UpsertId(msg.MessageId, bson.M{
+		"$set": bson.M{
+			"queue":     name,
+			"group":     group,
+			"priority":  msg.Priority,
+			"published": msg.Timestamp,
+			"ready_at":  time.Now(),
+		},
+		"$unset": bson.M{"locked_until": ""},
+	}); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Queue": name,
+			"Group": group,
+			"Error": err.Error(),
+		}).Errorln("Failed to record a message")
+	}
+}
+
+// newAMQPMessage locks the record of a delivery of a group of the queue
+func newAMQPMessage(d amqp.Delivery, name string, group string) *amqpMessage {
+	m := &amqpMessage{
+		d:     d,
+		queue: name,
+		id:    d.MessageId,
+		done:  make(chan struct{}),
+	}
+	if m.id == "" {
+		m.id = bson.NewObjectId().Hex()
+	}
+
+	now := time.Now()
+	if _, err := db.QueueDeliveries().UpsertId(m.id, bson.M{"$set": bson.M{
+		"queue":        name,
+		"group":        group,
+		"priority":     d.Priority,
+		"published":    d.Timestamp,
+		"ready_at":     now,
+		"locked_until": now.Add(lockTimeout),
+	}}); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Queue": name,
+			"Error": err.Error(),
+		}).Errorln("Failed to record a delivery")
+	}
+	go m.renew()
+	return m
+}
+
+// renew extends the lock of the record until the message is handled
+func (m *amqpMessage) renew() {
+	ticker := time.NewTicker(lockRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			err := db.QueueDeliveries().UpdateId(m.id,
+				bson.M{"$set": bson.M{"locked_until": time.Now().Add(lockTimeout)}})
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"Queue": m.queue,
+					"Error": err.Error(),
+				}).Errorln("Failed to renew the lock of a delivery")
+			}
+		}
+	}
+}
+
+// stop stops renewing the lock of the record
+func (m *amqpMessage) stop() {
+	m.once.Do(func() { close(m.done) })
+}
+
+// remove removes the record of the handled message
+func (m *amqpMessage) remove() {
+	m.stop()
+	if err := db.QueueDeliveries().RemoveId(m.id); err != nil && err != mgo.ErrNotFound {
+		logrus.WithFields(logrus.Fields{
+			"Queue": m.queue,
+			"Error": err.Error(),
+		}).Errorln("Failed to remove the record of a delivery")
+	}
+}
+
+// unlock returns the record to the pending messages of its group,
+// the message is ready again at readyAt
+func (m *amqpMessage) unlock(readyAt time.Time) {
+	m.stop()
+	if err := db.QueueDeliveries().UpdateId(m.id, bson.M{
+		"$set":   bson.M{"ready_at": readyAt},
+		"$unset": bson.M{"locked_until": ""},
+	}); err != nil && err != mgo.ErrNotFound {
+		logrus.WithFields(logrus.Fields{
+			"Queue": m.queue,
+			"Error": err.Error(),
+		}).Errorln("Failed to unlock the record of a delivery")
+	}
+}
+
+func (m *amqpMessage) Body() []byte {
+	return m.d.Body
+}
+
+func (m *amqpMessage) Ack() error {
+	m.remove()
+	return m.d.Ack(false)
+}
+
+// Reject publishes the message to the dead letter queue and acknowledges it,
+// the message is discarded if it can not be dead lettered
+func (m *amqpMessage) Reject(reason string) error {
+	m.remove()
+	msg := amqp.Publishing{
+		Headers: amqp.Table{
+			headerQueue:  m.d.RoutingKey,
+			headerReason: reason,
+		},
+		DeliveryMode: amqp.Persistent,
+		ContentType:  m.d.ContentType,
+		Priority:     m.d.Priority,
+		MessageId:    m.d.MessageId,
+		Timestamp:    m.d.Timestamp,
+		Body:         m.d.Body,
+	}
+
+	if err := publishMessage(DeadLetterQueue(m.queue), msg); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Queue": m.queue,
+			"Error": err.Error(),
+		}).Errorln("Failed to dead letter a message, discarding it")
+		return m.d.Reject(false)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"Queue":  m.queue,
+		"Reason": reason,
+	}).Warningln("Message moved to the dead letter queue")
+	return m.d.Ack(false)
+}
+
+// Defer publishes the message to the delay queue of its queue and acknowledges
+// it, the broker moves the message back to its queue once the delay expired
+func (m *amqpMessage) Defer(delay time.Duration) error {
+	msg := amqp.Publishing{
+		DeliveryMode: amqp.Persistent,
+		ContentType:  m.d.ContentType,
+		Priority:     m.d.Priority,
+		MessageId:    m.d.MessageId,
+		Timestamp:    m.d.Timestamp,
+		Expiration:   strconv.FormatInt(int64(delay/time.Millisecond), 10),
+		Body:         m.d.Body,
+	}
+
+	if err := publishMessage(m.d.RoutingKey+delaySuffix, msg); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Queue": m.queue,
+			"Error": err.Error(),
+		}).Errorln("Failed to defer a message, returning it to the queue")
+		m.unlock(time.Now())
+		m.d.Nack(false, true)
+		return err
+	}
+	m.unlock(time.Now().Add(delay))
+	return m.d.Ack(false)
+}
+
+func (*amqpBackend) Ping() error {
+	conn, err := shared.get()
+	if err != nil {
+		return err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	return ch.Close()
+}
+
+// Publish publishes the message to the queue of its group and waits for the
+// publisher confirm
+func (b *amqpBackend) Publish(name string, body []byte, opts Options) error {
+	if err := b.register(name, opts.Group); err != nil {
+		return err
+	}
+
+	msg := amqp.Publishing{
+		DeliveryMode: amqp.Persistent,
+		ContentType:  "text/plain",
+		Priority:     opts.Priority,
+		MessageId:    bson.NewObjectId().Hex(),
+		Timestamp:    time.Now(),
+		Body:         body,
+	}
+	if err := publishMessage(groupQueue(name, opts.Group), msg); err != nil {
+		return err
+	}
+	record(name, opts.Group, msg)
+	return nil
+}
+
+// register adds the group to the groups of the queue
+func (b *amqpBackend) register(name string, group string) error {
+	if group == "" {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	queue := groupQueue(name, group)
+	if b.registered[queue] {
+		return nil
+	}
+
+	if _, err := db.QueueGroups().UpsertId(queue, bson.M{
+		"$set": bson.M{"queue": name, "group": group},
+	}); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Queue": name,
+			"Group": group,
+			"Error": err.Error(),
+		}).Errorln("Failed to register the group of a queue")
+		return ErrUnavailable
+	}
+
+	if b.registered == nil {
+		b.registered = map[string]bool{}
+	}
+	b.registered[queue] = true
+	return nil
+}
+
+// groupQueue returns the RabbitMQ queue of a group of a queue. Messages without
+// a group are stored in the queue itself, which has no priorities so that the
+// queue declared by earlier versions can still be used.
+func groupQueue(name string, group string) string {
+	if group == "" {
+		return name
+	}
+	return name + groupSeparator + group
+}
+
+// queueGroup returns the group of a RabbitMQ queue of a queue
+func queueGroup(name string, queue string) string {
+	if !strings.HasPrefix(queue, name+groupSeparator) {
+		return ""
+	}
+	return strings.TrimPrefix(queue, name+groupSeparator)
+}
+
+func publishMessage(name string, msg amqp.Publishing) (err error) {
+	var delay time.Duration
+	for i := 0; i < publishAttempts; i++ {
+		if i > 0 {
+			delay = backoff(delay)
+			time.Sleep(delay)
+		}
+
+		err = publisher.publish(name, msg)
+		if err != ErrUnavailable {
+			break
+		}
+	}
+	return
+}
+
+// Consume delivers the messages of the queue to the workers. When the
+// connection to the broker is lost the consumer reconnects with an
+// exponential backoff, the running workers finish their messages on
+// their own and take part in the new consumer once they are idle.
+func (*amqpBackend) Consume(name string, workers int, handle func(Message)) {
+	idle := make(chan bool, workers)
+	for i := 0; i < workers; i++ {
+		idle <- true
+	}
+
+	var delay time.Duration
+	for {
+		logrus.WithFields(logrus.Fields{
+			"Queue":   name,
+			"Workers": workers,
+		}).Infoln("Starting consumer")
+
+		if err := consume(name, idle, handle); err != nil {
+			delay = backoff(delay)
+			logrus.WithFields(logrus.Fields{
+				"Queue": name,
+				"Error": err.Error(),
+				"Retry": delay.String(),
+			}).Errorln("Failed to start consumer")
+		} else {
+			delay = minBackoff
+			logrus.WithFields(logrus.Fields{
+				"Queue": name,
+				"Retry": delay.String(),
+			}).Warningln("Consumer stopped")
+		}
+
+		time.Sleep(delay)
+	}
+}
+
+// consume takes a message whenever a worker is idle until the channel is closed.
+// The next group is chosen with Next among the pending records of the groups,
+// the group with the fewest running messages goes first.
+func consume(name string, idle chan bool, handle func(Message)) error {
+	conn, err := shared.get()
+	if err != nil {
+		return err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	if err := declare(ch, name); err != nil {
+		return err
+	}
+	closed := ch.NotifyClose(make(chan *amqp.Error, 1))
+
+	groups := groupQueues{name: name}
+	for {
+		select {
+		case <-idle:
+		case err := <-closed:
+			return closeError(err)
+		}
+
+		d, group, ok, err := groups.get(ch)
+		if err != nil {
+			idle <- true
+			return err
+		}
+
+		if !ok {
+			idle <- true
+			select {
+			case err := <-closed:
+				return closeError(err)
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+
+		go func(m *amqpMessage) {
+			defer func() { idle <- true }()
+			handle(m)
+			m.stop()
+		}(newAMQPMessage(d, name, group))
+	}
+}
+
+// closeError returns the error that closed a channel, nil if it was closed normally
+func closeError(err *amqp.Error) error {
+	if err == nil {
+		return nil
+	}
+	return err
+}
+
+// groupQueues holds the groups of a queue
+type groupQueues struct {
+	name   string
+	groups map[string]bool
+	loaded time.Time
+}
+
+// get takes a message of the group chosen with Next among the pending records
+// of the groups, only the queue of the chosen group is read. Next counts the
+// running messages of every consumer of the queue. It returns false if no
+// group has a ready message.
+func (g *groupQueues) get(ch *amqp.Channel) (amqp.Delivery, string, bool, error) {
+	if time.Since(g.loaded) > groupsInterval {
+		if err := g.load(ch); err != nil {
+			return amqp.Delivery{}, "", false, err
+		}
+	}
+
+	reloaded := false
+	for {
+		now := time.Now()
+		heads, running, err := recordedHeads(g.name, now)
+		if err != nil {
+			return amqp.Delivery{}, "", false, err
+		}
+		if len(heads) == 0 {
+			return amqp.Delivery{}, "", false, nil
+		}
+
+		group := heads[Next(heads, running)].Group
+		// the group was added after the groups were loaded
+		if !g.groups[group] && !reloaded {
+			if err := g.load(ch); err != nil {
+				return amqp.Delivery{}, "", false, err
+			}
+			reloaded = true
+		}
+
+		if g.groups[group] {
+			d, ok, err := ch.Get(groupQueue(g.name, group), false)
+			if err != nil {
+				// unacknowledged messages return to their queues when the channel is closed
+				return d, "", false, err
+			}
+			if ok {
+				return d, group, true, nil
+			}
+		}
+
+		// the records are ahead of the queue of the group, a deferred message is
+		// still in the delay queue or a record of a handled message was kept
+		if err := postpone(g.name, group, now); err != nil {
+			return amqp.Delivery{}, "", false, err
+		}
+	}
+}
+
+// load loads the groups of the queue and declares their queues. A queue with
+// ready messages but without pending records, like the messages published
+// before the records were kept, gets a placeholder record, pending records of
+// a queue without ready messages are removed once they are old.
+func (g *groupQueues) load(ch *amqp.Channel) error {
+	var groups []string
+	if err := db.QueueGroups().Find(bson.M{"queue": g.name}).Distinct("group", &groups); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	pending, err := pendingGroups(g.name, now)
+	if err != nil {
+		return err
+	}
+
+	loaded := map[string]bool{}
+	for _, group := range append([]string{""}, groups...) {
+		queue := groupQueue(g.name, group)
+		if err := declare(ch, queue); err != nil {
+			return err
+		}
+		loaded[group] = true
+
+		q, err := ch.QueueInspect(queue)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case q.Messages > 0 && pending[group] == 0:
+			if _, err := db.QueueDeliveries().UpsertId(queue, bson.M{"$set": bson.M{
+				"queue":     g.name,
+				"group":     group,
+				"priority":  uint8(0),
+				"published": time.Time{},
+				"ready_at":  time.Time{},
+			}}); err != nil {
+				return err
+			}
+		case q.Messages == 0 && pending[group] > 0:
+			if _, err := db.QueueDeliveries().RemoveAll(bson.M{
+				"queue":        g.name,
+				"group":        group,
+				"ready_at":     bson.M{"$lt": now.Add(-groupsInterval)},
+				"locked_until": bson.M{"$not": bson.M{"$gte": now}},
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	g.groups = loaded
+	g.loaded = now
+	return nil
+}
+
+// recordedHeads returns the next ready record of every group of the queue and
+// the number of running messages of every group
+func recordedHeads(name string, now time.Time) ([]Pending, map[string]int, error) {
+	running, err := runningGroups(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var first []struct {
+		Group     string    `bson:"_id"`
+		ID        string    `bson:"id"`
+		Priority  uint8     `bson:"priority"`
+		Published time.Time `bson:"published"`
+	}
+	if err := db.QueueDeliveries().Pipe([]bson.M{
+		{"$match": bson.M{
+			"queue":        name,
+			"ready_at":     bson.M{"$lte": now},
+			"locked_until": bson.M{"$not": bson.M{"$gte": now}},
+		}},
+		{"$sort": bson.D{{Name: "priority", Value: -1}, {Name: "published", Value: 1}, {Name: "_id", Value: 1}}},
+		{"$group": bson.M{
+			"_id":       "$group",
+			"id":        bson.M{"$first": "$_id"},
+			"priority":  bson.M{"$first": "$priority"},
+			"published": bson.M{"$first": "$published"},
+		}},
+	}).All(&first); err != nil {
+		return nil, nil, err
+	}
+
+	heads := []Pending{}
+	for _, msg := range first {
+		heads = append(heads, Pending{
+			ID:        msg.ID,
+			Options:   Options{Priority: msg.Priority, Group: msg.Group},
+			Published: msg.Published,
+		})
+	}
+	return heads, running, nil
+}
+
+// pendingGroups returns the number of pending records of every group of the queue
+func pendingGroups(name string, now time.Time) (map[string]int, error) {
+	var unlocked []struct {
+		Group string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if err := db.QueueDeliveries().Pipe([]bson.M{
+		{"$match": bson.M{"queue": name, "locked_until": bson.M{"$not": bson.M{"$gte": now}}}},
+		{"$group": bson.M{"_id": "$group", "count": bson.M{"$sum": 1}}},
+	}).All(&unlocked); err != nil {
+		return nil, err
+	}
+
+	pending := map[string]int{}
+	for _, group := range unlocked {
+		pending[group.Group] = group.Count
+	}
+	return pending, nil
+}
+
+// postpone delays the pending records of a group whose queue has no ready
+// message, the placeholder record of the group is removed
+func postpone(name string, group string, now time.Time) error {
+	if err := db.QueueDeliveries().RemoveId(groupQueue(name, group)); err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+
+	_, err := db.QueueDeliveries().UpdateAll(bson.M{
+		"queue":        name,
+		"group":        group,
+		"ready_at":     bson.M{"$lte": now},
+		"locked_until": bson.M{"$not": bson.M{"$gte": now}},
+	}, bson.M{"$set": bson.M{"ready_at": now.Add(pollInterval)}})
+	return err
+}
+
+// runningGroups returns the number of running messages of every group of the queue
+func runningGroups(name string) (map[string]int, error) {
+	var locked []struct {
+		Group string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if err := db.QueueDeliveries().Pipe([]bson.M{
+		{"$match": bson.M{"queue": name, "locked_until": bson.M{"$gte": time.Now()}}},
+		{"$group": bson.M{"_id": "$group", "count": bson.M{"$sum": 1}}},
+	}).All(&locked); err != nil {
+		return nil, err
+	}
+
+	running := map[string]int{}
+	for _, group := range locked {
+		running[group.Group] = group.Count
+	}
+	return running, nil
+}
+
+func (*amqpBackend) DeadLetters(name string) ([]DeadLetter, error) {
+	letters := []DeadLetter{}
+	err := deadLetters(name, func(d amqp.Delivery, letter DeadLetter) error {
+		letters = append(letters, letter)
+		return nil
+	})
+	return letters, err
+}
+
+// Requeue publishes the dead letters to the queue of their group again
+func (*amqpBackend) Requeue(name string, ids []string) (int, error) {
+	selected := map[string]bool{}
+	for _, id := range ids {
+		selected[id] = true
+	}
+
+	count := 0
+	err := deadLetters(name, func(d amqp.Delivery, letter DeadLetter) error {
+		if len(ids) > 0 && !selected[letter.ID] {
+			return nil
+		}
+
+		queue := name
+		if q, ok := d.Headers[headerQueue].(string); ok && q != "" {
+			queue = q
+		}
+
+		msg := amqp.Publishing{
+			DeliveryMode: amqp.Persistent,
+			ContentType:  d.ContentType,
+			Priority:     d.Priority,
+			MessageId:    d.MessageId,
+			Timestamp:    d.Timestamp,
+			Body:         d.Body,
+		}
+		if err := publishMessage(queue, msg); err != nil {
+			return err
+		}
+		if msg.MessageId != "" {
+			record(name, queueGroup(name, queue), msg)
+		}
+		count++
+		return d.Ack(false)
+	})
+	return count, err
+}
+
+// deadLetters calls fn for every message of the dead letter queue of a
+// queue, messages that are not acknowledged by fn stay in the queue
+func deadLetters(name string, fn func(amqp.Delivery, DeadLetter) error) error {
+	conn, err := shared.get()
+	if err != nil {
+		return ErrUnavailable
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return ErrUnavailable
+	}
+	// unacknowledged messages return to the queue when the channel is closed
+	defer ch.Close()
+
+	dead := DeadLetterQueue(name)
+	if err := declare(ch, dead); err != nil {
+		return err
+	}
+
+	for {
+		d, ok, err := ch.Get(dead, false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+
+		letter := DeadLetter{
+			ID:        d.MessageId,
+			Queue:     name,
+			Published: d.Timestamp,
+			Body:      d.Body,
+		}
+		if reason, ok := d.Headers[headerReason].(string); ok {
+			letter.Reason = reason
+		}
+
+		if err := fn(d, letter); err != nil {
+			return err
+		}
+	}
+}
+
+// declare declares the durable queue, the queues of the groups are priority queues.
+// A delay queue has no consumers, its expired messages return to their queue.
+func declare(ch *amqp.Channel, name string) error {
+	var args amqp.Table
+	switch {
+	case strings.HasSuffix(name, delaySuffix):
+		args = amqp.Table{
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": strings.TrimSuffix(name, delaySuffix),
+		}
+	case strings.Contains(name, groupSeparator):
+		args = amqp.Table{"x-max-priority": int32(MaxPriority)}
+	}
+
+	_, err := ch.QueueDeclare(
+		name,  // name
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		args,  // arguments
+	)
+	return err
+}
@@ -0,0 +1,72 @@
+package queue
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pearsonappeng/tensor/util"
+	"github.com/streadway/amqp"
+)
+
+const (
+	// minBackoff is the delay before the first reconnection attempt
+	minBackoff = time.Second
+	// maxBackoff is the maximum delay between two reconnection attempts
+	maxBackoff = time.Minute
+)
+
+// backoff returns the delay before the next reconnection attempt,
+// the delay doubles after every failed attempt
+func backoff(delay time.Duration) time.Duration {
+	if delay < minBackoff {
+		return minBackoff
+	}
+	delay *= 2
+	if delay > maxBackoff {
+		return maxBackoff
+	}
+	return delay
+}
+
+// connection is a connection to the broker shared by the publisher and
+// the consumers of the process, it is dialed again once it was closed
+type connection struct {
+	mu   sync.Mutex
+	conn *amqp.Connection
+}
+
+var shared connection
+
+// get returns the open connection or dials a new one
+func (c *connection) get() (*amqp.Connection, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != nil {
+		return c.conn, nil
+	}
+
+	conn, err := amqp.Dial(util.Config.RabbitMQ)
+	if err != nil {
+		return nil, err
+	}
+
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	go func() {
+		if err := <-closed; err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Error": err.Error(),
+			}).Warningln("Connection to RabbitMQ closed")
+		}
+
+		c.mu.Lock()
+		if c.conn == conn {
+			c.conn = nil
+		}
+		c.mu.Unlock()
+	}()
+
+	c.conn = conn
+	return conn, nil
+}
@@ -0,0 +1,17 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(time.Second, backoff(0))
+	assert.Equal(2*time.Second, backoff(time.Second))
+	assert.Equal(32*time.Second, backoff(16*time.Second))
+	assert.Equal(time.Minute, backoff(32*time.Second))
+	assert.Equal(time.Minute, backoff(time.Minute))
+}
@@ -0,0 +1,309 @@
+package queue
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pearsonappeng/tensor/db"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// pollInterval is the time between two polls of an empty queue
+	pollInterval = time.Second
+	// lockTimeout is the time a message stays locked by its consumer, the
+	// message is delivered again if the lock is not renewed in time
+	lockTimeout = 2 * time.Minute
+	// lockRenewInterval is the interval between the renewals of a lock
+	lockRenewInterval = 30 * time.Second
+)
+
+// mongoBackend stores the messages in the queue_messages collection of the
+// Tensor database, a message broker is not needed. A consumer locks the
+// message it handles and renews the lock until the message is acknowledged,
+// the message of a stopped consumer is delivered again once its lock expired.
+type mongoBackend struct{}
+
+type mongoMessage struct {
+	ID          bson.ObjectId `bson:"_id"`
+	Queue       string        `bson:"queue"`
+	Body        []byte        `bson:"body"`
+	Priority    uint8         `bson:"priority"`
+	Group       string        `bson:"group,omitempty"`
+	Reason      string        `bson:"reason,omitempty"`
+	Published   time.Time     `bson:"published"`
+	Lock        bson.ObjectId `bson:"lock,omitempty"`
+	LockedUntil time.Time     `bson:"locked_until"`
+}
+
+// mongoDelivery is a message locked by a consumer
+type mongoDelivery struct {
+	msg  mongoMessage
+	done chan struct{}
+	once sync.Once
+}
+
+func (d *mongoDelivery) Body() []byte {
+	return d.msg.Body
+}
+
+func (d *mongoDelivery) Ack() error {
+	d.release()
+	err := db.QueueMessages().Remove(bson.M{"_id": d.msg.ID, "lock": d.msg.Lock})
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func (d *mongoDelivery) Reject(reason string) error {
+	d.release()
+	err := db.QueueMessages().Update(bson.M{"_id": d.msg.ID, "lock": d.msg.Lock}, bson.M{
+		"$set":   bson.M{"queue": DeadLetterQueue(d.msg.Queue), "reason": reason, "locked_until": time.Time{}},
+		"$unset": bson.M{"lock": ""},
+	})
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	if err == nil {
+		logrus.WithFields(logrus.Fields{
+			"Queue":  d.msg.Queue,
+			"Reason": reason,
+		}).Warningln("Message moved to the dead letter queue")
+	}
+	return err
+}
+
+// Defer unlocks the message, it is delivered again once the delay expired
+func (d *mongoDelivery) Defer(delay time.Duration) error {
+	d.release()
+	err := db.QueueMessages().Update(bson.M{"_id": d.msg.ID, "lock": d.msg.Lock}, bson.M{
+		"$set":   bson.M{"locked_until": time.Now().Add(delay)},
+		"$unset": bson.M{"lock": ""},
+	})
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// renew extends the lock of the message until it is released
+func (d *mongoDelivery) renew() {
+	ticker := time.NewTicker(lockRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+			err := db.QueueMessages().Update(bson.M{"_id": d.msg.ID, "lock": d.msg.Lock},
+				bson.M{"$set": bson.M{"locked_until": time.Now().Add(lockTimeout)}})
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"Queue": d.msg.Queue,
+					"Error": err.Error(),
+				}).Errorln("Failed to renew the lock of a message")
+			}
+		}
+	}
+}
+
+func (d *mongoDelivery) release() {
+	d.once.Do(func() { close(d.done) })
+}
+
+func (mongoBackend) Ping() error {
+	return db.MongoDb.Session.Ping()
+}
+
+func (mongoBackend) Publish(name string, body []byte, opts Options) error {
+	msg := mongoMessage{
+		ID:        bson.NewObjectId(),
+		Queue:     name,
+		Body:      body,
+		Priority:  opts.Priority,
+		Group:     opts.Group,
+		Published: time.Now(),
+	}
+	if err := db.QueueMessages().Insert(msg); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Queue": name,
+			"Error": err.Error(),
+		}).Errorln("Failed to store a message")
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// Consume polls the queue with every worker, the next message is chosen with Next
+func (b mongoBackend) Consume(name string, workers int, handle func(Message)) {
+	logrus.WithFields(logrus.Fields{
+		"Queue":   name,
+		"Workers": workers,
+	}).Infoln("Starting consumer")
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			var delay time.Duration
+			for {
+				d, err := b.receive(name)
+				if err != nil {
+					delay = backoff(delay)
+					logrus.WithFields(logrus.Fields{
+						"Queue": name,
+						"Error": err.Error(),
+						"Retry": delay.String(),
+					}).Errorln("Failed to receive a message")
+					time.Sleep(delay)
+					continue
+				}
+				delay = 0
+
+				if d == nil {
+					time.Sleep(pollInterval)
+					continue
+				}
+
+				go d.renew()
+				handle(d)
+				d.release()
+			}
+		}()
+	}
+	select {}
+}
+
+// receive locks the next message of the queue, it returns nil if the queue is
+// empty. The next message is chosen among the first messages of the groups by
+// the number of locked messages of each group.
+func (mongoBackend) receive(name string) (*mongoDelivery, error) {
+	for {
+		now := time.Now()
+		heads, running, err := groupHeads(name, now)
+		if err != nil {
+			return nil, err
+		}
+		if len(heads) == 0 {
+			return nil, nil
+		}
+
+		head := heads[Next(heads, running)]
+		change := mgo.Change{
+			Update: bson.M{"$set": bson.M{
+				"lock":         bson.NewObjectId(),
+				"locked_until": now.Add(lockTimeout),
+			}},
+			ReturnNew: true,
+		}
+
+		var msg mongoMessage
+		_, err = db.QueueMessages().Find(bson.M{
+			"_id":          bson.ObjectIdHex(head.ID),
+			"queue":        name,
+			"locked_until": bson.M{"$lt": now},
+		}).Apply(change, &msg)
+		// locked by another consumer in the meantime
+		if err == mgo.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &mongoDelivery{msg: msg, done: make(chan struct{})}, nil
+	}
+}
+
+// groupHeads returns the next unlocked message of every group of the queue
+// and the number of locked messages of every group, deferred messages are
+// neither locked nor delivered until their delay expired
+func groupHeads(name string, now time.Time) ([]Pending, map[string]int, error) {
+	var locked []struct {
+		Group string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if err := db.QueueMessages().Pipe([]bson.M{
+		{"$match": bson.M{"queue": name, "lock": bson.M{"$exists": true}, "locked_until": bson.M{"$gte": now}}},
+		{"$group": bson.M{"_id": "$group", "count": bson.M{"$sum": 1}}},
+	}).All(&locked); err != nil {
+		return nil, nil, err
+	}
+
+	running := map[string]int{}
+	for _, group := range locked {
+		running[group.Group] = group.Count
+	}
+
+	var first []struct {
+		Group     string        `bson:"_id"`
+		ID        bson.ObjectId `bson:"id"`
+		Priority  uint8         `bson:"priority"`
+		Published time.Time     `bson:"published"`
+	}
+	if err := db.QueueMessages().Pipe([]bson.M{
+		{"$match": bson.M{"queue": name, "locked_until": bson.M{"$lt": now}}},
+		{"$sort": bson.D{{Name: "priority", Value: -1}, {Name: "_id", Value: 1}}},
+		{"$group": bson.M{
+			"_id":       "$group",
+			"id":        bson.M{"$first": "$_id"},
+			"priority":  bson.M{"$first": "$priority"},
+			"published": bson.M{"$first": "$published"},
+		}},
+	}).All(&first); err != nil {
+		return nil, nil, err
+	}
+
+	heads := []Pending{}
+	for _, msg := range first {
+		heads = append(heads, Pending{
+			ID:        msg.ID.Hex(),
+			Options:   Options{Priority: msg.Priority, Group: msg.Group},
+			Published: msg.Published,
+		})
+	}
+	return heads, running, nil
+}
+
+func (mongoBackend) DeadLetters(name string) ([]DeadLetter, error) {
+	var msgs []mongoMessage
+	if err := db.QueueMessages().Find(bson.M{"queue": DeadLetterQueue(name)}).Sort("_id").All(&msgs); err != nil {
+		return nil, ErrUnavailable
+	}
+
+	letters := []DeadLetter{}
+	for _, msg := range msgs {
+		letters = append(letters, DeadLetter{
+			ID:        msg.ID.Hex(),
+			Queue:     name,
+			Reason:    msg.Reason,
+			Published: msg.Published,
+			Body:      msg.Body,
+		})
+	}
+	return letters, nil
+}
+
+func (mongoBackend) Requeue(name string, ids []string) (int, error) {
+	query := bson.M{"queue": DeadLetterQueue(name)}
+	if len(ids) > 0 {
+		oids := []bson.ObjectId{}
+		for _, id := range ids {
+			if bson.IsObjectIdHex(id) {
+				oids = append(oids, bson.ObjectIdHex(id))
+			}
+		}
+		query["_id"] = bson.M{"$in": oids}
+	}
+
+	info, err := db.QueueMessages().UpdateAll(query, bson.M{
+		"$set":   bson.M{"queue": name, "locked_until": time.Time{}},
+		"$unset": bson.M{"reason": ""},
+	})
+	if err != nil {
+		return 0, ErrUnavailable
+	}
+	return info.Updated, nil
+}
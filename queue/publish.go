@@ -0,0 +1,105 @@
+package queue
+
+import (
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// confirmPublisher publishes messages on a channel in confirm mode, one
+// message at a time so that each confirm belongs to the last message
+type confirmPublisher struct {
+	mu       sync.Mutex
+	ch       *amqp.Channel
+	confirms chan amqp.Confirmation
+	returns  chan amqp.Return
+	declared map[string]bool
+}
+
+var publisher confirmPublisher
+
+func (p *confirmPublisher) publish(name string, msg amqp.Publishing) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.open(); err != nil {
+		return ErrUnavailable
+	}
+
+	if !p.declared[name] {
+		if err := declare(p.ch, name); err != nil {
+			p.reset()
+			return ErrUnavailable
+		}
+		p.declared[name] = true
+	}
+
+	if err := p.ch.Publish(
+		"",    // exchange
+		name,  // routing key
+		true,  // mandatory
+		false, // immediate
+		msg,
+	); err != nil {
+		p.reset()
+		return ErrUnavailable
+	}
+
+	select {
+	case confirm, ok := <-p.confirms:
+		if !ok {
+			p.reset()
+			return ErrUnavailable
+		}
+		// an unroutable message is returned before it is confirmed
+		select {
+		case <-p.returns:
+			return ErrRefused
+		default:
+		}
+		if !confirm.Ack {
+			return ErrRefused
+		}
+		return nil
+	case <-time.After(confirmTimeout):
+		p.reset()
+		return ErrUnavailable
+	}
+}
+
+// open opens a channel in confirm mode on the shared connection
+func (p *confirmPublisher) open() error {
+	if p.ch != nil {
+		return nil
+	}
+
+	conn, err := shared.get()
+	if err != nil {
+		return err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return err
+	}
+
+	p.ch = ch
+	p.confirms = ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	p.returns = ch.NotifyReturn(make(chan amqp.Return, 1))
+	p.declared = map[string]bool{}
+	return nil
+}
+
+// reset closes the channel, the next message opens a new one
+func (p *confirmPublisher) reset() {
+	if p.ch != nil {
+		p.ch.Close()
+	}
+	p.ch = nil
+}
@@ -1,9 +1,12 @@
 package queue
 
 import (
+	"errors"
+	"sync"
+	"time"
+
 	"github.com/Sirupsen/logrus"
 	"github.com/pearsonappeng/tensor/util"
-	"github.com/streadway/amqp"
 )
 
 const (
@@ -13,84 +16,123 @@ const (
 	Terraform = "terraform"
 )
 
-// TestConnect will test the connectivity to rabbitmq
-func TestConnect() (err error) {
-	conn, err := amqp.Dial(util.Config.RabbitMQ)
-	if err != nil {
-		return
-	}
-	defer conn.Close()
+// Queue backends selectable with queue_backend in tensor.conf
+const (
+	// BackendAMQP stores the jobs in RabbitMQ
+	BackendAMQP = "amqp"
+	// BackendMongoDB stores the jobs in the Tensor database, it needs
+	// no message broker and suits single node installs
+	BackendMongoDB = "mongodb"
+)
 
-	ch, err := conn.Channel()
-	if err != nil {
-		return
-	}
-	defer ch.Close()
-	return
-}
+var (
+	// ErrUnavailable is returned when the broker can not be reached
+	ErrUnavailable = errors.New("Job queue is unavailable, try again later")
+	// ErrRefused is returned when the broker did not accept the job
+	ErrRefused = errors.New("Job queue refused the job")
+)
 
-// Publish publishes a given json message to a given queue
-// accepts string and array of bytes and returns a cleanup function and error
-func Publish(name string, job []byte) (err error) {
-	conn, err := amqp.Dial(util.Config.RabbitMQ)
+// Message is a job received from a queue. The consumer must either
+// acknowledge the message, defer it or reject it to the dead letter queue.
+type Message interface {
+	// Body returns the encoded job
+	Body() []byte
+	// Ack removes the handled message from the queue
+	Ack() error
+	// Reject moves a message that can not be handled to the
+	// dead letter queue of its queue
+	Reject(reason string) error
+	// Defer returns a message that can not be handled yet to its
+	// queue, it is delivered again after the delay
+	Defer(delay time.Duration) error
+}
 
-	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"Queue": name,
-			"Error": err.Error(),
-		}).Infoln("Could not contact RabbitMQ server")
-		return
-	}
+// Backend stores the messages of the job queues
+type Backend interface {
+	// Ping checks that the backend can be reached
+	Ping() error
+	// Publish stores a message in the queue
+	Publish(name string, body []byte, opts Options) error
+	// Consume runs workers handlers that receive the messages of the
+	// queue, it never returns
+	Consume(name string, workers int, handle func(Message))
+	// DeadLetters returns the messages of the dead letter queue of a queue
+	DeadLetters(name string) ([]DeadLetter, error)
+	// Requeue moves dead lettered messages back to their queue
+	Requeue(name string, ids []string) (int, error)
+}
 
-	defer conn.Close()
+// DeadLetter is a message that could not be handled by the consumer of its queue
+type DeadLetter struct {
+	ID        string    `json:"id"`
+	Queue     string    `json:"queue"`
+	Reason    string    `json:"reason"`
+	Published time.Time `json:"published"`
+	Body      []byte    `json:"-"`
+}
 
-	ch, err := conn.Channel()
+// DeadLetterQueue returns the name of the dead letter queue of a queue
+func DeadLetterQueue(name string) string {
+	return name + ".dead"
+}
 
-	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"Queue": name,
-			"Error": err.Error(),
-		}).Infoln("Failed to open a channel")
-		return
-	}
+var (
+	backendOnce sync.Once
+	backend     Backend
+)
 
-	defer ch.Close()
+// current returns the backend selected in the configuration
+func current() Backend {
+	backendOnce.Do(func() {
+		switch util.Config.QueueBackend {
+		case BackendMongoDB:
+			backend = &mongoBackend{}
+		default:
+			backend = &amqpBackend{}
+		}
+		logrus.Infoln("Queue Backend:", util.Config.QueueBackend)
+	})
+	return backend
+}
 
-	q, err := ch.QueueDeclare(
-		name,  // name
-		true,  // durable
-		false, // delete when unused
-		false, // exclusive
-		false, // no-wait
-		nil,   // arguments
-	)
+// TestConnect will test the connectivity to the queue backend
+func TestConnect() error {
+	return current().Ping()
+}
 
+// Publish publishes a given json message to a given queue and waits until
+// the backend stored it. It returns ErrRefused if the backend rejected the
+// message and ErrUnavailable if the backend could not be reached.
+func Publish(name string, job []byte, opts Options) error {
+	err := current().Publish(name, job, opts)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"Queue": name,
+			"Group": opts.Group,
 			"Error": err.Error(),
-		}).Infoln("Failed to declare a queue")
-		return
+		}).Errorln("Failed to publish a message")
 	}
+	return err
+}
 
-	err = ch.Publish(
-		"",     // exchange
-		q.Name, // routing key
-		false,  // mandatory
-		false,
-		amqp.Publishing{
-			DeliveryMode: amqp.Persistent,
-			ContentType:  "text/plain",
-			Body:         job,
-		})
+// Consume runs workers handlers that receive the messages of the queue.
+// The groups of the queue take turns and the messages of a group are
+// delivered by priority. The handler must acknowledge, defer or reject
+// each message. Consume reconnects when the backend can not be reached
+// and never returns.
+func Consume(name string, workers int, handle func(Message)) {
+	current().Consume(name, workers, handle)
+}
 
-	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"Queue": name,
-			"Error": err.Error(),
-		}).Infoln("Failed to publish a message")
-		return
-	}
+// DeadLetters returns the messages of the dead letter queue of a queue,
+// the messages stay in the dead letter queue
+func DeadLetters(name string) ([]DeadLetter, error) {
+	return current().DeadLetters(name)
+}
 
-	return
+// Requeue publishes the dead lettered messages with the given ids to the
+// queue again, all messages are requeued if ids is empty. It returns the
+// number of requeued messages.
+func Requeue(name string, ids []string) (int, error) {
+	return current().Requeue(name, ids)
 }
@@ -0,0 +1,105 @@
+package queue
+
+import (
+	"sort"
+	"time"
+)
+
+// Priorities of the messages, messages with a higher priority are delivered first
+const (
+	// DefaultPriority is the priority of jobs launched without a priority
+	DefaultPriority = 0
+	// MaxPriority is the highest priority. Project and inventory updates have
+	// it so that they run before the jobs that wait for them.
+	MaxPriority = 10
+)
+
+// Options are the scheduling options of a published message
+type Options struct {
+	// Priority orders the messages of a group, higher first
+	Priority uint8
+	// Group is the organization of the job. Groups take turns so that a
+	// group with many messages does not starve the other groups.
+	Group string
+}
+
+// Pending is a message that waits in its queue
+type Pending struct {
+	ID string
+	Options
+	Published time.Time
+}
+
+// before reports whether a is delivered before b when both are in the same group
+func before(a, b Pending) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	if !a.Published.Equal(b.Published) {
+		return a.Published.Before(b.Published)
+	}
+	return a.ID < b.ID
+}
+
+// Next returns the index of the message of heads that is delivered next.
+// heads holds the next message of every group and running the number of
+// delivered messages that are not acknowledged yet by group. The group with
+// the fewest running messages goes first, ties are broken by the priority
+// and then by the age of the message.
+func Next(heads []Pending, running map[string]int) int {
+	next := -1
+	for i, head := range heads {
+		if next < 0 {
+			next = i
+			continue
+		}
+		best := heads[next]
+		if running[head.Group] != running[best.Group] {
+			if running[head.Group] < running[best.Group] {
+				next = i
+			}
+			continue
+		}
+		if before(head, best) {
+			next = i
+		}
+	}
+	return next
+}
+
+// Order returns the pending messages in the order they are delivered when
+// no other message is published and no running message is acknowledged
+func Order(pending []Pending, running map[string]int) []Pending {
+	groups := map[string][]Pending{}
+	for _, p := range pending {
+		groups[p.Group] = append(groups[p.Group], p)
+	}
+	for _, msgs := range groups {
+		sort.SliceStable(msgs, func(i, j int) bool {
+			return before(msgs[i], msgs[j])
+		})
+	}
+
+	counts := map[string]int{}
+	for group, count := range running {
+		counts[group] = count
+	}
+
+	ordered := []Pending{}
+	for len(groups) > 0 {
+		heads := []Pending{}
+		for _, msgs := range groups {
+			heads = append(heads, msgs[0])
+		}
+
+		head := heads[Next(heads, counts)]
+		ordered = append(ordered, head)
+		counts[head.Group]++
+		if msgs := groups[head.Group][1:]; len(msgs) > 0 {
+			groups[head.Group] = msgs
+		} else {
+			delete(groups, head.Group)
+		}
+	}
+	return ordered
+}
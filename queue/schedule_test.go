@@ -0,0 +1,48 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrder(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	pending := []Pending{
+		{ID: "a1", Options: Options{Group: "a"}, Published: now},
+		{ID: "a2", Options: Options{Group: "a"}, Published: now.Add(time.Second)},
+		{ID: "a3", Options: Options{Group: "a", Priority: 5}, Published: now.Add(2 * time.Second)},
+		{ID: "b1", Options: Options{Group: "b"}, Published: now.Add(3 * time.Second)},
+		{ID: "c1", Options: Options{Group: "c"}, Published: now.Add(4 * time.Second)},
+		{ID: "c2", Options: Options{Group: "c"}, Published: now.Add(5 * time.Second)},
+	}
+
+	ids := func(ordered []Pending) []string {
+		var ids []string
+		for _, p := range ordered {
+			ids = append(ids, p.ID)
+		}
+		return ids
+	}
+
+	// groups take turns, the priority orders the messages of a group
+	assert.Equal([]string{"a3", "b1", "c1", "a1", "c2", "a2"}, ids(Order(pending, nil)))
+	// groups with running messages wait for the others
+	assert.Equal([]string{"b1", "c1", "c2", "a3", "a1", "a2"}, ids(Order(pending, map[string]int{"a": 2})))
+	assert.Empty(Order(nil, nil))
+}
+
+func TestNext(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	heads := []Pending{
+		{ID: "a", Options: Options{Group: "a"}, Published: now},
+		{ID: "b", Options: Options{Group: "b", Priority: MaxPriority}, Published: now.Add(time.Second)},
+	}
+
+	assert.Equal(1, Next(heads, nil))
+	assert.Equal(0, Next(heads, map[string]int{"b": 1}))
+	assert.Equal(-1, Next(nil, nil))
+}
@@ -118,6 +118,17 @@ func (c Credential) ReadByID(user common.User, credentialID bson.ObjectId) bool
 	return c.Read(user, credential)
 }
 
+// ReadAllByID reports whether the user can read every credential,
+// nil ids of the credentials that are not set are skipped
+func (c Credential) ReadAllByID(user common.User, ids ...*bson.ObjectId) bool {
+	for _, id := range ids {
+		if id != nil && !c.ReadByID(user, *id) {
+			return false
+		}
+	}
+	return true
+}
+
 func (Credential) Associate(resourceID bson.ObjectId, grantee bson.ObjectId, roleType string, role string) (err error) {
 	access := bson.M{"$addToSet": bson.M{"roles": common.AccessControl{Type: roleType, GranteeID: grantee, Role: role}}}
 
@@ -13,6 +13,7 @@ const (
 	InventoryAdmin  = "admin"
 	InventoryUse    = "use"
 	InventoryUpdate = "update"
+	InventoryAdHoc  = "adhoc"
 )
 
 type Inventory struct{}
@@ -99,6 +100,56 @@ func (Inventory) Write(user common.User, inventory ansible.Inventory) bool {
 	return false
 }
 
+// AdHoc reports whether the user can run ad hoc commands against the inventory.
+// The adhoc role is granted separately from job template execution, inventory
+// admins can run ad hoc commands as well
+func (Inventory) AdHoc(user common.User, inventory ansible.Inventory) bool {
+	if HasGlobalWrite(user) {
+		return true
+	}
+
+	if IsOrganizationAdmin(inventory.OrganizationID, user.ID) {
+		return true
+	}
+
+	var teams []bson.ObjectId
+	for _, v := range inventory.Roles {
+		if v.Role != InventoryAdmin && v.Role != InventoryAdHoc {
+			continue
+		}
+
+		if v.Type == RoleTypeTeam {
+			teams = append(teams, v.GranteeID)
+		}
+
+		if v.Type == RoleTypeUser && v.GranteeID == user.ID {
+			return true
+		}
+	}
+
+	if len(teams) == 0 {
+		return false
+	}
+
+	// Check whether the user is a member of a team that has the role
+	count, err := db.Teams().Find(bson.M{"_id": bson.M{"$in": teams}, "roles.grantee_id": user.ID}).Count()
+	if err != nil {
+		logrus.Errorln("Error while checking the user is granted teams' memeber:", err)
+	}
+	return count > 0
+}
+
+func (i Inventory) AdHocByID(user common.User, inventoryID bson.ObjectId) bool {
+	var inventory ansible.Inventory
+	if err := db.Inventories().FindId(inventoryID).One(&inventory); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		})
+		return false
+	}
+	return i.AdHoc(user, inventory)
+}
+
 func (i Inventory) ReadByID(user common.User, inventoryID bson.ObjectId) bool {
 	var inventory ansible.Inventory
 	if err := db.Inventories().FindId(inventoryID).One(&inventory); err != nil {
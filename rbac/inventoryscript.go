@@ -0,0 +1,29 @@
+package rbac
+
+import (
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"github.com/pearsonappeng/tensor/models/common"
+)
+
+// InventoryScript permissions derive from the organization of the
+// inventory script, members can read and use it and admins can modify it
+type InventoryScript struct{}
+
+func (InventoryScript) Read(user common.User, script ansible.InventoryScript) bool {
+	// Allow access if the user is super user or
+	// a system auditor
+	if HasGlobalRead(user) {
+		return true
+	}
+
+	return HasOrganizationRead(script.OrganizationID, user.ID)
+}
+
+func (InventoryScript) Write(user common.User, script ansible.InventoryScript) bool {
+	// Allow access if the user is super user
+	if HasGlobalWrite(user) {
+		return true
+	}
+
+	return IsOrganizationAdmin(script.OrganizationID, user.ID)
+}
@@ -150,6 +150,23 @@ func (j JobTemplate) WriteByID(user common.User, templateID bson.ObjectId) bool
 	return j.Write(user, template)
 }
 
+// Launch reports whether the user can launch the job template, the user must
+// be able to write the template and to read its inventory and credentials
+func (j JobTemplate) Launch(user common.User, jtemplate ansible.JobTemplate) bool {
+	if !j.Write(user, jtemplate) || !new(Inventory).ReadByID(user, jtemplate.InventoryID) {
+		return false
+	}
+
+	credentials := new(Credential)
+	for _, id := range jtemplate.VaultCredentialIDs {
+		if !credentials.ReadByID(user, id) {
+			return false
+		}
+	}
+	return credentials.ReadAllByID(user, jtemplate.MachineCredentialID,
+		jtemplate.NetworkCredentialID, jtemplate.CloudCredentialID)
+}
+
 func (JobTemplate) Associate(resourceID bson.ObjectId, grantee bson.ObjectId, roleType string, role string) (err error) {
 	access := bson.M{"$addToSet": bson.M{"roles": common.AccessControl{Type: roleType, GranteeID: grantee, Role: role}}}
 
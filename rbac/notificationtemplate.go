@@ -0,0 +1,28 @@
+package rbac
+
+import (
+	"github.com/pearsonappeng/tensor/models/common"
+)
+
+// NotificationTemplate permissions derive from the organization of the
+// notification template, members can read it and admins can modify it
+type NotificationTemplate struct{}
+
+func (NotificationTemplate) Read(user common.User, template common.NotificationTemplate) bool {
+	// Allow access if the user is super user or
+	// a system auditor
+	if HasGlobalRead(user) {
+		return true
+	}
+
+	return HasOrganizationRead(template.OrganizationID, user.ID)
+}
+
+func (NotificationTemplate) Write(user common.User, template common.NotificationTemplate) bool {
+	// Allow access if the user is super user
+	if HasGlobalWrite(user) {
+		return true
+	}
+
+	return IsOrganizationAdmin(template.OrganizationID, user.ID)
+}
@@ -141,6 +141,16 @@ func (j TerraformJobTemplate) WriteByID(user common.User, templateID bson.Object
 	return j.Write(user, template)
 }
 
+// Launch reports whether the user can launch the terraform job template, the
+// user must be able to write the template and to read its credentials
+func (j TerraformJobTemplate) Launch(user common.User, jtemplate terraform.JobTemplate) bool {
+	if !j.Write(user, jtemplate) {
+		return false
+	}
+	return new(Credential).ReadAllByID(user, jtemplate.MachineCredentialID,
+		jtemplate.NetworkCredentialID, jtemplate.CloudCredentialID, jtemplate.SCMCredentialID)
+}
+
 func (TerraformJobTemplate) Associate(resourceID bson.ObjectId, grantee bson.ObjectId, roleType string, role string) (err error) {
 	access := bson.M{"$addToSet": bson.M{"roles": common.AccessControl{Type: roleType, GranteeID: grantee, Role: role}}}
 
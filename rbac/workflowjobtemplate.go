@@ -0,0 +1,53 @@
+package rbac
+
+import (
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/models/workflow"
+)
+
+// WorkflowJobTemplate permissions derive from the organization of the
+// workflow job template, members can read it and admins can modify it
+type WorkflowJobTemplate struct{}
+
+func (WorkflowJobTemplate) Read(user common.User, template workflow.JobTemplate) bool {
+	// Allow access if the user is super user or
+	// a system auditor
+	if HasGlobalRead(user) {
+		return true
+	}
+
+	return HasOrganizationRead(template.OrganizationID, user.ID)
+}
+
+func (WorkflowJobTemplate) Write(user common.User, template workflow.JobTemplate) bool {
+	// Allow access if the user is super user
+	if HasGlobalWrite(user) {
+		return true
+	}
+
+	return IsOrganizationAdmin(template.OrganizationID, user.ID)
+}
+
+// WorkflowJob permissions derive from the organization of the workflow job
+type WorkflowJob struct{}
+
+func (WorkflowJob) Read(user common.User, job workflow.Job) bool {
+	// Allow access if the user is super user or
+	// a system auditor
+	if HasGlobalRead(user) {
+		return true
+	}
+
+	return HasOrganizationRead(job.OrganizationID, user.ID)
+}
+
+// Write allows the creator of the workflow job and the
+// admins of the organization to cancel and delete it
+func (WorkflowJob) Write(user common.User, job workflow.Job) bool {
+	// Allow access if the user is super user
+	if HasGlobalWrite(user) {
+		return true
+	}
+
+	return job.CreatedByID == user.ID || IsOrganizationAdmin(job.OrganizationID, user.ID)
+}
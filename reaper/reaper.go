@@ -0,0 +1,50 @@
+// Package reaper finishes the jobs of workers that stopped heartbeating.
+package reaper
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	execansible "github.com/pearsonappeng/tensor/exec/ansible"
+	execterraform "github.com/pearsonappeng/tensor/exec/terraform"
+	"github.com/pearsonappeng/tensor/worker"
+)
+
+// Run reaps the jobs of stopped workers periodically. Every tensord
+// process runs a reaper, a job is reaped by only one of them.
+func Run() {
+	ticker := time.NewTicker(worker.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		reap()
+	}
+}
+
+func reap() {
+	workers, err := worker.Stopped()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Failed to get stopped workers")
+		return
+	}
+
+	for _, w := range workers {
+		logrus.WithFields(logrus.Fields{
+			"Worker ID": w.ID.Hex(),
+			"Hostname":  w.Hostname,
+			"Heartbeat": w.Heartbeat,
+		}).Warningln("Worker stopped heartbeating, reaping its jobs")
+
+		execansible.Reap(w.ID)
+		execterraform.Reap(w.ID)
+
+		if err := worker.Remove(w.ID); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Worker ID": w.ID.Hex(),
+				"Error":     err.Error(),
+			}).Errorln("Failed to remove stopped worker")
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type CronTestSuite struct {
+	suite.Suite
+}
+
+func (suite *CronTestSuite) TestParseErrors() {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	} {
+		_, err := ParseCron(spec, time.UTC)
+		suite.Error(err, spec)
+	}
+}
+
+func (suite *CronTestSuite) TestNext() {
+	c, err := ParseCron("30 2 * * 1-5", time.UTC)
+	suite.NoError(err)
+
+	// saturday
+	start := time.Date(2017, 3, 4, 12, 0, 0, 0, time.UTC)
+	suite.Equal([]time.Time{
+		time.Date(2017, 3, 6, 2, 30, 0, 0, time.UTC),
+		time.Date(2017, 3, 7, 2, 30, 0, 0, time.UTC),
+	}, occurrences(c, start, 2))
+}
+
+func (suite *CronTestSuite) TestSteps() {
+	c, err := ParseCron("*/15 * * * *", time.UTC)
+	suite.NoError(err)
+
+	start := time.Date(2017, 3, 4, 12, 14, 59, 0, time.UTC)
+	suite.Equal([]time.Time{
+		time.Date(2017, 3, 4, 12, 15, 0, 0, time.UTC),
+		time.Date(2017, 3, 4, 12, 30, 0, 0, time.UTC),
+	}, occurrences(c, start, 2))
+}
+
+func (suite *CronTestSuite) TestDayOfMonthOrWeek() {
+	// the 13th or any sunday
+	c, err := ParseCron("0 0 13 * 7", time.UTC)
+	suite.NoError(err)
+
+	start := time.Date(2017, 3, 10, 0, 0, 0, 0, time.UTC)
+	suite.Equal([]time.Time{
+		time.Date(2017, 3, 12, 0, 0, 0, 0, time.UTC),
+		time.Date(2017, 3, 13, 0, 0, 0, 0, time.UTC),
+		time.Date(2017, 3, 19, 0, 0, 0, 0, time.UTC),
+	}, occurrences(c, start, 3))
+}
+
+func (suite *CronTestSuite) TestLocation() {
+	loc := time.FixedZone("UTC-5", -5*3600)
+	c, err := ParseCron("0 1 * * *", loc)
+	suite.NoError(err)
+
+	next, ok := c.Next(time.Date(2017, 3, 4, 0, 0, 0, 0, time.UTC))
+	suite.True(ok)
+	suite.Equal(time.Date(2017, 3, 4, 6, 0, 0, 0, time.UTC), next.UTC())
+}
+
+func TestCronSuite(t *testing.T) {
+	suite.Run(t, new(CronTestSuite))
+}
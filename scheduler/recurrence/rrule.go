@@ -0,0 +1,365 @@
+// Package recurrence computes the occurrences of schedules described by an
+// iCalendar recurrence rule (RFC 5545 RRULE) or a cron expression
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence frequencies
+const (
+	Minutely = "MINUTELY"
+	Hourly   = "HOURLY"
+	Daily    = "DAILY"
+	Weekly   = "WEEKLY"
+	Monthly  = "MONTHLY"
+	Yearly   = "YEARLY"
+)
+
+// maxPeriods bounds the number of periods evaluated to find an occurrence,
+// it protects the scheduler from rules that never match
+const maxPeriods = 100000
+
+// Recurrence is a set of points in time
+type Recurrence interface {
+	// Next returns the first occurrence strictly after t,
+	// false is returned when the recurrence has no more occurrences
+	Next(t time.Time) (time.Time, bool)
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// Rule is a parsed RRULE anchored at its start time (DTSTART).
+// The supported parts are FREQ, INTERVAL, COUNT, UNTIL, BYMONTH,
+// BYMONTHDAY, BYDAY (without ordinals), BYHOUR and BYMINUTE.
+type Rule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByMonth    []int
+	ByMonthDay []int
+	ByDay      []time.Weekday
+	ByHour     []int
+	ByMinute   []int
+
+	start time.Time
+}
+
+// ParseRRule parses rule, for example `FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR`.
+// Occurrences are computed in the location of start.
+func ParseRRule(rule string, start time.Time) (*Rule, error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if len(rule) == 0 {
+		return nil, errors.New("empty recurrence rule")
+	}
+
+	r := &Rule{Interval: 1, start: start.Truncate(time.Second)}
+	for _, part := range strings.Split(rule, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 || len(kv[1]) == 0 {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+
+		key, value := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		var err error
+		switch key {
+		case "FREQ":
+			switch value {
+			case Minutely, Hourly, Daily, Weekly, Monthly, Yearly:
+				r.Freq = value
+			default:
+				return nil, fmt.Errorf("unsupported frequency %q", value)
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err == nil && r.Interval < 1 {
+				err = errors.New("must be positive")
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err == nil && r.Count < 1 {
+				err = errors.New("must be positive")
+			}
+		case "UNTIL":
+			r.Until, err = parseUntil(value, start.Location())
+		case "BYMONTH":
+			r.ByMonth, err = parseInts(value, 1, 12, false)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseInts(value, 1, 31, true)
+		case "BYHOUR":
+			r.ByHour, err = parseInts(value, 0, 23, false)
+		case "BYMINUTE":
+			r.ByMinute, err = parseInts(value, 0, 59, false)
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				wd, ok := weekdays[day]
+				if !ok {
+					return nil, fmt.Errorf("unsupported BYDAY value %q", day)
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		case "WKST":
+			// weeks always start on monday
+		default:
+			return nil, fmt.Errorf("unsupported rule part %q", key)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", key, err)
+		}
+	}
+
+	if len(r.Freq) == 0 {
+		return nil, errors.New("FREQ is required")
+	}
+
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, errors.New("COUNT and UNTIL are mutually exclusive")
+	}
+
+	return r, nil
+}
+
+// Next returns the first occurrence of the rule strictly after t
+func (r *Rule) Next(t time.Time) (time.Time, bool) {
+	period := 0
+	// without COUNT the occurrences before t do not matter, fixed length
+	// periods can be skipped up to the period right before t
+	if r.Count == 0 && t.After(r.start) {
+		if d := r.fixedPeriod(); d > 0 {
+			period = int(t.Sub(r.start)/d) - 1
+			if period < 0 {
+				period = 0
+			}
+		}
+	}
+
+	count := 0
+	for i := 0; i < maxPeriods; i, period = i+1, period+1 {
+		for _, occ := range r.expand(period) {
+			if occ.Before(r.start) {
+				continue
+			}
+			if !r.Until.IsZero() && occ.After(r.Until) {
+				return time.Time{}, false
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return time.Time{}, false
+			}
+			if occ.After(t) {
+				return occ, true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// fixedPeriod returns the length of a period for frequencies that are
+// not affected by the calendar, a day is considered to be 24 hours
+func (r *Rule) fixedPeriod() time.Duration {
+	var d time.Duration
+	switch r.Freq {
+	case Minutely:
+		d = time.Minute
+	case Hourly:
+		d = time.Hour
+	case Daily:
+		d = 24 * time.Hour
+	case Weekly:
+		d = 7 * 24 * time.Hour
+	default:
+		return 0
+	}
+	return d * time.Duration(r.Interval)
+}
+
+// expand returns the sorted occurrences of the n-th period of the rule
+func (r *Rule) expand(n int) []time.Time {
+	s := r.start
+	loc := s.Location()
+	step := n * r.Interval
+
+	var days []time.Time
+	switch r.Freq {
+	case Minutely:
+		return r.filter([]time.Time{s.Add(time.Duration(step) * time.Minute)})
+	case Hourly:
+		return r.filter([]time.Time{s.Add(time.Duration(step) * time.Hour)})
+	case Daily:
+		days = []time.Time{date(s.Year(), s.Month(), s.Day()+step, loc)}
+	case Weekly:
+		day := date(s.Year(), s.Month(), s.Day()+7*step, loc)
+		if len(r.ByDay) == 0 {
+			days = []time.Time{day}
+			break
+		}
+		// weeks start on monday
+		monday := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		for i := 0; i < 7; i++ {
+			days = append(days, monday.AddDate(0, 0, i))
+		}
+	case Monthly:
+		first := date(s.Year(), s.Month()+time.Month(step), 1, loc)
+		days = r.monthDays(first)
+	case Yearly:
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []int{int(s.Month())}
+		}
+		for _, m := range months {
+			days = append(days, r.monthDays(date(s.Year()+step, time.Month(m), 1, loc))...)
+		}
+	}
+
+	hours := r.ByHour
+	if len(hours) == 0 {
+		hours = []int{s.Hour()}
+	}
+	minutes := r.ByMinute
+	if len(minutes) == 0 {
+		minutes = []int{s.Minute()}
+	}
+
+	var occurrences []time.Time
+	for _, day := range days {
+		for _, h := range hours {
+			for _, m := range minutes {
+				occurrences = append(occurrences, time.Date(day.Year(), day.Month(), day.Day(),
+					h, m, s.Second(), 0, loc))
+			}
+		}
+	}
+
+	return r.filter(occurrences)
+}
+
+// monthDays returns the days of the month starting at first that match
+// BYMONTHDAY or BYDAY, the day of the start time is used otherwise
+func (r *Rule) monthDays(first time.Time) []time.Time {
+	last := first.AddDate(0, 1, -1).Day()
+
+	var days []time.Time
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, d := range r.ByMonthDay {
+			if d < 0 {
+				d = last + d + 1
+			}
+			if d >= 1 && d <= last {
+				days = append(days, first.AddDate(0, 0, d-1))
+			}
+		}
+	case len(r.ByDay) > 0:
+		for d := 0; d < last; d++ {
+			days = append(days, first.AddDate(0, 0, d))
+		}
+	default:
+		// months without the day of the start time are skipped
+		if d := r.start.Day(); d <= last {
+			days = append(days, first.AddDate(0, 0, d-1))
+		}
+	}
+	return days
+}
+
+// filter removes the occurrences that do not match the BYxxx parts
+// and sorts the remaining ones
+func (r *Rule) filter(occurrences []time.Time) []time.Time {
+	var out []time.Time
+	for _, occ := range occurrences {
+		if len(r.ByMonth) > 0 && !containsInt(r.ByMonth, int(occ.Month())) {
+			continue
+		}
+		if len(r.ByDay) > 0 && !containsWeekday(r.ByDay, occ.Weekday()) {
+			continue
+		}
+		if r.Freq == Minutely || r.Freq == Hourly {
+			if len(r.ByHour) > 0 && !containsInt(r.ByHour, occ.Hour()) {
+				continue
+			}
+			if len(r.ByMinute) > 0 && !containsInt(r.ByMinute, occ.Minute()) {
+				continue
+			}
+		}
+		out = append(out, occ)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return out
+}
+
+func date(year int, month time.Month, day int, loc *time.Location) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, loc)
+}
+
+// parseUntil parses an UNTIL value in UTC (20170101T000000Z),
+// local (20170101T000000) or date (20170101) form
+func parseUntil(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("20060102T150405", value, loc); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("20060102", value, loc)
+	if err != nil {
+		return t, err
+	}
+	// a date includes the whole day
+	return t.AddDate(0, 0, 1).Add(-time.Second), nil
+}
+
+// parseInts parses a comma separated list of integers between min and max,
+// negative values down to -max are accepted when negative is true
+func parseInts(value string, min, max int, negative bool) ([]int, error) {
+	var out []int
+	for _, v := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		if negative && n < 0 && n >= -max {
+			out = append(out, n)
+			continue
+		}
+		if n < min || n > max {
+			return nil, fmt.Errorf("%d is out of range", n)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+func containsInt(s []int, v int) bool {
+	for _, i := range s {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsWeekday(s []time.Weekday, v time.Weekday) bool {
+	for _, i := range s {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
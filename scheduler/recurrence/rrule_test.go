@@ -0,0 +1,115 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RRuleTestSuite struct {
+	suite.Suite
+	loc *time.Location
+}
+
+func (suite *RRuleTestSuite) SetupTest() {
+	suite.loc = time.FixedZone("UTC+5:30", 5*3600+1800)
+}
+
+// occurrences returns the first n occurrences of r after t
+func occurrences(r Recurrence, t time.Time, n int) []time.Time {
+	var out []time.Time
+	for i := 0; i < n; i++ {
+		next, ok := r.Next(t)
+		if !ok {
+			break
+		}
+		out = append(out, next)
+		t = next
+	}
+	return out
+}
+
+func (suite *RRuleTestSuite) TestParseErrors() {
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, rule := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=SECONDLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;BYDAY=XX",
+		"FREQ=DAILY;COUNT=2;UNTIL=20170201",
+		"FREQ=DAILY;BYSETPOS=1",
+	} {
+		_, err := ParseRRule(rule, start)
+		suite.Error(err, rule)
+	}
+}
+
+func (suite *RRuleTestSuite) TestDaily() {
+	start := time.Date(2017, 3, 1, 2, 30, 0, 0, suite.loc)
+	r, err := ParseRRule("RRULE:FREQ=DAILY;INTERVAL=2", start)
+	suite.NoError(err)
+
+	suite.Equal([]time.Time{
+		start,
+		start.AddDate(0, 0, 2),
+		start.AddDate(0, 0, 4),
+	}, occurrences(r, start.Add(-time.Second), 3))
+
+	// skip ahead without COUNT
+	next, ok := r.Next(time.Date(2018, 3, 1, 0, 0, 0, 0, suite.loc))
+	suite.True(ok)
+	suite.Equal(time.Date(2018, 3, 2, 2, 30, 0, 0, suite.loc), next)
+}
+
+func (suite *RRuleTestSuite) TestWeeklyByDay() {
+	// wednesday
+	start := time.Date(2017, 3, 1, 9, 0, 0, 0, suite.loc)
+	r, err := ParseRRule("FREQ=WEEKLY;BYDAY=MO,FR", start)
+	suite.NoError(err)
+
+	suite.Equal([]time.Time{
+		time.Date(2017, 3, 3, 9, 0, 0, 0, suite.loc),
+		time.Date(2017, 3, 6, 9, 0, 0, 0, suite.loc),
+		time.Date(2017, 3, 10, 9, 0, 0, 0, suite.loc),
+	}, occurrences(r, start, 3))
+}
+
+func (suite *RRuleTestSuite) TestMonthly() {
+	start := time.Date(2017, 1, 31, 23, 0, 0, 0, suite.loc)
+	r, err := ParseRRule("FREQ=MONTHLY", start)
+	suite.NoError(err)
+
+	// months without a 31st are skipped
+	suite.Equal([]time.Time{
+		start,
+		time.Date(2017, 3, 31, 23, 0, 0, 0, suite.loc),
+	}, occurrences(r, start.Add(-time.Second), 2))
+
+	r, err = ParseRRule("FREQ=MONTHLY;BYMONTHDAY=1,-1;BYHOUR=6", start)
+	suite.NoError(err)
+	suite.Equal([]time.Time{
+		time.Date(2017, 2, 1, 6, 0, 0, 0, suite.loc),
+		time.Date(2017, 2, 28, 6, 0, 0, 0, suite.loc),
+		time.Date(2017, 3, 1, 6, 0, 0, 0, suite.loc),
+	}, occurrences(r, start, 3))
+}
+
+func (suite *RRuleTestSuite) TestCountAndUntil() {
+	start := time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC)
+	r, err := ParseRRule("FREQ=HOURLY;COUNT=2", start)
+	suite.NoError(err)
+	suite.Len(occurrences(r, start.Add(-time.Second), 5), 2)
+
+	r, err = ParseRRule("FREQ=DAILY;UNTIL=20170303T000000Z", start)
+	suite.NoError(err)
+	suite.Len(occurrences(r, start.Add(-time.Second), 5), 3)
+
+	_, ok := r.Next(time.Date(2017, 3, 3, 0, 0, 0, 0, time.UTC))
+	suite.False(ok)
+}
+
+func TestRRuleSuite(t *testing.T) {
+	suite.Run(t, new(RRuleTestSuite))
+}
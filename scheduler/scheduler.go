@@ -0,0 +1,316 @@
+// Package scheduler launches the job templates, terraform job templates and
+// project updates of the schedules that are due
+package scheduler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pearsonappeng/tensor/db"
+	execansible "github.com/pearsonappeng/tensor/exec/ansible"
+	"github.com/pearsonappeng/tensor/exec/sync"
+	execterraform "github.com/pearsonappeng/tensor/exec/terraform"
+	"github.com/pearsonappeng/tensor/models/ansible"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/models/terraform"
+	"github.com/pearsonappeng/tensor/rbac"
+	"github.com/pearsonappeng/tensor/scheduler/recurrence"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// interval between two lookups of due schedules
+const interval = 30 * time.Second
+
+// errPermission is returned when the owner of the schedule
+// can no longer launch its resource
+var errPermission = errors.New("Schedule owner can no longer launch the resource")
+
+// Run checks for due schedules until the process exits
+func Run() {
+	logrus.Infoln("Starting scheduler")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	runDue(time.Now())
+	for now := range ticker.C {
+		runDue(now)
+	}
+}
+
+// runDue launches every enabled schedule whose next run is before now
+func runDue(now time.Time) {
+	var schedules []common.Schedule
+	if err := db.Schedules().Find(bson.M{
+		"enabled":  true,
+		"next_run": bson.M{"$lte": now},
+	}).All(&schedules); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Errorln("Error while getting due schedules")
+		return
+	}
+
+	for _, schedule := range schedules {
+		claimed, err := claim(schedule, now)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Schedule ID": schedule.ID.Hex(),
+				"Error":       err.Error(),
+			}).Errorln("Error while updating schedule")
+			continue
+		}
+
+		// another instance of tensor launched this occurrence
+		if !claimed {
+			continue
+		}
+
+		jobID, err := launch(schedule)
+		if err == errPermission {
+			disable(schedule)
+		} else if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"Schedule ID": schedule.ID.Hex(),
+				"Error":       err.Error(),
+			}).Errorln("Error while launching schedule")
+		} else if jobID != nil {
+			if err := db.Schedules().UpdateId(schedule.ID, bson.M{"$set": bson.M{"last_job_id": *jobID}}); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"Schedule ID": schedule.ID.Hex(),
+					"Error":       err.Error(),
+				}).Errorln("Error while updating schedule")
+			}
+		}
+
+		UpdateNextJobRun(schedule.ResourceType, schedule.ResourceID)
+	}
+}
+
+// claim moves the next run of the schedule to its following occurrence.
+// The update only matches while next_run is unchanged, so when several
+// instances of tensor share the database a single one launches the job.
+func claim(s common.Schedule, now time.Time) (bool, error) {
+	set := bson.M{"last_run": now, "modified": now}
+	update := bson.M{"$set": set}
+
+	next, err := NextRun(s, now)
+	if err != nil {
+		return false, err
+	}
+	if next != nil {
+		set["next_run"] = *next
+	} else {
+		// the schedule has no more occurrences
+		update["$unset"] = bson.M{"next_run": ""}
+	}
+
+	err = db.Schedules().Update(bson.M{"_id": s.ID, "next_run": s.NextRun}, update)
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// disable turns off a schedule whose owner lost the permissions to launch it
+func disable(s common.Schedule) {
+	logrus.WithFields(logrus.Fields{
+		"Schedule ID": s.ID.Hex(),
+		"User ID":     s.CreatedByID.Hex(),
+	}).Warnln("Disabling schedule, its owner can no longer launch the resource")
+
+	if err := db.Schedules().UpdateId(s.ID, bson.M{"$set": bson.M{"enabled": false}}); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Schedule ID": s.ID.Hex(),
+			"Error":       err.Error(),
+		}).Errorln("Error while disabling schedule")
+	}
+}
+
+// launch starts the job of the schedule on behalf of the user that
+// created the schedule and returns the id of the job. The permissions
+// of the user are checked again for every occurrence.
+func launch(s common.Schedule) (*bson.ObjectId, error) {
+	var user common.User
+	if err := db.Users().FindId(s.CreatedByID).One(&user); err != nil {
+		return nil, errors.New("Error while getting schedule owner")
+	}
+
+	switch s.ResourceType {
+	case common.ScheduleJobTemplate:
+		var template ansible.JobTemplate
+		if err := db.JobTemplates().FindId(s.ResourceID).One(&template); err != nil {
+			return nil, errors.New("Error while getting job template")
+		}
+		if !new(rbac.JobTemplate).Launch(user, template) {
+			return nil, errPermission
+		}
+
+		job := execansible.NewJob(template, user)
+		job.LaunchType = ansible.JOB_LAUNCH_TYPE_SCHEDULED
+		job.ExtraVars = mergeVars(template.ExtraVars, s.ExtraVars)
+		if err := execansible.Launch(job, template, user); err != nil {
+			return nil, err
+		}
+		return &job.ID, nil
+
+	case common.ScheduleTerraformJobTemplate:
+		var template terraform.JobTemplate
+		if err := db.TerrafromJobTemplates().FindId(s.ResourceID).One(&template); err != nil {
+			return nil, errors.New("Error while getting terraform job template")
+		}
+		if !new(rbac.TerraformJobTemplate).Launch(user, template) {
+			return nil, errPermission
+		}
+
+		job := execterraform.NewJob(template, user)
+		job.LaunchType = terraform.JobLaunchTypeScheduled
+		job.Vars = mergeVars(template.Vars, s.ExtraVars)
+		if err := execterraform.Launch(job, template, user); err != nil {
+			return nil, err
+		}
+		return &job.ID, nil
+
+	case common.ScheduleProject:
+		var project common.Project
+		if err := db.Projects().FindId(s.ResourceID).One(&project); err != nil {
+			return nil, errors.New("Error while getting project")
+		}
+		if !new(rbac.Project).Update(user, project) {
+			return nil, errPermission
+		}
+
+		job, err := sync.UpdateProject(project)
+		if err != nil {
+			return nil, err
+		}
+		return &job.Job.ID, nil
+	}
+
+	return nil, errors.New("Unknown schedule resource type " + s.ResourceType)
+}
+
+// mergeVars returns the template variables overridden by the schedule variables
+func mergeVars(template, schedule map[string]interface{}) map[string]interface{} {
+	if len(schedule) == 0 {
+		return template
+	}
+
+	vars := map[string]interface{}{}
+	for k, v := range template {
+		vars[k] = v
+	}
+	for k, v := range schedule {
+		vars[k] = v
+	}
+	return vars
+}
+
+// NextRun returns the first occurrence of the schedule after the given time,
+// nil is returned when the schedule has no more occurrences.
+// An error is returned when the recurrence of the schedule is invalid.
+func NextRun(s common.Schedule, after time.Time) (*time.Time, error) {
+	loc := time.UTC
+	if len(s.TimeZone) > 0 {
+		var err error
+		if loc, err = time.LoadLocation(s.TimeZone); err != nil {
+			return nil, errors.New("Invalid timezone " + s.TimeZone)
+		}
+	}
+
+	start := s.Start.In(loc)
+
+	var r recurrence.Recurrence
+	switch {
+	case len(s.RRule) > 0 && len(s.Cron) > 0:
+		return nil, errors.New("Only one of rrule and cron can be set")
+	case len(s.RRule) > 0:
+		rule, err := recurrence.ParseRRule(s.RRule, start)
+		if err != nil {
+			return nil, errors.New("Invalid rrule: " + err.Error())
+		}
+		r = rule
+	case len(s.Cron) > 0:
+		cron, err := recurrence.ParseCron(s.Cron, loc)
+		if err != nil {
+			return nil, errors.New("Invalid cron: " + err.Error())
+		}
+		r = cron
+	default:
+		return nil, errors.New("One of rrule and cron is required")
+	}
+
+	// occurrences are never before the start of the schedule
+	if after.Before(start) {
+		after = start.Add(-time.Second)
+	}
+
+	next, ok := r.Next(after)
+	if !ok || (s.End != nil && next.After(*s.End)) {
+		return nil, nil
+	}
+
+	next = next.UTC()
+	return &next, nil
+}
+
+// UpdateNextJobRun refreshes the next job run of a job template,
+// terraform job template or project from its enabled schedules
+func UpdateNextJobRun(resourceType string, resourceID bson.ObjectId) {
+	var schedules []common.Schedule
+	if err := db.Schedules().Find(bson.M{
+		"resource_type": resourceType,
+		"resource_id":   resourceID,
+	}).Select(bson.M{"_id": 1, "enabled": 1, "next_run": 1}).All(&schedules); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Resource ID": resourceID.Hex(),
+			"Error":       err.Error(),
+		}).Errorln("Error while getting schedules")
+		return
+	}
+
+	var next *common.Schedule
+	for i, schedule := range schedules {
+		if !schedule.Enabled || schedule.NextRun == nil {
+			continue
+		}
+		if next == nil || schedule.NextRun.Before(*next.NextRun) {
+			next = &schedules[i]
+		}
+	}
+
+	set := bson.M{"has_schedules": len(schedules) > 0}
+	update := bson.M{"$set": set}
+	if next != nil {
+		set["next_job_run"] = *next.NextRun
+		if resourceType != common.ScheduleProject {
+			set["next_schedule_id"] = next.ID
+		}
+	} else {
+		unset := bson.M{"next_job_run": ""}
+		if resourceType != common.ScheduleProject {
+			unset["next_schedule_id"] = ""
+		}
+		update["$unset"] = unset
+	}
+
+	var c *mgo.Collection
+	switch resourceType {
+	case common.ScheduleJobTemplate:
+		c = db.JobTemplates()
+	case common.ScheduleTerraformJobTemplate:
+		c = db.TerrafromJobTemplates()
+	case common.ScheduleProject:
+		c = db.Projects()
+	default:
+		return
+	}
+
+	if err := c.UpdateId(resourceID, update); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Resource ID": resourceID.Hex(),
+			"Error":       err.Error(),
+		}).Errorln("Error while updating next job run")
+	}
+}
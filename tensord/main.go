@@ -20,10 +20,15 @@ import (
 	"github.com/pearsonappeng/tensor/db"
 	"github.com/pearsonappeng/tensor/exec/ansible"
 	"github.com/pearsonappeng/tensor/exec/terraform"
+	"github.com/pearsonappeng/tensor/exec/workflow"
 	"github.com/pearsonappeng/tensor/log"
+	"github.com/pearsonappeng/tensor/models/common"
 	"github.com/pearsonappeng/tensor/queue"
+	"github.com/pearsonappeng/tensor/reaper"
+	"github.com/pearsonappeng/tensor/scheduler"
 	"github.com/pearsonappeng/tensor/util"
 	"github.com/pearsonappeng/tensor/validate"
+	"github.com/pearsonappeng/tensor/worker"
 	"gopkg.in/gin-gonic/gin.v1/binding"
 )
 
@@ -44,14 +49,29 @@ func main() {
 	}
 	defer db.MongoDb.Session.Close()
 
-	// Test Connectivity to RabbitMQ
+	// Test Connectivity to the queue backend
 	if err := queue.TestConnect(); err != nil {
 		logrus.WithFields(logrus.Fields{
-			"Error": err.Error(),
-		}).Fatalln("Unable to initialize a connection to RabbitMQ")
+			"Backend": util.Config.QueueBackend,
+			"Error":   err.Error(),
+		}).Fatalln("Unable to initialize a connection to the queue backend")
 		os.Exit(1)
 	}
 
+	logrus.Infoln("Mode:", util.Config.Mode)
+
+	// every process finishes the jobs of stopped workers
+	go reaper.Run()
+
+	if util.Config.RunWorker() {
+		runWorkers()
+	}
+
+	// worker only processes have no HTTP listener
+	if !util.Config.RunAPI() {
+		select {}
+	}
+
 	// Define custom validator
 	binding.Validator = &validate.Validator{}
 	r := gin.New()
@@ -67,8 +87,8 @@ func main() {
 	api.Route(r)
 
 	//Background tasks
-	go ansible.Run()
-	go terraform.Run()
+	go scheduler.Run()
+	go workflow.Run()
 
 	if util.Config.TLSEnabled {
 		if err := r.RunTLS(util.Config.GetAddress(), util.Config.SSLCertificate, util.Config.SSLCertificateKey); err != nil {
@@ -84,3 +104,34 @@ func main() {
 		}
 	}
 }
+
+// runWorkers starts the consumers of the queues handled by this process
+// and registers the process as a worker
+func runWorkers() {
+	for _, name := range util.Config.Queues {
+		if name != queue.Ansible && name != queue.Terraform {
+			logrus.WithFields(logrus.Fields{
+				"Queue": name,
+			}).Fatalln("Unknown queue")
+			os.Exit(1)
+		}
+	}
+
+	queues := []common.WorkerQueue{}
+	if util.Config.RunQueue(queue.Ansible) {
+		queues = append(queues, common.WorkerQueue{Queue: queue.Ansible, Workers: util.Config.AnsibleWorkers})
+		go ansible.Run()
+	}
+	if util.Config.RunQueue(queue.Terraform) {
+		queues = append(queues, common.WorkerQueue{Queue: queue.Terraform, Workers: util.Config.TerraformWorkers})
+		go terraform.Run()
+	}
+
+	if err := worker.Register(queues); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Error": err.Error(),
+		}).Fatalln("Unable to register the worker")
+		os.Exit(1)
+	}
+	go worker.Run()
+}
@@ -0,0 +1,121 @@
+package util
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ansiEscape matches the ANSI escape sequences that ansible and terraform
+// write to a terminal, submatch 1 holds the parameters of SGR sequences
+var ansiEscape = regexp.MustCompile(`\x1b\[([0-9;?]*)([A-Za-z])`)
+
+// ansiColors are the names of the eight basic colors in the order of their codes
+var ansiColors = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// ANSIStylesheet holds the CSS rules for the classes used by ANSIToHTML
+const ANSIStylesheet = `body { background: #161b1f; color: #d7d7d7; }
+pre { font-family: monospace; white-space: pre-wrap; }
+.ansi-bold { font-weight: bold; }
+.ansi-black { color: #4d4d4d; } .ansi-bg-black { background: #000000; }
+.ansi-red { color: #d9534f; } .ansi-bg-red { background: #d9534f; }
+.ansi-green { color: #5cb85c; } .ansi-bg-green { background: #5cb85c; }
+.ansi-yellow { color: #f0ad4e; } .ansi-bg-yellow { background: #f0ad4e; }
+.ansi-blue { color: #337ab7; } .ansi-bg-blue { background: #337ab7; }
+.ansi-magenta { color: #e25ec1; } .ansi-bg-magenta { background: #e25ec1; }
+.ansi-cyan { color: #2dbaba; } .ansi-bg-cyan { background: #2dbaba; }
+.ansi-white { color: #ffffff; } .ansi-bg-white { background: #ffffff; }
+.ansi-bright-black { color: #7f7f7f; } .ansi-bright-red { color: #ff6f6b; }
+.ansi-bright-green { color: #79ef79; } .ansi-bright-yellow { color: #ffd25a; }
+.ansi-bright-blue { color: #5ca8ff; } .ansi-bright-magenta { color: #ff7fe0; }
+.ansi-bright-cyan { color: #5ff2f2; } .ansi-bright-white { color: #ffffff; }`
+
+// StripANSI removes the ANSI escape sequences from s
+func StripANSI(s string) string {
+	return ansiEscape.ReplaceAllString(s, "")
+}
+
+// ansiState is the graphic rendition in effect at a point of the output
+type ansiState struct {
+	bold bool
+	fg   string
+	bg   string
+}
+
+// apply updates the state with the parameters of a SGR sequence
+func (state *ansiState) apply(params string) {
+	for _, param := range strings.Split(params, ";") {
+		code, err := strconv.Atoi(param)
+		if err != nil && len(param) > 0 {
+			continue
+		}
+		switch {
+		case code == 0:
+			*state = ansiState{}
+		case code == 1:
+			state.bold = true
+		case code == 22:
+			state.bold = false
+		case code >= 30 && code <= 37:
+			state.fg = "ansi-" + ansiColors[code-30]
+		case code == 39:
+			state.fg = ""
+		case code >= 40 && code <= 47:
+			state.bg = "ansi-bg-" + ansiColors[code-40]
+		case code == 49:
+			state.bg = ""
+		case code >= 90 && code <= 97:
+			state.fg = "ansi-bright-" + ansiColors[code-90]
+		}
+	}
+}
+
+// classes returns the CSS classes for the state
+func (state ansiState) classes() string {
+	var classes []string
+	if state.bold {
+		classes = append(classes, "ansi-bold")
+	}
+	if len(state.fg) > 0 {
+		classes = append(classes, state.fg)
+	}
+	if len(state.bg) > 0 {
+		classes = append(classes, state.bg)
+	}
+	return strings.Join(classes, " ")
+}
+
+// ANSIToHTML converts the colors and bold text of s to HTML span elements
+// with the classes of ANSIStylesheet. The text is HTML escaped and escape
+// sequences that do not set colors are removed.
+func ANSIToHTML(s string) string {
+	var b bytes.Buffer
+	var state ansiState
+	open := false
+
+	last := 0
+	for _, m := range ansiEscape.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(html.EscapeString(s[last:m[0]]))
+		last = m[1]
+		if s[m[4]:m[5]] != "m" {
+			continue
+		}
+
+		state.apply(s[m[2]:m[3]])
+		if open {
+			b.WriteString("</span>")
+			open = false
+		}
+		if classes := state.classes(); len(classes) > 0 {
+			b.WriteString(`<span class="` + classes + `">`)
+			open = true
+		}
+	}
+	b.WriteString(html.EscapeString(s[last:]))
+	if open {
+		b.WriteString("</span>")
+	}
+	return b.String()
+}
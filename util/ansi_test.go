@@ -0,0 +1,27 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripANSI(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("ok: [localhost]\n", StripANSI("\x1b[0;32mok: [localhost]\x1b[0m\n"))
+	assert.Equal("changed", StripANSI("\x1b[1;33mchanged\x1b[0m\x1b[K"))
+	assert.Equal("plain", StripANSI("plain"))
+}
+
+func TestANSIToHTML(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(`<span class="ansi-green">ok: [localhost]</span>`+"\n",
+		ANSIToHTML("\x1b[0;32mok: [localhost]\x1b[0m\n"))
+	assert.Equal(`<span class="ansi-bold ansi-red">fatal</span> &lt;a&gt;`,
+		ANSIToHTML("\x1b[1;31mfatal\x1b[0m <a>"))
+	assert.Equal(`<span class="ansi-bright-blue">a</span><span class="ansi-bright-blue ansi-bg-white">b</span>`,
+		ANSIToHTML("\x1b[94ma\x1b[47mb"))
+	assert.Equal("cleared", ANSIToHTML("\x1b[2Kcleared"))
+}
@@ -18,6 +18,16 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+// Run modes of tensord
+const (
+	// ModeAPI serves the REST API and schedules jobs
+	ModeAPI = "api"
+	// ModeWorker runs the jobs of the queues
+	ModeWorker = "worker"
+	// ModeAll runs both roles in one process
+	ModeAll = "all"
+)
+
 var InteractiveSetup bool
 var Secrets bool
 
@@ -33,6 +43,13 @@ type configType struct {
 	MongoDB MongoDBConfig `yaml:"mongodb"`
 
 	RabbitMQ string `yaml:"rabbitmq"`
+	// Backend of the job queues, amqp or mongodb
+	QueueBackend string `yaml:"queue_backend"`
+
+	// Roles of the process, api, worker or all
+	Mode string `yaml:"mode"`
+	// Queues consumed by the workers, all queues if empty
+	Queues []string `yaml:"queues"`
 
 	// TCP address to listen on, ":http" if empty
 	Host string `yaml:"host"`
@@ -48,6 +65,10 @@ type configType struct {
 	SyncJobTimeOut      int `yaml:"sync_job_timeout"`
 	TerraformJobTimeOut int `yaml:"terraform_job_timeout"`
 
+	// number of jobs each queue runs at the same time
+	AnsibleWorkers   int `yaml:"ansible_workers"`
+	TerraformWorkers int `yaml:"terraform_workers"`
+
 	JWTTimeout        int `yaml:"jwt_timeout"`
 	JWTRefreshTimeout int `yaml:"jwt_refresh_timeout"`
 
@@ -58,6 +79,43 @@ type configType struct {
 	Debug bool `yaml:"debug"`
 }
 
+// RunAPI reports whether the process serves the REST API
+func (c configType) RunAPI() bool {
+	return c.Mode == ModeAPI || c.Mode == ModeAll
+}
+
+// RunWorker reports whether the process runs jobs
+func (c configType) RunWorker() bool {
+	return c.Mode == ModeWorker || c.Mode == ModeAll
+}
+
+// RunQueue reports whether the workers of the process consume the queue
+func (c configType) RunQueue(name string) bool {
+	if !c.RunWorker() {
+		return false
+	}
+	if len(c.Queues) == 0 {
+		return true
+	}
+	for _, q := range c.Queues {
+		if q == name {
+			return true
+		}
+	}
+	return false
+}
+
+// queueNames trims the names of the queues and drops the empty ones
+func queueNames(queues []string) []string {
+	names := []string{}
+	for _, q := range queues {
+		if name := strings.TrimSpace(q); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 func (c configType) GetAddress() string {
 	return c.Host + ":" + c.Port
 }
@@ -108,6 +166,25 @@ func init() {
 		}
 	}
 
+	// the run mode can be given as a subcommand, e.g. tensord worker
+	if flag.NArg() > 0 {
+		Config.Mode = flag.Arg(0)
+	} else if len(os.Getenv("TENSOR_MODE")) > 0 {
+		Config.Mode = os.Getenv("TENSOR_MODE")
+	} else if len(Config.Mode) == 0 {
+		Config.Mode = ModeAll
+	}
+
+	if Config.Mode != ModeAll && Config.Mode != ModeAPI && Config.Mode != ModeWorker {
+		logrus.Fatal("Invalid mode " + Config.Mode + ", expected one of api, worker or all")
+		os.Exit(6)
+	}
+
+	if len(os.Getenv("TENSOR_QUEUES")) > 0 {
+		Config.Queues = strings.Split(os.Getenv("TENSOR_QUEUES"), ",")
+	}
+	Config.Queues = queueNames(Config.Queues)
+
 	if len(os.Getenv("TENSOR_HOST")) > 0 {
 		Config.Host = os.Getenv("TENSOR_HOST")
 	} else if len(Config.Host) == 0 {
@@ -153,6 +230,22 @@ func init() {
 		Config.SyncJobTimeOut = 3600
 	}
 
+	if len(os.Getenv("TENSOR_ANSIBLE_WORKERS")) > 0 {
+		workers, _ := strconv.Atoi(os.Getenv("TENSOR_ANSIBLE_WORKERS"))
+		Config.AnsibleWorkers = workers
+	}
+	if Config.AnsibleWorkers < 1 {
+		Config.AnsibleWorkers = 1
+	}
+
+	if len(os.Getenv("TENSOR_TERRAFORM_WORKERS")) > 0 {
+		workers, _ := strconv.Atoi(os.Getenv("TENSOR_TERRAFORM_WORKERS"))
+		Config.TerraformWorkers = workers
+	}
+	if Config.TerraformWorkers < 1 {
+		Config.TerraformWorkers = 1
+	}
+
 	if len(os.Getenv("TENSOR_JWT_TIMEOUT")) > 0 {
 		time, _ := strconv.Atoi(os.Getenv("TENSOR_JWT_TIMEOUT"))
 		Config.JWTTimeout = time
@@ -191,6 +284,17 @@ func init() {
 		Config.RabbitMQ = os.Getenv("TENSOR_RABBITMQ")
 	}
 
+	if len(os.Getenv("TENSOR_QUEUE_BACKEND")) > 0 {
+		Config.QueueBackend = os.Getenv("TENSOR_QUEUE_BACKEND")
+	} else if len(Config.QueueBackend) == 0 {
+		Config.QueueBackend = "amqp"
+	}
+
+	if Config.QueueBackend != "amqp" && Config.QueueBackend != "mongodb" {
+		logrus.Fatal("Invalid queue backend " + Config.QueueBackend + ", expected amqp or mongodb")
+		os.Exit(6)
+	}
+
 	// TLS configuration
 	if os.Getenv("TENSOR_TLS_ENABLED") == "true" {
 		Config.TLSEnabled = true
@@ -0,0 +1,15 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueNames(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]string{"ansible", "terraform"}, queueNames([]string{"ansible", " terraform"}))
+	assert.Equal([]string{"terraform"}, queueNames([]string{"", " terraform ", " "}))
+	assert.Empty(queueNames(nil))
+}
@@ -20,12 +20,18 @@ import (
 
 const (
 	Become           string = "^(sudo|su|pbrun|pfexec|runas|doas|dzdo)$"
-	CredentialKind   string = "^(windows|ssh|net|scm|aws|rax|vmware|satellite6|cloudforms|gce|azure|openstack)$"
+	CredentialKind   string = "^(windows|ssh|net|scm|aws|rax|vmware|satellite6|cloudforms|gce|azure|openstack|vault)$"
 	ScmType          string = "^(manual|git|hg|svn)$"
 	JobType          string = "^(run|check|scan)$"
 	ProjectKind      string = "^(ansible|terraform)$"
 	TerraformJobType string = "^(plan|apply|destroy|destroy_plan)$"
 	ResourceType     string = "^(credential|organization|team|project|job_template|terraform_job_template|inventory)$"
+	NotificationType string = "^(email|webhook|slack)$"
+	InventorySource  string = "^(ec2|gce|azure_rm|openstack|vmware|rax|satellite6|cloudforms|custom)$"
+	NetworkConn      string = "^(network_cli|netconf|httpapi)$"
+	NetworkOS        string = `^[a-zA-Z0-9_]+(\.[a-zA-Z0-9_]+)*$`
+	WinRMTransport   string = "^(kerberos|ntlm)$"
+	Krb5Value        string = `^[a-zA-Z0-9_.:\[\]-]{1,255}$`
 
 	DNSName      string = `^([a-zA-Z0-9]{1}[a-zA-Z0-9_-]{1,62}){1}(\.[a-zA-Z0-9]{1}[a-zA-Z0-9_-]{1,62})*$`
 	IP           string = `(([0-9a-fA-F]{1,4}:){7,7}[0-9a-fA-F]{1,4}|([0-9a-fA-F]{1,4}:){1,7}:|([0-9a-fA-F]{1,4}:){1,6}:[0-9a-fA-F]{1,4}|([0-9a-fA-F]{1,4}:){1,5}(:[0-9a-fA-F]{1,4}){1,2}|([0-9a-fA-F]{1,4}:){1,4}(:[0-9a-fA-F]{1,4}){1,3}|([0-9a-fA-F]{1,4}:){1,3}(:[0-9a-fA-F]{1,4}){1,4}|([0-9a-fA-F]{1,4}:){1,2}(:[0-9a-fA-F]{1,4}){1,5}|[0-9a-fA-F]{1,4}:((:[0-9a-fA-F]{1,4}){1,6})|:((:[0-9a-fA-F]{1,4}){1,7}|:)|fe80:(:[0-9a-fA-F]{0,4}){0,4}%[0-9a-zA-Z]{1,}|::(ffff(:0{1,4}){0,1}:){0,1}((25[0-5]|(2[0-4]|1{0,1}[0-9]){0,1}[0-9])\.){3,3}(25[0-5]|(2[0-4]|1{0,1}[0-9]){0,1}[0-9])|([0-9a-fA-F]{1,4}:){1,4}:((25[0-5]|(2[0-4]|1{0,1}[0-9]){0,1}[0-9])\.){3,3}(25[0-5]|(2[0-4]|1{0,1}[0-9]){0,1}[0-9]))`
@@ -52,6 +58,12 @@ var (
 	rxProjectKind      = regexp.MustCompile(ProjectKind)
 	rxTerraformJobType = regexp.MustCompile(TerraformJobType)
 	rxResourceType     = regexp.MustCompile(ResourceType)
+	rxNotificationType = regexp.MustCompile(NotificationType)
+	rxInventorySource  = regexp.MustCompile(InventorySource)
+	rxNetworkConn      = regexp.MustCompile(NetworkConn)
+	rxNetworkOS        = regexp.MustCompile(NetworkOS)
+	rxWinRMTransport   = regexp.MustCompile(WinRMTransport)
+	rxKrb5Value        = regexp.MustCompile(Krb5Value)
 )
 
 type Validator struct {
@@ -92,10 +104,16 @@ func (v *Validator) lazyinit() {
 		v.validate.RegisterValidation("project_kind", isProjectKind)
 		v.validate.RegisterValidation("terraform_jobtype", isTerraformJobType)
 		v.validate.RegisterValidation("resource_type", isResourceType)
+		v.validate.RegisterValidation("notification_type", isNotificationType)
+		v.validate.RegisterValidation("inventory_source", isInventorySource)
+		v.validate.RegisterValidation("network_connection", isNetworkConnection)
+		v.validate.RegisterValidation("network_os", isNetworkOS)
+		v.validate.RegisterValidation("winrm_transport", isWinRMTransport)
+		v.validate.RegisterValidation("krb5", isKrb5Value)
 
 		//translations
 		v.validate.RegisterTranslation("credential_kind", trans, func(ut ut.Translator) error {
-			return ut.Add("credential_kind", "{0} must have either one of windows,ssh,net,scm,aws,rax,vmware,satellite6,cloudforms,gce,azure,openstack", true)
+			return ut.Add("credential_kind", "{0} must have either one of windows,ssh,net,scm,aws,rax,vmware,satellite6,cloudforms,gce,azure,openstack,vault", true)
 		}, func(ut ut.Translator, fe validator.FieldError) string {
 			t, _ := ut.T("credential_kind", fe.Field())
 
@@ -166,10 +184,59 @@ func (v *Validator) lazyinit() {
 			return t
 		})
 
+		v.validate.RegisterTranslation("notification_type", trans, func(ut ut.Translator) error {
+			return ut.Add("notification_type", "{0} must have either one of email,webhook,slack", true)
+		}, func(ut ut.Translator, fe validator.FieldError) string {
+			t, _ := ut.T("notification_type", fe.Field())
+
+			return t
+		})
+
+		v.validate.RegisterTranslation("inventory_source", trans, func(ut ut.Translator) error {
+			return ut.Add("inventory_source", "{0} must have either one of ec2,gce,azure_rm,openstack,vmware,rax,satellite6,cloudforms,custom", true)
+		}, func(ut ut.Translator, fe validator.FieldError) string {
+			t, _ := ut.T("inventory_source", fe.Field())
+
+			return t
+		})
+
+		v.validate.RegisterTranslation("network_connection", trans, func(ut ut.Translator) error {
+			return ut.Add("network_connection", "{0} must have either one of network_cli,netconf,httpapi", true)
+		}, func(ut ut.Translator, fe validator.FieldError) string {
+			t, _ := ut.T("network_connection", fe.Field())
+
+			return t
+		})
+
+		v.validate.RegisterTranslation("network_os", trans, func(ut ut.Translator) error {
+			return ut.Add("network_os", "{0} must be a valid ansible_network_os like ios or cisco.ios.ios", true)
+		}, func(ut ut.Translator, fe validator.FieldError) string {
+			t, _ := ut.T("network_os", fe.Field())
+
+			return t
+		})
+
+		v.validate.RegisterTranslation("winrm_transport", trans, func(ut ut.Translator) error {
+			return ut.Add("winrm_transport", "{0} must have either one of kerberos,ntlm", true)
+		}, func(ut ut.Translator, fe validator.FieldError) string {
+			t, _ := ut.T("winrm_transport", fe.Field())
+
+			return t
+		})
+
+		v.validate.RegisterTranslation("krb5", trans, func(ut ut.Translator) error {
+			return ut.Add("krb5", "{0} must be a valid realm or host name", true)
+		}, func(ut ut.Translator, fe validator.FieldError) string {
+			t, _ := ut.T("krb5", fe.Field())
+
+			return t
+		})
+
 		//struct level validations
 		v.validate.RegisterStructValidation(credentialStructLevelValidation, common.Credential{})
 		v.validate.RegisterStructValidation(projectStructLevelValidation, common.Project{})
 		v.validate.RegisterStructValidation(roleObjStructLevelValidation, common.RoleObj{})
+		v.validate.RegisterStructValidation(notificationTemplateStructLevelValidation, common.NotificationTemplate{})
 	})
 }
 
@@ -229,6 +296,31 @@ func isResourceType(fl validator.FieldLevel) bool {
 	return rxResourceType.MatchString(fl.Field().String())
 }
 
+func isNotificationType(fl validator.FieldLevel) bool {
+	return rxNotificationType.MatchString(fl.Field().String())
+}
+
+func isInventorySource(fl validator.FieldLevel) bool {
+	return rxInventorySource.MatchString(fl.Field().String())
+}
+
+func isNetworkConnection(fl validator.FieldLevel) bool {
+	return rxNetworkConn.MatchString(fl.Field().String())
+}
+
+func isNetworkOS(fl validator.FieldLevel) bool {
+	return rxNetworkOS.MatchString(fl.Field().String())
+}
+
+func isWinRMTransport(fl validator.FieldLevel) bool {
+	return rxWinRMTransport.MatchString(fl.Field().String())
+}
+
+// isKrb5Value checks that a value can be written to krb5.conf as is
+func isKrb5Value(fl validator.FieldLevel) bool {
+	return rxKrb5Value.MatchString(fl.Field().String())
+}
+
 // fail all
 func naProperty(fl validator.FieldLevel) bool {
 	if fl.Field().String() == "" {
@@ -271,8 +363,29 @@ func credentialStructLevelValidation(sl validator.StructLevel) {
 
 	credential := sl.Current().Interface().(common.Credential)
 
-	if credential.Kind == common.CredentialKindNET && len(credential.Username) == 0 {
-		sl.ReportError(credential.Username, "Username", "Username", "required", "")
+	if credential.Kind == common.CredentialKindNET {
+		if len(credential.Username) == 0 {
+			sl.ReportError(credential.Username, "Username", "Username", "required", "")
+		}
+
+		if len(credential.Password) == 0 && len(credential.SSHKeyData) == 0 {
+			sl.ReportError(credential.Password, "Password", "Password", "required", "")
+		}
+
+		// enable mode needs the enable password
+		if credential.Authorize && len(credential.AuthorizePassword) == 0 {
+			sl.ReportError(credential.AuthorizePassword, "AuthorizePassword", "Authorize Password", "required", "")
+		}
+	}
+
+	// kerberos needs the domain to build the principal
+	if credential.Kind == common.CredentialKindWIN && credential.WinRMTransport == "kerberos" &&
+		len(credential.Domain) == 0 {
+		sl.ReportError(credential.Domain, "Domain", "Domain", "required", "")
+	}
+
+	if credential.Kind == common.CredentialKindVAULT && len(credential.VaultPassword) == 0 {
+		sl.ReportError(credential.VaultPassword, "VaultPassword", "Vault Password", "required", "")
 	}
 
 	if credential.Kind == common.CredentialKindAWS {
@@ -309,6 +422,25 @@ func credentialStructLevelValidation(sl validator.StructLevel) {
 		}
 	}
 
+	if credential.Kind == common.CredentialKindVMWARE || credential.Kind == common.CredentialKindOPENSTACK ||
+		credential.Kind == common.CredentialKindSATELLITE6 || credential.Kind == common.CredentialKindCLOUDFORMS {
+		if len(credential.Host) == 0 {
+			sl.ReportError(credential.Host, "Host", "Host", "required", "")
+		}
+
+		if len(credential.Username) == 0 {
+			sl.ReportError(credential.Username, "Username", "Username", "required", "")
+		}
+
+		if len(credential.Password) == 0 {
+			sl.ReportError(credential.Password, "Password", "Password", "required", "")
+		}
+	}
+
+	if credential.Kind == common.CredentialKindOPENSTACK && len(credential.Project) == 0 {
+		sl.ReportError(credential.Project, "Project", "Project", "required", "")
+	}
+
 	if credential.Kind == common.CredentialKindAZURE {
 		if len(credential.Username) > 0 {
 			if len(credential.Username) == 0 {
@@ -370,12 +502,18 @@ func roleObjStructLevelValidation(sl validator.StructLevel) {
 				sl.ReportError(roleobj.Role, "Role", "Role", "Role must be either one of admin,member", "")
 			}
 		}
-	case "project", "inventory":
+	case "project":
 		{
 			if roleobj.Role != "admin" && roleobj.Role != "update" && roleobj.Role != "use" {
 				sl.ReportError(roleobj.Role, "Role", "Role", "Role must be either one of admin,update,use", "")
 			}
 		}
+	case "inventory":
+		{
+			if roleobj.Role != "admin" && roleobj.Role != "update" && roleobj.Role != "use" && roleobj.Role != "adhoc" {
+				sl.ReportError(roleobj.Role, "Role", "Role", "Role must be either one of admin,update,use,adhoc", "")
+			}
+		}
 	case "job_template", "terraform_job_template":
 		{
 			if roleobj.Role != "admin" && roleobj.Role != "execute" {
@@ -384,3 +522,35 @@ func roleObjStructLevelValidation(sl validator.StructLevel) {
 		}
 	}
 }
+
+func notificationTemplateStructLevelValidation(sl validator.StructLevel) {
+	template := sl.Current().Interface().(common.NotificationTemplate)
+	config := template.NotificationConfiguration
+
+	switch template.NotificationsType {
+	case common.NotificationTypeEmail:
+		{
+			if len(config.Host) == 0 {
+				sl.ReportError(config.Host, "Host", "Host", "required", "")
+			}
+
+			if len(config.Sender) == 0 {
+				sl.ReportError(config.Sender, "Sender", "Sender", "required", "")
+			}
+
+			if len(config.Recipients) == 0 {
+				sl.ReportError(config.Recipients, "Recipients", "Recipients", "required", "")
+			}
+
+			if config.UseTLS && config.UseSSL {
+				sl.ReportError(config.UseSSL, "UseSSL", "UseSSL", "use_tls and use_ssl are mutually exclusive", "")
+			}
+		}
+	case common.NotificationTypeWebhook, common.NotificationTypeSlack:
+		{
+			if len(config.URL) == 0 {
+				sl.ReportError(config.URL, "URL", "URL", "required", "")
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Workers counts the running jobs of the worker pool of a queue
+type Workers struct {
+	queue string
+	size  int
+	busy  int32
+}
+
+// Capacity describes the worker pool of a queue
+type Capacity struct {
+	Queue   string `json:"queue"`
+	Workers int    `json:"workers"`
+	Busy    int    `json:"busy"`
+	Idle    int    `json:"idle"`
+}
+
+var (
+	poolsMu sync.Mutex
+	pools   = map[string]*Workers{}
+)
+
+// NewWorkers registers the worker pool of the queue, the pool is
+// reported by WorkerCapacity
+func NewWorkers(queue string, size int) *Workers {
+	w := &Workers{queue: queue, size: size}
+
+	poolsMu.Lock()
+	pools[queue] = w
+	poolsMu.Unlock()
+
+	return w
+}
+
+// Size returns the number of workers of the pool
+func (w *Workers) Size() int {
+	return w.size
+}
+
+// Start marks a worker as busy
+func (w *Workers) Start() {
+	atomic.AddInt32(&w.busy, 1)
+}
+
+// Done marks a worker as idle
+func (w *Workers) Done() {
+	atomic.AddInt32(&w.busy, -1)
+}
+
+// Capacity returns the number of busy and idle workers of the pool
+func (w *Workers) Capacity() Capacity {
+	busy := int(atomic.LoadInt32(&w.busy))
+	return Capacity{
+		Queue:   w.queue,
+		Workers: w.size,
+		Busy:    busy,
+		Idle:    w.size - busy,
+	}
+}
+
+// WorkerCapacity returns the capacity of the worker pools
+// registered in this process ordered by queue
+func WorkerCapacity() []Capacity {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+
+	capacity := []Capacity{}
+	for _, w := range pools {
+		capacity = append(capacity, w.Capacity())
+	}
+	sort.Slice(capacity, func(i, j int) bool {
+		return capacity[i].Queue < capacity[j].Queue
+	})
+	return capacity
+}
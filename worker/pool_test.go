@@ -0,0 +1,22 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerCapacity(t *testing.T) {
+	assert := assert.New(t)
+	terraform := NewWorkers("terraform_test", 2)
+	ansible := NewWorkers("ansible_test", 4)
+
+	ansible.Start()
+	ansible.Start()
+	ansible.Done()
+	terraform.Start()
+
+	capacity := WorkerCapacity()
+	assert.Contains(capacity, Capacity{Queue: "ansible_test", Workers: 4, Busy: 1, Idle: 3})
+	assert.Contains(capacity, Capacity{Queue: "terraform_test", Workers: 2, Busy: 1, Idle: 1})
+}
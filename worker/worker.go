@@ -0,0 +1,133 @@
+// Package worker registers the tensord processes that run jobs in the
+// database and keeps their heartbeat and capacity up to date.
+package worker
+
+import (
+	"os"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pearsonappeng/tensor/db"
+	"github.com/pearsonappeng/tensor/models/common"
+	"github.com/pearsonappeng/tensor/util"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// HeartbeatInterval is the interval between the heartbeats of a worker
+	HeartbeatInterval = 30 * time.Second
+	// StoppedAfter is the age of the last heartbeat of a stopped worker
+	StoppedAfter = 3 * HeartbeatInterval
+)
+
+// ID identifies the worker of this process
+var ID = bson.NewObjectId()
+
+// registered is the worker of this process, it is registered again
+// when it was removed as a stopped worker
+var registered *common.Worker
+
+// Register adds the worker of this process with the worker pools of the
+// queues it consumes
+func Register(queues []common.WorkerQueue) error {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+
+	w := common.Worker{
+		ID:        ID,
+		Hostname:  hostname,
+		PID:       os.Getpid(),
+		Version:   util.Version,
+		Queues:    queues,
+		Started:   time.Now(),
+		Heartbeat: time.Now(),
+	}
+	for _, q := range queues {
+		w.Capacity += q.Workers
+	}
+
+	if err := db.Workers().Insert(w); err != nil {
+		return err
+	}
+	registered = &w
+
+	logrus.WithFields(logrus.Fields{
+		"Worker ID": ID.Hex(),
+		"Hostname":  hostname,
+		"Capacity":  w.Capacity,
+	}).Infoln("Worker registered")
+	return nil
+}
+
+// Run refreshes the heartbeat and the busy workers of this process
+func Run() {
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		heartbeat()
+	}
+}
+
+// Active returns the workers with a recent heartbeat
+func Active() ([]common.Worker, error) {
+	workers := []common.Worker{}
+	since := time.Now().Add(-2 * HeartbeatInterval)
+	err := db.Workers().Find(bson.M{"heartbeat": bson.M{"$gte": since}}).Sort("hostname").All(&workers)
+	return workers, err
+}
+
+// Stopped returns the workers of other processes that stopped heartbeating
+func Stopped() ([]common.Worker, error) {
+	workers := []common.Worker{}
+	before := time.Now().Add(-StoppedAfter)
+	err := db.Workers().Find(bson.M{"_id": bson.M{"$ne": ID}, "heartbeat": bson.M{"$lt": before}}).All(&workers)
+	return workers, err
+}
+
+// Remove removes a stopped worker
+func Remove(id bson.ObjectId) error {
+	err := db.Workers().RemoveId(id)
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func heartbeat() {
+	queues := []common.WorkerQueue{}
+	busy := 0
+	for _, c := range WorkerCapacity() {
+		queues = append(queues, common.WorkerQueue{Queue: c.Queue, Workers: c.Workers, Busy: c.Busy})
+		busy += c.Busy
+	}
+
+	d := bson.M{
+		"$set": bson.M{
+			"heartbeat": time.Now(),
+			"busy":      busy,
+			"queues":    queues,
+		},
+	}
+	err := db.Workers().UpdateId(ID, d)
+	if err == mgo.ErrNotFound && registered != nil {
+		logrus.WithFields(logrus.Fields{
+			"Worker ID": ID.Hex(),
+		}).Warningln("Worker was removed as stopped, registering it again")
+
+		w := *registered
+		w.Heartbeat = time.Now()
+		w.Busy = busy
+		w.Queues = queues
+		err = db.Workers().Insert(w)
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"Worker ID": ID.Hex(),
+			"Error":     err.Error(),
+		}).Errorln("Failed to update worker heartbeat")
+	}
+}